@@ -0,0 +1,278 @@
+package apis
+
+import (
+	"net/http"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) AdminGetCollections(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	enabled, err := s.boolFromContextQuery(c, "enabled")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	collections, count, err := s.nls.AdminGetCollections(ctx, enabled, page, limit)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionRespArr(collections), Count: &count})
+}
+
+func (s *Server) AdminCreateCollection(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.AdminCollectionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	collection, err := s.nls.AdminCreateCollection(ctx, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	s.setAuditTargetID(c, collection.ID)
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionResp(collection)})
+}
+
+func (s *Server) AdminUpdateCollection(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminCollectionReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	collection, err := s.nls.AdminUpdateCollection(ctx, id, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionResp(collection)})
+}
+
+func (s *Server) adminSetCollectionEnabled(c *gin.Context, enabled bool) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	collection, err := s.nls.AdminSetCollectionEnabled(ctx, id, enabled)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionResp(collection)})
+}
+
+func (s *Server) AdminEnableCollection(c *gin.Context) {
+	s.adminSetCollectionEnabled(c, true)
+}
+
+func (s *Server) AdminDisableCollection(c *gin.Context) {
+	s.adminSetCollectionEnabled(c, false)
+}
+
+func (s *Server) AdminGetCurrencies(c *gin.Context) {
+	ctx := s.requestContext(c)
+	currencies, err := s.nls.AdminGetCurrencies(ctx, models.Chain(s.stringFromContextQuery(c, "network")))
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCurrencyRespArr(currencies)})
+}
+
+func (s *Server) AdminCreateCurrency(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.AdminCurrencyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	currency, err := s.nls.AdminCreateCurrency(ctx, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	s.setAuditTargetID(c, currency.ID)
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCurrencyResp(currency)})
+}
+
+func (s *Server) AdminUpdateCurrency(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminCurrencyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	currency, err := s.nls.AdminUpdateCurrency(ctx, id, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCurrencyResp(currency)})
+}
+
+func (s *Server) adminSetCurrencyEnabled(c *gin.Context, enabled bool) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	currency, err := s.nls.AdminSetCurrencyEnabled(ctx, id, enabled)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCurrencyResp(currency)})
+}
+
+func (s *Server) AdminEnableCurrency(c *gin.Context) {
+	s.adminSetCurrencyEnabled(c, true)
+}
+
+func (s *Server) AdminDisableCurrency(c *gin.Context) {
+	s.adminSetCurrencyEnabled(c, false)
+}
+
+func (s *Server) AdminUpdateAssetInfo(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	asset, err := s.nls.AdminUpdateAssetInfo(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewAdminAssetResp(asset)})
+}
+
+func (s *Server) AdminUpdateAssetTestContract(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminAssetTestContractReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	asset, err := s.nls.AdminUpdateAssetTestContract(ctx, id, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewAdminAssetResp(asset)})
+}
+
+func (s *Server) AdminGetInstructions(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	instructions, count, err := s.nls.AdminGetInstructions(
+		ctx,
+		s.stringFromContextQuery(c, "status"),
+		s.stringFromContextQuery(c, "instruction"),
+		s.stringFromContextQuery(c, "transaction_hash"),
+		page,
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewInstructionRespArr(instructions), Count: &count})
+}
+
+func (s *Server) AdminGetInstruction(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	instruction, err := s.nls.AdminGetInstruction(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewInstructionResp(instruction)})
+}
+
+func (s *Server) AdminReprocessInstruction(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	instruction, err := s.nls.AdminReprocessInstruction(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewInstructionResp(instruction)})
+}
+
+func (s *Server) AdminGetAuditLogs(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	targetID, err := s.uintFromContextQuery(c, "target_id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	fromTime, err := s.timeFromContextQuery(c, "from")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	toTime, err := s.timeFromContextQuery(c, "to")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	ms, count, err := s.nls.AdminGetAuditLogs(
+		ctx,
+		s.stringFromContextQuery(c, "actor"),
+		models.AuditTargetType(s.stringFromContextQuery(c, "target_type")),
+		targetID,
+		s.stringFromContextQuery(c, "route"),
+		fromTime,
+		toTime,
+		page,
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewAuditLogRespArr(ms), Count: &count})
+}
+
+func (s *Server) AdminGetSaleStreams(c *gin.Context) {
+	ctx := s.requestContext(c)
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewSaleStreamHealthRespArr(s.nls.GetSaleStreamHealths(ctx))})
+}
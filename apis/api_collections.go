@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
 	"github.com/czConstant/constant-nftylend-api/serializers"
 	"github.com/gin-gonic/gin"
 )
@@ -15,10 +16,121 @@ func (s *Server) CreateCollectionSubmitted(c *gin.Context) {
 		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
-	err := s.nls.CreateCollectionSubmitted(ctx, &req)
+	m, err := s.nls.CreateCollectionSubmitted(ctx, s.clientIPFromContext(c), &req)
 	if err != nil {
 		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
-	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedStatusResp(m)})
+}
+
+func (s *Server) GetCollectionSubmittedStatus(c *gin.Context) {
+	ctx := s.requestContext(c)
+	m, err := s.nls.GetCollectionSubmittedByToken(ctx, s.stringFromContextParam(c, "lookup_token"))
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedStatusResp(m)})
+}
+
+func (s *Server) AdminGetCollectionSubmitteds(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	ms, count, err := s.nls.AdminGetCollectionSubmitteds(ctx, models.CollectionSubmittedStatus(s.stringFromContextQuery(c, "status")), page, limit)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedRespArr(ms), Count: &count})
+}
+
+func (s *Server) AdminGetCollectionSubmitted(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	m, err := s.nls.AdminGetCollectionSubmitted(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedResp(m)})
+}
+
+func (s *Server) AdminCommentCollectionSubmitted(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminCommentReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.AdminCommentCollectionSubmitted(ctx, id, s.actorFromContext(c), req.Comment)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedResp(m)})
+}
+
+func (s *Server) AdminReviewCollectionSubmitted(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	m, err := s.nls.AdminReviewCollectionSubmitted(ctx, id, s.actorFromContext(c))
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedResp(m)})
+}
+
+func (s *Server) AdminApproveCollectionSubmitted(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminReviewNoteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.AdminApproveCollectionSubmitted(ctx, id, s.actorFromContext(c), req.Note)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedResp(m)})
+}
+
+func (s *Server) AdminRejectCollectionSubmitted(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminReviewNoteReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.AdminRejectCollectionSubmitted(ctx, id, s.actorFromContext(c), req.Note)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionSubmittedResp(m)})
 }
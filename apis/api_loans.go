@@ -20,24 +20,63 @@ func (s *Server) GetListingLoans(c *gin.Context) {
 	maxInterestRate, _ := s.float64FromContextQuery(c, "max_interest_rate")
 	excludeIds, _ := s.uintArrayFromContextQuery(c, "exclude_ids")
 	var sort []string
+	sortColumn, sortDesc := "id", true
 	switch s.stringFromContextQuery(c, "sort") {
 	case "created_at":
 		{
 			sort = []string{"created_at asc"}
+			sortColumn, sortDesc = "created_at", false
 		}
 	case "-created_at":
 		{
 			sort = []string{"created_at desc"}
+			sortColumn, sortDesc = "created_at", true
 		}
 	case "principal_amount":
 		{
 			sort = []string{"principal_amount asc"}
+			sortColumn, sortDesc = "principal_amount", false
 		}
 	case "-principal_amount":
 		{
 			sort = []string{"principal_amount desc"}
+			sortColumn, sortDesc = "principal_amount", true
+		}
+	case "rarity_score":
+		{
+			sort = []string{"asset_rarity_score asc", "id asc"}
+			sortColumn, sortDesc = "asset_rarity_score", false
+		}
+	case "-rarity_score":
+		{
+			sort = []string{"asset_rarity_score desc", "id desc"}
+			sortColumn, sortDesc = "asset_rarity_score", true
 		}
 	}
+	if cursor, limit, withCount, ok := s.cursorPagingFromContext(c); ok {
+		loans, cursorPage, err := s.nls.GetListingLoans4Cursor(
+			ctx,
+			collectionId,
+			minPrice,
+			maxPrice,
+			minDuration,
+			maxDuration,
+			minInterestRate,
+			maxInterestRate,
+			excludeIds,
+			sortColumn,
+			sortDesc,
+			cursor,
+			limit,
+			withCount,
+		)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		ctxCursorJSON(c, serializers.NewLoanRespArr(loans), cursorPage)
+		return
+	}
 	loans, count, err := s.nls.GetListingLoans(
 		ctx,
 		collectionId,
@@ -63,6 +102,24 @@ func (s *Server) GetLoans(c *gin.Context) {
 	ctx := s.requestContext(c)
 	page, limit := s.pagingFromContext(c)
 	assetId, _ := s.uintFromContextQuery(c, "asset_id")
+	if cursor, limit, withCount, ok := s.cursorPagingFromContext(c); ok {
+		loans, cursorPage, err := s.nls.GetLoans4Cursor(
+			ctx,
+			s.stringFromContextQuery(c, "owner"),
+			s.stringFromContextQuery(c, "lender"),
+			assetId,
+			s.stringArrayFromContextQuery(c, "status"),
+			cursor,
+			limit,
+			withCount,
+		)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		ctxCursorJSON(c, serializers.NewLoanRespArr(loans), cursorPage)
+		return
+	}
 	loans, count, err := s.nls.GetLoans(
 		ctx,
 		s.stringFromContextQuery(c, "owner"),
@@ -81,6 +138,23 @@ func (s *Server) GetLoans(c *gin.Context) {
 
 func (s *Server) GetLoanOffers(c *gin.Context) {
 	ctx := s.requestContext(c)
+	if cursor, limit, withCount, ok := s.cursorPagingFromContext(c); ok {
+		offers, cursorPage, err := s.nls.GetLoanOffers4Cursor(
+			ctx,
+			s.stringFromContextQuery(c, "borrower"),
+			s.stringFromContextQuery(c, "lender"),
+			s.stringArrayFromContextQuery(c, "status"),
+			cursor,
+			limit,
+			withCount,
+		)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		ctxCursorJSON(c, serializers.NewLoanOfferRespArr(offers), cursorPage)
+		return
+	}
 	page, limit := s.pagingFromContext(c)
 	offers, count, err := s.nls.GetLoanOffers(
 		ctx,
@@ -105,6 +179,21 @@ func (s *Server) GetLoanTransactions(c *gin.Context) {
 		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
+	if cursor, limit, withCount, ok := s.cursorPagingFromContext(c); ok {
+		tnxs, cursorPage, err := s.nls.GetLoanTransactions4Cursor(
+			ctx,
+			assetId,
+			cursor,
+			limit,
+			withCount,
+		)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		ctxCursorJSON(c, serializers.NewLoanTransactionRespArr(tnxs), cursorPage)
+		return
+	}
 	tnxs, count, err := s.nls.GetLoanTransactions(
 		ctx,
 		assetId,
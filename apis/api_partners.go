@@ -0,0 +1,114 @@
+package apis
+
+import (
+	"net/http"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) GetPartnerMeUsage(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	ms, count, err := s.nls.GetPartnerUsages(
+		ctx,
+		s.partnerIDFromContext(c),
+		s.stringFromContextQuery(c, "from"),
+		s.stringFromContextQuery(c, "to"),
+		page,
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewPartnerUsageRespArr(ms), Count: &count})
+}
+
+func (s *Server) AdminGetPartners(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	ms, count, err := s.nls.AdminGetPartners(ctx, page, limit)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewPartnerRespArr(ms), Count: &count})
+}
+
+func (s *Server) AdminCreatePartner(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.AdminPartnerReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, key, err := s.nls.AdminCreatePartner(ctx, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	s.setAuditTargetID(c, m.ID)
+	resp := serializers.NewPartnerResp(m)
+	resp.Key = key
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: resp})
+}
+
+func (s *Server) AdminUpdatePartner(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminPartnerReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.AdminUpdatePartner(ctx, id, &req)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewPartnerResp(m)})
+}
+
+func (s *Server) AdminRevokePartner(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	m, err := s.nls.AdminRevokePartner(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewPartnerResp(m)})
+}
+
+func (s *Server) AdminGetPartnerUsages(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	ms, count, err := s.nls.GetPartnerUsages(
+		ctx,
+		id,
+		s.stringFromContextQuery(c, "from"),
+		s.stringFromContextQuery(c, "to"),
+		page,
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewPartnerUsageRespArr(ms), Count: &count})
+}
@@ -0,0 +1,125 @@
+package apis
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) AdminGetApiKeys(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	ms, count, err := s.nls.AdminGetApiKeys(ctx, page, limit)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewApiKeyRespArr(ms), Count: &count})
+}
+
+func (s *Server) AdminCreateApiKey(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.AdminApiKeyReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, key, err := s.nls.AdminCreateApiKey(ctx, req.Name, req.Role, req.ExpiredAt)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	s.setAuditTargetID(c, m.ID)
+	resp := serializers.NewApiKeyResp(m)
+	resp.Key = key
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: resp})
+}
+
+func (s *Server) AdminRotateApiKey(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	var req serializers.AdminApiKeyRotateReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, key, err := s.nls.AdminRotateApiKey(ctx, id, time.Duration(req.GraceSeconds)*time.Second)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	resp := serializers.NewApiKeyResp(m)
+	resp.Key = key
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: resp})
+}
+
+func (s *Server) AdminRevokeApiKey(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	m, err := s.nls.AdminRevokeApiKey(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewApiKeyResp(m)})
+}
+
+func (s *Server) AdminGetUserRoles(c *gin.Context) {
+	ctx := s.requestContext(c)
+	page, limit := s.pagingFromContext(c)
+	ms, count, err := s.nls.AdminGetUserRoles(
+		ctx,
+		s.stringFromContextQuery(c, "address"),
+		models.Role(s.stringFromContextQuery(c, "role")),
+		page,
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewUserRoleRespArr(ms), Count: &count})
+}
+
+func (s *Server) AdminCreateUserRole(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.AdminUserRoleReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.AdminCreateUserRole(ctx, req.Network, req.Address, req.Role)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	s.setAuditTargetID(c, m.ID)
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewUserRoleResp(m)})
+}
+
+func (s *Server) AdminDeleteUserRole(c *gin.Context) {
+	ctx := s.requestContext(c)
+	id, err := s.uintFromContextParam(c, "id")
+	if err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrInvalidParams)})
+		return
+	}
+	m, err := s.nls.AdminDeleteUserRole(ctx, id)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewUserRoleResp(m)})
+}
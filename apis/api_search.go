@@ -0,0 +1,56 @@
+package apis
+
+import (
+	"net/http"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/gin-gonic/gin"
+)
+
+func (s *Server) Search(c *gin.Context) {
+	ctx := s.requestContext(c)
+	_, limit := s.pagingFromContext(c)
+	if limit > 50 {
+		limit = 50
+	}
+	rs, err := s.nls.Search(
+		ctx,
+		s.stringFromContextQuery(c, "q"),
+		s.stringArrayFromContextQuery(c, "types"),
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewSearchResultRespArr(rs)})
+}
+
+func (s *Server) SearchAutocomplete(c *gin.Context) {
+	ctx := s.requestContext(c)
+	_, limit := s.pagingFromContext(c)
+	if limit > 10 {
+		limit = 10
+	}
+	rs, err := s.nls.SearchAutocomplete(
+		ctx,
+		s.stringFromContextQuery(c, "q"),
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewSearchResultRespArr(rs)})
+}
+
+func (s *Server) JobSearchReindex(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobSearchReindex(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
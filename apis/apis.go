@@ -1,9 +1,15 @@
 package apis
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
 	"github.com/czConstant/constant-nftylend-api/serializers"
 	"github.com/gin-gonic/gin"
 )
@@ -63,6 +69,38 @@ func (s *Server) GetAssetDetail(c *gin.Context) {
 	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewAssetResp(m)})
 }
 
+// GetAssetMedia serves the asset image in a preset size, the medium by
+// default. The responses are cached by the clients and proxies for a day
+func (s *Server) GetAssetMedia(c *gin.Context) {
+	ctx := s.requestContext(c)
+	assetID, err := s.uintFromContextParam(c, "asset_id")
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	size := models.MediaSize(s.stringFromContextQuery(c, "size"))
+	if size == "" {
+		size = models.MediaSizeMedium
+	}
+	blob, err := s.nls.GetAssetMedia(ctx, assetID, size)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	h := sha256.Sum256(blob.Data)
+	contentType := strings.ToLower(strings.TrimSpace(strings.Split(blob.ContentType, ";")[0]))
+	if !models.MediaContentTypes[contentType] {
+		contentType = "application/octet-stream"
+		c.Header("Content-Disposition", "attachment")
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Security-Policy", "default-src 'none'; sandbox")
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("ETag", fmt.Sprintf(`"%s"`, hex.EncodeToString(h[:16])))
+	http.ServeContent(c.Writer, c.Request, "", blob.ModTime, bytes.NewReader(blob.Data))
+}
+
 func (s *Server) GetCollectionAssetVerified(c *gin.Context) {
 	ctx := s.requestContext(c)
 	m, err := s.nls.GetCollectionVerified(ctx, s.stringFromContextQuery(c, "mint"))
@@ -76,25 +114,85 @@ func (s *Server) GetCollectionAssetVerified(c *gin.Context) {
 func (s *Server) GetCollections(c *gin.Context) {
 	ctx := s.requestContext(c)
 	page, limit := s.pagingFromContext(c)
-	collections, count, err := s.nls.GetCollections(ctx, page, limit)
+	verified, err := s.boolFromContextQuery(c, "verified")
 	if err != nil {
 		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
-	rptCollections, err := s.nls.GetRPTListingCollection(ctx)
+	hasActiveListingsQuery, err := s.boolFromContextQuery(c, "has_active_listings")
 	if err != nil {
 		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
-	mapRPTCollection := map[uint]uint{}
-	for _, rptCollection := range rptCollections {
-		mapRPTCollection[rptCollection.CollectionID] = rptCollection.Total
+	hasActiveListings := hasActiveListingsQuery != nil && *hasActiveListingsQuery
+	currencyID, err := s.uintFromContextQuery(c, "currency_id")
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
 	}
-	resps := serializers.NewCollectionRespArr(collections)
-	for _, resp := range resps {
-		resp.ListingTotal = mapRPTCollection[resp.ID]
+	// prices only compare within a currency, the price sorts group the
+	// collections by their price currency and leave the unpriced ones last
+	var sort []string
+	switch s.stringFromContextQuery(c, "sort") {
+	case "listing_count":
+		{
+			sort = []string{"listing_count asc", "id desc"}
+		}
+	case "-listing_count":
+		{
+			sort = []string{"listing_count desc", "id desc"}
+		}
+	case "weekly_volume":
+		{
+			sort = []string{"price_currency_id desc", "weekly_volume asc", "id desc"}
+		}
+	case "-weekly_volume":
+		{
+			sort = []string{"price_currency_id desc", "weekly_volume desc", "id desc"}
+		}
+	case "avg_interest_rate":
+		{
+			sort = []string{"avg_interest_rate asc", "id desc"}
+		}
+	case "-avg_interest_rate":
+		{
+			sort = []string{"avg_interest_rate desc", "id desc"}
+		}
+	case "floor_price":
+		{
+			// collections without listings have no floor
+			sort = []string{"price_currency_id desc", "floor_price asc", "id desc"}
+			hasActiveListings = true
+		}
+	case "-floor_price":
+		{
+			sort = []string{"price_currency_id desc", "floor_price desc", "id desc"}
+			hasActiveListings = true
+		}
+	case "default_rate":
+		{
+			sort = []string{"default_rate asc", "id desc"}
+		}
+	case "-default_rate":
+		{
+			sort = []string{"default_rate desc", "id desc"}
+		}
 	}
-	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: resps, Count: &count})
+	collections, count, err := s.nls.GetCollections(
+		ctx,
+		models.Chain(s.stringFromContextQuery(c, "network")),
+		verified,
+		hasActiveListings,
+		currencyID,
+		sort,
+		page,
+		limit,
+	)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionRespArr(collections), Count: &count})
 }
 
 func (s *Server) GetCollectionDetail(c *gin.Context) {
@@ -108,18 +206,40 @@ func (s *Server) GetCollectionDetail(c *gin.Context) {
 		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrBadRequest)})
 		return
 	}
-	rpt, err := s.nls.GetRPTCollectionLoan(ctx, m.ID)
+	stat, err := s.nls.GetCollectionLatestStat(ctx, m.ID)
 	if err != nil {
 		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
 	resp := serializers.NewCollectionResp(m)
-	resp.TotalVolume = rpt.TotalVolume
-	resp.Avg24hAmount = rpt.Avg24hAmount
-	resp.TotalListed = rpt.TotalListed
+	if stat != nil {
+		resp.TotalVolume = stat.TotalVolume
+		resp.Avg24hAmount = stat.AvgPrincipal
+		resp.TotalListed = stat.TotalFundedCount
+		resp.Stats = serializers.NewCollectionStatResp(stat)
+	}
 	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: resp})
 }
 
+func (s *Server) GetCollectionStats(c *gin.Context) {
+	ctx := s.requestContext(c)
+	interval := models.CollectionStatInterval(s.stringFromContextQuery(c, "interval"))
+	if interval == "" {
+		interval = models.CollectionStatIntervalDay
+	}
+	limit, err := s.uintFromContextQuery(c, "limit")
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ms, err := s.nls.GetCollectionStats(ctx, s.stringFromContextParam(c, "seo_url"), interval, int(limit))
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewCollectionStatRespArr(ms)})
+}
+
 func (s *Server) GetCurrencies(c *gin.Context) {
 	ctx := s.requestContext(c)
 	currencies, err := s.nls.GetCurrencies(ctx)
@@ -138,6 +258,21 @@ func (s *Server) GetAseetTransactions(c *gin.Context) {
 		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
 		return
 	}
+	if cursor, limit, withCount, ok := s.cursorPagingFromContext(c); ok {
+		tnxs, cursorPage, err := s.nls.GetAseetTransactions4Cursor(
+			ctx,
+			assetId,
+			cursor,
+			limit,
+			withCount,
+		)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		ctxCursorJSON(c, serializers.NewAssetTransactionRespArr(tnxs), cursorPage)
+		return
+	}
 	tnxs, count, err := s.nls.GetAseetTransactions(
 		ctx,
 		assetId,
@@ -150,3 +285,83 @@ func (s *Server) GetAseetTransactions(c *gin.Context) {
 	}
 	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewAssetTransactionRespArr(tnxs), Count: &count})
 }
+
+func (s *Server) JobSeedAssetCrawls(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobSeedAssetCrawls(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
+
+func (s *Server) JobRefreshAssetMetadata(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobRefreshAssetMetadata(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
+
+func (s *Server) JobComputeCollectionRarity(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobComputeCollectionRarity(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
+
+func (s *Server) JobSnapshotCollectionStats(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobSnapshotCollectionStats(ctx, models.CollectionStatInterval(s.stringFromContextQuery(c, "interval")))
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
+
+func (s *Server) JobRefreshCollectionAggregates(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobRefreshCollectionAggregates(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
+
+func (s *Server) JobDedupeAssetTransactions(c *gin.Context) {
+	ctx := s.requestContext(c)
+	merged, err := s.nls.JobDedupeAssetTransactions(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: merged})
+}
+
+func (s *Server) JobVerifyApprovedCollections(c *gin.Context) {
+	ctx := s.requestContext(c)
+	verified, err := s.nls.JobVerifyApprovedCollections(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: verified})
+}
+
+func (s *Server) JobCrawlAssetTransactions(c *gin.Context) {
+	ctx := s.requestContext(c)
+	err := s.nls.JobCrawlAssetTransactions(ctx)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
+}
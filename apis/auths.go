@@ -3,10 +3,91 @@ package apis
 import (
 	"net/http"
 
+	"github.com/czConstant/constant-nftylend-api/errs"
 	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/czConstant/constant-nftylend-api/services"
 	"github.com/gin-gonic/gin"
 )
 
-func (s *Server) UserMe(c *gin.Context) {
+func (s *Server) GetUserNonce(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.UserNonceReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.GetUserNonce(ctx, req.Network, req.Address)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: &serializers.UserNonceResp{
+		Network:   m.Network,
+		Address:   m.Address,
+		Nonce:     m.Nonce,
+		Message:   services.UserSignInMessage(m),
+		ExpiredAt: m.ExpiredAt,
+	}})
+}
+
+func (s *Server) UserSignIn(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.UserSignInReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, token, err := s.nls.UserSignIn(ctx, req.Network, req.Address, req.Nonce, req.Signature)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: &serializers.UserSignInResp{
+		Token: token,
+		User:  serializers.NewUserResp(m),
+	}})
+}
+
+func (s *Server) UserSignOut(c *gin.Context) {
+	ctx := s.requestContext(c)
+	token, err := s.GetUserToken(c)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusUnauthorized, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	err = s.nls.UserSignOut(ctx, token)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
 	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: true})
 }
+
+func (s *Server) UserMe(c *gin.Context) {
+	ctx := s.requestContext(c)
+	m, loans, offers, err := s.nls.GetUserMe(ctx, s.userIDFromContext(c))
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: &serializers.UserMeResp{
+		UserResp: serializers.NewUserResp(m),
+		Loans:    serializers.NewLoanRespArr(loans),
+		Offers:   serializers.NewLoanOfferRespArr(offers),
+	}})
+}
+
+func (s *Server) UpdateUserSettings(c *gin.Context) {
+	ctx := s.requestContext(c)
+	var req serializers.UserSettingsReq
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ctxJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	m, err := s.nls.UpdateUserSettings(ctx, s.userIDFromContext(c), req.Email, req.NewsNotiEnabled, req.LoanNotiEnabled)
+	if err != nil {
+		ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+		return
+	}
+	ctxJSON(c, http.StatusOK, &serializers.Resp{Result: serializers.NewUserResp(m)})
+}
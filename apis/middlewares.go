@@ -3,21 +3,25 @@ package apis
 import (
 	"bytes"
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"math"
+	"net"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/czConstant/constant-nftylend-api/configs"
+	"github.com/czConstant/constant-nftylend-api/daos"
 	"github.com/czConstant/constant-nftylend-api/errs"
 	"github.com/czConstant/constant-nftylend-api/helpers"
 	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
 	"github.com/czConstant/constant-nftylend-api/serializers"
 	"github.com/getsentry/raven-go"
 	"go.uber.org/zap"
@@ -27,6 +31,13 @@ import (
 
 const (
 	CONTEXT_USER_DATA       = "context_user_data"
+	CONTEXT_USER_ID_DATA    = "context_user_id_data"
+	CONTEXT_ACTOR_DATA      = "context_actor_data"
+	CONTEXT_ROLES_DATA      = "context_roles_data"
+	CONTEXT_PRINCIPAL_DATA  = "context_principal_data"
+	CONTEXT_AUDIT_ID_DATA   = "context_audit_id_data"
+	CONTEXT_PARTNER_DATA    = "context_partner_data"
+	CONTEXT_PARTNER_ID_DATA = "context_partner_id_data"
 	CONTEXT_ERROR_DATA      = "context_error_data"
 	CONTEXT_STACKTRACE_DATA = "context_stacktrace_data"
 )
@@ -91,16 +102,243 @@ func (s *Server) GetUserToken(c *gin.Context) (string, error) {
 	return auths[1], nil
 }
 
-func (s *Server) authorizeJobMiddleware() gin.HandlerFunc {
+// authorizeUserMiddleware resolves the session token and puts the signed in
+// wallet address into CONTEXT_USER_DATA
+func (s *Server) authorizeUserMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		if c.GetHeader("Authorization") != configs.GetConfig().JobToken {
-			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(errs.ErrBadRequest)})
+		token, err := s.GetUserToken(c)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusUnauthorized, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		user, err := s.nls.GetUserBySessionToken(s.requestContext(c), token)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusUnauthorized, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		c.Set(CONTEXT_USER_DATA, user.Address)
+		c.Set(CONTEXT_USER_ID_DATA, user.ID)
+		c.Next()
+	}
+}
+
+// principal is the caller of a privileged route, either an api key or a
+// signed in wallet
+type principal struct {
+	Actor   string
+	Address string
+	UserID  uint
+	Roles   []models.Role
+}
+
+func (p *principal) hasRole(roles ...models.Role) bool {
+	for _, role := range roles {
+		for _, r := range p.Roles {
+			if r == role {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// principalResult keeps the resolved caller of the request, or why it could
+// not be resolved
+type principalResult struct {
+	principal *principal
+	err       error
+}
+
+// principalFromContext resolves the caller once per request, the rate limit
+// and the role checks share the result
+func (s *Server) principalFromContext(c *gin.Context) (*principal, error) {
+	if v, ok := c.Get(CONTEXT_PRINCIPAL_DATA); ok {
+		r := v.(*principalResult)
+		return r.principal, r.err
+	}
+	p, err := s.getPrincipal(c)
+	c.Set(CONTEXT_PRINCIPAL_DATA, &principalResult{principal: p, err: err})
+	return p, err
+}
+
+// getPrincipal resolves the caller from X-Api-Key, the legacy job token or the
+// session token, roles are read from the db on every request
+func (s *Server) getPrincipal(c *gin.Context) (*principal, error) {
+	ctx := s.requestContext(c)
+	apiKey := c.GetHeader("X-Api-Key")
+	if apiKey != "" {
+		m, err := s.nls.GetApiKey(ctx, apiKey)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		return &principal{
+			Actor: fmt.Sprintf("api_key:%s", m.Name),
+			Roles: []models.Role{m.Role},
+		}, nil
+	}
+	auth := c.GetHeader("Authorization")
+	if s.conf.JobToken != "" && subtle.ConstantTimeCompare([]byte(auth), []byte(s.conf.JobToken)) == 1 {
+		return &principal{
+			Actor: "job_token",
+			Roles: []models.Role{models.RoleJob},
+		}, nil
+	}
+	token, err := s.GetUserToken(c)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	user, err := s.nls.GetUserBySessionToken(ctx, token)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	roles, err := s.nls.GetUserRoles(ctx, user.Network, user.Address)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if s.isAdminAddress(user.Address) {
+		roles = append(roles, models.RoleAdmin)
+	}
+	return &principal{
+		Actor:   user.Address,
+		Address: user.Address,
+		UserID:  user.ID,
+		Roles:   roles,
+	}, nil
+}
+
+// authorizeRolesMiddleware declares the roles allowed on a route
+func (s *Server) authorizeRolesMiddleware(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := s.principalFromContext(c)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusUnauthorized, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		if !p.hasRole(roles...) {
+			ctxAbortWithStatusJSON(c, http.StatusForbidden, &serializers.Resp{Error: errs.NewError(errs.ErrBadPermission)})
+			return
+		}
+		c.Set(CONTEXT_ACTOR_DATA, p.Actor)
+		c.Set(CONTEXT_ROLES_DATA, p.Roles)
+		if p.Address != "" {
+			c.Set(CONTEXT_USER_DATA, p.Address)
+			c.Set(CONTEXT_USER_ID_DATA, p.UserID)
+		}
+		c.Next()
+	}
+}
+
+// authorizeIndexerMiddleware guards the routes the indexer posts to, which
+// take the indexer and job roles, so the legacy job token keeps working. The
+// indexer called them without credentials before roles, indexer_legacy_auth
+// keeps accepting such calls until it sends an api key
+func (s *Server) authorizeIndexerMiddleware() gin.HandlerFunc {
+	authorize := s.authorizeRolesMiddleware(models.RoleAdmin, models.RoleJob, models.RoleIndexer)
+	return func(c *gin.Context) {
+		if s.conf.IndexerLegacyAuth &&
+			c.GetHeader("X-Api-Key") == "" &&
+			c.GetHeader("Authorization") == "" {
+			logger.Info("indexer", "call without credentials", zap.String("path", c.FullPath()), zap.String("client_ip", c.ClientIP()))
+			c.Set(CONTEXT_ACTOR_DATA, "legacy_indexer")
+			c.Set(CONTEXT_ROLES_DATA, []models.Role{models.RoleIndexer})
+			c.Next()
 			return
 		}
+		authorize(c)
+	}
+}
+
+// isAdminAddress bootstraps admins from admin_addresses before any role is stored
+func (s *Server) isAdminAddress(address string) bool {
+	for _, adminAddress := range s.conf.AdminAddresses {
+		adminAddress = strings.TrimSpace(adminAddress)
+		// evm addresses are stored lower case, solana addresses are case sensitive
+		if strings.HasPrefix(adminAddress, "0x") {
+			adminAddress = strings.ToLower(adminAddress)
+		}
+		if adminAddress == address {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) userAddressFromContext(c *gin.Context) string {
+	return c.GetString(CONTEXT_USER_DATA)
+}
+
+func (s *Server) userIDFromContext(c *gin.Context) uint {
+	return c.GetUint(CONTEXT_USER_ID_DATA)
+}
+
+func (s *Server) actorFromContext(c *gin.Context) string {
+	return c.GetString(CONTEXT_ACTOR_DATA)
+}
+
+func (s *Server) rolesFromContext(c *gin.Context) []models.Role {
+	v, ok := c.Get(CONTEXT_ROLES_DATA)
+	if !ok {
+		return []models.Role{}
+	}
+	return v.([]models.Role)
+}
+
+// auditMiddleware records who called a privileged route and the state of the
+// target entity before and after the request, the target is the :id param or
+// the id set by the handler through setAuditTargetID
+func (s *Server) auditMiddleware(targetType models.AuditTargetType) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := s.requestContext(c)
+		targetID, _ := s.uintFromContextParam(c, "id")
+		var before, after string
+		var err error
+		if targetID > 0 {
+			before, err = s.nls.GetAuditSnapshot(ctx, targetType, targetID)
+			if err != nil {
+				logger.Error("audit_log", "load target failed", zap.Error(err))
+			}
+		}
 		c.Next()
+		if targetID == 0 {
+			targetID = c.GetUint(CONTEXT_AUDIT_ID_DATA)
+		}
+		if targetID > 0 {
+			after, err = s.nls.GetAuditSnapshot(ctx, targetType, targetID)
+			if err != nil {
+				logger.Error("audit_log", "load target failed", zap.Error(err))
+			}
+		}
+		roles := []string{}
+		for _, role := range s.rolesFromContext(c) {
+			roles = append(roles, string(role))
+		}
+		err = s.nls.CreateAuditLog(
+			ctx,
+			&models.AuditLog{
+				Actor:      s.actorFromContext(c),
+				Roles:      strings.Join(roles, ","),
+				IP:         s.clientIPFromContext(c),
+				Method:     c.Request.Method,
+				Route:      c.FullPath(),
+				Path:       c.Request.URL.Path,
+				TargetType: targetType,
+				TargetID:   targetID,
+				Before:     before,
+				After:      after,
+				Status:     c.Writer.Status(),
+				Error:      c.GetString(CONTEXT_ERROR_DATA),
+			},
+		)
+		if err != nil {
+			logger.Error("audit_log", "create audit log failed", zap.Error(err))
+		}
 	}
 }
 
+func (s *Server) setAuditTargetID(c *gin.Context, id uint) {
+	c.Set(CONTEXT_AUDIT_ID_DATA, id)
+}
+
 func (s *Server) recaptchaV3Middleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		if configs.GetConfig().RecaptchaV3Serect != "" {
@@ -126,6 +364,225 @@ func (s *Server) recaptchaV3Middleware() gin.HandlerFunc {
 	}
 }
 
+// partnerMiddleware resolves the X-Partner-Key header and meters the request on
+// the partner daily usage, requests without the header are left untouched and
+// requests that can not be metered are refused. The partner routes are not
+// metered so the usage stays readable once the quota is used
+func (s *Server) partnerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("X-Partner-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		ctx := s.requestContext(c)
+		partner, err := s.nls.GetPartner(ctx, key)
+		if err != nil {
+			ctxAbortWithStatusJSON(c, http.StatusUnauthorized, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		c.Set(CONTEXT_PARTNER_DATA, partner.Name)
+		c.Set(CONTEXT_PARTNER_ID_DATA, partner.ID)
+		if strings.HasPrefix(c.FullPath(), "/nfty-lend-api/partners/") {
+			c.Next()
+			return
+		}
+		allowed, err := s.nls.MeterPartnerRequest(ctx, partner)
+		if err != nil {
+			logger.Error("partner", "meter partner request failed", zap.Error(err))
+			ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
+			return
+		}
+		if !allowed {
+			ctxAbortWithStatusJSON(c, http.StatusTooManyRequests, &serializers.Resp{Error: errs.NewError(errs.ErrPartnerQuotaExceeded)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// authorizePartnerMiddleware requires a partner key resolved by partnerMiddleware
+func (s *Server) authorizePartnerMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.partnerIDFromContext(c) == 0 {
+			ctxAbortWithStatusJSON(c, http.StatusUnauthorized, &serializers.Resp{Error: errs.NewError(errs.ErrTokenInvalid)})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (s *Server) partnerIDFromContext(c *gin.Context) uint {
+	return c.GetUint(CONTEXT_PARTNER_ID_DATA)
+}
+
+// rateLimitRule returns the budget of route, routes missing from the config use
+// the default budget
+func (s *Server) rateLimitRule(route string) (ratelimit.Rule, bool) {
+	rule, ok := s.conf.RateLimit.Routes[route]
+	if !ok {
+		rule = s.conf.RateLimit.Default
+	}
+	if rule.Limit <= 0 || rule.PeriodSeconds <= 0 {
+		return ratelimit.Rule{}, false
+	}
+	return ratelimit.Rule{
+		Limit:  rule.Limit,
+		Period: time.Duration(rule.PeriodSeconds) * time.Second,
+	}, true
+}
+
+// rateLimitClient keys the caller by partner, api key, signed in wallet or ip, invalid
+// credentials fall back to the ip so they can not be used to skip the limit
+func (s *Server) rateLimitClient(c *gin.Context) string {
+	partnerID := s.partnerIDFromContext(c)
+	if partnerID > 0 {
+		return fmt.Sprintf("partner:%d", partnerID)
+	}
+	if c.GetHeader("X-Api-Key") != "" ||
+		c.GetHeader("Authorization") != "" ||
+		c.Query("auth_token") != "" {
+		p, err := s.principalFromContext(c)
+		if err == nil {
+			if p.Address != "" {
+				return fmt.Sprintf("wallet:%s", p.Address)
+			}
+			return p.Actor
+		}
+	}
+	return fmt.Sprintf("ip:%s", s.clientIPFromContext(c))
+}
+
+// rateLimitMiddleware takes a token from the bucket of the client on the
+// matched route, the store failing does not block the request
+func (s *Server) rateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !s.conf.RateLimit.Enabled || s.rls == nil {
+			c.Next()
+			return
+		}
+		route := c.FullPath()
+		rule, ok := s.rateLimitRule(route)
+		if !ok {
+			c.Next()
+			return
+		}
+		r, err := s.rls.Take(
+			s.requestContext(c),
+			fmt.Sprintf("%s|%s", route, s.rateLimitClient(c)),
+			rule,
+		)
+		if err != nil {
+			logger.Error("rate_limit", "take token failed", zap.Error(err))
+			c.Next()
+			return
+		}
+		c.Header("X-RateLimit-Limit", strconv.Itoa(r.Limit))
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(r.Remaining))
+		c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(r.Reset.Seconds()))))
+		if !r.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(r.RetryAfter.Seconds()))))
+			ctxAbortWithStatusJSON(c, http.StatusTooManyRequests, &serializers.Resp{Error: errs.NewError(errs.ErrTooManyRequests)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// throttleMiddleware takes a token from the bucket of the client ip on a fixed
+// budget, unlike rateLimitMiddleware it applies whatever the rate limit config
+func (s *Server) throttleMiddleware(name string, rule ratelimit.Rule) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.rls == nil {
+			c.Next()
+			return
+		}
+		r, err := s.rls.Take(
+			s.requestContext(c),
+			fmt.Sprintf("throttle:%s|ip:%s", name, s.clientIPFromContext(c)),
+			rule,
+		)
+		if err != nil {
+			logger.Error("throttle", "take token failed", zap.Error(err))
+			c.Next()
+			return
+		}
+		if !r.Allowed {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(r.RetryAfter.Seconds()))))
+			ctxAbortWithStatusJSON(c, http.StatusTooManyRequests, &serializers.Resp{Error: errs.NewError(errs.ErrTooManyRequests)})
+			return
+		}
+		c.Next()
+	}
+}
+
+// parseTrustedProxies reads the ips and cidrs of the proxies allowed to set
+// X-Forwarded-For, invalid entries are logged and ignored
+func parseTrustedProxies(proxies []string) []*net.IPNet {
+	nets := []*net.IPNet{}
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				logger.Error("trusted_proxies", "invalid proxy", zap.String("proxy", proxy))
+				continue
+			}
+			if ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(proxy)
+		if err != nil {
+			logger.Error("trusted_proxies", "invalid proxy", zap.String("proxy", proxy), zap.Error(err))
+			continue
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets
+}
+
+func (s *Server) isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range s.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIPFromContext returns the peer address of the request, X-Forwarded-For
+// is only read when the peer is a trusted proxy and is walked from the right so
+// the entries a client prepends are never used
+func (s *Server) clientIPFromContext(c *gin.Context) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Request.RemoteAddr))
+	if err != nil {
+		host = strings.TrimSpace(c.Request.RemoteAddr)
+	}
+	remoteIP := net.ParseIP(host)
+	if remoteIP == nil {
+		return host
+	}
+	if !s.isTrustedProxy(remoteIP) {
+		return remoteIP.String()
+	}
+	clientIP := remoteIP
+	items := strings.Split(c.GetHeader("X-Forwarded-For"), ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(items[i]))
+		if ip == nil {
+			break
+		}
+		clientIP = ip
+		if !s.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return clientIP.String()
+}
+
 func (s *Server) otpFromContext(c *gin.Context) string {
 	myOtp := c.GetHeader("OTP")
 	return myOtp
@@ -174,10 +631,7 @@ func (s *Server) logApiMiddleware() gin.HandlerFunc {
 		if c.GetBool("log") {
 			end := time.Now()
 			latency := end.Sub(start).Seconds()
-			ipStr := c.Request.Header.Get("ip")
-			if ipStr == "" {
-				ipStr = c.ClientIP()
-			}
+			ipStr := s.clientIPFromContext(c)
 			var errText, stacktraceText, bodyResponse string
 			v, ok := c.Get(CONTEXT_ERROR_DATA)
 			if ok {
@@ -206,6 +660,8 @@ func (s *Server) logApiMiddleware() gin.HandlerFunc {
 				zap.Any("platform", c.Request.Header.Get("platform")),
 				zap.Any("os", c.Request.Header.Get("os")),
 				zap.Any("country", c.Request.Header.Get("country")),
+				zap.Any("partner", c.GetString(CONTEXT_PARTNER_DATA)),
+				zap.Any("partner_id", c.GetUint(CONTEXT_PARTNER_ID_DATA)),
 				zap.Any("error_text", errText),
 				zap.Any("stacktrace", stacktraceText),
 				zap.Any("body_request", helpers.SubStringBodyResponse(bodyRequest, 1000)),
@@ -273,27 +729,31 @@ func (s *Server) pagingFromContext(c *gin.Context) (int, int) {
 	return page, limit
 }
 
-func (s *Server) ipfsProxyMiddleware(hostPath string) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		hash := c.Param("hash")
-		r := c.Request
-		w := c.Writer
-		director := func(req *http.Request) {
-			hostURL, err := url.Parse(hostPath)
-			if err != nil {
-				ctxAbortWithStatusJSON(c, http.StatusBadRequest, &serializers.Resp{Error: errs.NewError(err)})
-				return
-			}
-			req.URL.Scheme = hostURL.Scheme
-			req.URL.Host = hostURL.Host
-			req.Host = hostURL.Host
-			req.URL.Path = hostPath + "/" + hash + ".mp4"
-		}
-		proxy := &httputil.ReverseProxy{
-			Director: director,
-		}
-		proxy.ServeHTTP(w, r)
+// cursorPagingFromContext returns keyset paging params, ok is false when the client asks for offset paging
+func (s *Server) cursorPagingFromContext(c *gin.Context) (string, int, bool, bool) {
+	cursor, ok := c.GetQuery("cursor")
+	if !ok {
+		return "", 0, false, false
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+	if limit > 100 {
+		limit = 100
+	}
+	withCount, _ := strconv.ParseBool(c.Query("with_count"))
+	return strings.TrimSpace(cursor), limit, withCount, true
+}
+
+func ctxCursorJSON(c *gin.Context, result interface{}, page *daos.CursorPage) {
+	resp := &serializers.Resp{Result: result}
+	if page != nil {
+		resp.Count = page.Count
+		resp.NextCursor = page.NextCursor
+		resp.HasMore = &page.HasMore
 	}
+	ctxJSON(c, http.StatusOK, resp)
 }
 
 func (s *Server) uintFromContextParam(c *gin.Context, param string) (uint, error) {
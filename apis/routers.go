@@ -1,10 +1,13 @@
 package apis
 
 import (
+	"net"
 	"net/http"
 	"time"
 
 	"github.com/czConstant/constant-nftylend-api/configs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
 	"github.com/czConstant/constant-nftylend-api/serializers"
 	"github.com/czConstant/constant-nftylend-api/services"
 	"github.com/getsentry/raven-go"
@@ -12,28 +15,38 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+var (
+	collectionSubmittedThrottle = ratelimit.Rule{Limit: 5, Period: time.Hour}
+	userNonceThrottle           = ratelimit.Rule{Limit: 10, Period: time.Minute}
+)
+
 type Server struct {
-	g    *gin.Engine
-	conf *configs.Config
-	nls  *services.NftLend
+	g              *gin.Engine
+	conf           *configs.Config
+	nls            *services.NftLend
+	rls            ratelimit.Store
+	trustedProxies []*net.IPNet
 }
 
 func NewServer(
 	g *gin.Engine,
 	conf *configs.Config,
 	nls *services.NftLend,
+	rls ratelimit.Store,
 ) *Server {
 	return &Server{
-		g:    g,
-		conf: conf,
-		nls:  nls,
+		g:              g,
+		conf:           conf,
+		nls:            nls,
+		rls:            rls,
+		trustedProxies: parseTrustedProxies(conf.TrustedProxies),
 	}
 }
 
 func (s *Server) Routers() {
 	s.g.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://*", "https://*"},
-		ExposeHeaders:    []string{"Content-Length"},
+		ExposeHeaders:    []string{"Content-Length", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		AllowOriginFunc:  func(origin string) bool { return true },
 		AllowMethods:     []string{"GET", "POST", "PUT", "HEAD", "OPTIONS", "DELETE"},
@@ -42,6 +55,15 @@ func (s *Server) Routers() {
 	}))
 	s.g.Use(s.logApiMiddleware())
 	s.g.Use(s.recoveryMiddleware(raven.DefaultClient, false))
+	s.g.Use(s.partnerMiddleware())
+	s.g.Use(s.rateLimitMiddleware())
+	// route permissions, each privileged route declares the roles allowed on it
+	var (
+		adminRoles    = s.authorizeRolesMiddleware(models.RoleAdmin)
+		reviewerRoles = s.authorizeRolesMiddleware(models.RoleAdmin, models.RoleReviewer)
+		jobRoles      = s.authorizeRolesMiddleware(models.RoleAdmin, models.RoleJob)
+		indexerRoles  = s.authorizeIndexerMiddleware()
+	)
 	nftAPI := s.g.Group("/nfty-lend-api")
 	{
 		nftAPI.GET("/", func(c *gin.Context) {
@@ -49,7 +71,7 @@ func (s *Server) Routers() {
 		})
 		nftAPI.GET("/configs", s.AppConfigs)
 	}
-	nftAPI.POST("/blockchain/update-block/:block", s.NftLendUpdateBlock)
+	nftAPI.POST("/blockchain/update-block/:block", indexerRoles, s.NftLendUpdateBlock)
 	currencynftAPI := nftAPI.Group("/currencies")
 	{
 		currencynftAPI.GET("/list", s.GetCurrencies)
@@ -59,12 +81,15 @@ func (s *Server) Routers() {
 		assetnftAPI.GET("/detail/:seo_url", s.GetAssetDetail)
 		assetnftAPI.GET("/transactions", s.GetAseetTransactions)
 	}
+	nftAPI.GET("/media/:asset_id", s.GetAssetMedia)
 	collectionnftAPI := nftAPI.Group("/collections")
 	{
 		collectionnftAPI.GET("/list", s.GetCollections)
 		collectionnftAPI.GET("/detail/:seo_url", s.GetCollectionDetail)
+		collectionnftAPI.GET("/:seo_url/stats", s.GetCollectionStats)
 		collectionnftAPI.GET("/verified", s.GetCollectionAssetVerified)
-		collectionnftAPI.POST("/submitted", s.CreateCollectionSubmitted)
+		collectionnftAPI.POST("/submitted", s.throttleMiddleware("collection_submitted", collectionSubmittedThrottle), s.recaptchaV3Middleware(), s.CreateCollectionSubmitted)
+		collectionnftAPI.GET("/submitted/:lookup_token", s.GetCollectionSubmittedStatus)
 	}
 	loannftAPI := nftAPI.Group("/loans")
 	{
@@ -73,8 +98,75 @@ func (s *Server) Routers() {
 		loannftAPI.GET("/offers", s.GetLoanOffers)
 		loannftAPI.GET("/transactions", s.GetLoanTransactions)
 	}
+	usernftAPI := nftAPI.Group("/users")
+	{
+		usernftAPI.POST("/nonce", s.throttleMiddleware("user_nonce", userNonceThrottle), s.GetUserNonce)
+		usernftAPI.POST("/sign-in", s.UserSignIn)
+		usernftAPI.POST("/sign-out", s.UserSignOut)
+		usernftAPI.GET("/me", s.authorizeUserMiddleware(), s.UserMe)
+		usernftAPI.PUT("/me/settings", s.authorizeUserMiddleware(), s.UpdateUserSettings)
+	}
+	partnernftAPI := nftAPI.Group("/partners")
+	{
+		partnernftAPI.GET("/me/usage", s.authorizePartnerMiddleware(), s.GetPartnerMeUsage)
+	}
+	searchnftAPI := nftAPI.Group("/search")
+	{
+		searchnftAPI.GET("", s.Search)
+		searchnftAPI.GET("/autocomplete", s.SearchAutocomplete)
+	}
+	adminnftAPI := nftAPI.Group("/admin")
+	{
+		adminnftAPI.GET("/collections", reviewerRoles, s.AdminGetCollections)
+		adminnftAPI.POST("/collections", adminRoles, s.auditMiddleware(models.AuditTargetCollection), s.AdminCreateCollection)
+		adminnftAPI.PUT("/collections/:id", adminRoles, s.auditMiddleware(models.AuditTargetCollection), s.AdminUpdateCollection)
+		adminnftAPI.POST("/collections/:id/enable", adminRoles, s.auditMiddleware(models.AuditTargetCollection), s.AdminEnableCollection)
+		adminnftAPI.POST("/collections/:id/disable", adminRoles, s.auditMiddleware(models.AuditTargetCollection), s.AdminDisableCollection)
+		adminnftAPI.GET("/currencies", adminRoles, s.AdminGetCurrencies)
+		adminnftAPI.POST("/currencies", adminRoles, s.auditMiddleware(models.AuditTargetCurrency), s.AdminCreateCurrency)
+		adminnftAPI.PUT("/currencies/:id", adminRoles, s.auditMiddleware(models.AuditTargetCurrency), s.AdminUpdateCurrency)
+		adminnftAPI.POST("/currencies/:id/enable", adminRoles, s.auditMiddleware(models.AuditTargetCurrency), s.AdminEnableCurrency)
+		adminnftAPI.POST("/currencies/:id/disable", adminRoles, s.auditMiddleware(models.AuditTargetCurrency), s.AdminDisableCurrency)
+		adminnftAPI.POST("/assets/:id/update-info", adminRoles, s.auditMiddleware(models.AuditTargetAsset), s.AdminUpdateAssetInfo)
+		adminnftAPI.PUT("/assets/:id/test-contract", adminRoles, s.auditMiddleware(models.AuditTargetAsset), s.AdminUpdateAssetTestContract)
+		adminnftAPI.GET("/instructions", adminRoles, s.AdminGetInstructions)
+		adminnftAPI.GET("/instructions/:id", adminRoles, s.AdminGetInstruction)
+		adminnftAPI.POST("/instructions/:id/reprocess", adminRoles, s.auditMiddleware(models.AuditTargetInstruction), s.AdminReprocessInstruction)
+		adminnftAPI.GET("/collection-submitted", reviewerRoles, s.AdminGetCollectionSubmitteds)
+		adminnftAPI.GET("/collection-submitted/:id", reviewerRoles, s.AdminGetCollectionSubmitted)
+		adminnftAPI.POST("/collection-submitted/:id/comments", reviewerRoles, s.auditMiddleware(models.AuditTargetCollectionSubmitted), s.AdminCommentCollectionSubmitted)
+		adminnftAPI.POST("/collection-submitted/:id/review", reviewerRoles, s.auditMiddleware(models.AuditTargetCollectionSubmitted), s.AdminReviewCollectionSubmitted)
+		adminnftAPI.POST("/collection-submitted/:id/approve", reviewerRoles, s.auditMiddleware(models.AuditTargetCollectionSubmitted), s.AdminApproveCollectionSubmitted)
+		adminnftAPI.POST("/collection-submitted/:id/reject", reviewerRoles, s.auditMiddleware(models.AuditTargetCollectionSubmitted), s.AdminRejectCollectionSubmitted)
+		adminnftAPI.GET("/api-keys", adminRoles, s.AdminGetApiKeys)
+		adminnftAPI.POST("/api-keys", adminRoles, s.auditMiddleware(models.AuditTargetApiKey), s.AdminCreateApiKey)
+		adminnftAPI.POST("/api-keys/:id/rotate", adminRoles, s.auditMiddleware(models.AuditTargetApiKey), s.AdminRotateApiKey)
+		adminnftAPI.POST("/api-keys/:id/revoke", adminRoles, s.auditMiddleware(models.AuditTargetApiKey), s.AdminRevokeApiKey)
+		adminnftAPI.GET("/roles", adminRoles, s.AdminGetUserRoles)
+		adminnftAPI.POST("/roles", adminRoles, s.auditMiddleware(models.AuditTargetUserRole), s.AdminCreateUserRole)
+		adminnftAPI.DELETE("/roles/:id", adminRoles, s.auditMiddleware(models.AuditTargetUserRole), s.AdminDeleteUserRole)
+		adminnftAPI.GET("/partners", adminRoles, s.AdminGetPartners)
+		adminnftAPI.POST("/partners", adminRoles, s.auditMiddleware(models.AuditTargetPartner), s.AdminCreatePartner)
+		adminnftAPI.PUT("/partners/:id", adminRoles, s.auditMiddleware(models.AuditTargetPartner), s.AdminUpdatePartner)
+		adminnftAPI.POST("/partners/:id/revoke", adminRoles, s.auditMiddleware(models.AuditTargetPartner), s.AdminRevokePartner)
+		adminnftAPI.GET("/partners/:id/usage", adminRoles, s.AdminGetPartnerUsages)
+		adminnftAPI.GET("/audit", adminRoles, s.AdminGetAuditLogs)
+		adminnftAPI.GET("/sale-streams", indexerRoles, s.AdminGetSaleStreams)
+	}
+	jobnftAPI := nftAPI.Group("/jobs")
+	{
+		jobnftAPI.POST("/search/reindex", jobRoles, s.auditMiddleware(models.AuditTargetNone), s.JobSearchReindex)
+		jobnftAPI.POST("/asset-crawls/seed", jobRoles, s.auditMiddleware(models.AuditTargetNone), s.JobSeedAssetCrawls)
+		jobnftAPI.POST("/asset-transactions/crawl", jobRoles, s.JobCrawlAssetTransactions)
+		jobnftAPI.POST("/asset-transactions/dedupe", jobRoles, s.auditMiddleware(models.AuditTargetNone), s.JobDedupeAssetTransactions)
+		jobnftAPI.POST("/asset-metadata/refresh", jobRoles, s.JobRefreshAssetMetadata)
+		jobnftAPI.POST("/collection-rarity/compute", jobRoles, s.JobComputeCollectionRarity)
+		jobnftAPI.POST("/collection-stats/snapshot", jobRoles, s.JobSnapshotCollectionStats)
+		jobnftAPI.POST("/collection-aggregates/refresh", jobRoles, s.JobRefreshCollectionAggregates)
+		jobnftAPI.POST("/collections/verify-approved", jobRoles, s.auditMiddleware(models.AuditTargetNone), s.JobVerifyApprovedCollections)
+	}
 	hookInternalnftAPI := nftAPI.Group("/hook/internal")
 	{
-		hookInternalnftAPI.POST("/solana-instruction", s.LenInternalHookSolanaInstruction)
+		hookInternalnftAPI.POST("/solana-instruction", indexerRoles, s.LenInternalHookSolanaInstruction)
 	}
 }
@@ -0,0 +1,24 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned by Get for the keys never stored
+var ErrNotFound = errors.New("blobstore: not found")
+
+type Blob struct {
+	Data        []byte
+	ContentType string
+	ModTime     time.Time
+}
+
+// Store keeps media blobs by key, keys are slash separated paths. The disk
+// store is used by default and an object storage can be plugged in when the
+// api runs on several instances
+type Store interface {
+	Get(ctx context.Context, key string) (*Blob, error)
+	Put(ctx context.Context, key string, blob *Blob) error
+}
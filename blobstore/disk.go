@@ -0,0 +1,97 @@
+package blobstore
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	contentTypeExt = ".type"
+)
+
+// DiskStore keeps the blobs as files under its directory, the content type
+// is kept next to the data in a .type file
+type DiskStore struct {
+	dir string
+}
+
+func NewDiskStore(dir string) *DiskStore {
+	return &DiskStore{
+		dir: dir,
+	}
+}
+
+func (s *DiskStore) path(key string) (string, error) {
+	p := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("blobstore: invalid key %q", key)
+	}
+	return p, nil
+}
+
+func (s *DiskStore) Get(ctx context.Context, key string) (*Blob, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	contentType, err := ioutil.ReadFile(p + contentTypeExt)
+	if err != nil || len(contentType) == 0 {
+		contentType = []byte(http.DetectContentType(data))
+	}
+	return &Blob{
+		Data:        data,
+		ContentType: string(contentType),
+		ModTime:     fi.ModTime(),
+	}, nil
+}
+
+// Put writes through a temporary file so readers never see a partial blob
+func (s *DiskStore) Put(ctx context.Context, key string, blob *Blob) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(p), 0755)
+	if err != nil {
+		return err
+	}
+	err = s.write(p+contentTypeExt, []byte(blob.ContentType))
+	if err != nil {
+		return err
+	}
+	return s.write(p, blob.Data)
+}
+
+func (s *DiskStore) write(p string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(p), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), p)
+}
@@ -0,0 +1,40 @@
+package blobstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the blobs of this instance only
+type MemoryStore struct {
+	mtx   sync.Mutex
+	blobs map[string]*Blob
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		blobs: map[string]*Blob{},
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (*Blob, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return b, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, blob *Blob) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	b := *blob
+	if b.ModTime.IsZero() {
+		b.ModTime = time.Now()
+	}
+	s.blobs[key] = &b
+	return nil
+}
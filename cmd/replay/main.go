@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/replay"
+)
+
+// replays recorded solana instruction hooks and checks the lending tables
+// against golden snapshots
+//
+//	go run ./cmd/replay -dir replay/testdata
+//	go run ./cmd/replay -dir replay/testdata -update
+//
+// the same scenarios run under go test ./replay, -update rewrites them there
+func main() {
+	dir := flag.String("dir", "replay/testdata", "directory of scenarios")
+	update := flag.Bool("update", false, "rewrite golden snapshots")
+	flag.Parse()
+	logger.NewLogger("nft-api-replay", "", false)
+	defer logger.Sync()
+	scenarios, err := replay.Scenarios(*dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	failed := 0
+	for _, scenario := range scenarios {
+		err = replay.Run(scenario, *update)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %s\n%v\n", scenario, err)
+			continue
+		}
+		fmt.Printf("ok   %s\n", scenario)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -3,14 +3,20 @@ package configs
 import (
 	"encoding/json"
 	"os"
+	"sync"
 
 	"github.com/czConstant/blockchain-api/bcclient"
 	"github.com/czConstant/constant-evn/client"
 )
 
-var config *Config
+var (
+	config     *Config
+	configOnce sync.Once
+)
 
-func init() {
+// loaded on first use so tools that only wire the router do not need
+// configs/config.json or the evn secrets
+func loadConfig() {
 	file, err := os.Open("configs/config.json")
 	if err != nil {
 		panic(err)
@@ -34,21 +40,40 @@ func init() {
 }
 
 func GetConfig() *Config {
+	configOnce.Do(loadConfig)
 	return config
 }
 
 type Config struct {
-	Env               string `json:"env"`
-	EvnURL            string `json:"evn_url"`
-	RavenDNS          string `json:"raven_dns"`
-	RavenENV          string `json:"raven_env"`
-	Port              int    `json:"port"`
-	LogPath           string `json:"log_path"`
-	DbURL             string `json:"db_url"`
-	Debug             bool   `json:"debug"`
-	RecaptchaV3Serect string `json:"recaptcha_v3_serect"`
-	JobToken          string `json:"job_token"`
-	Datadog           struct {
+	Env               string   `json:"env"`
+	EvnURL            string   `json:"evn_url"`
+	RavenDNS          string   `json:"raven_dns"`
+	RavenENV          string   `json:"raven_env"`
+	Port              int      `json:"port"`
+	LogPath           string   `json:"log_path"`
+	DbURL             string   `json:"db_url"`
+	Debug             bool     `json:"debug"`
+	RecaptchaV3Serect string   `json:"recaptcha_v3_serect"`
+	JobToken          string   `json:"job_token"`
+	IndexerLegacyAuth bool     `json:"indexer_legacy_auth"`
+	AdminAddresses    []string `json:"admin_addresses"`
+	TrustedProxies    []string `json:"trusted_proxies"`
+	RateLimit         struct {
+		Enabled bool                     `json:"enabled"`
+		Default RateLimitRule            `json:"default"`
+		Routes  map[string]RateLimitRule `json:"routes"`
+	} `json:"rate_limit"`
+	Metadata struct {
+		IPFSGateways    []string `json:"ipfs_gateways"`
+		ArweaveGateways []string `json:"arweave_gateways"`
+		TimeoutSeconds  int      `json:"timeout_seconds"`
+		Retries         int      `json:"retries"`
+		CacheTTLSeconds int      `json:"cache_ttl_seconds"`
+	} `json:"metadata"`
+	Media struct {
+		Dir string `json:"dir"`
+	} `json:"media"`
+	Datadog struct {
 		Env     string `json:"env"`
 		Service string `json:"service"`
 		Version string `json:"version"`
@@ -58,3 +83,10 @@ type Config struct {
 	} `json:"contract"`
 	Blockchain bcclient.Config `json:"blockchain"`
 }
+
+// RateLimitRule allows Limit requests per PeriodSeconds for each client, a zero
+// Limit leaves the route unlimited
+type RateLimitRule struct {
+	Limit         int `json:"limit"`
+	PeriodSeconds int `json:"period_seconds"`
+}
@@ -0,0 +1,56 @@
+package daos
+
+import (
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+type ApiKey struct {
+	DAO
+}
+
+func (d *ApiKey) FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.ApiKey, error) {
+	var m models.ApiKey
+	if err := d.first(tx, &m, map[string][]interface{}{"id = ?": []interface{}{id}}, preloads, nil, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *ApiKey) First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.ApiKey, error) {
+	var m models.ApiKey
+	if err := d.first(tx, &m, filters, preloads, orders, false); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *ApiKey) Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.ApiKey, error) {
+	var ms []*models.ApiKey
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *ApiKey) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.ApiKey, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.ApiKey
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.count(tx, &models.ApiKey{}, filters)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
@@ -0,0 +1,56 @@
+package daos
+
+import (
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+type AssetCrawl struct {
+	DAO
+}
+
+func (d *AssetCrawl) FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.AssetCrawl, error) {
+	var m models.AssetCrawl
+	if err := d.first(tx, &m, map[string][]interface{}{"id = ?": []interface{}{id}}, preloads, nil, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *AssetCrawl) First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.AssetCrawl, error) {
+	var m models.AssetCrawl
+	if err := d.first(tx, &m, filters, preloads, orders, false); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *AssetCrawl) Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.AssetCrawl, error) {
+	var ms []*models.AssetCrawl
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *AssetCrawl) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.AssetCrawl, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.AssetCrawl
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.count(tx, &models.AssetCrawl{}, filters)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
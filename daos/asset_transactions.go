@@ -55,25 +55,68 @@ func (d *AssetTransaction) Find4Page(tx *gorm.DB, filters map[string][]interface
 	return ms, c, nil
 }
 
-func (d *AssetTransaction) GetRPTListingCollection(tx *gorm.DB) ([]*models.NftyRPTListingCollection, error) {
-	var rs []*models.NftyRPTListingCollection
-	err := tx.Raw(`
-	select collection_id, count(1) total
-	from assets
-	where exists(
-				select 1
-				from loans
-				where asset_id = assets.id
-					and loans.status in (?)
-			)
-	group by collection_id
-	`,
-		[]models.LoanStatus{
-			models.LoanStatusNew,
-		},
-	).Find(&rs).Error
+func (d *AssetTransaction) FirstSpec(tx *gorm.DB, spec *Spec, forUpdate bool) (*models.AssetTransaction, error) {
+	var m models.AssetTransaction
+	if err := d.firstSpec(tx, &m, spec, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *AssetTransaction) FindSpec(tx *gorm.DB, spec *Spec, offset int, limit int) ([]*models.AssetTransaction, error) {
+	var ms []*models.AssetTransaction
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *AssetTransaction) Find4PageSpec(tx *gorm.DB, spec *Spec, page int, limit int) ([]*models.AssetTransaction, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.AssetTransaction
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.countSpec(tx, &models.AssetTransaction{}, spec)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+func (d *AssetTransaction) Find4Cursor(tx *gorm.DB, spec *Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.AssetTransaction, *CursorPage, error) {
+	var ms []*models.AssetTransaction
+	page, err := d.find4Cursor(tx, &models.AssetTransaction{}, &ms, spec, sortColumn, desc, cursor, limit, withCount)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return ms, page, nil
+}
+
+// Upsert inserts m or refreshes the sale already stored under its dedup key,
+// m gets the id of the stored row either way
+func (d *AssetTransaction) Upsert(tx *gorm.DB, m *models.AssetTransaction) error {
+	err := tx.
+		Set(
+			"gorm:insert_option",
+			`ON DUPLICATE KEY UPDATE
+				id = LAST_INSERT_ID(id),
+				updated_at = VALUES(updated_at),
+				seller = VALUES(seller),
+				buyer = VALUES(buyer),
+				transaction_at = VALUES(transaction_at),
+				amount = VALUES(amount),
+				currency_id = VALUES(currency_id)`,
+		).
+		Create(m).
+		Error
 	if err != nil {
-		return nil, errs.NewError(err)
+		return err
 	}
-	return rs, nil
+	return nil
 }
@@ -55,25 +55,54 @@ func (d *Asset) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preload
 	return ms, c, nil
 }
 
-func (d *Asset) GetRPTListingCollection(tx *gorm.DB) ([]*models.NftyRPTListingCollection, error) {
-	var rs []*models.NftyRPTListingCollection
-	err := tx.Raw(`
-	select collection_id, count(1) total
-	from assets
-	where exists(
-				select 1
-				from loans
-				where asset_id = assets.id
-					and loans.status in (?)
-			)
-	group by collection_id
-	`,
-		[]models.LoanStatus{
-			models.LoanStatusNew,
-		},
-	).Find(&rs).Error
+func (d *Asset) FirstSpec(tx *gorm.DB, spec *Spec, forUpdate bool) (*models.Asset, error) {
+	var m models.Asset
+	if err := d.firstSpec(tx, &m, spec, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *Asset) FindSpec(tx *gorm.DB, spec *Spec, offset int, limit int) ([]*models.Asset, error) {
+	var ms []*models.Asset
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *Asset) Find4PageSpec(tx *gorm.DB, spec *Spec, page int, limit int) ([]*models.Asset, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.Asset
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.countSpec(tx, &models.Asset{}, spec)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+// UpdateRarity sets the rarity of the asset, the other columns are left as
+// they are
+func (d *Asset) UpdateRarity(tx *gorm.DB, id uint, score float64, rank uint) error {
+	err := tx.Model(&models.Asset{}).
+		Where("id = ?", id).
+		UpdateColumns(
+			map[string]interface{}{
+				"rarity_score": score,
+				"rarity_rank":  rank,
+			},
+		).
+		Error
 	if err != nil {
-		return nil, errs.NewError(err)
+		return errs.NewError(err)
 	}
-	return rs, nil
+	return nil
 }
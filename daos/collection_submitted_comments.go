@@ -0,0 +1,56 @@
+package daos
+
+import (
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+type CollectionSubmittedComment struct {
+	DAO
+}
+
+func (d *CollectionSubmittedComment) FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.CollectionSubmittedComment, error) {
+	var m models.CollectionSubmittedComment
+	if err := d.first(tx, &m, map[string][]interface{}{"id = ?": []interface{}{id}}, preloads, nil, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *CollectionSubmittedComment) First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.CollectionSubmittedComment, error) {
+	var m models.CollectionSubmittedComment
+	if err := d.first(tx, &m, filters, preloads, orders, false); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *CollectionSubmittedComment) Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.CollectionSubmittedComment, error) {
+	var ms []*models.CollectionSubmittedComment
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *CollectionSubmittedComment) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.CollectionSubmittedComment, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.CollectionSubmittedComment
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.count(tx, &models.CollectionSubmittedComment{}, filters)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
@@ -0,0 +1,65 @@
+package daos
+
+import (
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+type CollectionTrait struct {
+	DAO
+}
+
+func (d *CollectionTrait) FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.CollectionTrait, error) {
+	var m models.CollectionTrait
+	if err := d.first(tx, &m, map[string][]interface{}{"id = ?": []interface{}{id}}, preloads, nil, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *CollectionTrait) First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.CollectionTrait, error) {
+	var m models.CollectionTrait
+	if err := d.first(tx, &m, filters, preloads, orders, false); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *CollectionTrait) Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.CollectionTrait, error) {
+	var ms []*models.CollectionTrait
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *CollectionTrait) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.CollectionTrait, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.CollectionTrait
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.count(tx, &models.CollectionTrait{}, filters)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+// DeleteUnscoped removes the row, a soft deleted trait would keep its value in
+// the unique index and block the value from coming back
+func (d *CollectionTrait) DeleteUnscoped(tx *gorm.DB, m *models.CollectionTrait) error {
+	if err := tx.Unscoped().Delete(m).Error; err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
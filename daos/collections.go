@@ -54,3 +54,37 @@ func (d *Collection) Find4Page(tx *gorm.DB, filters map[string][]interface{}, pr
 	}
 	return ms, c, nil
 }
+
+func (d *Collection) FirstSpec(tx *gorm.DB, spec *Spec, forUpdate bool) (*models.Collection, error) {
+	var m models.Collection
+	if err := d.firstSpec(tx, &m, spec, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *Collection) FindSpec(tx *gorm.DB, spec *Spec, offset int, limit int) ([]*models.Collection, error) {
+	var ms []*models.Collection
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *Collection) Find4PageSpec(tx *gorm.DB, spec *Spec, page int, limit int) ([]*models.Collection, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.Collection
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.countSpec(tx, &models.Collection{}, spec)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
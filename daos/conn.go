@@ -0,0 +1,47 @@
+package daos
+
+import (
+	"context"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Conn hands out db handles and transactions so callers do not depend on the
+// package level connection directly
+type Conn interface {
+	DB(ctx context.Context) *gorm.DB
+	WithTransaction(ctx context.Context, callback func(*gorm.DB) error) error
+}
+
+type mainConn struct{}
+
+func NewMainConn() Conn {
+	return &mainConn{}
+}
+
+func (c *mainConn) DB(ctx context.Context) *gorm.DB {
+	return GetDBMainCtx(ctx)
+}
+
+func (c *mainConn) WithTransaction(ctx context.Context, callback func(*gorm.DB) error) error {
+	return WithTransaction(GetDBMainCtx(ctx), callback)
+}
+
+type dbConn struct {
+	db *gorm.DB
+}
+
+// NewConn serves the handles of the given db instead of the main one
+func NewConn(db *gorm.DB) Conn {
+	return &dbConn{
+		db: db,
+	}
+}
+
+func (c *dbConn) DB(ctx context.Context) *gorm.DB {
+	return c.db.New()
+}
+
+func (c *dbConn) WithTransaction(ctx context.Context, callback func(*gorm.DB) error) error {
+	return WithTransaction(c.db.New(), callback)
+}
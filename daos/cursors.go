@@ -0,0 +1,202 @@
+package daos
+
+import (
+	"database/sql/driver"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/jinzhu/gorm"
+)
+
+var cursorColumnRegexp = regexp.MustCompile(`^[a-z_]+$`)
+
+type CursorPage struct {
+	NextCursor string
+	HasMore    bool
+	Count      *uint
+}
+
+type cursorToken struct {
+	Sort string `json:"s"`
+	Key  string `json:"k,omitempty"`
+	Null bool   `json:"n,omitempty"`
+	Time bool   `json:"t,omitempty"`
+	ID   uint   `json:"i"`
+}
+
+func encodeCursor(token *cursorToken) (string, error) {
+	b, err := json.Marshal(token)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func decodeCursor(cursor string, sortColumn string) (*cursorToken, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	var token cursorToken
+	err = json.Unmarshal(b, &token)
+	if err != nil {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	if token.Sort != sortColumn {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	return &token, nil
+}
+
+// setKey keeps the sort value of the last row, times are kept in utc so that
+// the cursor does not depend on the zone of the server or of the connection
+func (t *cursorToken) setKey(v reflect.Value) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			t.Null = true
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch val := v.Interface().(type) {
+	case time.Time:
+		{
+			t.Key = val.UTC().Format(time.RFC3339Nano)
+			t.Time = true
+			return nil
+		}
+	case driver.Valuer:
+		{
+			dv, err := val.Value()
+			if err != nil {
+				return err
+			}
+			if dv == nil {
+				t.Null = true
+				return nil
+			}
+			t.Key = fmt.Sprint(dv)
+			return nil
+		}
+	}
+	t.Key = fmt.Sprint(v.Interface())
+	return nil
+}
+
+// keyValue is the sort value to compare the column with, times go to the
+// driver as time values so it converts them to the zone of the connection
+// like the values it writes
+func (t *cursorToken) keyValue() (interface{}, error) {
+	if !t.Time {
+		return t.Key, nil
+	}
+	v, err := time.Parse(time.RFC3339Nano, t.Key)
+	if err != nil {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	return v.UTC(), nil
+}
+
+func keysetCondition(tableName string, sortColumn string, desc bool, token *cursorToken) (string, []interface{}, error) {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	idCol := fmt.Sprintf("%s.id", tableName)
+	if sortColumn == "id" {
+		return fmt.Sprintf("%s %s ?", idCol, op), []interface{}{token.ID}, nil
+	}
+	col := fmt.Sprintf("%s.%s", tableName, sortColumn)
+	// mysql sorts null values first in asc order and last in desc order
+	if token.Null {
+		if desc {
+			return fmt.Sprintf("(%s is null and %s %s ?)", col, idCol, op), []interface{}{token.ID}, nil
+		}
+		return fmt.Sprintf("((%s is null and %s %s ?) or %s is not null)", col, idCol, op, col), []interface{}{token.ID}, nil
+	}
+	key, err := token.keyValue()
+	if err != nil {
+		return "", nil, err
+	}
+	if desc {
+		return fmt.Sprintf("(%s %s ? or (%s = ? and %s %s ?) or %s is null)", col, op, col, idCol, op, col), []interface{}{key, key, token.ID}, nil
+	}
+	return fmt.Sprintf("(%s %s ? or (%s = ? and %s %s ?))", col, op, col, idCol, op), []interface{}{key, key, token.ID}, nil
+}
+
+func (d *DAO) find4Cursor(tx *gorm.DB, m interface{}, ms interface{}, spec *Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) (*CursorPage, error) {
+	if sortColumn == "" {
+		sortColumn = "id"
+	}
+	if !cursorColumnRegexp.MatchString(sortColumn) {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	scope := tx.NewScope(m)
+	if _, ok := scope.FieldByName(sortColumn); !ok {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	tableName := scope.TableName()
+	token, err := decodeCursor(cursor, sortColumn)
+	if err != nil {
+		return nil, err
+	}
+	page := &CursorPage{}
+	if withCount {
+		count, err := d.countSpec(tx, m, spec)
+		if err != nil {
+			return nil, err
+		}
+		page.Count = &count
+	}
+	query := spec.apply(tx)
+	if token != nil {
+		cond, args, err := keysetCondition(tableName, sortColumn, desc, token)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where(cond, args...)
+	}
+	direction := "asc"
+	if desc {
+		direction = "desc"
+	}
+	if sortColumn != "id" {
+		query = query.Order(fmt.Sprintf("%s.%s %s", tableName, sortColumn, direction))
+	}
+	query = query.Order(fmt.Sprintf("%s.id %s", tableName, direction))
+	query = query.Limit(limit + 1)
+	if err := query.Find(ms).Error; err != nil {
+		return nil, err
+	}
+	rv := reflect.ValueOf(ms).Elem()
+	if rv.Len() > limit {
+		page.HasMore = true
+		rv.Set(rv.Slice(0, limit))
+		if limit > 0 {
+			lastScope := tx.NewScope(rv.Index(limit - 1).Interface())
+			idField, _ := lastScope.FieldByName("id")
+			sortField, _ := lastScope.FieldByName(sortColumn)
+			next := &cursorToken{
+				Sort: sortColumn,
+				ID:   uint(idField.Field.Uint()),
+			}
+			err := next.setKey(sortField.Field)
+			if err != nil {
+				return nil, err
+			}
+			page.NextCursor, err = encodeCursor(next)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return page, nil
+}
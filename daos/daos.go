@@ -3,6 +3,7 @@ package daos
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/czConstant/constant-nftylend-api/errs"
 	"github.com/jinzhu/gorm"
@@ -40,6 +41,49 @@ func WithTransaction(dbConn *gorm.DB, callback func(*gorm.DB) error) (err error)
 	return nil
 }
 
+// map keys are sorted so the generated sql does not depend on map iteration order
+func sortedKeys(m map[string][]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func applyFilters(query *gorm.DB, filters map[string][]interface{}) *gorm.DB {
+	for _, k := range sortedKeys(filters) {
+		if v := filters[k]; v != nil {
+			query = query.Where(k, v...)
+		} else {
+			query = query.Where(k)
+		}
+	}
+	return query
+}
+
+func applyPreloads(query *gorm.DB, preloads map[string][]interface{}) *gorm.DB {
+	for _, k := range sortedKeys(preloads) {
+		if v := preloads[k]; v != nil {
+			query = query.Preload(k, v...)
+		} else {
+			query = query.Preload(k)
+		}
+	}
+	return query
+}
+
+func applyJoins(query *gorm.DB, joins map[string][]interface{}) *gorm.DB {
+	for _, k := range sortedKeys(joins) {
+		if v := joins[k]; v != nil {
+			query = query.Joins(k, v...)
+		} else {
+			query = query.Joins(k)
+		}
+	}
+	return query
+}
+
 type DAO struct {
 }
 
@@ -66,20 +110,8 @@ func (d *DAO) Delete(tx *gorm.DB, m interface{}) error {
 
 func (d *DAO) first(tx *gorm.DB, m interface{}, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, forUpdate bool) error {
 	query := tx
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
-	for k, v := range preloads {
-		if v != nil {
-			query = query.Preload(k, v...)
-		} else {
-			query = query.Preload(k)
-		}
-	}
+	query = applyFilters(query, filters)
+	query = applyPreloads(query, preloads)
 	if orders != nil && len(orders) > 0 {
 		for _, v := range orders {
 			query = query.Order(v)
@@ -96,20 +128,8 @@ func (d *DAO) first(tx *gorm.DB, m interface{}, filters map[string][]interface{}
 
 func (d *DAO) find(tx *gorm.DB, ms interface{}, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int, forUpdate bool) error {
 	query := tx
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
-	for k, v := range preloads {
-		if v != nil {
-			query = query.Preload(k, v...)
-		} else {
-			query = query.Preload(k)
-		}
-	}
+	query = applyFilters(query, filters)
+	query = applyPreloads(query, preloads)
 	if orders != nil && len(orders) > 0 {
 		for _, v := range orders {
 			query = query.Order(v)
@@ -132,13 +152,7 @@ func (d *DAO) find(tx *gorm.DB, ms interface{}, filters map[string][]interface{}
 
 func (d *DAO) count(tx *gorm.DB, m interface{}, filters map[string][]interface{}) (uint, error) {
 	query := tx
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
+	query = applyFilters(query, filters)
 	var count uint
 	if err := query.Model(m).Count(&count).Error; err != nil {
 		return 0, err
@@ -148,27 +162,9 @@ func (d *DAO) count(tx *gorm.DB, m interface{}, filters map[string][]interface{}
 
 func (d *DAO) findJoin(tx *gorm.DB, ms interface{}, joins map[string][]interface{}, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int, forUpdate bool) error {
 	query := tx
-	for k, v := range joins {
-		if v != nil {
-			query = query.Joins(k, v...)
-		} else {
-			query = query.Joins(k)
-		}
-	}
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
-	for k, v := range preloads {
-		if v != nil {
-			query = query.Preload(k, v...)
-		} else {
-			query = query.Preload(k)
-		}
-	}
+	query = applyJoins(query, joins)
+	query = applyFilters(query, filters)
+	query = applyPreloads(query, preloads)
 	for _, v := range orders {
 		query = query.Order(v)
 	}
@@ -189,20 +185,8 @@ func (d *DAO) findJoin(tx *gorm.DB, ms interface{}, joins map[string][]interface
 
 func (d *DAO) countJoin(tx *gorm.DB, m interface{}, joins map[string][]interface{}, filters map[string][]interface{}) (uint, error) {
 	query := tx
-	for k, v := range joins {
-		if v != nil {
-			query = query.Joins(k, v...)
-		} else {
-			query = query.Joins(k)
-		}
-	}
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
+	query = applyJoins(query, joins)
+	query = applyFilters(query, filters)
 	var count uint
 	if err := query.Model(m).Count(&count).Error; err != nil {
 		return 0, err
@@ -214,27 +198,9 @@ func (d *DAO) findJoin4Page(tx *gorm.DB, m interface{}, ms interface{}, joins ma
 	var count uint
 	offset := page*limit - limit
 	query := tx
-	for k, v := range joins {
-		if v != nil {
-			query = query.Joins(k, v...)
-		} else {
-			query = query.Joins(k)
-		}
-	}
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
-	for k, v := range preloads {
-		if v != nil {
-			query = query.Preload(k, v...)
-		} else {
-			query = query.Preload(k)
-		}
-	}
+	query = applyJoins(query, joins)
+	query = applyFilters(query, filters)
+	query = applyPreloads(query, preloads)
 	if orders != nil && len(orders) > 0 {
 		for _, v := range orders {
 			query = query.Order(v)
@@ -255,20 +221,8 @@ func (d *DAO) findJoin4Page(tx *gorm.DB, m interface{}, ms interface{}, joins ma
 
 func (d *DAO) findAll(tx *gorm.DB, ms interface{}, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, forUpdate bool) error {
 	query := tx
-	for k, v := range filters {
-		if v != nil {
-			query = query.Where(k, v...)
-		} else {
-			query = query.Where(k)
-		}
-	}
-	for k, v := range preloads {
-		if v != nil {
-			query = query.Preload(k, v...)
-		} else {
-			query = query.Preload(k)
-		}
-	}
+	query = applyFilters(query, filters)
+	query = applyPreloads(query, preloads)
 	if orders != nil && len(orders) > 0 {
 		for _, v := range orders {
 			query = query.Order(v)
@@ -282,3 +236,40 @@ func (d *DAO) findAll(tx *gorm.DB, ms interface{}, filters map[string][]interfac
 	}
 	return nil
 }
+
+func (d *DAO) firstSpec(tx *gorm.DB, m interface{}, spec *Spec, forUpdate bool) error {
+	query := spec.apply(tx)
+	if forUpdate {
+		query = query.Set("gorm:query_option", "FOR UPDATE")
+	}
+	if err := query.First(m).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *DAO) findSpec(tx *gorm.DB, ms interface{}, spec *Spec, offset int, limit int, forUpdate bool) error {
+	query := spec.apply(tx)
+	if offset >= 0 {
+		query = query.Offset(offset)
+	}
+	if limit >= 0 {
+		query = query.Limit(limit)
+	}
+	if forUpdate {
+		query = query.Set("gorm:query_option", "FOR UPDATE")
+	}
+	if err := query.Find(ms).Error; err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *DAO) countSpec(tx *gorm.DB, m interface{}, spec *Spec) (uint, error) {
+	query := spec.applyFilters(tx)
+	var count uint
+	if err := query.Model(m).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
@@ -54,3 +54,46 @@ func (d *LoanOffer) Find4Page(tx *gorm.DB, filters map[string][]interface{}, pre
 	}
 	return ms, c, nil
 }
+
+func (d *LoanOffer) FirstSpec(tx *gorm.DB, spec *Spec, forUpdate bool) (*models.LoanOffer, error) {
+	var m models.LoanOffer
+	if err := d.firstSpec(tx, &m, spec, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *LoanOffer) FindSpec(tx *gorm.DB, spec *Spec, offset int, limit int) ([]*models.LoanOffer, error) {
+	var ms []*models.LoanOffer
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *LoanOffer) Find4PageSpec(tx *gorm.DB, spec *Spec, page int, limit int) ([]*models.LoanOffer, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.LoanOffer
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.countSpec(tx, &models.LoanOffer{}, spec)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+func (d *LoanOffer) Find4Cursor(tx *gorm.DB, spec *Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.LoanOffer, *CursorPage, error) {
+	var ms []*models.LoanOffer
+	page, err := d.find4Cursor(tx, &models.LoanOffer{}, &ms, spec, sortColumn, desc, cursor, limit, withCount)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return ms, page, nil
+}
@@ -54,3 +54,46 @@ func (d *LoanTransaction) Find4Page(tx *gorm.DB, filters map[string][]interface{
 	}
 	return ms, c, nil
 }
+
+func (d *LoanTransaction) FirstSpec(tx *gorm.DB, spec *Spec, forUpdate bool) (*models.LoanTransaction, error) {
+	var m models.LoanTransaction
+	if err := d.firstSpec(tx, &m, spec, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *LoanTransaction) FindSpec(tx *gorm.DB, spec *Spec, offset int, limit int) ([]*models.LoanTransaction, error) {
+	var ms []*models.LoanTransaction
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *LoanTransaction) Find4PageSpec(tx *gorm.DB, spec *Spec, page int, limit int) ([]*models.LoanTransaction, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.LoanTransaction
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.countSpec(tx, &models.LoanTransaction{}, spec)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+func (d *LoanTransaction) Find4Cursor(tx *gorm.DB, spec *Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.LoanTransaction, *CursorPage, error) {
+	var ms []*models.LoanTransaction
+	page, err := d.find4Cursor(tx, &models.LoanTransaction{}, &ms, spec, sortColumn, desc, cursor, limit, withCount)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return ms, page, nil
+}
@@ -1,6 +1,9 @@
 package daos
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/czConstant/constant-nftylend-api/errs"
 	"github.com/czConstant/constant-nftylend-api/models"
 	"github.com/jinzhu/gorm"
@@ -54,37 +57,157 @@ func (d *Loan) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads
 	}
 	return ms, c, nil
 }
-func (d *Loan) GetRPTCollectionLoan(tx *gorm.DB, collectionId uint) (*models.NftyRPTCollectionLoan, error) {
-	var rs models.NftyRPTCollectionLoan
-	err := tx.Raw(`
-	select (
-		select sum(nll.offer_principal_amount)
-		from loans nll
-				 join assets nla on nll.asset_id = nla.id
-		where collection_id = ?
-		  and nll.offer_principal_amount > 0
-	) total_volume,
-	(
-		select count(1)
-		from loans nll
-				 join assets nla on nll.asset_id = nla.id
-		where collection_id = ?
-		  and nll.offer_principal_amount > 0
-	) total_listed,
-	(
-		select avg(nll.offer_principal_amount)
-		from loans nll
-				 join assets nla on nll.asset_id = nla.id
-		where collection_id = ?
-		  and nll.offer_principal_amount > 0
-	) avg_24h_amount;
+
+func (d *Loan) FirstSpec(tx *gorm.DB, spec *Spec, forUpdate bool) (*models.Loan, error) {
+	var m models.Loan
+	if err := d.firstSpec(tx, &m, spec, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *Loan) FindSpec(tx *gorm.DB, spec *Spec, offset int, limit int) ([]*models.Loan, error) {
+	var ms []*models.Loan
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *Loan) Find4PageSpec(tx *gorm.DB, spec *Spec, page int, limit int) ([]*models.Loan, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.Loan
+	if err := d.findSpec(tx, &ms, spec, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.countSpec(tx, &models.Loan{}, spec)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+func (d *Loan) Find4Cursor(tx *gorm.DB, spec *Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.Loan, *CursorPage, error) {
+	var ms []*models.Loan
+	page, err := d.find4Cursor(tx, &models.Loan{}, &ms, spec, sortColumn, desc, cursor, limit, withCount)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return ms, page, nil
+}
+
+// GetRPTCollectionStats aggregates the loans of the collections in one pass,
+// of every collection when collectionIDs is empty. The period figures count
+// the loans listed or funded in [start, end)
+func (d *Loan) GetRPTCollectionStats(tx *gorm.DB, collectionIDs []uint, start time.Time, end time.Time) ([]*models.NftyRPTCollectionStat, error) {
+	var rs []*models.NftyRPTCollectionStat
+	collectionCond := ""
+	if len(collectionIDs) > 0 {
+		collectionCond = "and nla.collection_id in (?)"
+	}
+	query := fmt.Sprintf(`
+	select nla.collection_id,
+		   sum(case when nll.created_at >= ? and nll.created_at < ? then 1 else 0 end) new_listings,
+		   sum(case when nll.status = ? then 1 else 0 end) active_listings,
+		   coalesce(min(case when nll.status = ? then nll.principal_amount end), 0) floor_price,
+		   sum(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then 1 else 0 end) funded_count,
+		   coalesce(sum(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then nll.offer_principal_amount end), 0) funded_volume,
+		   coalesce(avg(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then nll.offer_principal_amount end), 0) avg_principal,
+		   coalesce(avg(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then nll.offer_interest_rate end), 0) avg_interest_rate,
+		   sum(case when nll.status in (?, ?) and nll.finished_at < ? then 1 else 0 end) finished_count,
+		   sum(case when nll.status = ? and nll.finished_at < ? then 1 else 0 end) defaulted_count,
+		   sum(case when nll.offer_started_at < ? then 1 else 0 end) total_funded_count,
+		   coalesce(sum(case when nll.offer_started_at < ? then nll.offer_principal_amount end), 0) total_volume
+	from loans nll
+			 join assets nla on nll.asset_id = nla.id
+	where nll.deleted_at is null
+	  %s
+	group by nla.collection_id;
+	`,
+		collectionCond,
+	)
+	args := []interface{}{
+		start, end,
+		models.LoanStatusNew,
+		models.LoanStatusNew,
+		start, end,
+		start, end,
+		start, end,
+		start, end,
+		models.LoanStatusDone, models.LoanStatusLiquidated, end,
+		models.LoanStatusLiquidated, end,
+		end,
+		end,
+	}
+	if len(collectionIDs) > 0 {
+		args = append(args, collectionIDs)
+	}
+	err := tx.Raw(query, args...).Find(&rs).Error
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return rs, nil
+}
+
+// GetRPTCollectionCurrencyStats aggregates the price figures of the loans of
+// the collections by loan currency, amounts of different currencies can not be
+// added or compared. The funded figures count the loans funded in [start, end),
+// the total volume the loans funded before end
+func (d *Loan) GetRPTCollectionCurrencyStats(tx *gorm.DB, collectionIDs []uint, start time.Time, end time.Time) ([]*models.NftyRPTCollectionCurrencyStat, error) {
+	var rs []*models.NftyRPTCollectionCurrencyStat
+	collectionCond := ""
+	if len(collectionIDs) > 0 {
+		collectionCond = "and nla.collection_id in (?)"
+	}
+	query := fmt.Sprintf(`
+	select nla.collection_id,
+		   nll.currency_id,
+		   sum(case when nll.status = ? then 1 else 0 end) active_listings,
+		   coalesce(min(case when nll.status = ? then nll.principal_amount end), 0) floor_price,
+		   sum(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then 1 else 0 end) funded_count,
+		   coalesce(sum(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then nll.offer_principal_amount end), 0) funded_volume,
+		   coalesce(avg(case when nll.offer_started_at >= ? and nll.offer_started_at < ? then nll.offer_principal_amount end), 0) avg_principal,
+		   coalesce(sum(case when nll.offer_started_at < ? then nll.offer_principal_amount end), 0) total_volume
+	from loans nll
+			 join assets nla on nll.asset_id = nla.id
+	where nll.deleted_at is null
+	  %s
+	group by nla.collection_id, nll.currency_id;
 	`,
-		collectionId,
-		collectionId,
-		collectionId,
-	).Find(&rs).Error
+		collectionCond,
+	)
+	args := []interface{}{
+		models.LoanStatusNew,
+		models.LoanStatusNew,
+		start, end,
+		start, end,
+		start, end,
+		end,
+	}
+	if len(collectionIDs) > 0 {
+		args = append(args, collectionIDs)
+	}
+	err := tx.Raw(query, args...).Find(&rs).Error
 	if err != nil {
 		return nil, errs.NewError(err)
 	}
-	return &rs, nil
+	return rs, nil
+}
+
+// UpdateAssetRarityScore copies the rarity score of the asset on its new loans
+func (d *Loan) UpdateAssetRarityScore(tx *gorm.DB, assetID uint, score float64) error {
+	err := tx.Model(&models.Loan{}).
+		Where("asset_id = ?", assetID).
+		Where("status = ?", models.LoanStatusNew).
+		UpdateColumn("asset_rarity_score", score).
+		Error
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
 }
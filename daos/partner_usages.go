@@ -0,0 +1,127 @@
+package daos
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+type PartnerUsage struct {
+	DAO
+}
+
+func (d *PartnerUsage) FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.PartnerUsage, error) {
+	var m models.PartnerUsage
+	if err := d.first(tx, &m, map[string][]interface{}{"id = ?": []interface{}{id}}, preloads, nil, forUpdate); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *PartnerUsage) First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.PartnerUsage, error) {
+	var m models.PartnerUsage
+	if err := d.first(tx, &m, filters, preloads, orders, false); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *PartnerUsage) Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.PartnerUsage, error) {
+	var ms []*models.PartnerUsage
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *PartnerUsage) Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.PartnerUsage, uint, error) {
+	var (
+		offset = (page - 1) * limit
+	)
+	var ms []*models.PartnerUsage
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	c, err := d.count(tx, &models.PartnerUsage{}, filters)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, c, nil
+}
+
+// Meter counts a request on the usage of the partner on date, once requests
+// reach quota the request is counted as rejected instead, 0 is no quota. Each
+// statement is atomic so concurrent requests can not go over the quota
+func (d *PartnerUsage) Meter(tx *gorm.DB, partnerID uint, date string, quota uint) (bool, error) {
+	now := time.Now()
+	take := func() (bool, error) {
+		rs := tx.Exec(`
+		update partner_usages
+		set requests = requests + 1, updated_at = ?
+		where partner_id = ?
+		  and date = ?
+		  and (? = 0 or requests < ?)
+		`,
+			now,
+			partnerID,
+			date,
+			quota,
+			quota,
+		)
+		if rs.Error != nil {
+			return false, errs.NewError(rs.Error)
+		}
+		return rs.RowsAffected > 0, nil
+	}
+	allowed, err := take()
+	if err != nil {
+		return false, err
+	}
+	if allowed {
+		return true, nil
+	}
+	// the first request of the day creates the row, a concurrent one may have
+	// created it already
+	err = tx.Exec(`
+	insert into partner_usages (created_at, updated_at, partner_id, date, requests, rejected)
+	values (?, ?, ?, ?, 0, 0)
+	on duplicate key update partner_id = partner_id
+	`,
+		now,
+		now,
+		partnerID,
+		date,
+	).Error
+	if err != nil {
+		return false, errs.NewError(err)
+	}
+	allowed, err = take()
+	if err != nil {
+		return false, err
+	}
+	if allowed {
+		return true, nil
+	}
+	err = tx.Exec(`
+	update partner_usages
+	set rejected = rejected + 1, updated_at = ?
+	where partner_id = ?
+	  and date = ?
+	`,
+		now,
+		partnerID,
+		date,
+	).Error
+	if err != nil {
+		return false, errs.NewError(err)
+	}
+	return false, nil
+}
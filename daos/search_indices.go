@@ -0,0 +1,135 @@
+package daos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+type SearchIndex struct {
+	DAO
+}
+
+func (d *SearchIndex) First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.SearchIndex, error) {
+	var m models.SearchIndex
+	if err := d.first(tx, &m, filters, preloads, orders, false); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (d *SearchIndex) Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.SearchIndex, error) {
+	var ms []*models.SearchIndex
+	if err := d.find(tx, &ms, filters, preloads, orders, offset, limit, false); err != nil {
+		return nil, err
+	}
+	return ms, nil
+}
+
+func (d *SearchIndex) DeleteByRef(tx *gorm.DB, typ models.SearchIndexType, refID uint, fields []models.SearchIndexField) error {
+	query := tx.Unscoped().
+		Where("type = ?", typ).
+		Where("ref_id = ?", refID)
+	if len(fields) > 0 {
+		query = query.Where("field in (?)", fields)
+	}
+	if err := query.Delete(&models.SearchIndex{}).Error; err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// DeleteByRefRange deletes the rows of the refs in (fromRefID, toRefID], or of
+// every ref after fromRefID when toRefID is 0
+func (d *SearchIndex) DeleteByRefRange(tx *gorm.DB, typ models.SearchIndexType, fromRefID uint, toRefID uint) error {
+	query := tx.Unscoped().
+		Where("type = ?", typ).
+		Where("ref_id > ?", fromRefID)
+	if toRefID > 0 {
+		query = query.Where("ref_id <= ?", toRefID)
+	}
+	if err := query.Delete(&models.SearchIndex{}).Error; err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// Search ranks the refs whose keywords prefix match every token, a keyword
+// equal to the token scores twice its weight. Refs of disabled collections
+// are left out
+func (d *SearchIndex) Search(tx *gorm.DB, tokens []string, types []models.SearchIndexType, limit int) ([]*models.SearchResult, error) {
+	if len(tokens) == 0 {
+		return []*models.SearchResult{}, nil
+	}
+	matches := []string{}
+	args := []interface{}{}
+	for i, token := range tokens {
+		matches = append(matches, `
+		select ? token, type, ref_id, title, case when keyword = ? then weight * 2 else weight end score
+		from search_indices
+		where deleted_at is null
+		  and keyword like ?
+		  and type in (?)`)
+		args = append(args, i, token, fmt.Sprintf("%s%%", escapeLike(token)), types)
+	}
+	args = append(
+		args,
+		models.SearchIndexTypeCollection,
+		models.SearchIndexTypeAsset,
+		models.SearchIndexTypeLoan,
+		len(tokens),
+		limit,
+	)
+	var rs []*models.SearchResult
+	err := tx.Raw(fmt.Sprintf(`
+	select type, ref_id, max(title) title, sum(score) score
+	from (%s
+	) matches
+	where (matches.type = ? and exists(
+			select 1
+			from collections c
+			where c.id = matches.ref_id
+			  and c.deleted_at is null
+			  and c.enabled = 1))
+	   or (matches.type = ? and exists(
+			select 1
+			from assets a
+					 join collections c on a.collection_id = c.id
+			where a.id = matches.ref_id
+			  and a.deleted_at is null
+			  and c.deleted_at is null
+			  and c.enabled = 1))
+	   or (matches.type = ? and exists(
+			select 1
+			from loans l
+					 join assets a on l.asset_id = a.id
+					 join collections c on a.collection_id = c.id
+			where l.id = matches.ref_id
+			  and l.deleted_at is null
+			  and c.deleted_at is null
+			  and c.enabled = 1))
+	group by type, ref_id
+	having count(distinct token) = ?
+	order by score desc, ref_id desc
+	limit ?
+	`,
+		strings.Join(matches, `
+		union all`),
+	),
+		args...,
+	).Scan(&rs).Error
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return rs, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
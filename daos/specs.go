@@ -0,0 +1,441 @@
+package daos
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+// Predicate is a single sql condition with its bound args. Column, Op and Value
+// are set for simple comparisons and Name for the shared subqueries below, so
+// predicates can also be evaluated without a database
+type Predicate struct {
+	Query  string
+	Args   []interface{}
+	Column string
+	Op     string
+	Value  interface{}
+	Name   string
+	Parts  []Predicate
+}
+
+func Raw(query string, args ...interface{}) Predicate {
+	return Predicate{Query: query, Args: args}
+}
+
+func compare(column string, op string, value interface{}) Predicate {
+	query := fmt.Sprintf("%s %s ?", column, op)
+	if op == "in" || op == "not in" {
+		query = fmt.Sprintf("%s %s (?)", column, op)
+	}
+	return Predicate{
+		Query:  query,
+		Args:   []interface{}{value},
+		Column: column,
+		Op:     op,
+		Value:  value,
+	}
+}
+
+func named(name string, query string, args ...interface{}) Predicate {
+	p := Raw(query, args...)
+	p.Name = name
+	return p
+}
+
+func Eq(column string, value interface{}) Predicate {
+	return compare(column, "=", value)
+}
+
+func Gte(column string, value interface{}) Predicate {
+	return compare(column, ">=", value)
+}
+
+func Lte(column string, value interface{}) Predicate {
+	return compare(column, "<=", value)
+}
+
+func Gt(column string, value interface{}) Predicate {
+	return compare(column, ">", value)
+}
+
+func In(column string, values interface{}) Predicate {
+	return compare(column, "in", values)
+}
+
+func NotIn(column string, values interface{}) Predicate {
+	return compare(column, "not in", values)
+}
+
+func Like(column string, value string) Predicate {
+	return compare(column, "like", value)
+}
+
+func And(ps ...Predicate) Predicate {
+	return combine("and", ps)
+}
+
+func Or(ps ...Predicate) Predicate {
+	return combine("or", ps)
+}
+
+func combine(op string, ps []Predicate) Predicate {
+	queries := []string{}
+	args := []interface{}{}
+	for _, p := range ps {
+		queries = append(queries, fmt.Sprintf("(%s)", p.Query))
+		args = append(args, p.Args...)
+	}
+	p := Raw(strings.Join(queries, fmt.Sprintf(" %s ", op)), args...)
+	p.Op = op
+	p.Parts = ps
+	return p
+}
+
+type preload struct {
+	column     string
+	conditions []interface{}
+}
+
+// Spec composes joins, predicates, preloads and orders in the order they are added
+type Spec struct {
+	joins    []Predicate
+	wheres   []Predicate
+	preloads []preload
+	orders   []string
+}
+
+func NewSpec() *Spec {
+	return &Spec{}
+}
+
+func (s *Spec) Where(ps ...Predicate) *Spec {
+	s.wheres = append(s.wheres, ps...)
+	return s
+}
+
+func (s *Spec) Join(query string, args ...interface{}) *Spec {
+	s.joins = append(s.joins, Raw(query, args...))
+	return s
+}
+
+func (s *Spec) Preload(column string, conditions ...interface{}) *Spec {
+	s.preloads = append(s.preloads, preload{column: column, conditions: conditions})
+	return s
+}
+
+func (s *Spec) Order(orders ...string) *Spec {
+	s.orders = append(s.orders, orders...)
+	return s
+}
+
+func (s *Spec) Predicates() []Predicate {
+	if s == nil {
+		return nil
+	}
+	return s.wheres
+}
+
+func (s *Spec) Preloads() map[string][]interface{} {
+	ps := map[string][]interface{}{}
+	if s == nil {
+		return ps
+	}
+	for _, p := range s.preloads {
+		ps[p.column] = p.conditions
+	}
+	return ps
+}
+
+func (s *Spec) Orders() []string {
+	if s == nil {
+		return nil
+	}
+	return s.orders
+}
+
+func (s *Spec) applyFilters(query *gorm.DB) *gorm.DB {
+	if s == nil {
+		return query
+	}
+	for _, j := range s.joins {
+		query = query.Joins(j.Query, j.Args...)
+	}
+	for _, w := range s.wheres {
+		query = query.Where(w.Query, w.Args...)
+	}
+	return query
+}
+
+func (s *Spec) apply(query *gorm.DB) *gorm.DB {
+	if s == nil {
+		return query
+	}
+	query = s.applyFilters(query)
+	for _, p := range s.preloads {
+		query = query.Preload(p.column, p.conditions...)
+	}
+	for _, o := range s.orders {
+		query = query.Order(o)
+	}
+	return query
+}
+
+// shared correlated subqueries
+
+const (
+	PredicateLoanInCollection       = "loan_in_collection"
+	PredicateLoanOfferOfBorrower    = "loan_offer_of_borrower"
+	PredicateLoanTransactionOfAsset = "loan_transaction_of_asset"
+)
+
+func LoanInCollection(collectionID uint) Predicate {
+	return named(PredicateLoanInCollection, `
+	exists(
+		select 1
+		from assets
+		where loans.asset_id = assets.id
+		  and assets.collection_id = ?
+	)
+	`, collectionID)
+}
+
+func LoanOfferOfBorrower(borrower string) Predicate {
+	return named(PredicateLoanOfferOfBorrower, `
+	exists(
+		select 1
+		from loans
+		where loan_offers.loan_id = loans.id
+		  and loans.owner = ?
+	)
+	`, borrower)
+}
+
+func LoanTransactionOfAsset(assetID uint) Predicate {
+	return named(PredicateLoanTransactionOfAsset, `
+	exists(
+		select 1
+		from loans
+		where loan_transactions.loan_id = loans.id
+		  and loans.asset_id = ?
+	)
+	`, assetID)
+}
+
+// typed filters, zero values are ignored
+
+type LoanFilter struct {
+	IDs                []uint
+	ExcludeIDs         []uint
+	Owner              string
+	Lender             string
+	AssetID            uint
+	CollectionID       uint
+	Statuses           []models.LoanStatus
+	DataLoanAddress    string
+	MinPrincipalAmount float64
+	MaxPrincipalAmount float64
+	MinDuration        uint
+	MaxDuration        uint
+	MinInterestRate    float64
+	MaxInterestRate    float64
+}
+
+func (f *LoanFilter) Spec() *Spec {
+	s := NewSpec()
+	if len(f.IDs) > 0 {
+		s.Where(In("loans.id", f.IDs))
+	}
+	if len(f.Statuses) > 0 {
+		s.Where(In("loans.status", f.Statuses))
+	}
+	if f.Owner != "" {
+		s.Where(Eq("loans.owner", f.Owner))
+	}
+	if f.Lender != "" {
+		s.Where(Eq("loans.lender", f.Lender))
+	}
+	if f.AssetID > 0 {
+		s.Where(Eq("loans.asset_id", f.AssetID))
+	}
+	if f.CollectionID > 0 {
+		s.Where(LoanInCollection(f.CollectionID))
+	}
+	if f.DataLoanAddress != "" {
+		s.Where(Eq("loans.data_loan_address", f.DataLoanAddress))
+	}
+	if f.MinPrincipalAmount > 0 {
+		s.Where(Gte("loans.principal_amount", f.MinPrincipalAmount))
+	}
+	if f.MaxPrincipalAmount > 0 {
+		s.Where(Lte("loans.principal_amount", f.MaxPrincipalAmount))
+	}
+	if f.MinDuration > 0 {
+		s.Where(Gte("loans.duration", f.MinDuration))
+	}
+	if f.MaxDuration > 0 {
+		s.Where(Lte("loans.duration", f.MaxDuration))
+	}
+	if f.MinInterestRate > 0 {
+		s.Where(Gte("loans.interest_rate", f.MinInterestRate))
+	}
+	if f.MaxInterestRate > 0 {
+		s.Where(Lte("loans.interest_rate", f.MaxInterestRate))
+	}
+	if len(f.ExcludeIDs) > 0 {
+		s.Where(NotIn("loans.id", f.ExcludeIDs))
+	}
+	return s
+}
+
+type LoanOfferFilter struct {
+	LoanID           uint
+	Borrower         string
+	Lender           string
+	Statuses         []models.LoanOfferStatus
+	DataOfferAddress string
+}
+
+func (f *LoanOfferFilter) Spec() *Spec {
+	s := NewSpec()
+	if f.LoanID > 0 {
+		s.Where(Eq("loan_offers.loan_id", f.LoanID))
+	}
+	if f.Borrower != "" {
+		s.Where(LoanOfferOfBorrower(f.Borrower))
+	}
+	if f.Lender != "" {
+		s.Where(Eq("loan_offers.lender", f.Lender))
+	}
+	if len(f.Statuses) > 0 {
+		s.Where(In("loan_offers.status", f.Statuses))
+	}
+	if f.DataOfferAddress != "" {
+		s.Where(Eq("loan_offers.data_offer_address", f.DataOfferAddress))
+	}
+	return s
+}
+
+type LoanTransactionFilter struct {
+	LoanID  uint
+	AssetID uint
+	Types   []models.LoanTransactionType
+}
+
+func (f *LoanTransactionFilter) Spec() *Spec {
+	s := NewSpec()
+	if f.LoanID > 0 {
+		s.Where(Eq("loan_transactions.loan_id", f.LoanID))
+	}
+	if f.AssetID > 0 {
+		s.Where(LoanTransactionOfAsset(f.AssetID))
+	}
+	if len(f.Types) > 0 {
+		s.Where(In("loan_transactions.type", f.Types))
+	}
+	return s
+}
+
+type AssetFilter struct {
+	IDs                 []uint
+	CollectionID        uint
+	SeoURL              string
+	ContractAddress     string
+	TestContractAddress string
+}
+
+func (f *AssetFilter) Spec() *Spec {
+	s := NewSpec()
+	if len(f.IDs) > 0 {
+		s.Where(In("assets.id", f.IDs))
+	}
+	if f.CollectionID > 0 {
+		s.Where(Eq("assets.collection_id", f.CollectionID))
+	}
+	if f.SeoURL != "" {
+		s.Where(Eq("assets.seo_url", f.SeoURL))
+	}
+	if f.ContractAddress != "" {
+		s.Where(Eq("assets.contract_address", f.ContractAddress))
+	}
+	if f.TestContractAddress != "" {
+		s.Where(Eq("assets.test_contract_address", f.TestContractAddress))
+	}
+	return s
+}
+
+type AssetTransactionFilter struct {
+	AssetID uint
+	Sources []string
+}
+
+func (f *AssetTransactionFilter) Spec() *Spec {
+	s := NewSpec()
+	if f.AssetID > 0 {
+		s.Where(Eq("asset_transactions.asset_id", f.AssetID))
+	}
+	if len(f.Sources) > 0 {
+		s.Where(In("asset_transactions.source", f.Sources))
+	}
+	return s
+}
+
+type CollectionFilter struct {
+	IDs                   []uint
+	SeoURL                string
+	Name                  string
+	CreatorLike           string
+	OriginNetwork         models.Chain
+	OriginContractAddress string
+	Enabled               *bool
+	// AnyNetwork matches the network of the collection or its origin network
+	AnyNetwork        models.Chain
+	Verified          *bool
+	HasActiveListings bool
+	PriceCurrencyID   uint
+}
+
+func (f *CollectionFilter) Spec() *Spec {
+	s := NewSpec()
+	if len(f.IDs) > 0 {
+		s.Where(In("collections.id", f.IDs))
+	}
+	if f.SeoURL != "" {
+		s.Where(Eq("collections.seo_url", f.SeoURL))
+	}
+	if f.Name != "" {
+		s.Where(Eq("collections.name", f.Name))
+	}
+	if f.CreatorLike != "" {
+		s.Where(Like("collections.creator", fmt.Sprintf("%%%s%%", f.CreatorLike)))
+	}
+	if f.OriginNetwork != "" {
+		s.Where(Eq("collections.origin_network", f.OriginNetwork))
+	}
+	if f.OriginContractAddress != "" {
+		s.Where(Eq("collections.origin_contract_address", f.OriginContractAddress))
+	}
+	if f.Enabled != nil {
+		s.Where(Eq("collections.enabled", *f.Enabled))
+	}
+	if f.AnyNetwork != "" {
+		s.Where(
+			Or(
+				Eq("collections.network", f.AnyNetwork),
+				Eq("collections.origin_network", f.AnyNetwork),
+			),
+		)
+	}
+	if f.Verified != nil {
+		s.Where(Eq("collections.verified", *f.Verified))
+	}
+	if f.HasActiveListings {
+		s.Where(Gt("collections.listing_count", 0))
+	}
+	if f.PriceCurrencyID > 0 {
+		s.Where(Eq("collections.price_currency_id", f.PriceCurrencyID))
+	}
+	return s
+}
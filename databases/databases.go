@@ -39,6 +39,19 @@ func MigrateDBMain(db *gorm.DB) error {
 		(*models.LoanOffer)(nil),
 		(*models.LoanTransaction)(nil),
 		(*models.Instruction)(nil),
+		(*models.SearchIndex)(nil),
+		(*models.User)(nil),
+		(*models.UserSession)(nil),
+		(*models.CollectionSubmittedComment)(nil),
+		(*models.ApiKey)(nil),
+		(*models.UserRole)(nil),
+		(*models.AuditLog)(nil),
+		(*models.Partner)(nil),
+		(*models.PartnerUsage)(nil),
+		(*models.AssetCrawl)(nil),
+		(*models.CollectionTrait)(nil),
+		(*models.CollectionStat)(nil),
+		(*models.UserNonce)(nil),
 	}
 	if err := db.AutoMigrate(allTables...).Error; err != nil {
 		return err
@@ -0,0 +1,125 @@
+package memdb
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+	"reflect"
+
+	"github.com/czConstant/constant-nftylend-api/databases"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/jinzhu/gorm"
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+)
+
+const dbName = "nftylend"
+
+func init() {
+	// the engine logs every failed query, the callers see them as errors
+	logrus.SetLevel(logrus.FatalLevel)
+	gorm.RegisterDialect(dbName, &dialect{})
+}
+
+// dialect is the mysql dialect reading indexes from information_schema, the
+// engine ignores the where clause of show indexes so the mysql dialect takes
+// any index of a table for the one it migrates and skips creating it
+type dialect struct {
+	gorm.Dialect
+	db gorm.SQLCommon
+}
+
+func (d *dialect) SetDB(db gorm.SQLCommon) {
+	m, _ := gorm.GetDialect("mysql")
+	d.Dialect = reflect.New(reflect.TypeOf(m).Elem()).Interface().(gorm.Dialect)
+	d.Dialect.SetDB(db)
+	d.db = db
+}
+
+// GetName keeps the dialect on the clones gorm makes by name
+func (d *dialect) GetName() string {
+	return dbName
+}
+
+func (d *dialect) HasIndex(tableName string, indexName string) bool {
+	var n int
+	err := d.db.QueryRow(
+		"select count(*) from information_schema.statistics where table_schema = database() and table_name = ? and index_name = ?",
+		tableName,
+		indexName,
+	).Scan(&n)
+	if err != nil {
+		// the mysql dialect panics too, migrating can not go on
+		panic(err)
+	}
+	return n > 0
+}
+
+// DB is a mysql compatible server running in process over an in memory
+// database, the daos reach it through the mysql driver like the main db
+type DB struct {
+	*gorm.DB
+	srv *server.Server
+}
+
+// Open starts a server on a free local port with the main schema migrated
+func Open() (*DB, error) {
+	pro := memory.NewDBProvider(memory.NewDatabase(dbName))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, errors.Wrap(err, "net.Listen")
+	}
+	addr := l.Addr().String()
+	l.Close()
+	srv, err := server.NewServer(
+		server.Config{
+			Protocol: "tcp",
+			Address:  addr,
+		},
+		sqle.NewDefault(pro),
+		gmssql.NewContext,
+		memory.NewSessionBuilder(pro),
+		nil,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "server.NewServer")
+	}
+	go srv.Start()
+	// the engine does not bind the placeholders of show statements, the
+	// driver interpolates them instead
+	sqlDB, err := sql.Open("mysql", fmt.Sprintf("root:@tcp(%s)/%s?parseTime=true&charset=utf8mb4&loc=UTC&interpolateParams=true", addr, dbName))
+	if err != nil {
+		srv.Close()
+		return nil, errors.Wrap(err, "sql.Open")
+	}
+	dbConn, err := gorm.Open(dbName, sqlDB)
+	if err != nil {
+		sqlDB.Close()
+		srv.Close()
+		return nil, errors.Wrap(err, "gorm.Open")
+	}
+	dbConn = dbConn.Set("gorm:save_associations", false)
+	dbConn = dbConn.Set("gorm:association_save_reference", false)
+	err = databases.MigrateDBMain(dbConn)
+	if err != nil {
+		dbConn.Close()
+		srv.Close()
+		return nil, errors.Wrap(err, "databases.MigrateDBMain")
+	}
+	return &DB{
+		DB:  dbConn,
+		srv: srv,
+	}, nil
+}
+
+func (db *DB) Close() error {
+	err := db.DB.Close()
+	if cerr := db.srv.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -24,6 +24,8 @@ var (
 	ErrBadPermission            = &Error{Code: -1008, Message: "bad permission"}
 	ErrBadBodyRequest           = &Error{Code: -1009, Message: "bad body request"}
 	ErrVerificationTokenExpired = &Error{Code: -1010, Message: "verification token expired"}
+	ErrInvalidParams            = &Error{Code: -1011, Message: "invalid params"}
+	ErrTooManyRequests          = &Error{Code: -1012, Message: "too many requests"}
 	ErrOTPIsInvalid             = &Error{Code: -1045, Message: "OTP not matched or invalidated!"}
 	ErrUserNotFound             = &Error{Code: -1046, Message: "User not found"}
 	ErrInvalidRecaptcha         = &Error{Code: -1076, Message: "invalid recaptcha"}
@@ -40,6 +42,25 @@ var (
 	ErrQueueNotFound           = &Error{Code: -333008, Message: "Contract queue not found"}
 	ErrRatingInvalid           = &Error{Code: -333010, Message: "Invalid rating"}
 	ErrPlayerNotFound          = &Error{Code: -333011, Message: "Player not found"}
+	ErrSignatureInvalid        = &Error{Code: -333012, Message: "Signature invalid"}
+	ErrNonceExpired            = &Error{Code: -333013, Message: "Nonce expired"}
+	ErrSessionInvalid          = &Error{Code: -333014, Message: "Session invalid"}
+	ErrCollectionNotFound      = &Error{Code: -333015, Message: "Collection not found"}
+	ErrAssetNotFound           = &Error{Code: -333016, Message: "Asset not found"}
+	ErrInstructionNotFound     = &Error{Code: -333017, Message: "Instruction not found"}
+	ErrCollectionExists        = &Error{Code: -333018, Message: "Collection already exists"}
+	ErrCurrencyExists          = &Error{Code: -333019, Message: "Currency already exists"}
+	ErrSubmissionNotFound      = &Error{Code: -333020, Message: "Collection submission not found"}
+	ErrSubmissionStatusInvalid = &Error{Code: -333021, Message: "Collection submission status invalid"}
+	ErrSubmissionExists        = &Error{Code: -333022, Message: "Collection submission already exists"}
+	ErrApiKeyNotFound          = &Error{Code: -333023, Message: "Api key not found"}
+	ErrRoleInvalid             = &Error{Code: -333024, Message: "Role invalid"}
+	ErrUserRoleNotFound        = &Error{Code: -333025, Message: "User role not found"}
+	ErrInstructionProcessed    = &Error{Code: -333026, Message: "Instruction already processed"}
+	ErrPartnerNotFound         = &Error{Code: -333027, Message: "Partner not found"}
+	ErrPartnerQuotaExceeded    = &Error{Code: -333028, Message: "Partner daily quota exceeded"}
+	ErrMetadataUnavailable     = &Error{Code: -333029, Message: "Asset metadata unavailable"}
+	ErrMediaUnavailable        = &Error{Code: -333030, Message: "Asset media unavailable"}
 
 	ErrPriceOutOfDate = &Error{Code: -9036, Message: "price is out of date"}
 )
@@ -98,6 +119,11 @@ func NewError(err error) error {
 	return retErr
 }
 
+// NewInvalidParamsError keeps the invalid params code with a message naming the field
+func NewInvalidParamsError(format string, args ...interface{}) error {
+	return NewError(&Error{Code: ErrInvalidParams.Code, Message: fmt.Sprintf(format, args...)})
+}
+
 func NewStacktraceString() string {
 	var rets []string
 	st := raven.NewStacktrace(1, 3, nil)
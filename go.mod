@@ -1,18 +1,20 @@
 module github.com/czConstant/constant-nftylend-api
 
-go 1.16
+go 1.23
 
 require (
 	github.com/DaRealFreak/cloudflare-bp-go v1.0.4
 	github.com/Microsoft/go-winio v0.5.0 // indirect
+	github.com/btcsuite/btcd/btcec/v2 v2.3.2
 	github.com/certifi/gocertifi v0.0.0-20210507211836-431795d63e8d // indirect
 	github.com/czConstant/blockchain-api v0.0.0-20220304072816-b9318b98dbb8
 	github.com/czConstant/constant-core v0.0.0-20210916103853-a22db9f518c2
 	github.com/czConstant/constant-evn v0.0.0-20210621093849-5a457b90a058
+	github.com/dolthub/go-mysql-server v0.20.0
 	github.com/getsentry/raven-go v0.2.1-0.20190619092523-5c24d5110e0e
 	github.com/gin-contrib/cors v1.3.1
 	github.com/gin-gonic/gin v1.7.2
-	github.com/go-sql-driver/mysql v1.6.0
+	github.com/go-sql-driver/mysql v1.7.2-0.20231213112541-0004702b931d
 	github.com/google/uuid v1.1.5 // indirect
 	github.com/gorilla/websocket v1.5.0 // indirect
 	github.com/jinzhu/gorm v1.9.16
@@ -20,8 +22,10 @@ require (
 	github.com/opentracing/opentracing-go v1.1.0 // indirect
 	github.com/philhofer/fwd v1.0.0 // indirect
 	github.com/pkg/errors v0.9.1
-	github.com/shopspring/decimal v1.2.0
+	github.com/shopspring/decimal v1.3.1
+	github.com/sirupsen/logrus v1.8.1
 	go.uber.org/zap v1.17.0
+	golang.org/x/crypto v0.0.0-20210616213533-5ff15b29337e
 	golang.org/x/time v0.0.0-20210220033141-f8bda1e9f3ba // indirect
 	gopkg.in/DataDog/dd-trace-go.v1 v1.31.1
 	gopkg.in/check.v1 v1.0.0-20201130134442-10cb98267c6c // indirect
@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"errors"
+	"math/big"
+)
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+func Base58Decode(s string) ([]byte, error) {
+	n := big.NewInt(0)
+	radix := big.NewInt(58)
+	for _, r := range s {
+		i := -1
+		for j, a := range base58Alphabet {
+			if a == r {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			return nil, errors.New("invalid base58 string")
+		}
+		n.Mul(n, radix)
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	b := n.Bytes()
+	// leading '1's are leading zero bytes
+	zeros := 0
+	for zeros < len(s) && s[zeros] == base58Alphabet[0] {
+		zeros++
+	}
+	return append(make([]byte, zeros), b...), nil
+}
+
+func Base58Encode(b []byte) string {
+	n := big.NewInt(0).SetBytes(b)
+	radix := big.NewInt(58)
+	mod := big.NewInt(0)
+	rs := []byte{}
+	for n.Sign() > 0 {
+		n.DivMod(n, radix, mod)
+		rs = append(rs, base58Alphabet[mod.Int64()])
+	}
+	for i := 0; i < len(b) && b[i] == 0; i++ {
+		rs = append(rs, base58Alphabet[0])
+	}
+	for i, j := 0, len(rs)-1; i < j; i, j = i+1, j-1 {
+		rs[i], rs[j] = rs[j], rs[i]
+	}
+	return string(rs)
+}
@@ -0,0 +1,79 @@
+package helpers
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/btcsuite/btcd/btcec/v2/ecdsa"
+	"golang.org/x/crypto/sha3"
+)
+
+func Keccak256(data ...[]byte) []byte {
+	h := sha3.NewLegacyKeccak256()
+	for _, b := range data {
+		h.Write(b)
+	}
+	return h.Sum(nil)
+}
+
+// recoverEthereumAddress returns the signer of hash from a 65 bytes r || s || v signature
+func recoverEthereumAddress(hash []byte, sig []byte) (string, error) {
+	if len(sig) != 65 {
+		return "", errors.New("invalid signature length")
+	}
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	if v > 1 {
+		return "", errors.New("invalid signature recovery id")
+	}
+	// the compact format puts the recovery id first, offset by 27 for an
+	// uncompressed key
+	compact := make([]byte, 65)
+	compact[0] = 27 + v
+	copy(compact[1:], sig[:64])
+	pub, _, err := ecdsa.RecoverCompact(compact, hash)
+	if err != nil {
+		return "", err
+	}
+	return "0x" + hex.EncodeToString(Keccak256(pub.SerializeUncompressed()[1:])[12:]), nil
+}
+
+// VerifyEthereumSignature checks a personal_sign signature of message
+func VerifyEthereumSignature(address string, message string, signature string) (bool, error) {
+	sig, err := hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+	if err != nil {
+		return false, err
+	}
+	signer, err := recoverEthereumAddress(Keccak256([]byte(GetSignMsg(message))), sig)
+	if err != nil {
+		return false, err
+	}
+	return strings.EqualFold(signer, address), nil
+}
+
+// VerifySolanaSignature checks an ed25519 signature of message, the signature
+// is base58 or hex encoded
+func VerifySolanaSignature(address string, message string, signature string) (bool, error) {
+	pub, err := Base58Decode(address)
+	if err != nil {
+		return false, err
+	}
+	if len(pub) != ed25519.PublicKeySize {
+		return false, errors.New("invalid public key")
+	}
+	sig, err := Base58Decode(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		sig, err = hex.DecodeString(strings.TrimPrefix(signature, "0x"))
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(sig) != ed25519.SignatureSize {
+		return false, errors.New("invalid signature length")
+	}
+	return ed25519.Verify(ed25519.PublicKey(pub), []byte(message), sig), nil
+}
@@ -0,0 +1,154 @@
+package helpers
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// web3.js accounts.sign("Some data") with the private key
+// 0x4c0883a69102937d6231471b5dbb6204fe5129617082792ae468d01a3f362318
+const (
+	ethAddress   = "0x2c7536E3605D9C16a7a3D7b1898e529396a65c23"
+	ethMessage   = "Some data"
+	ethHash      = "1da44b586eb0729ff70a73c326926f6ed5a25f5b056e7f47fbc6e58d86871655"
+	ethSignature = "0xb91467e570a6466aa9e9876cbcd013baba02900b8979d43fe208a4a4f339f5fd6007e74cd82e037b800186422fc2da167c747ef045e5d18a5f5d4300f8e1a0291c"
+
+	// secp256k1 order
+	secp256k1NHex = "fffffffffffffffffffffffffffffffebaaedce6af48a03bbfd25e8cd0364141"
+)
+
+// RFC 8032 section 7.1 tests 1 and 2
+var ed25519Vectors = []struct {
+	pub       string
+	message   string
+	signature string
+}{
+	{
+		pub:       "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		message:   "",
+		signature: "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b",
+	},
+	{
+		pub:       "3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		message:   "r",
+		signature: "92a009a9f0d4cab8720e820b5f642540a2b27b5416503f8fb3762223ebdb69da085ac1e43e15996e458f3613d0f11d8c387b2eaeb4302aeeb00d291612bb0c00",
+	},
+}
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.TrimPrefix(s, "0x"))
+	if err != nil {
+		t.Fatalf("decode %s: %v", s, err)
+	}
+	return b
+}
+
+// ethSignatureWith returns the test signature with v replaced
+func ethSignatureWith(t *testing.T, v byte) string {
+	t.Helper()
+	sig := mustHex(t, ethSignature)
+	sig[64] = v
+	return hex.EncodeToString(sig)
+}
+
+func TestEthereumSignMessageHash(t *testing.T) {
+	hash := hex.EncodeToString(Keccak256([]byte(GetSignMsg(ethMessage))))
+	if hash != ethHash {
+		t.Fatalf("hash = %s, want %s", hash, ethHash)
+	}
+}
+
+func TestVerifyEthereumSignature(t *testing.T) {
+	sig := mustHex(t, ethSignature)
+	highS := append([]byte{}, sig...)
+	copy(highS[32:64], mustHex(t, secp256k1NHex))
+	zeroS := append([]byte{}, sig...)
+	copy(zeroS[32:64], make([]byte, 32))
+	highR := append([]byte{}, sig...)
+	copy(highR[:32], mustHex(t, secp256k1NHex))
+	tests := []struct {
+		name      string
+		address   string
+		message   string
+		signature string
+		want      bool
+		wantErr   bool
+	}{
+		{name: "valid", address: ethAddress, message: ethMessage, signature: ethSignature, want: true},
+		{name: "lower case address", address: strings.ToLower(ethAddress), message: ethMessage, signature: ethSignature, want: true},
+		{name: "no 0x prefix", address: ethAddress, message: ethMessage, signature: strings.TrimPrefix(ethSignature, "0x"), want: true},
+		{name: "v as recovery id", address: ethAddress, message: ethMessage, signature: ethSignatureWith(t, 1), want: true},
+		{name: "other recovery id", address: ethAddress, message: ethMessage, signature: ethSignatureWith(t, 27), want: false},
+		{name: "other message", address: ethAddress, message: "Some datb", signature: ethSignature, want: false},
+		{name: "other address", address: "0x0000000000000000000000000000000000000001", message: ethMessage, signature: ethSignature, want: false},
+		{name: "v out of range", address: ethAddress, message: ethMessage, signature: ethSignatureWith(t, 29), wantErr: true},
+		{name: "v 2", address: ethAddress, message: ethMessage, signature: ethSignatureWith(t, 2), wantErr: true},
+		{name: "s zero", address: ethAddress, message: ethMessage, signature: hex.EncodeToString(zeroS), wantErr: true},
+		{name: "s not below order", address: ethAddress, message: ethMessage, signature: hex.EncodeToString(highS), wantErr: true},
+		{name: "r not below order", address: ethAddress, message: ethMessage, signature: hex.EncodeToString(highR), wantErr: true},
+		{name: "64 bytes", address: ethAddress, message: ethMessage, signature: hex.EncodeToString(sig[:64]), wantErr: true},
+		{name: "66 bytes", address: ethAddress, message: ethMessage, signature: hex.EncodeToString(append(sig, 0)), wantErr: true},
+		{name: "empty", address: ethAddress, message: ethMessage, signature: "", wantErr: true},
+		{name: "not hex", address: ethAddress, message: ethMessage, signature: "0xzz", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := VerifyEthereumSignature(tt.address, tt.message, tt.signature)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRecoverEthereumAddress(t *testing.T) {
+	signer, err := recoverEthereumAddress(mustHex(t, ethHash), mustHex(t, ethSignature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signer != strings.ToLower(ethAddress) {
+		t.Fatalf("signer = %s, want %s", signer, strings.ToLower(ethAddress))
+	}
+}
+
+func TestVerifySolanaSignature(t *testing.T) {
+	for _, v := range ed25519Vectors {
+		address := Base58Encode(mustHex(t, v.pub))
+		sig := mustHex(t, v.signature)
+		tests := []struct {
+			name      string
+			address   string
+			message   string
+			signature string
+			want      bool
+			wantErr   bool
+		}{
+			{name: "hex", address: address, message: v.message, signature: v.signature, want: true},
+			{name: "0x hex", address: address, message: v.message, signature: "0x" + v.signature, want: true},
+			{name: "base58", address: address, message: v.message, signature: Base58Encode(sig), want: true},
+			{name: "other message", address: address, message: v.message + "x", signature: v.signature, want: false},
+			{name: "other key", address: Base58Encode(make([]byte, 32)), message: v.message, signature: v.signature, want: false},
+			{name: "63 bytes", address: address, message: v.message, signature: hex.EncodeToString(sig[:63]), wantErr: true},
+			{name: "65 bytes", address: address, message: v.message, signature: hex.EncodeToString(append(append([]byte{}, sig...), 0)), wantErr: true},
+			{name: "empty", address: address, message: v.message, signature: "", wantErr: true},
+			{name: "short key", address: Base58Encode(mustHex(t, v.pub)[:31]), message: v.message, signature: v.signature, wantErr: true},
+			{name: "invalid address", address: "0OIl", message: v.message, signature: v.signature, wantErr: true},
+		}
+		for _, tt := range tests {
+			t.Run(v.pub[:8]+" "+tt.name, func(t *testing.T) {
+				got, err := VerifySolanaSignature(tt.address, tt.message, tt.signature)
+				if (err != nil) != tt.wantErr {
+					t.Fatalf("err = %v, wantErr %v", err, tt.wantErr)
+				}
+				if got != tt.want {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// AssetCrawl is the crawl queue entry of an asset on one marketplace, due
+// entries are picked by popularity then staleness. Network is the chain the
+// marketplace runs on, the origin chain for the bridged assets.
+//
+// The Sync fields checkpoint the paginated histories: SyncTransactionID is the
+// newest sale the stored history is complete up to, SyncCursor and
+// SyncHeadTransactionID are the resume page and the newest sale of a backfill
+// which spans several crawls
+type AssetCrawl struct {
+	gorm.Model
+	AssetID     uint `gorm:"unique_index:asset_crawls_main_uidx"`
+	Asset       *Asset
+	Source      string `gorm:"unique_index:asset_crawls_main_uidx"`
+	Network     Chain
+	Popularity  uint
+	NextCrawlAt *time.Time `gorm:"index:asset_crawls_next_idx"`
+	CrawledAt   *time.Time
+	Attempts    uint
+	LastError   string `gorm:"type:text"`
+
+	SyncTransactionID     string
+	SyncHeadTransactionID string
+	SyncCursor            string
+}
@@ -13,11 +13,15 @@ const (
 	AssetTransactionTypeExchange AssetTransactionType = "exchange"
 )
 
+// AssetTransaction is a marketplace sale of an asset. DedupKey is the natural
+// key of the sale on its source, the marketplace transaction id or a content
+// hash when the marketplace has none, rows crawled before keys existed are
+// null until the dedupe job merges them
 type AssetTransaction struct {
 	gorm.Model
-	Source        string
+	Source        string `gorm:"unique_index:asset_transactions_dedup_uidx"`
 	Network       Chain
-	AssetID       uint
+	AssetID       uint `gorm:"unique_index:asset_transactions_dedup_uidx"`
 	Asset         *Asset
 	Type          AssetTransactionType
 	Seller        string
@@ -27,4 +31,5 @@ type AssetTransaction struct {
 	CurrencyID    uint
 	Currency      *Currency
 	TransactionID string
+	DedupKey      *string `gorm:"unique_index:asset_transactions_dedup_uidx"`
 }
@@ -6,6 +6,37 @@ import (
 	"github.com/jinzhu/gorm"
 )
 
+type MediaSize string
+
+const (
+	MediaSizeSmall    MediaSize = "sm"
+	MediaSizeMedium   MediaSize = "md"
+	MediaSizeLarge    MediaSize = "lg"
+	MediaSizeOriginal MediaSize = "original"
+)
+
+// MediaSizes are the thumbnail presets served by the media endpoint with the
+// max width and height of each
+var MediaSizes = map[MediaSize]int{
+	MediaSizeSmall:  160,
+	MediaSizeMedium: 400,
+	MediaSizeLarge:  800,
+}
+
+// MediaContentTypes are the raster image types the media endpoint serves
+// inline, anything else is served as a download
+var MediaContentTypes = map[string]bool{
+	"image/png":  true,
+	"image/jpeg": true,
+	"image/gif":  true,
+	"image/webp": true,
+	"image/bmp":  true,
+}
+
+// Asset is an nft used as collateral. The Meta fields schedule the refresh of
+// its metadata json, MetaPlaceholder marks the assets created while the json
+// could not be fetched. RarityScore and RarityRank are computed within the
+// collection, a zero rank is an asset not ranked yet
 type Asset struct {
 	gorm.Model
 	Network                   Chain
@@ -28,8 +59,11 @@ type Asset struct {
 	OriginTokenID             string
 	TestOriginContractAddress string
 	TestOriginTokenID         uint
-	MagicEdenCrawAt           *time.Time
-	SolanartCrawAt            *time.Time
-	SolSeaCrawAt              *time.Time
-	OpenseaCrawAt             *time.Time
+	MetaPlaceholder           bool `gorm:"default:0"`
+	MetaRefreshedAt           *time.Time
+	MetaNextRefreshAt         *time.Time `gorm:"index:assets_meta_next_refresh_idx"`
+	MetaAttempts              uint
+	MetaError                 string  `gorm:"type:text"`
+	RarityScore               float64 `gorm:"default:0"`
+	RarityRank                uint    `gorm:"default:0"`
 }
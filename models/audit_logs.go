@@ -0,0 +1,35 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+type AuditTargetType string
+
+const (
+	AuditTargetNone                AuditTargetType = ""
+	AuditTargetCollection          AuditTargetType = "collection"
+	AuditTargetCurrency            AuditTargetType = "currency"
+	AuditTargetAsset               AuditTargetType = "asset"
+	AuditTargetInstruction         AuditTargetType = "instruction"
+	AuditTargetCollectionSubmitted AuditTargetType = "collection_submitted"
+	AuditTargetApiKey              AuditTargetType = "api_key"
+	AuditTargetUserRole            AuditTargetType = "user_role"
+	AuditTargetPartner             AuditTargetType = "partner"
+)
+
+// AuditLog is append only, rows are never updated or deleted
+type AuditLog struct {
+	gorm.Model
+	Actor      string `gorm:"index"`
+	Roles      string
+	IP         string
+	Method     string
+	Route      string `gorm:"index"`
+	Path       string
+	TargetType AuditTargetType `gorm:"index:audit_logs_target_idx"`
+	TargetID   uint            `gorm:"index:audit_logs_target_idx"`
+	Before     string          `gorm:"type:text"`
+	After      string          `gorm:"type:text"`
+	Diff       string          `gorm:"type:text"`
+	Status     int
+	Error      string `gorm:"type:text"`
+}
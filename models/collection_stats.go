@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/types/numeric"
+	"github.com/jinzhu/gorm"
+)
+
+type CollectionStatInterval string
+
+const (
+	CollectionStatIntervalHour CollectionStatInterval = "hour"
+	CollectionStatIntervalDay  CollectionStatInterval = "day"
+)
+
+// CollectionStat is the snapshot of the loans of a collection over one hour
+// or one day. The listing and funding figures cover the period only, the
+// active listings and the floor are read when the snapshot is taken and the
+// totals and the default rate are cumulated up to the end of the period. The
+// counts cover every loan, the amounts only the loans in PriceCurrency
+type CollectionStat struct {
+	gorm.Model
+	CollectionID     uint                   `gorm:"unique_index:collection_stats_main_uidx"`
+	StatInterval     CollectionStatInterval `gorm:"unique_index:collection_stats_main_uidx"`
+	PeriodStart      time.Time              `gorm:"unique_index:collection_stats_main_uidx"`
+	PeriodEnd        time.Time
+	NewListings      uint
+	ActiveListings   uint
+	FloorPrice       numeric.BigFloat `gorm:"type:decimal(36,18);default:0"`
+	FundedCount      uint
+	FundedVolume     numeric.BigFloat `gorm:"type:decimal(36,18);default:0"`
+	AvgPrincipal     numeric.BigFloat `gorm:"type:decimal(36,18);default:0"`
+	AvgInterestRate  float64          `gorm:"type:decimal(6,4);default:0"`
+	FinishedCount    uint
+	DefaultedCount   uint
+	DefaultRate      float64 `gorm:"type:decimal(6,4);default:0"`
+	TotalFundedCount uint
+	TotalVolume      numeric.BigFloat `gorm:"type:decimal(36,18);default:0"`
+	PriceCurrencyID  uint             `gorm:"default:0"`
+	PriceCurrency    *Currency
+}
@@ -1,9 +1,20 @@
 package models
 
-import "github.com/jinzhu/gorm"
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
 
 type CollectionSubmittedStatus string
 
+const (
+	CollectionSubmittedStatusPending  CollectionSubmittedStatus = "pending"
+	CollectionSubmittedStatusInReview CollectionSubmittedStatus = "in_review"
+	CollectionSubmittedStatusApproved CollectionSubmittedStatus = "approved"
+	CollectionSubmittedStatusRejected CollectionSubmittedStatus = "rejected"
+)
+
 type CollectionSubmitted struct {
 	gorm.Model
 	Network         Chain
@@ -15,4 +26,19 @@ type CollectionSubmitted struct {
 	Verified        bool `gorm:"default:0"`
 	WhoVerified     string
 	Status          CollectionSubmittedStatus
+	LookupToken     string `gorm:"unique_index"`
+	SubmitterIP     string `gorm:"index"`
+	CollectionID    uint   `gorm:"default:0"`
+	Collection      *Collection
+	ReviewNote      string `gorm:"type:text"`
+	ReviewedBy      string
+	ReviewedAt      *time.Time
+	Comments        []*CollectionSubmittedComment
+}
+
+type CollectionSubmittedComment struct {
+	gorm.Model
+	CollectionSubmittedID uint `gorm:"index"`
+	Author                string
+	Comment               string `gorm:"type:text"`
 }
@@ -0,0 +1,16 @@
+package models
+
+import (
+	"github.com/jinzhu/gorm"
+)
+
+// CollectionTrait is the number of assets of a collection having a trait
+// value, the assets missing a trait type are counted under the None value
+type CollectionTrait struct {
+	gorm.Model
+	CollectionID uint   `gorm:"unique_index:collection_traits_main_uidx"`
+	TraitType    string `gorm:"unique_index:collection_traits_main_uidx"`
+	Value        string `gorm:"unique_index:collection_traits_main_uidx"`
+	Count        uint
+	Frequency    float64
+}
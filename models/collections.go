@@ -1,10 +1,21 @@
 package models
 
 import (
+	"time"
+
 	"github.com/czConstant/constant-nftylend-api/types/numeric"
 	"github.com/jinzhu/gorm"
 )
 
+// Collection groups the assets of an nft project. RarityStale is set when
+// assets arrive and cleared once the rarity of the collection is recomputed.
+//
+// The aggregates back the rankings of the collections: ListingCount and
+// FloorPrice are read from the active listings, WeeklyVolume and
+// AvgInterestRate from the loans funded in the last 7 days and DefaultRate
+// from the finished loans. FloorPrice and WeeklyVolume only count the loans in
+// PriceCurrency, the currency of most of the listings. Verified marks the
+// collections of an approved submission
 type Collection struct {
 	gorm.Model
 	Network               Chain
@@ -16,15 +27,41 @@ type Collection struct {
 	OriginContractAddress string
 	Enabled               bool `gorm:"default:0"`
 	ListingAsset          *Asset
+	RarityStale           bool `gorm:"default:0;index:collections_rarity_stale_idx"`
+	RarityComputedAt      *time.Time
+	Verified              bool             `gorm:"default:0"`
+	ListingCount          uint             `gorm:"default:0;index:collections_listing_count_idx"`
+	FloorPrice            numeric.BigFloat `gorm:"type:decimal(36,18);default:0"`
+	WeeklyVolume          numeric.BigFloat `gorm:"type:decimal(36,18);default:0"`
+	AvgInterestRate       float64          `gorm:"type:decimal(6,4);default:0"`
+	DefaultRate           float64          `gorm:"type:decimal(6,4);default:0"`
+	PriceCurrencyID       uint             `gorm:"default:0;index:collections_price_currency_id_idx"`
+	PriceCurrency         *Currency
+	AggregatedAt          *time.Time
 }
 
-type NftyRPTListingCollection struct {
-	CollectionID uint
-	Total        uint
+type NftyRPTCollectionStat struct {
+	CollectionID     uint
+	NewListings      uint
+	ActiveListings   uint
+	FloorPrice       numeric.BigFloat
+	FundedCount      uint
+	FundedVolume     numeric.BigFloat
+	AvgPrincipal     numeric.BigFloat
+	AvgInterestRate  float64
+	FinishedCount    uint
+	DefaultedCount   uint
+	TotalFundedCount uint
+	TotalVolume      numeric.BigFloat
 }
 
-type NftyRPTCollectionLoan struct {
-	TotalVolume  numeric.BigFloat
-	TotalListed  uint
-	Avg24hAmount numeric.BigFloat
+type NftyRPTCollectionCurrencyStat struct {
+	CollectionID   uint
+	CurrencyID     uint
+	ActiveListings uint
+	FloorPrice     numeric.BigFloat
+	FundedCount    uint
+	FundedVolume   numeric.BigFloat
+	AvgPrincipal   numeric.BigFloat
+	TotalVolume    numeric.BigFloat
 }
@@ -23,6 +23,8 @@ const (
 	ChainETH   Chain = "ETH"
 )
 
+// Loan is a listing of an asset. AssetRarityScore copies the rarity score of
+// the asset so that the listings can be sorted and paged by rarity
 type Loan struct {
 	gorm.Model
 	Network              Chain
@@ -58,4 +60,5 @@ type Loan struct {
 	CancelTxHash         string
 	PayTxHash            string
 	LiquidateTxHash      string
+	AssetRarityScore     float64 `gorm:"default:0"`
 }
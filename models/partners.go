@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Partner struct {
+	gorm.Model
+	Name         string
+	ContactEmail string
+	KeyPrefix    string
+	KeyHash      string `gorm:"unique_index:partners_key_uidx"`
+	DailyQuota   uint
+	RevokedAt    *time.Time
+}
+
+// PartnerUsage meters the requests of a partner for one UTC day, Date is
+// formatted as yyyy-mm-dd
+type PartnerUsage struct {
+	gorm.Model
+	PartnerID uint   `gorm:"unique_index:partner_usages_main_uidx"`
+	Date      string `gorm:"unique_index:partner_usages_main_uidx"`
+	Requests  uint
+	Rejected  uint
+}
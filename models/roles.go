@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleReviewer Role = "reviewer"
+	RoleJob      Role = "job"
+	RoleIndexer  Role = "indexer"
+)
+
+type ApiKey struct {
+	gorm.Model
+	Name      string
+	KeyPrefix string
+	KeyHash   string `gorm:"unique_index:api_keys_key_uidx"`
+	Role      Role
+	ExpiredAt *time.Time
+	RevokedAt *time.Time
+}
+
+type UserRole struct {
+	gorm.Model
+	Network Chain  `gorm:"index:user_roles_main_idx"`
+	Address string `gorm:"index:user_roles_main_idx"`
+	Role    Role   `gorm:"index:user_roles_main_idx"`
+}
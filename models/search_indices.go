@@ -0,0 +1,37 @@
+package models
+
+import "github.com/jinzhu/gorm"
+
+type SearchIndexType string
+type SearchIndexField string
+
+const (
+	SearchIndexTypeCollection SearchIndexType = "collection"
+	SearchIndexTypeAsset      SearchIndexType = "asset"
+	SearchIndexTypeLoan       SearchIndexType = "loan"
+
+	SearchIndexFieldName            SearchIndexField = "name"
+	SearchIndexFieldSymbol          SearchIndexField = "symbol"
+	SearchIndexFieldContractAddress SearchIndexField = "contract_address"
+	SearchIndexFieldTxHash          SearchIndexField = "tx_hash"
+)
+
+type SearchIndex struct {
+	gorm.Model
+	Type    SearchIndexType `gorm:"index:search_indices_ref_idx"`
+	RefID   uint            `gorm:"index:search_indices_ref_idx"`
+	Field   SearchIndexField
+	Keyword string `gorm:"index:search_indices_keyword_idx"`
+	Title   string
+	Weight  float64 `gorm:"type:decimal(6,2);default:0"`
+}
+
+type SearchResult struct {
+	Type       SearchIndexType
+	RefID      uint
+	Title      string
+	Score      float64
+	Collection *Collection `gorm:"-"`
+	Asset      *Asset      `gorm:"-"`
+	Loan       *Loan       `gorm:"-"`
+}
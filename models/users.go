@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+type User struct {
+	gorm.Model
+	Network         Chain  `gorm:"unique_index:users_main_uidx"`
+	Address         string `gorm:"unique_index:users_main_uidx"`
+	SignedInAt      *time.Time
+	Email           string
+	NewsNotiEnabled bool `gorm:"default:0"`
+	LoanNotiEnabled bool `gorm:"default:0"`
+}
+
+// UserNonce is a sign in challenge of a wallet, every request issues its own
+// nonce so a pending sign in can not be replaced by another caller
+type UserNonce struct {
+	gorm.Model
+	Network   Chain  `gorm:"index:user_nonces_address_idx"`
+	Address   string `gorm:"index:user_nonces_address_idx"`
+	Nonce     string `gorm:"unique_index"`
+	ExpiredAt *time.Time
+	UsedAt    *time.Time
+}
+
+type UserSession struct {
+	gorm.Model
+	UserID    uint `gorm:"index"`
+	User      *User
+	TokenHash string `gorm:"unique_index:user_sessions_token_uidx"`
+	ExpiredAt *time.Time
+	RevokedAt *time.Time
+}
@@ -0,0 +1,64 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	memorySweepInterval = time.Minute
+)
+
+type memoryBucket struct {
+	bucket
+	period time.Duration
+}
+
+// MemoryStore keeps the buckets of this instance only
+type MemoryStore struct {
+	mtx       sync.Mutex
+	buckets   map[string]*memoryBucket
+	sweptAt   time.Time
+	timeNowFn func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets:   map[string]*memoryBucket{},
+		sweptAt:   time.Now(),
+		timeNowFn: time.Now,
+	}
+}
+
+func (s *MemoryStore) Take(ctx context.Context, key string, rule Rule) (*Result, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	now := s.timeNowFn()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{
+			bucket: bucket{
+				tokens:    float64(rule.Limit),
+				updatedAt: now,
+			},
+		}
+		s.buckets[key] = b
+	}
+	b.period = rule.Period
+	return b.take(rule, now), nil
+}
+
+// sweep drops the buckets idle for a full period, they are refilled anyway
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.sweptAt) < memorySweepInterval {
+		return
+	}
+	for key, b := range s.buckets {
+		if now.Sub(b.updatedAt) >= b.period {
+			delete(s.buckets, key)
+		}
+	}
+	s.sweptAt = now
+}
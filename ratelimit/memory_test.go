@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+func newTestStore() (*MemoryStore, *clock) {
+	c := &clock{now: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)}
+	s := NewMemoryStore()
+	s.sweptAt = c.now
+	s.timeNowFn = func() time.Time {
+		return c.now
+	}
+	return s, c
+}
+
+func take(t *testing.T, s *MemoryStore, key string, rule Rule) *Result {
+	t.Helper()
+	r, err := s.Take(context.Background(), key, rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// near compares durations computed from float token counts
+func near(d time.Duration, want time.Duration) bool {
+	diff := d - want
+	return diff > -time.Microsecond && diff < time.Microsecond
+}
+
+func TestMemoryStoreRefill(t *testing.T) {
+	s, c := newTestStore()
+	rule := Rule{Limit: 2, Period: 10 * time.Second}
+	tests := []struct {
+		advance   time.Duration
+		allowed   bool
+		remaining int
+		reset     time.Duration
+	}{
+		// a new bucket is full and allows a burst of the limit
+		{0, true, 1, 5 * time.Second},
+		{0, true, 0, 10 * time.Second},
+		{0, false, 0, 10 * time.Second},
+		// a token refills every period / limit
+		{4 * time.Second, false, 0, 6 * time.Second},
+		{time.Second, true, 0, 10 * time.Second},
+		// the bucket does not refill past the limit
+		{time.Minute, true, 1, 5 * time.Second},
+	}
+	for i, tt := range tests {
+		c.advance(tt.advance)
+		r := take(t, s, "client", rule)
+		if r.Allowed != tt.allowed || r.Remaining != tt.remaining || !near(r.Reset, tt.reset) || r.Limit != rule.Limit {
+			t.Fatalf("take %d = %+v, want allowed %v remaining %d reset %v", i, r, tt.allowed, tt.remaining, tt.reset)
+		}
+	}
+}
+
+func TestMemoryStoreRetryAfter(t *testing.T) {
+	s, c := newTestStore()
+	rule := Rule{Limit: 3, Period: 3 * time.Second}
+	for i := 0; i < 3; i++ {
+		r := take(t, s, "client", rule)
+		if !r.Allowed || r.RetryAfter != 0 {
+			t.Fatalf("take %d = %+v, want allowed without retry", i, r)
+		}
+	}
+	r := take(t, s, "client", rule)
+	if r.Allowed || !near(r.RetryAfter, time.Second) {
+		t.Fatalf("denied take = %+v, want retry after 1s", r)
+	}
+	c.advance(400 * time.Millisecond)
+	r = take(t, s, "client", rule)
+	if r.Allowed || !near(r.RetryAfter, 600*time.Millisecond) {
+		t.Fatalf("take after 400ms = %+v, want retry after 600ms", r)
+	}
+	c.advance(r.RetryAfter)
+	r = take(t, s, "client", rule)
+	if !r.Allowed {
+		t.Fatalf("take after the retry delay = %+v, want allowed", r)
+	}
+	// other clients have their own bucket
+	r = take(t, s, "other", rule)
+	if !r.Allowed || r.Remaining != 2 {
+		t.Fatalf("take of another client = %+v, want allowed with 2 left", r)
+	}
+}
+
+func TestMemoryStoreSweep(t *testing.T) {
+	s, c := newTestStore()
+	short := Rule{Limit: 1, Period: 10 * time.Second}
+	long := Rule{Limit: 1, Period: 5 * time.Minute}
+	take(t, s, "short", short)
+	take(t, s, "long", long)
+	// the sweep runs at most once per interval
+	c.advance(memorySweepInterval - time.Second)
+	take(t, s, "other", short)
+	if len(s.buckets) != 3 {
+		t.Fatalf("buckets before the sweep interval = %d, want 3", len(s.buckets))
+	}
+	// buckets idle for a full period are dropped, the others are kept
+	c.advance(time.Second)
+	take(t, s, "other", short)
+	if _, ok := s.buckets["short"]; ok {
+		t.Fatal("idle short bucket kept")
+	}
+	if _, ok := s.buckets["long"]; !ok {
+		t.Fatal("long bucket dropped before its period")
+	}
+	if _, ok := s.buckets["other"]; !ok {
+		t.Fatal("bucket taken from dropped")
+	}
+	// a dropped bucket starts full again
+	r := take(t, s, "short", short)
+	if !r.Allowed {
+		t.Fatalf("take of a swept bucket = %+v, want allowed", r)
+	}
+}
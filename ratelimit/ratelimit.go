@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Rule is a token bucket budget, Limit requests are allowed in a burst and the
+// bucket refills at Limit per Period
+type Rule struct {
+	Limit  int
+	Period time.Duration
+}
+
+func (r Rule) rate() float64 {
+	return float64(r.Limit) / r.Period.Seconds()
+}
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// Store keeps the buckets, the in-memory store is used by default and a shared
+// store can be plugged in when the api runs on several instances
+type Store interface {
+	Take(ctx context.Context, key string, rule Rule) (*Result, error)
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// take refills the bucket for the elapsed time and consumes one token
+func (b *bucket) take(rule Rule, now time.Time) *Result {
+	rate := rule.rate()
+	b.tokens = math.Min(float64(rule.Limit), b.tokens+now.Sub(b.updatedAt).Seconds()*rate)
+	b.updatedAt = now
+	r := &Result{
+		Limit: rule.Limit,
+	}
+	if b.tokens >= 1 {
+		b.tokens = b.tokens - 1
+		r.Allowed = true
+	} else {
+		r.RetryAfter = time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	}
+	r.Remaining = int(math.Floor(b.tokens))
+	r.Reset = time.Duration((float64(rule.Limit) - b.tokens) / rate * float64(time.Second))
+	return r
+}
@@ -0,0 +1,323 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/apis"
+	"github.com/czConstant/constant-nftylend-api/configs"
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/databases/memdb"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+	"github.com/czConstant/constant-nftylend-api/services"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+	"github.com/czConstant/constant-nftylend-api/services/fakes"
+	"github.com/czConstant/constant-nftylend-api/types/numeric"
+	"github.com/gin-gonic/gin"
+	"github.com/jinzhu/gorm"
+)
+
+// a scenario is a directory holding
+//   seed.json          rows and blockchain responses loaded before the replay
+//   instructions.jsonl hook payloads as posted to /hook/internal/solana-instruction
+//   golden.json        expected loans, loan_offers and loan_transactions
+
+const (
+	SeedFile         = "seed.json"
+	InstructionsFile = "instructions.jsonl"
+	GoldenFile       = "golden.json"
+
+	hookSolanaInstructionURL = "/nfty-lend-api/hook/internal/solana-instruction"
+)
+
+type Seed struct {
+	Currencies    []*models.Currency                   `json:"currencies"`
+	Collections   []*models.Collection                 `json:"collections"`
+	Assets        []*models.Asset                      `json:"assets"`
+	Metadatas     map[string]*solana.MetadataResp      `json:"metadatas"`
+	MetadataInfos map[string]*solana.MetadataInfoResp  `json:"metadata_infos"`
+	Verifiers     map[string]*services.NftVerification `json:"verifiers"`
+}
+
+type Snapshot struct {
+	Loans            []map[string]interface{} `json:"loans"`
+	LoanOffers       []map[string]interface{} `json:"loan_offers"`
+	LoanTransactions []map[string]interface{} `json:"loan_transactions"`
+}
+
+// Scenarios lists the scenario directories under root
+func Scenarios(root string) ([]string, error) {
+	fs, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, err
+	}
+	dirs := []string{}
+	for _, f := range fs {
+		if !f.IsDir() {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(root, f.Name(), InstructionsFile))
+		if err == nil {
+			dirs = append(dirs, filepath.Join(root, f.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// Run replays a scenario through the http router over an embedded database
+// and compares the resulting rows with the golden snapshot, or rewrites the
+// snapshot when update is set
+func Run(dir string, update bool) error {
+	db, err := memdb.Open()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	handler, apiKey, err := newServer(db.DB, dir)
+	if err != nil {
+		return err
+	}
+	err = replayInstructions(filepath.Join(dir, InstructionsFile), apiKey, handler)
+	if err != nil {
+		return err
+	}
+	snapshot, err := takeSnapshot(db.DB)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	goldenPath := filepath.Join(dir, GoldenFile)
+	if update {
+		return ioutil.WriteFile(goldenPath, data, 0644)
+	}
+	golden, err := ioutil.ReadFile(goldenPath)
+	if err != nil {
+		return err
+	}
+	return compareSnapshot(goldenPath, golden, data)
+}
+
+// newServer seeds db from the scenario and routes the api over the real daos,
+// the returned api key holds the indexer role the hook requires
+func newServer(db *gorm.DB, dir string) (http.Handler, string, error) {
+	bcs := fakes.NewBlockchainClient()
+	err := loadSeed(filepath.Join(dir, SeedFile), db, bcs)
+	if err != nil {
+		return nil, "", err
+	}
+	nls := fakes.NewNftLend(db, bcs, saletrack.NewRegistry())
+	_, apiKey, err := nls.AdminCreateApiKey(context.Background(), "replay", models.RoleIndexer, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	gin.SetMode(gin.TestMode)
+	g := gin.New()
+	srv := apis.NewServer(
+		g,
+		&configs.Config{},
+		nls,
+		ratelimit.NewMemoryStore(),
+	)
+	srv.Routers()
+	return g, apiKey, nil
+}
+
+func loadSeed(path string, db *gorm.DB, bcs *fakes.BlockchainClient) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var seed Seed
+	err = json.Unmarshal(data, &seed)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for _, m := range seed.Currencies {
+		err = (&daos.Currency{}).Create(db, m)
+		if err != nil {
+			return err
+		}
+	}
+	for _, m := range seed.Collections {
+		err = (&daos.Collection{}).Create(db, m)
+		if err != nil {
+			return err
+		}
+	}
+	for _, m := range seed.Assets {
+		err = (&daos.Asset{}).Create(db, m)
+		if err != nil {
+			return err
+		}
+	}
+	for k, v := range seed.Metadatas {
+		bcs.Metadatas[k] = v
+	}
+	for k, v := range seed.MetadataInfos {
+		bcs.MetadataInfo[k] = v
+	}
+	for k, v := range seed.Verifiers {
+		bcs.Verifiers[k] = v
+	}
+	return nil
+}
+
+func replayInstructions(path string, apiKey string, handler http.Handler) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 1024*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		payload := bytes.TrimSpace(scanner.Bytes())
+		if len(payload) == 0 {
+			continue
+		}
+		w := postInstruction(handler, apiKey, payload)
+		if w.Code != http.StatusOK {
+			return fmt.Errorf("%s:%d: status %d %s", path, line, w.Code, strings.TrimSpace(w.Body.String()))
+		}
+	}
+	return scanner.Err()
+}
+
+func postInstruction(handler http.Handler, apiKey string, payload []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, hookSolanaInstructionURL, bytes.NewReader(payload))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Api-Key", apiKey)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func takeSnapshot(db *gorm.DB) (*Snapshot, error) {
+	loans, err := (&daos.Loan{}).Find(db, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	offers, err := (&daos.LoanOffer{}).Find(db, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	txs, err := (&daos.LoanTransaction{}).Find(db, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{
+		Loans:            []map[string]interface{}{},
+		LoanOffers:       []map[string]interface{}{},
+		LoanTransactions: []map[string]interface{}{},
+	}
+	for _, m := range loans {
+		snapshot.Loans = append(snapshot.Loans, snapshotRow(m))
+	}
+	for _, m := range offers {
+		snapshot.LoanOffers = append(snapshot.LoanOffers, snapshotRow(m))
+	}
+	for _, m := range txs {
+		snapshot.LoanTransactions = append(snapshot.LoanTransactions, snapshotRow(m))
+	}
+	return snapshot, nil
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	bigFloatType = reflect.TypeOf(numeric.BigFloat{})
+)
+
+// snapshotRow keeps the columns of a model keyed by column name, leaving out
+// associations and the bookkeeping timestamps that change on every run
+func snapshotRow(m interface{}) map[string]interface{} {
+	row := map[string]interface{}{}
+	v := reflect.ValueOf(m).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		f := v.Field(i)
+		if sf.PkgPath != "" || sf.Tag.Get("gorm") == "-" {
+			continue
+		}
+		if sf.Anonymous {
+			if sf.Type == reflect.TypeOf(gorm.Model{}) {
+				row["id"] = f.FieldByName("ID").Interface()
+			}
+			continue
+		}
+		t := sf.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Slice || (t.Kind() == reflect.Struct && t != timeType && t != bigFloatType) {
+			continue
+		}
+		row[gorm.ToColumnName(sf.Name)] = snapshotValue(f)
+	}
+	return row
+}
+
+func snapshotValue(f reflect.Value) interface{} {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return nil
+		}
+		f = f.Elem()
+	}
+	switch f.Type() {
+	case timeType:
+		{
+			return f.Interface().(time.Time).UTC().Format(time.RFC3339)
+		}
+	case bigFloatType:
+		{
+			n := f.Interface().(numeric.BigFloat)
+			return n.BigFloat().Text('f', 10)
+		}
+	}
+	return f.Interface()
+}
+
+func compareSnapshot(goldenPath string, golden []byte, actual []byte) error {
+	if bytes.Equal(golden, actual) {
+		return nil
+	}
+	gls := strings.Split(string(golden), "\n")
+	als := strings.Split(string(actual), "\n")
+	for i := 0; i < len(gls) || i < len(als); i++ {
+		var gl, al string
+		if i < len(gls) {
+			gl = gls[i]
+		}
+		if i < len(als) {
+			al = als[i]
+		}
+		if gl != al {
+			return fmt.Errorf("%s:%d: snapshot mismatch\n- %s\n+ %s", goldenPath, i+1, strings.TrimSpace(gl), strings.TrimSpace(al))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package replay
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/databases/memdb"
+	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+var update = flag.Bool("update", false, "rewrite golden snapshots")
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	logger.NewLogger("nft-api-replay-test", "", false)
+	code := m.Run()
+	logger.Sync()
+	os.Exit(code)
+}
+
+func TestScenarios(t *testing.T) {
+	scenarios, err := Scenarios("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scenarios) == 0 {
+		t.Fatal("no scenarios in testdata")
+	}
+	for _, scenario := range scenarios {
+		scenario := scenario
+		t.Run(filepath.Base(scenario), func(t *testing.T) {
+			err := Run(scenario, *update)
+			if err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// dbRows keeps every table an InitLoan can write
+func dbRows(t *testing.T, db *gorm.DB) map[string][]map[string]interface{} {
+	t.Helper()
+	filters := map[string][]interface{}{}
+	preloads := map[string][]interface{}{}
+	orders := []string{"id asc"}
+	rows := map[string][]map[string]interface{}{}
+	add := func(name string, ms interface{}, err error) {
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		v := reflect.ValueOf(ms)
+		rows[name] = []map[string]interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			rows[name] = append(rows[name], snapshotRow(v.Index(i).Interface()))
+		}
+	}
+	collections, err := (&daos.Collection{}).Find(db, filters, preloads, orders, 0, -1)
+	add("collections", collections, err)
+	assets, err := (&daos.Asset{}).Find(db, filters, preloads, orders, 0, -1)
+	add("assets", assets, err)
+	loans, err := (&daos.Loan{}).Find(db, filters, preloads, orders, 0, -1)
+	add("loans", loans, err)
+	offers, err := (&daos.LoanOffer{}).Find(db, filters, preloads, orders, 0, -1)
+	add("loan_offers", offers, err)
+	txs, err := (&daos.LoanTransaction{}).Find(db, filters, preloads, orders, 0, -1)
+	add("loan_transactions", txs, err)
+	indexes, err := (&daos.SearchIndex{}).Find(db, filters, preloads, orders, 0, -1)
+	add("search_indices", indexes, err)
+	crawls, err := (&daos.AssetCrawl{}).Find(db, filters, preloads, orders, 0, -1)
+	add("asset_crawls", crawls, err)
+	return rows
+}
+
+// failWrites makes the inserts and updates of the table of m fail with err
+// until the returned func is called
+func failWrites(db *gorm.DB, m interface{}, err error) func() {
+	table := db.NewScope(m).TableName()
+	fail := func(scope *gorm.Scope) {
+		if scope.TableName() == table {
+			scope.Err(err)
+		}
+	}
+	db.Callback().Create().Before("gorm:create").Register("replay:fail_writes", fail)
+	db.Callback().Update().Before("gorm:update").Register("replay:fail_writes", fail)
+	return func() {
+		db.Callback().Create().Remove("replay:fail_writes")
+		db.Callback().Update().Remove("replay:fail_writes")
+	}
+}
+
+func firstInstruction(t *testing.T, dir string) []byte {
+	t.Helper()
+	f, err := os.Open(filepath.Join(dir, InstructionsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatalf("no instructions in %s: %v", dir, scanner.Err())
+	}
+	return append([]byte{}, scanner.Bytes()...)
+}
+
+func TestFailedInstructionRollsBack(t *testing.T) {
+	dir := filepath.Join("testdata", "lifecycle_repaid")
+	payload := firstInstruction(t, dir)
+	for _, m := range []interface{}{&models.Loan{}, &models.LoanTransaction{}, &models.SearchIndex{}} {
+		m := m
+		t.Run(reflect.TypeOf(m).Elem().Name(), func(t *testing.T) {
+			db, err := memdb.Open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+			handler, apiKey, err := newServer(db.DB, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			before := dbRows(t, db.DB)
+			restore := failWrites(db.DB, m, errors.New("write failed"))
+			w := postInstruction(handler, apiKey, payload)
+			restore()
+			if w.Code == http.StatusOK {
+				t.Fatalf("status = %d, want the failed write to fail the hook", w.Code)
+			}
+			after := dbRows(t, db.DB)
+			if !reflect.DeepEqual(before, after) {
+				t.Fatalf("rows after the failed instruction = %v, want %v", after, before)
+			}
+			// the instruction is recorded before it is processed and stays
+			// new so that the indexer retries it
+			ins, err := (&daos.Instruction{}).Find(db.DB, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(ins) != 1 || ins[0].Status != "new" {
+				t.Fatalf("instructions = %+v, want one new", ins)
+			}
+			w = postInstruction(handler, apiKey, payload)
+			if w.Code != http.StatusOK {
+				t.Fatalf("retry status = %d: %s", w.Code, w.Body.String())
+			}
+			loans, err := (&daos.Loan{}).Find(db.DB, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loans) != 1 || loans[0].Status != models.LoanStatusNew {
+				t.Fatalf("loans after the retry = %+v, want one new", loans)
+			}
+			txs, err := (&daos.LoanTransaction{}).Find(db.DB, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(txs) != 1 || txs[0].LoanID != loans[0].ID || txs[0].Type != models.LoanTransactionTypeListed {
+				t.Fatalf("loan transactions after the retry = %+v", txs)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type AdminCollectionReq struct {
+	Network               models.Chain `json:"network"`
+	SeoURL                string       `json:"seo_url"`
+	Name                  string       `json:"name"`
+	Description           string       `json:"description"`
+	Creator               string       `json:"creator"`
+	OriginNetwork         models.Chain `json:"origin_network"`
+	OriginContractAddress string       `json:"origin_contract_address"`
+	Enabled               bool         `json:"enabled"`
+}
+
+type AdminCurrencyReq struct {
+	Network         models.Chain `json:"network"`
+	ContractAddress string       `json:"contract_address"`
+	Decimals        uint         `json:"decimals"`
+	Symbol          string       `json:"symbol"`
+	Name            string       `json:"name"`
+	IconURL         string       `json:"icon_url"`
+	AdminFeeAddress string       `json:"admin_fee_address"`
+	Enabled         bool         `json:"enabled"`
+}
+
+type AdminAssetTestContractReq struct {
+	TestContractAddress       string `json:"test_contract_address"`
+	TestOriginContractAddress string `json:"test_origin_contract_address"`
+	TestOriginTokenID         uint   `json:"test_origin_token_id"`
+}
+
+type AdminCommentReq struct {
+	Comment string `json:"comment"`
+}
+
+type AdminReviewNoteReq struct {
+	Note string `json:"note"`
+}
+
+type AdminApiKeyReq struct {
+	Name      string      `json:"name"`
+	Role      models.Role `json:"role"`
+	ExpiredAt *time.Time  `json:"expired_at"`
+}
+
+type AdminApiKeyRotateReq struct {
+	GraceSeconds int64 `json:"grace_seconds"`
+}
+
+type AdminUserRoleReq struct {
+	Network models.Chain `json:"network"`
+	Address string       `json:"address"`
+	Role    models.Role  `json:"role"`
+}
+
+type AdminPartnerReq struct {
+	Name         string `json:"name"`
+	ContactEmail string `json:"contact_email"`
+	DailyQuota   uint   `json:"daily_quota"`
+}
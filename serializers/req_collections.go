@@ -9,6 +9,4 @@ type CollectionSubmittedReq struct {
 	Creator         string       `json:"creator"`
 	ContractAddress string       `json:"contract_address"`
 	ContactInfo     string       `json:"contact_info"`
-	Verified        bool         `json:"verified"`
-	WhoVerified     string       `json:"who_verified"`
 }
@@ -0,0 +1,21 @@
+package serializers
+
+import "github.com/czConstant/constant-nftylend-api/models"
+
+type UserNonceReq struct {
+	Network models.Chain `json:"network"`
+	Address string       `json:"address"`
+}
+
+type UserSignInReq struct {
+	Network   models.Chain `json:"network"`
+	Address   string       `json:"address"`
+	Nonce     string       `json:"nonce"`
+	Signature string       `json:"signature"`
+}
+
+type UserSettingsReq struct {
+	Email           *string `json:"email"`
+	NewsNotiEnabled *bool   `json:"news_noti_enabled"`
+	LoanNotiEnabled *bool   `json:"loan_noti_enabled"`
+}
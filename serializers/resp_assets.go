@@ -2,27 +2,31 @@ package serializers
 
 import (
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/czConstant/constant-nftylend-api/models"
 )
 
 type AssetResp struct {
-	ID                    uint            `json:"id"`
-	CreatedAt             time.Time       `json:"created_at"`
-	UpdatedAt             time.Time       `json:"updated_at"`
-	CollectionID          uint            `json:"collection_id"`
-	Collection            *CollectionResp `json:"collection"`
-	SeoURL                string          `json:"seo_url"`
-	ContractAddress       string          `json:"contract_address"`
-	TokenURL              string          `json:"token_url"`
-	Name                  string          `json:"name"`
-	SellerFeeRate         float64         `json:"seller_fee_rate"`
-	Attributes            interface{}     `json:"attributes"`
-	OriginNetwork         models.Chain    `json:"origin_network"`
-	OriginContractAddress string          `json:"origin_contract_address"`
-	OriginTokenID         string          `json:"origin_token_id"`
-	NewLoan               *LoanResp       `json:"new_loan"`
+	ID                    uint              `json:"id"`
+	CreatedAt             time.Time         `json:"created_at"`
+	UpdatedAt             time.Time         `json:"updated_at"`
+	CollectionID          uint              `json:"collection_id"`
+	Collection            *CollectionResp   `json:"collection"`
+	SeoURL                string            `json:"seo_url"`
+	ContractAddress       string            `json:"contract_address"`
+	TokenURL              string            `json:"token_url"`
+	Name                  string            `json:"name"`
+	SellerFeeRate         float64           `json:"seller_fee_rate"`
+	Attributes            interface{}       `json:"attributes"`
+	OriginNetwork         models.Chain      `json:"origin_network"`
+	OriginContractAddress string            `json:"origin_contract_address"`
+	OriginTokenID         string            `json:"origin_token_id"`
+	NewLoan               *LoanResp         `json:"new_loan"`
+	RarityScore           float64           `json:"rarity_score"`
+	RarityRank            uint              `json:"rarity_rank"`
+	ThumbnailURLs         map[string]string `json:"thumbnail_urls"`
 }
 
 func NewAssetResp(m *models.Asset) *AssetResp {
@@ -47,10 +51,27 @@ func NewAssetResp(m *models.Asset) *AssetResp {
 		OriginContractAddress: m.OriginContractAddress,
 		OriginTokenID:         m.OriginTokenID,
 		NewLoan:               NewLoanResp(m.NewLoan),
+		RarityScore:           m.RarityScore,
+		RarityRank:            m.RarityRank,
+		ThumbnailURLs:         newAssetThumbnailURLs(m),
 	}
 	return resp
 }
 
+// newAssetThumbnailURLs links the media endpoint in every preset size, the
+// urls are relative to the api host
+func newAssetThumbnailURLs(m *models.Asset) map[string]string {
+	if m.TokenURL == "" {
+		return nil
+	}
+	urls := map[string]string{}
+	for size := range models.MediaSizes {
+		urls[string(size)] = fmt.Sprintf("/nfty-lend-api/media/%d?size=%s", m.ID, size)
+	}
+	urls[string(models.MediaSizeOriginal)] = fmt.Sprintf("/nfty-lend-api/media/%d?size=%s", m.ID, models.MediaSizeOriginal)
+	return urls
+}
+
 func NewAssetRespArr(arr []*models.Asset) []*AssetResp {
 	resps := []*AssetResp{}
 	for _, m := range arr {
@@ -58,3 +79,23 @@ func NewAssetRespArr(arr []*models.Asset) []*AssetResp {
 	}
 	return resps
 }
+
+type AdminAssetResp struct {
+	*AssetResp
+	TestContractAddress       string `json:"test_contract_address"`
+	TestOriginContractAddress string `json:"test_origin_contract_address"`
+	TestOriginTokenID         uint   `json:"test_origin_token_id"`
+}
+
+func NewAdminAssetResp(m *models.Asset) *AdminAssetResp {
+	if m == nil {
+		return nil
+	}
+	resp := &AdminAssetResp{
+		AssetResp:                 NewAssetResp(m),
+		TestContractAddress:       m.TestContractAddress,
+		TestOriginContractAddress: m.TestOriginContractAddress,
+		TestOriginTokenID:         m.TestOriginTokenID,
+	}
+	return resp
+}
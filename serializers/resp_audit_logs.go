@@ -0,0 +1,72 @@
+package serializers
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type AuditLogResp struct {
+	ID         uint                   `json:"id"`
+	CreatedAt  time.Time              `json:"created_at"`
+	Actor      string                 `json:"actor"`
+	Roles      []string               `json:"roles"`
+	IP         string                 `json:"ip"`
+	Method     string                 `json:"method"`
+	Route      string                 `json:"route"`
+	Path       string                 `json:"path"`
+	TargetType models.AuditTargetType `json:"target_type"`
+	TargetID   uint                   `json:"target_id"`
+	Before     interface{}            `json:"before"`
+	After      interface{}            `json:"after"`
+	Diff       interface{}            `json:"diff"`
+	Status     int                    `json:"status"`
+	Error      string                 `json:"error"`
+}
+
+func auditJSONValue(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	var v interface{}
+	json.Unmarshal([]byte(s), &v)
+	return v
+}
+
+func NewAuditLogResp(m *models.AuditLog) *AuditLogResp {
+	if m == nil {
+		return nil
+	}
+	roles := []string{}
+	if m.Roles != "" {
+		roles = strings.Split(m.Roles, ",")
+	}
+	resp := &AuditLogResp{
+		ID:         m.ID,
+		CreatedAt:  m.CreatedAt,
+		Actor:      m.Actor,
+		Roles:      roles,
+		IP:         m.IP,
+		Method:     m.Method,
+		Route:      m.Route,
+		Path:       m.Path,
+		TargetType: m.TargetType,
+		TargetID:   m.TargetID,
+		Before:     auditJSONValue(m.Before),
+		After:      auditJSONValue(m.After),
+		Diff:       auditJSONValue(m.Diff),
+		Status:     m.Status,
+		Error:      m.Error,
+	}
+	return resp
+}
+
+func NewAuditLogRespArr(arr []*models.AuditLog) []*AuditLogResp {
+	resps := []*AuditLogResp{}
+	for _, m := range arr {
+		resps = append(resps, NewAuditLogResp(m))
+	}
+	return resps
+}
@@ -0,0 +1,108 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type CollectionSubmittedCommentResp struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Author    string    `json:"author"`
+	Comment   string    `json:"comment"`
+}
+
+func NewCollectionSubmittedCommentResp(m *models.CollectionSubmittedComment) *CollectionSubmittedCommentResp {
+	if m == nil {
+		return nil
+	}
+	resp := &CollectionSubmittedCommentResp{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt,
+		Author:    m.Author,
+		Comment:   m.Comment,
+	}
+	return resp
+}
+
+func NewCollectionSubmittedCommentRespArr(arr []*models.CollectionSubmittedComment) []*CollectionSubmittedCommentResp {
+	resps := []*CollectionSubmittedCommentResp{}
+	for _, m := range arr {
+		resps = append(resps, NewCollectionSubmittedCommentResp(m))
+	}
+	return resps
+}
+
+// CollectionSubmittedStatusResp is what the submitter sees through the lookup token
+type CollectionSubmittedStatusResp struct {
+	ID              uint                             `json:"id"`
+	CreatedAt       time.Time                        `json:"created_at"`
+	UpdatedAt       time.Time                        `json:"updated_at"`
+	Network         models.Chain                     `json:"network"`
+	Name            string                           `json:"name"`
+	ContractAddress string                           `json:"contract_address"`
+	Status          models.CollectionSubmittedStatus `json:"status"`
+	LookupToken     string                           `json:"lookup_token"`
+	ReviewNote      string                           `json:"review_note"`
+	ReviewedAt      *time.Time                       `json:"reviewed_at"`
+	Collection      *CollectionResp                  `json:"collection"`
+}
+
+func NewCollectionSubmittedStatusResp(m *models.CollectionSubmitted) *CollectionSubmittedStatusResp {
+	if m == nil {
+		return nil
+	}
+	resp := &CollectionSubmittedStatusResp{
+		ID:              m.ID,
+		CreatedAt:       m.CreatedAt,
+		UpdatedAt:       m.UpdatedAt,
+		Network:         m.Network,
+		Name:            m.Name,
+		ContractAddress: m.ContractAddress,
+		Status:          m.Status,
+		LookupToken:     m.LookupToken,
+		ReviewNote:      m.ReviewNote,
+		ReviewedAt:      m.ReviewedAt,
+		Collection:      NewCollectionResp(m.Collection),
+	}
+	return resp
+}
+
+type CollectionSubmittedResp struct {
+	*CollectionSubmittedStatusResp
+	Description  string                            `json:"description"`
+	Creator      string                            `json:"creator"`
+	ContactInfo  string                            `json:"contact_info"`
+	Verified     bool                              `json:"verified"`
+	WhoVerified  string                            `json:"who_verified"`
+	CollectionID uint                              `json:"collection_id"`
+	ReviewedBy   string                            `json:"reviewed_by"`
+	Comments     []*CollectionSubmittedCommentResp `json:"comments"`
+}
+
+func NewCollectionSubmittedResp(m *models.CollectionSubmitted) *CollectionSubmittedResp {
+	if m == nil {
+		return nil
+	}
+	resp := &CollectionSubmittedResp{
+		CollectionSubmittedStatusResp: NewCollectionSubmittedStatusResp(m),
+		Description:                   m.Description,
+		Creator:                       m.Creator,
+		ContactInfo:                   m.ContactInfo,
+		Verified:                      m.Verified,
+		WhoVerified:                   m.WhoVerified,
+		CollectionID:                  m.CollectionID,
+		ReviewedBy:                    m.ReviewedBy,
+		Comments:                      NewCollectionSubmittedCommentRespArr(m.Comments),
+	}
+	return resp
+}
+
+func NewCollectionSubmittedRespArr(arr []*models.CollectionSubmitted) []*CollectionSubmittedResp {
+	resps := []*CollectionSubmittedResp{}
+	for _, m := range arr {
+		resps = append(resps, NewCollectionSubmittedResp(m))
+	}
+	return resps
+}
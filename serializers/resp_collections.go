@@ -8,19 +8,29 @@ import (
 )
 
 type CollectionResp struct {
-	ID                    uint             `json:"id"`
-	CreatedAt             time.Time        `json:"created_at"`
-	UpdatedAt             time.Time        `json:"updated_at"`
-	SeoURL                string           `json:"seo_url"`
-	Name                  string           `json:"name"`
-	Description           string           `json:"description"`
-	ListingAsset          *AssetResp       `json:"listing_asset"`
-	ListingTotal          uint             `json:"listing_total"`
-	TotalVolume           numeric.BigFloat `json:"total_volume"`
-	TotalListed           uint             `json:"total_listed"`
-	Avg24hAmount          numeric.BigFloat `json:"avg24h_amount"`
-	OriginNetwork         models.Chain     `json:"origin_network"`
-	OriginContractAddress string           `json:"origin_contract_address"`
+	ID                    uint                `json:"id"`
+	CreatedAt             time.Time           `json:"created_at"`
+	UpdatedAt             time.Time           `json:"updated_at"`
+	Network               models.Chain        `json:"network"`
+	SeoURL                string              `json:"seo_url"`
+	Name                  string              `json:"name"`
+	Description           string              `json:"description"`
+	Creator               string              `json:"creator"`
+	Enabled               bool                `json:"enabled"`
+	Verified              bool                `json:"verified"`
+	ListingAsset          *AssetResp          `json:"listing_asset"`
+	ListingTotal          uint                `json:"listing_total"`
+	FloorPrice            numeric.BigFloat    `json:"floor_price"`
+	WeeklyVolume          numeric.BigFloat    `json:"weekly_volume"`
+	PriceCurrency         *CurrencyResp       `json:"price_currency"`
+	AvgInterestRate       float64             `json:"avg_interest_rate"`
+	DefaultRate           float64             `json:"default_rate"`
+	TotalVolume           numeric.BigFloat    `json:"total_volume"`
+	TotalListed           uint                `json:"total_listed"`
+	Avg24hAmount          numeric.BigFloat    `json:"avg24h_amount"`
+	OriginNetwork         models.Chain        `json:"origin_network"`
+	OriginContractAddress string              `json:"origin_contract_address"`
+	Stats                 *CollectionStatResp `json:"stats"`
 }
 
 func NewCollectionResp(m *models.Collection) *CollectionResp {
@@ -31,9 +41,19 @@ func NewCollectionResp(m *models.Collection) *CollectionResp {
 		ID:                    m.ID,
 		CreatedAt:             m.CreatedAt,
 		UpdatedAt:             m.UpdatedAt,
+		Network:               m.Network,
 		SeoURL:                m.SeoURL,
 		Name:                  m.Name,
 		Description:           m.Description,
+		Creator:               m.Creator,
+		Enabled:               m.Enabled,
+		Verified:              m.Verified,
+		ListingTotal:          m.ListingCount,
+		FloorPrice:            m.FloorPrice,
+		WeeklyVolume:          m.WeeklyVolume,
+		PriceCurrency:         NewCurrencyResp(m.PriceCurrency),
+		AvgInterestRate:       m.AvgInterestRate,
+		DefaultRate:           m.DefaultRate,
 		OriginNetwork:         m.OriginNetwork,
 		OriginContractAddress: m.OriginContractAddress,
 		ListingAsset:          NewAssetResp(m.ListingAsset),
@@ -48,3 +68,51 @@ func NewCollectionRespArr(arr []*models.Collection) []*CollectionResp {
 	}
 	return resps
 }
+
+type CollectionStatResp struct {
+	StatInterval     models.CollectionStatInterval `json:"interval"`
+	PeriodStart      time.Time                     `json:"period_start"`
+	PeriodEnd        time.Time                     `json:"period_end"`
+	NewListings      uint                          `json:"new_listings"`
+	ActiveListings   uint                          `json:"active_listings"`
+	FloorPrice       numeric.BigFloat              `json:"floor_price"`
+	FundedCount      uint                          `json:"funded_count"`
+	FundedVolume     numeric.BigFloat              `json:"funded_volume"`
+	AvgPrincipal     numeric.BigFloat              `json:"avg_principal"`
+	AvgInterestRate  float64                       `json:"avg_interest_rate"`
+	DefaultRate      float64                       `json:"default_rate"`
+	TotalFundedCount uint                          `json:"total_funded_count"`
+	TotalVolume      numeric.BigFloat              `json:"total_volume"`
+	PriceCurrency    *CurrencyResp                 `json:"price_currency"`
+}
+
+func NewCollectionStatResp(m *models.CollectionStat) *CollectionStatResp {
+	if m == nil {
+		return nil
+	}
+	resp := &CollectionStatResp{
+		StatInterval:     m.StatInterval,
+		PeriodStart:      m.PeriodStart,
+		PeriodEnd:        m.PeriodEnd,
+		NewListings:      m.NewListings,
+		ActiveListings:   m.ActiveListings,
+		FloorPrice:       m.FloorPrice,
+		FundedCount:      m.FundedCount,
+		FundedVolume:     m.FundedVolume,
+		AvgPrincipal:     m.AvgPrincipal,
+		AvgInterestRate:  m.AvgInterestRate,
+		DefaultRate:      m.DefaultRate,
+		TotalFundedCount: m.TotalFundedCount,
+		TotalVolume:      m.TotalVolume,
+		PriceCurrency:    NewCurrencyResp(m.PriceCurrency),
+	}
+	return resp
+}
+
+func NewCollectionStatRespArr(arr []*models.CollectionStat) []*CollectionStatResp {
+	resps := []*CollectionStatResp{}
+	for _, m := range arr {
+		resps = append(resps, NewCollectionStatResp(m))
+	}
+	return resps
+}
@@ -17,6 +17,7 @@ type CurrencyResp struct {
 	Name            string       `json:"name"`
 	IconURL         string       `json:"icon_url"`
 	AdminFeeAddress string       `json:"admin_fee_address"`
+	Enabled         bool         `json:"enabled"`
 }
 
 func NewCurrencyResp(m *models.Currency) *CurrencyResp {
@@ -34,6 +35,7 @@ func NewCurrencyResp(m *models.Currency) *CurrencyResp {
 		Name:            m.Name,
 		IconURL:         m.IconURL,
 		AdminFeeAddress: m.AdminFeeAddress,
+		Enabled:         m.Enabled > 0,
 	}
 	return resp
 }
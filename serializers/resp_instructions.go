@@ -0,0 +1,51 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type InstructionResp struct {
+	ID               uint       `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	BlockNumber      uint64     `json:"block_number"`
+	BlockTime        *time.Time `json:"block_time"`
+	TransactionHash  string     `json:"transaction_hash"`
+	TransactionIndex uint       `json:"transaction_index"`
+	InstructionIndex uint       `json:"instruction_index"`
+	Program          string     `json:"program"`
+	Instruction      string     `json:"instruction"`
+	Data             string     `json:"data"`
+	Status           string     `json:"status"`
+}
+
+func NewInstructionResp(m *models.Instruction) *InstructionResp {
+	if m == nil {
+		return nil
+	}
+	resp := &InstructionResp{
+		ID:               m.ID,
+		CreatedAt:        m.CreatedAt,
+		UpdatedAt:        m.UpdatedAt,
+		BlockNumber:      m.BlockNumber,
+		BlockTime:        m.BlockTime,
+		TransactionHash:  m.TransactionHash,
+		TransactionIndex: m.TransactionIndex,
+		InstructionIndex: m.InstructionIndex,
+		Program:          m.Program,
+		Instruction:      m.Instruction,
+		Data:             m.Data,
+		Status:           m.Status,
+	}
+	return resp
+}
+
+func NewInstructionRespArr(arr []*models.Instruction) []*InstructionResp {
+	resps := []*InstructionResp{}
+	for _, m := range arr {
+		resps = append(resps, NewInstructionResp(m))
+	}
+	return resps
+}
@@ -0,0 +1,68 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type PartnerResp struct {
+	ID           uint       `json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	Name         string     `json:"name"`
+	ContactEmail string     `json:"contact_email"`
+	KeyPrefix    string     `json:"key_prefix"`
+	DailyQuota   uint       `json:"daily_quota"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	Key          string     `json:"key,omitempty"`
+}
+
+func NewPartnerResp(m *models.Partner) *PartnerResp {
+	if m == nil {
+		return nil
+	}
+	resp := &PartnerResp{
+		ID:           m.ID,
+		CreatedAt:    m.CreatedAt,
+		Name:         m.Name,
+		ContactEmail: m.ContactEmail,
+		KeyPrefix:    m.KeyPrefix,
+		DailyQuota:   m.DailyQuota,
+		RevokedAt:    m.RevokedAt,
+	}
+	return resp
+}
+
+func NewPartnerRespArr(arr []*models.Partner) []*PartnerResp {
+	resps := []*PartnerResp{}
+	for _, m := range arr {
+		resps = append(resps, NewPartnerResp(m))
+	}
+	return resps
+}
+
+type PartnerUsageResp struct {
+	Date     string `json:"date"`
+	Requests uint   `json:"requests"`
+	Rejected uint   `json:"rejected"`
+}
+
+func NewPartnerUsageResp(m *models.PartnerUsage) *PartnerUsageResp {
+	if m == nil {
+		return nil
+	}
+	resp := &PartnerUsageResp{
+		Date:     m.Date,
+		Requests: m.Requests,
+		Rejected: m.Rejected,
+	}
+	return resp
+}
+
+func NewPartnerUsageRespArr(arr []*models.PartnerUsage) []*PartnerUsageResp {
+	resps := []*PartnerUsageResp{}
+	for _, m := range arr {
+		resps = append(resps, NewPartnerUsageResp(m))
+	}
+	return resps
+}
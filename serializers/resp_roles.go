@@ -0,0 +1,72 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type ApiKeyResp struct {
+	ID        uint        `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Name      string      `json:"name"`
+	KeyPrefix string      `json:"key_prefix"`
+	Role      models.Role `json:"role"`
+	ExpiredAt *time.Time  `json:"expired_at"`
+	RevokedAt *time.Time  `json:"revoked_at"`
+	Key       string      `json:"key,omitempty"`
+}
+
+func NewApiKeyResp(m *models.ApiKey) *ApiKeyResp {
+	if m == nil {
+		return nil
+	}
+	resp := &ApiKeyResp{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt,
+		Name:      m.Name,
+		KeyPrefix: m.KeyPrefix,
+		Role:      m.Role,
+		ExpiredAt: m.ExpiredAt,
+		RevokedAt: m.RevokedAt,
+	}
+	return resp
+}
+
+func NewApiKeyRespArr(arr []*models.ApiKey) []*ApiKeyResp {
+	resps := []*ApiKeyResp{}
+	for _, m := range arr {
+		resps = append(resps, NewApiKeyResp(m))
+	}
+	return resps
+}
+
+type UserRoleResp struct {
+	ID        uint         `json:"id"`
+	CreatedAt time.Time    `json:"created_at"`
+	Network   models.Chain `json:"network"`
+	Address   string       `json:"address"`
+	Role      models.Role  `json:"role"`
+}
+
+func NewUserRoleResp(m *models.UserRole) *UserRoleResp {
+	if m == nil {
+		return nil
+	}
+	resp := &UserRoleResp{
+		ID:        m.ID,
+		CreatedAt: m.CreatedAt,
+		Network:   m.Network,
+		Address:   m.Address,
+		Role:      m.Role,
+	}
+	return resp
+}
+
+func NewUserRoleRespArr(arr []*models.UserRole) []*UserRoleResp {
+	resps := []*UserRoleResp{}
+	for _, m := range arr {
+		resps = append(resps, NewUserRoleResp(m))
+	}
+	return resps
+}
@@ -0,0 +1,50 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+)
+
+type SaleStreamHealthResp struct {
+	Source        string     `json:"source"`
+	Connected     bool       `json:"connected"`
+	ConnectedAt   *time.Time `json:"connected_at"`
+	LastMessageAt *time.Time `json:"last_message_at"`
+	LastError     string     `json:"last_error"`
+	LastErrorAt   *time.Time `json:"last_error_at"`
+	Reconnects    uint       `json:"reconnects"`
+	Pending       int        `json:"pending"`
+}
+
+func timeOrNil(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+func NewSaleStreamHealthResp(m *saletrack.SaleStreamHealth) *SaleStreamHealthResp {
+	if m == nil {
+		return nil
+	}
+	resp := &SaleStreamHealthResp{
+		Source:        m.Source,
+		Connected:     m.Connected,
+		ConnectedAt:   timeOrNil(m.ConnectedAt),
+		LastMessageAt: timeOrNil(m.LastMessageAt),
+		LastError:     m.LastError,
+		LastErrorAt:   timeOrNil(m.LastErrorAt),
+		Reconnects:    m.Reconnects,
+		Pending:       m.Pending,
+	}
+	return resp
+}
+
+func NewSaleStreamHealthRespArr(arr []*saletrack.SaleStreamHealth) []*SaleStreamHealthResp {
+	resps := []*SaleStreamHealthResp{}
+	for _, m := range arr {
+		resps = append(resps, NewSaleStreamHealthResp(m))
+	}
+	return resps
+}
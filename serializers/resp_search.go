@@ -0,0 +1,39 @@
+package serializers
+
+import (
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type SearchResultResp struct {
+	Type       models.SearchIndexType `json:"type"`
+	ID         uint                   `json:"id"`
+	Title      string                 `json:"title"`
+	Score      float64                `json:"score"`
+	Collection *CollectionResp        `json:"collection,omitempty"`
+	Asset      *AssetResp             `json:"asset,omitempty"`
+	Loan       *LoanResp              `json:"loan,omitempty"`
+}
+
+func NewSearchResultResp(m *models.SearchResult) *SearchResultResp {
+	if m == nil {
+		return nil
+	}
+	resp := &SearchResultResp{
+		Type:       m.Type,
+		ID:         m.RefID,
+		Title:      m.Title,
+		Score:      m.Score,
+		Collection: NewCollectionResp(m.Collection),
+		Asset:      NewAssetResp(m.Asset),
+		Loan:       NewLoanResp(m.Loan),
+	}
+	return resp
+}
+
+func NewSearchResultRespArr(arr []*models.SearchResult) []*SearchResultResp {
+	resps := []*SearchResultResp{}
+	for _, m := range arr {
+		resps = append(resps, NewSearchResultResp(m))
+	}
+	return resps
+}
@@ -0,0 +1,62 @@
+package serializers
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+type UserSettingsResp struct {
+	Email           string `json:"email"`
+	NewsNotiEnabled bool   `json:"news_noti_enabled"`
+	LoanNotiEnabled bool   `json:"loan_noti_enabled"`
+}
+
+type UserResp struct {
+	ID         uint              `json:"id"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	Network    models.Chain      `json:"network"`
+	Address    string            `json:"address"`
+	SignedInAt *time.Time        `json:"signed_in_at"`
+	Settings   *UserSettingsResp `json:"settings"`
+}
+
+func NewUserResp(m *models.User) *UserResp {
+	if m == nil {
+		return nil
+	}
+	resp := &UserResp{
+		ID:         m.ID,
+		CreatedAt:  m.CreatedAt,
+		UpdatedAt:  m.UpdatedAt,
+		Network:    m.Network,
+		Address:    m.Address,
+		SignedInAt: m.SignedInAt,
+		Settings: &UserSettingsResp{
+			Email:           m.Email,
+			NewsNotiEnabled: m.NewsNotiEnabled,
+			LoanNotiEnabled: m.LoanNotiEnabled,
+		},
+	}
+	return resp
+}
+
+type UserNonceResp struct {
+	Network   models.Chain `json:"network"`
+	Address   string       `json:"address"`
+	Nonce     string       `json:"nonce"`
+	Message   string       `json:"message"`
+	ExpiredAt *time.Time   `json:"expired_at"`
+}
+
+type UserSignInResp struct {
+	Token string    `json:"token"`
+	User  *UserResp `json:"user"`
+}
+
+type UserMeResp struct {
+	*UserResp
+	Loans  []*LoanResp      `json:"loans"`
+	Offers []*LoanOfferResp `json:"offers"`
+}
@@ -5,6 +5,8 @@ type Resp struct {
 	Error       error       `json:"error"`
 	Count       *uint       `json:"count,omitempty"`
 	CountUnread *uint       `json:"count_unread,omitempty"`
+	NextCursor  string      `json:"next_cursor,omitempty"`
+	HasMore     *bool       `json:"has_more,omitempty"`
 }
 
 // type NftTokenResp struct {
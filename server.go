@@ -1,12 +1,16 @@
 package main
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
 	"runtime/debug"
+	"syscall"
+	"time"
 
 	"github.com/getsentry/raven-go"
 	"github.com/go-sql-driver/mysql"
@@ -15,11 +19,15 @@ import (
 
 	"github.com/czConstant/blockchain-api/bcclient"
 	"github.com/czConstant/constant-nftylend-api/apis"
+	"github.com/czConstant/constant-nftylend-api/blobstore"
 	"github.com/czConstant/constant-nftylend-api/configs"
 	"github.com/czConstant/constant-nftylend-api/daos"
 	"github.com/czConstant/constant-nftylend-api/databases"
 	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
 	"github.com/czConstant/constant-nftylend-api/services"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/nftmeta"
 	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -89,25 +97,57 @@ func main() {
 	daos.InitDBConn(
 		dbMain,
 	)
+	// sale history providers of every network, the crawl queue and the streams
+	// only use the providers registered here
+	shr := saletrack.NewRegistry()
+	shr.Register(string(models.ChainSOL), saletrack.NewMagicEdenProvider(saletrack.MagicEdenAPIURL))
+	shr.Register(string(models.ChainSOL), saletrack.NewSolanartProvider(saletrack.SolanartAPIURL))
+	shr.Register(string(models.ChainSOL), saletrack.NewSolseaProvider(saletrack.SolseaWssURL))
+	shr.Register(string(models.ChainETH), saletrack.NewOpenseaProvider(saletrack.OpenseaAPIURL))
+	if conf.Media.Dir == "" {
+		conf.Media.Dir = "data/media"
+	}
 	var (
 		bcs = bcclient.NewBlockchainClient(
 			conf.Blockchain,
 		)
-		cd   = &daos.Currency{}
-		cld  = &daos.Collection{}
-		clsd = &daos.CollectionSubmitted{}
-		ad   = &daos.Asset{}
-		atd  = &daos.AssetTransaction{}
-		ld   = &daos.Loan{}
-		lod  = &daos.LoanOffer{}
-		ltd  = &daos.LoanTransaction{}
-		id   = &daos.Instruction{}
-
-		stc = &saletrack.Client{}
+		mdc = nftmeta.NewClient(
+			nftmeta.Config{
+				IPFSGateways:    conf.Metadata.IPFSGateways,
+				ArweaveGateways: conf.Metadata.ArweaveGateways,
+				Timeout:         time.Duration(conf.Metadata.TimeoutSeconds) * time.Second,
+				Retries:         conf.Metadata.Retries,
+				CacheTTL:        time.Duration(conf.Metadata.CacheTTLSeconds) * time.Second,
+			},
+		)
+		cd    = &daos.Currency{}
+		cld   = &daos.Collection{}
+		clsd  = &daos.CollectionSubmitted{}
+		ad    = &daos.Asset{}
+		atd   = &daos.AssetTransaction{}
+		ld    = &daos.Loan{}
+		lod   = &daos.LoanOffer{}
+		ltd   = &daos.LoanTransaction{}
+		id    = &daos.Instruction{}
+		sid   = &daos.SearchIndex{}
+		ud    = &daos.User{}
+		usd   = &daos.UserSession{}
+		clscd = &daos.CollectionSubmittedComment{}
+		akd   = &daos.ApiKey{}
+		urd   = &daos.UserRole{}
+		ald   = &daos.AuditLog{}
+		pd    = &daos.Partner{}
+		pud   = &daos.PartnerUsage{}
+		acd   = &daos.AssetCrawl{}
+		ctd   = &daos.CollectionTrait{}
+		csd   = &daos.CollectionStat{}
+		und   = &daos.UserNonce{}
 
 		s = services.NewNftLend(
-			bcs,
-			stc,
+			daos.NewMainConn(),
+			services.NewBlockchainClient(bcs, mdc),
+			shr,
+			blobstore.NewDiskStore(conf.Media.Dir),
 			cd,
 			cld,
 			clsd,
@@ -117,24 +157,60 @@ func main() {
 			lod,
 			ltd,
 			id,
+			sid,
+			ud,
+			usd,
+			clscd,
+			akd,
+			urd,
+			ald,
+			pd,
+			pud,
+			acd,
+			ctd,
+			csd,
+			und,
 		)
 	)
 
+	// an interrupt stops the sale streams and shuts the server down
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	streamsDone := make(chan struct{})
+	go func() {
+		s.RunSaleStreams(ctx)
+		close(streamsDone)
+	}()
+
 	r := gin.Default()
+	r.TrustedProxies = conf.TrustedProxies
 	r.Use(gintrace.Middleware(fmt.Sprintf("%s-gin", conf.Datadog.Service), gintrace.WithAnalytics(true)))
 	srv := apis.NewServer(
 		r,
 		conf,
 		s,
+		ratelimit.NewMemoryStore(),
 	)
 	srv.Routers()
 	if conf.Port == 0 {
 		conf.Port = 8080
 	}
-	if err := r.Run(fmt.Sprintf(":%d", conf.Port)); err != nil {
+	hs := &http.Server{
+		Addr:    fmt.Sprintf(":%d", conf.Port),
+		Handler: r,
+	}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		hs.Shutdown(shutdownCtx)
+	}()
+	if err := hs.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		logger.WrapError(
 			logger.LOGGER_API_APP_ERROR,
 			err,
 		)
 	}
+	stop()
+	<-streamsDone
 }
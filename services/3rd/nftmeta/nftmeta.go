@@ -0,0 +1,325 @@
+package nftmeta
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	defaultRetries  = 2
+	defaultCacheTTL = 10 * time.Minute
+	maxCacheSize    = 10000
+	retryBaseDelay  = 500 * time.Millisecond
+	maxMediaSize    = 20 << 20
+)
+
+var (
+	DefaultIPFSGateways = []string{
+		"https://ipfs.io",
+		"https://cloudflare-ipfs.com",
+		"https://gateway.pinata.cloud",
+	}
+	DefaultArweaveGateways = []string{
+		"https://arweave.net",
+	}
+	// sharedAddressSpace is the carrier grade nat range, not reachable from
+	// the internet either
+	sharedAddressSpace = &net.IPNet{
+		IP:   net.IPv4(100, 64, 0, 0),
+		Mask: net.CIDRMask(10, 32),
+	}
+)
+
+// Config lists the gateways tried in order for the ipfs and arweave uris, zero
+// values fall back to the defaults
+type Config struct {
+	IPFSGateways    []string
+	ArweaveGateways []string
+	Timeout         time.Duration
+	Retries         int
+	CacheTTL        time.Duration
+}
+
+// MetadataInfo is the off chain json of an nft following the metaplex token
+// standard
+type MetadataInfo struct {
+	Name                 string      `json:"name"`
+	Symbol               string      `json:"symbol"`
+	Description          string      `json:"description"`
+	Image                string      `json:"image"`
+	ExternalUrl          string      `json:"external_url"`
+	SellerFeeBasisPoints interface{} `json:"seller_fee_basis_points"`
+	Attributes           interface{} `json:"attributes"`
+	Collection           struct {
+		Name   string `json:"name"`
+		Family string `json:"family"`
+	} `json:"collection"`
+}
+
+type cacheEntry struct {
+	info      *MetadataInfo
+	expiresAt time.Time
+}
+
+// Client fetches metadata json through the configured gateways, successful
+// fetches are cached by uri for CacheTTL. The uris are set by the nft creators
+// so every connection, redirects included, is refused off the public internet
+type Client struct {
+	conf         Config
+	client       *http.Client
+	mtx          sync.Mutex
+	cache        map[string]*cacheEntry
+	checkAddress func(address string) error
+}
+
+func NewClient(conf Config) *Client {
+	if len(conf.IPFSGateways) == 0 {
+		conf.IPFSGateways = DefaultIPFSGateways
+	}
+	if len(conf.ArweaveGateways) == 0 {
+		conf.ArweaveGateways = DefaultArweaveGateways
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = defaultTimeout
+	}
+	if conf.Retries < 0 {
+		conf.Retries = 0
+	} else if conf.Retries == 0 {
+		conf.Retries = defaultRetries
+	}
+	if conf.CacheTTL <= 0 {
+		conf.CacheTTL = defaultCacheTTL
+	}
+	c := &Client{
+		conf:         conf,
+		cache:        map[string]*cacheEntry{},
+		checkAddress: publicAddress,
+	}
+	dialer := &net.Dialer{
+		Timeout: conf.Timeout,
+		// the address is checked once resolved, right before connecting, so
+		// neither a redirect nor a dns answer can reach the local networks
+		Control: func(network string, address string, _ syscall.RawConn) error {
+			return c.checkAddress(address)
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be the only address checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	c.client = &http.Client{
+		Timeout:   conf.Timeout,
+		Transport: transport,
+	}
+	return c
+}
+
+// publicAddress refuses the loopback, private, link local and other addresses
+// not routed on the internet
+func publicAddress(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil ||
+		ip.IsUnspecified() ||
+		ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("nftmeta: address %s is not public", host)
+	}
+	return nil
+}
+
+// GetMetadataInfo returns the cached metadata of uri or fetches it
+func (c *Client) GetMetadataInfo(uri string) (*MetadataInfo, error) {
+	c.mtx.Lock()
+	e, ok := c.cache[uri]
+	c.mtx.Unlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.info, nil
+	}
+	return c.RefreshMetadataInfo(uri)
+}
+
+// RefreshMetadataInfo fetches the metadata of uri ignoring the cache, every
+// gateway is tried on each attempt
+func (c *Client) RefreshMetadataInfo(uri string) (*MetadataInfo, error) {
+	urls := GatewayURLs(uri, c.conf.IPFSGateways, c.conf.ArweaveGateways)
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("nftmeta: unsupported uri %q", uri)
+	}
+	var lastErr error
+	delay := retryBaseDelay
+	for attempt := 0; attempt <= c.conf.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay = delay * 2
+		}
+		for _, u := range urls {
+			info, err := c.fetch(u)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			c.store(uri, info)
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("nftmeta: %s: %v", uri, lastErr)
+}
+
+func (c *Client) fetch(u string) (*MetadataInfo, error) {
+	resp, err := c.client.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, fmt.Errorf("http response bad status %d %s", resp.StatusCode, string(body))
+	}
+	var info MetadataInfo
+	err = json.NewDecoder(resp.Body).Decode(&info)
+	if err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// GetMedia downloads the media of uri, the image of a metadata json, through
+// the gateways. Media larger than 20MB are refused
+func (c *Client) GetMedia(uri string) ([]byte, string, error) {
+	urls := GatewayURLs(uri, c.conf.IPFSGateways, c.conf.ArweaveGateways)
+	if len(urls) == 0 {
+		return nil, "", fmt.Errorf("nftmeta: unsupported uri %q", uri)
+	}
+	var lastErr error
+	delay := retryBaseDelay
+	for attempt := 0; attempt <= c.conf.Retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(delay)
+			delay = delay * 2
+		}
+		for _, u := range urls {
+			data, contentType, err := c.fetchMedia(u)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			return data, contentType, nil
+		}
+	}
+	return nil, "", fmt.Errorf("nftmeta: %s: %v", uri, lastErr)
+}
+
+func (c *Client) fetchMedia(u string) ([]byte, string, error) {
+	resp, err := c.client.Get(u)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, "", fmt.Errorf("http response bad status %d %s", resp.StatusCode, string(body))
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxMediaSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if len(data) > maxMediaSize {
+		return nil, "", fmt.Errorf("media larger than %d bytes", maxMediaSize)
+	}
+	// the type declared by the host is not trusted, the media is typed from
+	// its content
+	return data, http.DetectContentType(data), nil
+}
+
+func (c *Client) store(uri string, info *MetadataInfo) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := time.Now()
+	if len(c.cache) >= maxCacheSize {
+		for k, e := range c.cache {
+			if now.After(e.expiresAt) {
+				delete(c.cache, k)
+			}
+		}
+		if len(c.cache) >= maxCacheSize {
+			c.cache = map[string]*cacheEntry{}
+		}
+	}
+	c.cache[uri] = &cacheEntry{
+		info:      info,
+		expiresAt: now.Add(c.conf.CacheTTL),
+	}
+}
+
+// GatewayURLs returns the urls tried for uri: ipfs:// and ar:// uris are
+// resolved on every gateway, http urls on a known gateway are tried as is
+// then on the other gateways
+func GatewayURLs(uri string, ipfsGateways []string, arweaveGateways []string) []string {
+	uri = strings.TrimSpace(uri)
+	urls := []string{}
+	add := func(u string) {
+		for _, v := range urls {
+			if v == u {
+				return
+			}
+		}
+		urls = append(urls, u)
+	}
+	switch {
+	case strings.HasPrefix(uri, "ipfs://"):
+		{
+			path := strings.TrimPrefix(strings.TrimPrefix(uri, "ipfs://"), "ipfs/")
+			for _, gw := range ipfsGateways {
+				add(fmt.Sprintf("%s/ipfs/%s", strings.TrimRight(gw, "/"), path))
+			}
+		}
+	case strings.HasPrefix(uri, "ar://"):
+		{
+			path := strings.TrimPrefix(uri, "ar://")
+			for _, gw := range arweaveGateways {
+				add(fmt.Sprintf("%s/%s", strings.TrimRight(gw, "/"), path))
+			}
+		}
+	case strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://"):
+		{
+			add(uri)
+			u, err := url.Parse(uri)
+			if err != nil {
+				break
+			}
+			path := u.Path
+			if u.RawQuery != "" {
+				path = fmt.Sprintf("%s?%s", path, u.RawQuery)
+			}
+			if i := strings.Index(path, "/ipfs/"); i >= 0 {
+				for _, gw := range ipfsGateways {
+					add(fmt.Sprintf("%s%s", strings.TrimRight(gw, "/"), path[i:]))
+				}
+			} else if u.Host == "arweave.net" || strings.HasSuffix(u.Host, ".arweave.net") {
+				for _, gw := range arweaveGateways {
+					add(fmt.Sprintf("%s%s", strings.TrimRight(gw, "/"), path))
+				}
+			}
+		}
+	}
+	return urls
+}
@@ -0,0 +1,108 @@
+package nftmeta
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestPublicAddress(t *testing.T) {
+	for _, c := range []struct {
+		address string
+		public  bool
+	}{
+		{"1.1.1.1:443", true},
+		{"104.18.6.8:80", true},
+		{"[2606:4700::1111]:443", true},
+		{"127.0.0.1:80", false},
+		{"127.1.2.3:8080", false},
+		{"[::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"0.0.0.0:80", false},
+		{"[::]:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"192.168.1.1:80", false},
+		{"[fd00::1]:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"100.64.0.1:80", false},
+		{"224.0.0.1:80", false},
+		{"localhost:80", false},
+	} {
+		err := publicAddress(c.address)
+		if (err == nil) != c.public {
+			t.Errorf("publicAddress(%s) = %v, want public %v", c.address, err, c.public)
+		}
+	}
+}
+
+// countingServer serves handler and counts its requests
+func countingServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, *int32) {
+	t.Helper()
+	var n int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&n, 1)
+		handler(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &n
+}
+
+func serveMetadata(w http.ResponseWriter, r *http.Request) {
+	fmt.Fprint(w, `{"name":"Degen Ape #1024","image":"https://arweave.net/image"}`)
+}
+
+func TestClientRefusesLocalHosts(t *testing.T) {
+	srv, n := countingServer(t, serveMetadata)
+	c := NewClient(Config{Retries: -1})
+	_, err := c.GetMetadataInfo(fmt.Sprintf("%s/metadata.json", srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Fatalf("GetMetadataInfo of a loopback host = %v, want refused", err)
+	}
+	_, _, err = c.GetMedia(fmt.Sprintf("%s/image.png", srv.URL))
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Fatalf("GetMedia of a loopback host = %v, want refused", err)
+	}
+	if atomic.LoadInt32(n) != 0 {
+		t.Fatalf("loopback host got %d requests, want none", atomic.LoadInt32(n))
+	}
+}
+
+// the public server is the only address allowed, its redirects to an internal
+// address are refused
+func TestClientChecksRedirects(t *testing.T) {
+	internal, internalN := countingServer(t, serveMetadata)
+	public, _ := countingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/redirect" {
+			http.Redirect(w, r, fmt.Sprintf("%s/metadata.json", internal.URL), http.StatusFound)
+			return
+		}
+		serveMetadata(w, r)
+	})
+	c := NewClient(Config{Retries: -1})
+	c.checkAddress = func(address string) error {
+		if address != public.Listener.Addr().String() {
+			return publicAddress(address)
+		}
+		return nil
+	}
+	info, err := c.GetMetadataInfo(fmt.Sprintf("%s/metadata.json", public.URL))
+	if err != nil || info.Name != "Degen Ape #1024" {
+		t.Fatalf("GetMetadataInfo of the public host = %+v %v", info, err)
+	}
+	_, err = c.RefreshMetadataInfo(fmt.Sprintf("%s/redirect", public.URL))
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Fatalf("RefreshMetadataInfo redirected to an internal host = %v, want refused", err)
+	}
+	_, _, err = c.GetMedia(fmt.Sprintf("%s/redirect", public.URL))
+	if err == nil || !strings.Contains(err.Error(), "is not public") {
+		t.Fatalf("GetMedia redirected to an internal host = %v, want refused", err)
+	}
+	if atomic.LoadInt32(internalN) != 0 {
+		t.Fatalf("internal host got %d requests, want none", atomic.LoadInt32(internalN))
+	}
+}
@@ -0,0 +1,126 @@
+package saletrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	cloudflarebp "github.com/DaRealFreak/cloudflare-bp-go"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+)
+
+const (
+	MagicEdenAPIURL = "https://api-mainnet.magiceden.io"
+)
+
+type MagicEdenSaleResp struct {
+	TxType            string `json:"txType"`
+	TransactionID     string `json:"transaction_id"`
+	BlockTime         int64  `json:"blockTime"`
+	Mint              string `json:"mint"`
+	BuyerAddress      string `json:"buyer_address"`
+	SellerAddress     string `json:"seller_address"`
+	ParsedTransaction struct {
+		BuyerAddress  string `json:"buyer_address"`
+		SellerAddress string `json:"seller_address"`
+		TotalAmount   uint64 `json:"total_amount"`
+	} `json:"parsedTransaction"`
+}
+
+type MagicEdenProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+func NewMagicEdenProvider(apiURL string) *MagicEdenProvider {
+	client := newHTTPClient()
+	client.Transport = cloudflarebp.AddCloudFlareByPass(client.Transport)
+	return &MagicEdenProvider{
+		apiURL: apiURL,
+		client: client,
+	}
+}
+
+func (p *MagicEdenProvider) Source() string {
+	return "magiceden.io"
+}
+
+func (p *MagicEdenProvider) RateLimit() ratelimit.Rule {
+	return ratelimit.Rule{Limit: 60, Period: time.Minute}
+}
+
+func (p *MagicEdenProvider) GetSaleHistories(q *SaleQuery) ([]*Sale, error) {
+	page, err := p.GetSaleHistoryPage(q, "")
+	if err != nil {
+		return nil, err
+	}
+	return page.Sales, nil
+}
+
+// GetSaleHistoryPage reads the activities from the cursor, the cursor is the
+// number of activities already read. Activities other than sales count
+// towards it so the page after the last one is the first empty page
+func (p *MagicEdenProvider) GetSaleHistoryPage(q *SaleQuery, cursor string) (*SalePage, error) {
+	var skip int
+	if cursor != "" {
+		var err error
+		skip, err = strconv.Atoi(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	query, err := json.Marshal(map[string]interface{}{
+		"$match": map[string]interface{}{
+			"mint": q.ContractAddress,
+		},
+		"$sort": map[string]interface{}{
+			"blockTime": -1,
+			"createdAt": -1,
+		},
+		"$skip": skip,
+	})
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(fmt.Sprintf("%s/rpc/getGlobalActivitiesByQuery", p.apiURL))
+	if err != nil {
+		return nil, err
+	}
+	values := url.Values{}
+	values.Add("q", string(query))
+	uri.RawQuery = values.Encode()
+	var rs struct {
+		Results []*MagicEdenSaleResp `json:"results"`
+	}
+	err = getJSON(p.client, uri.String(), &rs)
+	if err != nil {
+		return nil, err
+	}
+	page := &SalePage{
+		Sales: []*Sale{},
+	}
+	if len(rs.Results) > 0 {
+		page.NextCursor = strconv.Itoa(skip + len(rs.Results))
+	}
+	for _, r := range rs.Results {
+		if r.TxType != "exchange" {
+			continue
+		}
+		txnAt := time.Unix(r.BlockTime, 0)
+		page.Sales = append(page.Sales, &Sale{
+			Source:          p.Source(),
+			ContractAddress: q.ContractAddress,
+			TransactionID:   r.TransactionID,
+			Seller:          r.SellerAddress,
+			Buyer:           r.BuyerAddress,
+			TransactionAt:   &txnAt,
+			Amount:          convertDecimals(new(big.Int).SetUint64(r.ParsedTransaction.TotalAmount), 9),
+			Currency:        "SOL",
+		})
+	}
+	return page, nil
+}
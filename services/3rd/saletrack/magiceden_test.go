@@ -0,0 +1,147 @@
+package saletrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// magicEdenActivities is the activity history of the test mint, newest first
+var magicEdenActivities = []string{
+	`{"txType":"exchange","transaction_id":"tx3","blockTime":1643673600,"mint":"m1","buyer_address":"b3","seller_address":"s3","parsedTransaction":{"total_amount":2500000000}}`,
+	`{"txType":"bid","transaction_id":"tx2","blockTime":1643673500,"mint":"m1"}`,
+	`{"txType":"exchange","transaction_id":"tx1","blockTime":1643673400,"mint":"m1","buyer_address":"b1","seller_address":"s1","parsedTransaction":{"total_amount":1000000000}}`,
+}
+
+func newMagicEdenServer(t *testing.T, pageSize int) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rpc/getGlobalActivitiesByQuery" {
+			http.NotFound(w, r)
+			return
+		}
+		var q struct {
+			Match struct {
+				Mint string `json:"mint"`
+			} `json:"$match"`
+			Sort map[string]int `json:"$sort"`
+			Skip int            `json:"$skip"`
+		}
+		err := json.Unmarshal([]byte(r.URL.Query().Get("q")), &q)
+		if err != nil || q.Match.Mint != "m1" || q.Sort["blockTime"] != -1 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		results := []string{}
+		for i := q.Skip; i < len(magicEdenActivities) && i < q.Skip+pageSize; i++ {
+			results = append(results, magicEdenActivities[i])
+		}
+		fmt.Fprintf(w, `{"results":[%s]}`, strings.Join(results, ","))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMagicEdenGetSaleHistoryPagePaginates(t *testing.T) {
+	srv := newMagicEdenServer(t, 2)
+	p := NewMagicEdenProvider(srv.URL)
+	q := &SaleQuery{ContractAddress: "m1"}
+	sales := []*Sale{}
+	cursors := []string{}
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		page, err := p.GetSaleHistoryPage(q, cursor)
+		if err != nil {
+			t.Fatalf("page %q: %v", cursor, err)
+		}
+		sales = append(sales, page.Sales...)
+		cursor = page.NextCursor
+		cursors = append(cursors, cursor)
+		if cursor == "" {
+			break
+		}
+	}
+	// the bid counts towards the cursor, the empty page ends the history
+	if strings.Join(cursors, ",") != "2,3," {
+		t.Fatalf("cursors = %q, want 2,3,", cursors)
+	}
+	if len(sales) != 2 {
+		t.Fatalf("sales = %d, want 2", len(sales))
+	}
+	want := []struct {
+		txID, seller, buyer, amount string
+		at                          int64
+	}{
+		{"tx3", "s3", "b3", "2.5", 1643673600},
+		{"tx1", "s1", "b1", "1", 1643673400},
+	}
+	for i, w := range want {
+		s := sales[i]
+		if s.Source != "magiceden.io" || s.ContractAddress != "m1" || s.TransactionID != w.txID || s.Seller != w.seller || s.Buyer != w.buyer || s.Currency != "SOL" {
+			t.Fatalf("sale %d = %+v", i, s)
+		}
+		if s.Amount.Text('f', -1) != w.amount {
+			t.Fatalf("sale %d amount = %s, want %s", i, s.Amount.Text('f', -1), w.amount)
+		}
+		if s.TransactionAt == nil || !s.TransactionAt.Equal(time.Unix(w.at, 0)) {
+			t.Fatalf("sale %d at = %v", i, s.TransactionAt)
+		}
+	}
+}
+
+func TestMagicEdenGetSaleHistoriesReadsFirstPage(t *testing.T) {
+	srv := newMagicEdenServer(t, 2)
+	p := NewMagicEdenProvider(srv.URL)
+	sales, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sales) != 1 || sales[0].TransactionID != "tx3" {
+		t.Fatalf("sales = %+v", sales)
+	}
+}
+
+func TestMagicEdenGetSaleHistoryPageInvalidCursor(t *testing.T) {
+	srv := newMagicEdenServer(t, 2)
+	p := NewMagicEdenProvider(srv.URL)
+	_, err := p.GetSaleHistoryPage(&SaleQuery{ContractAddress: "m1"}, "next")
+	if err == nil {
+		t.Fatal("invalid cursor accepted")
+	}
+}
+
+func TestMagicEdenGetSaleHistoryPageErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "server error", status: http.StatusServiceUnavailable, body: "busy", wantErr: "bad status 503 busy"},
+		{name: "rate limited", status: http.StatusTooManyRequests, body: "slow down", wantErr: "bad status 429"},
+		{name: "truncated", status: http.StatusOK, body: `{"results":[`},
+		{name: "results not a list", status: http.StatusOK, body: `{"results":"none"}`},
+		{name: "amount not a number", status: http.StatusOK, body: `{"results":[{"txType":"exchange","parsedTransaction":{"total_amount":"1"}}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			p := NewMagicEdenProvider(srv.URL)
+			page, err := p.GetSaleHistoryPage(&SaleQuery{ContractAddress: "m1"}, "")
+			if err == nil {
+				t.Fatalf("page = %+v, want error", page)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
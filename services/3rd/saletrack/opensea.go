@@ -0,0 +1,177 @@
+package saletrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+)
+
+const (
+	OpenseaAPIURL = "https://api.opensea.io"
+
+	openseaEventTimeFmt = "2006-01-02T15:04:05"
+)
+
+type OpenseaSaleResp struct {
+	Data struct {
+		AssetEvents struct {
+			Edges []struct {
+				Node struct {
+					EventTimestamp string `json:"eventTimestamp"`
+					Seller         struct {
+						Address string `json:"address"`
+					} `json:"seller"`
+					WinnerAccount struct {
+						Address string `json:"address"`
+					} `json:"winnerAccount"`
+					Price struct {
+						Quantity      string `json:"quantity"`
+						QuantityInEth string `json:"quantityInEth"`
+						Asset         struct {
+							Decimals *int32 `json:"decimals"`
+							Symbol   string `json:"symbol"`
+						} `json:"asset"`
+					} `json:"price"`
+					Transaction struct {
+						BlockExplorerLink string `json:"blockExplorerLink"`
+					} `json:"transaction"`
+				} `json:"node"`
+			} `json:"edges"`
+			PageInfo struct {
+				EndCursor   string `json:"endCursor"`
+				HasNextPage bool   `json:"hasNextPage"`
+			} `json:"pageInfo"`
+		} `json:"assetEvents"`
+	} `json:"data"`
+}
+
+type OpenseaProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+func NewOpenseaProvider(apiURL string) *OpenseaProvider {
+	return &OpenseaProvider{
+		apiURL: apiURL,
+		client: newHTTPClient(),
+	}
+}
+
+func (p *OpenseaProvider) Source() string {
+	return "opensea.io"
+}
+
+func (p *OpenseaProvider) RateLimit() ratelimit.Rule {
+	return ratelimit.Rule{Limit: 30, Period: time.Minute}
+}
+
+func (p *OpenseaProvider) GetSaleHistories(q *SaleQuery) ([]*Sale, error) {
+	page, err := p.GetSaleHistoryPage(q, "")
+	if err != nil {
+		return nil, err
+	}
+	return page.Sales, nil
+}
+
+// GetSaleHistoryPage reads the sale events after the cursor, the cursor is the
+// end cursor of the previous page
+func (p *OpenseaProvider) GetSaleHistoryPage(q *SaleQuery, cursor string) (*SalePage, error) {
+	var cursorVar interface{}
+	if cursor != "" {
+		cursorVar = cursor
+	}
+	tokenID, err := json.Marshal(q.TokenID)
+	if err != nil {
+		return nil, err
+	}
+	contractAddress, err := json.Marshal(q.ContractAddress)
+	if err != nil {
+		return nil, err
+	}
+	cursorBytes, err := json.Marshal(cursorVar)
+	if err != nil {
+		return nil, err
+	}
+	bodyBytes := fmt.Sprintf(
+		`{
+			"id": "EventHistoryQuery",
+			"query": "query EventHistoryQuery(\n  $archetype: ArchetypeInputType\n  $bundle: BundleSlug\n  $collections: [CollectionSlug!]\n  $categories: [CollectionSlug!]\n  $chains: [ChainScalar!]\n  $eventTypes: [EventType!]\n  $cursor: String\n  $count: Int = 16\n  $showAll: Boolean = false\n  $identity: IdentityInputType\n) {\n  ...EventHistory_data_L1XK6\n}\n\nfragment AccountLink_data on AccountType {\n  address\n  config\n  isCompromised\n  user {\n    publicUsername\n    id\n  }\n  displayName\n  ...ProfileImage_data\n  ...wallet_accountKey\n  ...accounts_url\n}\n\nfragment AssetCell_asset on AssetType {\n  collection {\n    name\n    id\n  }\n  name\n  ...AssetMedia_asset\n  ...asset_url\n}\n\nfragment AssetCell_assetBundle on AssetBundleType {\n  assetQuantities(first: 2) {\n    edges {\n      node {\n        asset {\n          collection {\n            name\n            id\n          }\n          name\n          ...AssetMedia_asset\n          ...asset_url\n          id\n        }\n        relayId\n        id\n      }\n    }\n  }\n  name\n  ...bundle_url\n}\n\nfragment AssetMedia_asset on AssetType {\n  animationUrl\n  backgroundColor\n  collection {\n    displayData {\n      cardDisplayStyle\n    }\n    id\n  }\n  isDelisted\n  imageUrl\n  displayImageUrl\n}\n\nfragment AssetQuantity_data on AssetQuantityType {\n  asset {\n    ...Price_data\n    id\n  }\n  quantity\n}\n\nfragment CollectionLink_assetContract on AssetContractType {\n  address\n  blockExplorerLink\n}\n\nfragment CollectionLink_collection on CollectionType {\n  name\n  ...collection_url\n  ...verification_data\n}\n\nfragment EventHistory_data_L1XK6 on Query {\n  assetEvents(after: $cursor, bundle: $bundle, archetype: $archetype, first: $count, categories: $categories, collections: $collections, chains: $chains, eventTypes: $eventTypes, identity: $identity, includeHidden: true) {\n    edges {\n      node {\n        assetBundle @include(if: $showAll) {\n          relayId\n          ...AssetCell_assetBundle\n          ...bundle_url\n          id\n        }\n        assetQuantity {\n          asset @include(if: $showAll) {\n            relayId\n            assetContract {\n              ...CollectionLink_assetContract\n              id\n            }\n            ...AssetCell_asset\n            ...asset_url\n            collection {\n              ...CollectionLink_collection\n              id\n            }\n            id\n          }\n          ...quantity_data\n          id\n        }\n        relayId\n        eventTimestamp\n        eventType\n        offerExpired\n        customEventName\n        ...utilsAssetEventLabel\n        devFee {\n          asset {\n            assetContract {\n              chain\n              id\n            }\n            id\n          }\n          quantity\n          ...AssetQuantity_data\n          id\n        }\n        devFeePaymentEvent {\n          ...EventTimestamp_data\n          id\n        }\n        fromAccount {\n          address\n          ...AccountLink_data\n          id\n        }\n        price {\n          quantity\n          quantityInEth\n          ...AssetQuantity_data\n          id\n        }\n        endingPrice {\n          quantity\n          ...AssetQuantity_data\n          id\n        }\n        seller {\n          ...AccountLink_data\n          id\n        }\n        toAccount {\n          ...AccountLink_data\n          id\n        }\n        winnerAccount {\n          ...AccountLink_data\n          id\n        }\n        ...EventTimestamp_data\n        id\n        __typename\n      }\n      cursor\n    }\n    pageInfo {\n      endCursor\n      hasNextPage\n    }\n  }\n}\n\nfragment EventTimestamp_data on AssetEventType {\n  eventTimestamp\n  transaction {\n    blockExplorerLink\n    id\n  }\n}\n\nfragment Price_data on AssetType {\n  decimals\n  imageUrl\n  symbol\n  usdSpotPrice\n  assetContract {\n    blockExplorerLink\n    chain\n    id\n  }\n}\n\nfragment ProfileImage_data on AccountType {\n  imageUrl\n}\n\nfragment accounts_url on AccountType {\n  address\n  user {\n    publicUsername\n    id\n  }\n}\n\nfragment asset_url on AssetType {\n  assetContract {\n    address\n    chain\n    id\n  }\n  tokenId\n}\n\nfragment bundle_url on AssetBundleType {\n  slug\n}\n\nfragment collection_url on CollectionType {\n  slug\n}\n\nfragment quantity_data on AssetQuantityType {\n  asset {\n    decimals\n    id\n  }\n  quantity\n}\n\nfragment utilsAssetEventLabel on AssetEventType {\n  isMint\n  eventType\n}\n\nfragment verification_data on CollectionType {\n  isMintable\n  isSafelisted\n  isVerified\n}\n\nfragment wallet_accountKey on AccountType {\n  address\n}\n",
+			"variables": {
+				"archetype": {
+					"chain": "ETHEREUM",
+					"tokenId": %s,
+					"assetContractAddress": %s
+				},
+				"bundle": null,
+				"collections": null,
+				"categories": null,
+				"chains": null,
+				"eventTypes": [
+					"AUCTION_SUCCESSFUL"
+				],
+				"cursor": %s,
+				"count": 16,
+				"showAll": false,
+				"identity": null
+			}
+		}`,
+		string(tokenID),
+		string(contractAddress),
+		string(cursorBytes),
+	)
+	var rs OpenseaSaleResp
+	err = postJSON(p.client, fmt.Sprintf("%s/graphql/", p.apiURL), []byte(bodyBytes), &rs)
+	if err != nil {
+		return nil, err
+	}
+	page := &SalePage{
+		Sales: []*Sale{},
+	}
+	if rs.Data.AssetEvents.PageInfo.HasNextPage {
+		page.NextCursor = rs.Data.AssetEvents.PageInfo.EndCursor
+	}
+	for _, edge := range rs.Data.AssetEvents.Edges {
+		// the price is in the payment token of the sale, events without it
+		// only report the eth value
+		quantity, decimals, currency := edge.Node.Price.Quantity, int32(18), edge.Node.Price.Asset.Symbol
+		if currency == "" || edge.Node.Price.Asset.Decimals == nil {
+			quantity, currency = edge.Node.Price.QuantityInEth, "ETH"
+		} else {
+			decimals = *edge.Node.Price.Asset.Decimals
+		}
+		amount, ok := new(big.Int).SetString(quantity, 10)
+		if !ok {
+			return nil, fmt.Errorf("invalid price %s", quantity)
+		}
+		sale := &Sale{
+			Source:          p.Source(),
+			ContractAddress: q.ContractAddress,
+			TokenID:         q.TokenID,
+			TransactionID:   openseaTransactionID(edge.Node.Transaction.BlockExplorerLink),
+			Seller:          edge.Node.Seller.Address,
+			Buyer:           edge.Node.WinnerAccount.Address,
+			Amount:          convertDecimals(amount, decimals),
+			Currency:        currency,
+		}
+		txnAt, err := time.Parse(openseaEventTimeFmt, edge.Node.EventTimestamp)
+		if err == nil {
+			sale.TransactionAt = &txnAt
+		}
+		page.Sales = append(page.Sales, sale)
+	}
+	return page, nil
+}
+
+// openseaTransactionID takes the hash from the etherscan link of the event
+func openseaTransactionID(link string) string {
+	idx := strings.LastIndex(link, "/")
+	if idx < 0 {
+		return link
+	}
+	return link[idx+1:]
+}
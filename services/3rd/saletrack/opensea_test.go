@@ -0,0 +1,212 @@
+package saletrack
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type openseaRequest struct {
+	ID        string `json:"id"`
+	Variables struct {
+		Archetype struct {
+			Chain                string `json:"chain"`
+			TokenID              string `json:"tokenId"`
+			AssetContractAddress string `json:"assetContractAddress"`
+		} `json:"archetype"`
+		EventTypes []string `json:"eventTypes"`
+		Cursor     *string  `json:"cursor"`
+	} `json:"variables"`
+}
+
+func openseaEdge(at string, seller string, buyer string, price string, tx string) string {
+	return fmt.Sprintf(`{"node":{"eventTimestamp":"%s","seller":{"address":"%s"},"winnerAccount":{"address":"%s"},"price":%s,"transaction":{"blockExplorerLink":"https://etherscan.io/tx/%s"}}}`, at, seller, buyer, price, tx)
+}
+
+func openseaEvents(edges []string, endCursor string, hasNextPage bool) string {
+	return fmt.Sprintf(`{"data":{"assetEvents":{"edges":[%s],"pageInfo":{"endCursor":"%s","hasNextPage":%t}}}}`, strings.Join(edges, ","), endCursor, hasNextPage)
+}
+
+// newOpenseaServer serves the pages by cursor, the first page is under ""
+func newOpenseaServer(t *testing.T, q *SaleQuery, pages map[string]string) (*httptest.Server, *[]openseaRequest) {
+	t.Helper()
+	reqs := []openseaRequest{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var req openseaRequest
+		err = json.Unmarshal(body, &req)
+		if err != nil || r.Method != http.MethodPost || r.URL.Path != "/graphql/" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		reqs = append(reqs, req)
+		if req.Variables.Archetype.TokenID != q.TokenID || req.Variables.Archetype.AssetContractAddress != q.ContractAddress {
+			http.Error(w, "unknown asset", http.StatusBadRequest)
+			return
+		}
+		cursor := ""
+		if req.Variables.Cursor != nil {
+			cursor = *req.Variables.Cursor
+		}
+		page, ok := pages[cursor]
+		if !ok {
+			http.Error(w, "unknown cursor", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, page)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &reqs
+}
+
+func TestOpenseaGetSaleHistoryPagePaginates(t *testing.T) {
+	q := &SaleQuery{ContractAddress: "0xabc", TokenID: `1"2`}
+	srv, reqs := newOpenseaServer(t, q, map[string]string{
+		"": openseaEvents(
+			[]string{
+				openseaEdge("2022-02-01T10:00:00", "s3", "b3", `{"quantity":"2500000","quantityInEth":"1000000000000000000","asset":{"decimals":6,"symbol":"USDC"}}`, "0x3"),
+				openseaEdge("2022-01-15T10:00:00", "s2", "b2", `{"quantity":"0","quantityInEth":"1500000000000000000"}`, "0x2"),
+			},
+			"c1",
+			true,
+		),
+		"c1": openseaEvents(
+			[]string{
+				openseaEdge("bad time", "s1", "b1", `{"quantity":"2000000000000000000","quantityInEth":"2000000000000000000","asset":{"decimals":18,"symbol":"WETH"}}`, "0x1"),
+			},
+			"c2",
+			false,
+		),
+	})
+	p := NewOpenseaProvider(srv.URL)
+	sales := []*Sale{}
+	cursors := []string{}
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		page, err := p.GetSaleHistoryPage(q, cursor)
+		if err != nil {
+			t.Fatalf("page %q: %v", cursor, err)
+		}
+		sales = append(sales, page.Sales...)
+		cursor = page.NextCursor
+		cursors = append(cursors, cursor)
+		if cursor == "" {
+			break
+		}
+	}
+	if strings.Join(cursors, ",") != "c1," {
+		t.Fatalf("cursors = %q, want c1,", cursors)
+	}
+	if len(*reqs) != 2 || (*reqs)[0].Variables.Cursor != nil || *(*reqs)[1].Variables.Cursor != "c1" {
+		t.Fatalf("requests = %+v", *reqs)
+	}
+	for _, req := range *reqs {
+		if req.ID != "EventHistoryQuery" || req.Variables.Archetype.Chain != "ETHEREUM" || len(req.Variables.EventTypes) != 1 || req.Variables.EventTypes[0] != "AUCTION_SUCCESSFUL" {
+			t.Fatalf("request = %+v", req)
+		}
+	}
+	want := []struct {
+		txID, seller, buyer, amount, currency string
+		at                                    *time.Time
+	}{
+		// the payment token wins over the eth value
+		{"0x3", "s3", "b3", "2.5", "USDC", timePtr(time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC))},
+		// without the payment token the eth value is used
+		{"0x2", "s2", "b2", "1.5", "ETH", timePtr(time.Date(2022, 1, 15, 10, 0, 0, 0, time.UTC))},
+		{"0x1", "s1", "b1", "2", "WETH", nil},
+	}
+	if len(sales) != len(want) {
+		t.Fatalf("sales = %d, want %d", len(sales), len(want))
+	}
+	for i, w := range want {
+		s := sales[i]
+		if s.Source != "opensea.io" || s.ContractAddress != q.ContractAddress || s.TokenID != q.TokenID || s.TransactionID != w.txID || s.Seller != w.seller || s.Buyer != w.buyer || s.Currency != w.currency {
+			t.Fatalf("sale %d = %+v", i, s)
+		}
+		if s.Amount.Text('f', -1) != w.amount {
+			t.Fatalf("sale %d amount = %s, want %s", i, s.Amount.Text('f', -1), w.amount)
+		}
+		if (s.TransactionAt == nil) != (w.at == nil) || (w.at != nil && !s.TransactionAt.Equal(*w.at)) {
+			t.Fatalf("sale %d at = %v, want %v", i, s.TransactionAt, w.at)
+		}
+	}
+}
+
+func TestOpenseaGetSaleHistoriesReadsFirstPage(t *testing.T) {
+	q := &SaleQuery{ContractAddress: "0xabc", TokenID: "1"}
+	srv, reqs := newOpenseaServer(t, q, map[string]string{
+		"": openseaEvents(
+			[]string{
+				openseaEdge("2022-02-01T10:00:00", "s1", "b1", `{"quantityInEth":"1000000000000000000"}`, "0x1"),
+			},
+			"c1",
+			true,
+		),
+	})
+	p := NewOpenseaProvider(srv.URL)
+	sales, err := p.GetSaleHistories(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sales) != 1 || sales[0].TransactionID != "0x1" || len(*reqs) != 1 {
+		t.Fatalf("sales = %+v, requests = %d", sales, len(*reqs))
+	}
+}
+
+func TestOpenseaGetSaleHistoryPageErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "rate limited", status: http.StatusTooManyRequests, body: "slow down", wantErr: "bad status 429 slow down"},
+		{name: "forbidden", status: http.StatusForbidden, body: "", wantErr: "bad status 403"},
+		{name: "truncated", status: http.StatusOK, body: `{"data":{"assetEvents":`},
+		{name: "edges not a list", status: http.StatusOK, body: `{"data":{"assetEvents":{"edges":{}}}}`},
+		{name: "price not an integer", status: http.StatusOK, body: openseaEvents([]string{openseaEdge("2022-02-01T10:00:00", "s", "b", `{"quantityInEth":"1.5"}`, "0x1")}, "", false), wantErr: "invalid price 1.5"},
+		{name: "price missing", status: http.StatusOK, body: openseaEvents([]string{openseaEdge("2022-02-01T10:00:00", "s", "b", `{}`, "0x1")}, "", false), wantErr: "invalid price"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			p := NewOpenseaProvider(srv.URL)
+			page, err := p.GetSaleHistoryPage(&SaleQuery{ContractAddress: "0xabc", TokenID: "1"}, "")
+			if err == nil {
+				t.Fatalf("page = %+v, want error", page)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOpenseaTransactionID(t *testing.T) {
+	for link, want := range map[string]string{
+		"https://etherscan.io/tx/0x1": "0x1",
+		"0x2":                         "0x2",
+		"":                            "",
+	} {
+		if got := openseaTransactionID(link); got != want {
+			t.Fatalf("openseaTransactionID(%q) = %q, want %q", link, got, want)
+		}
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
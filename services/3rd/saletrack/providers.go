@@ -0,0 +1,112 @@
+package saletrack
+
+import (
+	"context"
+	"math/big"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+	"github.com/shopspring/decimal"
+)
+
+// Sale is a marketplace sale normalized by its provider, Amount is in units of
+// Currency
+type Sale struct {
+	Source          string
+	ContractAddress string
+	TokenID         string
+	TransactionID   string
+	Seller          string
+	Buyer           string
+	TransactionAt   *time.Time
+	Amount          *big.Float
+	Currency        string
+}
+
+// SaleQuery identifies the nft on the network of the provider, TokenID is only
+// used by the networks where a contract holds several tokens
+type SaleQuery struct {
+	ContractAddress string
+	TokenID         string
+}
+
+// SaleHistoryProvider fetches the sales of one marketplace, adding a
+// marketplace means writing one provider and registering it
+type SaleHistoryProvider interface {
+	Source() string
+	RateLimit() ratelimit.Rule
+	GetSaleHistories(q *SaleQuery) ([]*Sale, error)
+}
+
+// SalePage is one page of a paginated history, newest sales first. NextCursor
+// is empty on the oldest page
+type SalePage struct {
+	Sales      []*Sale
+	NextCursor string
+}
+
+// SalePageProvider is a provider whose history is paginated, crawls walk it
+// from the newest page and resume a long history from the cursor of the last
+// page they read
+type SalePageProvider interface {
+	SaleHistoryProvider
+	GetSaleHistoryPage(q *SaleQuery, cursor string) (*SalePage, error)
+}
+
+// SaleStreamProvider is a provider which delivers its sales asynchronously,
+// GetSaleHistories only requests them. StartSaleStream returns once ctx is done
+type SaleStreamProvider interface {
+	SaleHistoryProvider
+	StartSaleStream(ctx context.Context, salesReceivedFunc func(sales []*Sale))
+	Health() *SaleStreamHealth
+}
+
+type SaleStreamHealth struct {
+	Source        string
+	Connected     bool
+	ConnectedAt   time.Time
+	LastMessageAt time.Time
+	LastError     string
+	LastErrorAt   time.Time
+	Reconnects    uint
+	Pending       int
+}
+
+// Registry keeps the providers of every network, it is filled on start
+type Registry struct {
+	networks  map[string][]SaleHistoryProvider
+	sources   map[string]SaleHistoryProvider
+	providers []SaleHistoryProvider
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		networks: map[string][]SaleHistoryProvider{},
+		sources:  map[string]SaleHistoryProvider{},
+	}
+}
+
+func (r *Registry) Register(network string, p SaleHistoryProvider) {
+	r.networks[network] = append(r.networks[network], p)
+	if _, ok := r.sources[p.Source()]; !ok {
+		r.sources[p.Source()] = p
+		r.providers = append(r.providers, p)
+	}
+}
+
+func (r *Registry) Providers(network string) []SaleHistoryProvider {
+	return r.networks[network]
+}
+
+func (r *Registry) Provider(source string) SaleHistoryProvider {
+	return r.sources[source]
+}
+
+func (r *Registry) All() []SaleHistoryProvider {
+	return r.providers
+}
+
+// convertDecimals converts an integer amount of the smallest unit
+func convertDecimals(amount *big.Int, decimals int32) *big.Float {
+	return decimal.NewFromBigInt(amount, -decimals).BigFloat()
+}
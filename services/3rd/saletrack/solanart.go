@@ -0,0 +1,65 @@
+package saletrack
+
+import (
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+)
+
+const (
+	SolanartAPIURL = "https://api.solanart.io"
+)
+
+type SolnartSaleResp struct {
+	Date          *time.Time `json:"date"`
+	Mint          string     `json:"mint"`
+	BuyerAdd      string     `json:"buyerAdd"`
+	SellerAddress string     `json:"seller_address"`
+	Price         float64    `json:"price"`
+	Currency      string     `json:"currency"`
+}
+
+type SolanartProvider struct {
+	apiURL string
+	client *http.Client
+}
+
+func NewSolanartProvider(apiURL string) *SolanartProvider {
+	return &SolanartProvider{
+		apiURL: apiURL,
+		client: newHTTPClient(),
+	}
+}
+
+func (p *SolanartProvider) Source() string {
+	return "solanart.io"
+}
+
+func (p *SolanartProvider) RateLimit() ratelimit.Rule {
+	return ratelimit.Rule{Limit: 60, Period: time.Minute}
+}
+
+func (p *SolanartProvider) GetSaleHistories(q *SaleQuery) ([]*Sale, error) {
+	var rs []*SolnartSaleResp
+	err := getJSON(p.client, fmt.Sprintf("%s/last_sales_token?address=%s", p.apiURL, url.QueryEscape(q.ContractAddress)), &rs)
+	if err != nil {
+		return nil, err
+	}
+	sales := []*Sale{}
+	for _, r := range rs {
+		sales = append(sales, &Sale{
+			Source:          p.Source(),
+			ContractAddress: q.ContractAddress,
+			Seller:          r.SellerAddress,
+			Buyer:           r.BuyerAdd,
+			TransactionAt:   r.Date,
+			Amount:          big.NewFloat(r.Price),
+			Currency:        r.Currency,
+		})
+	}
+	return sales, nil
+}
@@ -0,0 +1,107 @@
+package saletrack
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSolanartGetSaleHistories(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/last_sales_token" || r.URL.Query().Get("address") != "m1&x=1" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `[
+			{"date":"2022-02-01T10:00:00Z","mint":"m1","buyerAdd":"b2","seller_address":"s2","price":2.5,"currency":"SOL"},
+			{"date":"2022-01-01T10:00:00Z","mint":"m1","buyerAdd":"b1","seller_address":"s1","price":100,"currency":"USDC"}
+		]`)
+	}))
+	defer srv.Close()
+	p := NewSolanartProvider(srv.URL)
+	sales, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1&x=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the history is not paginated, one request returns all of it
+	if requests != 1 {
+		t.Fatalf("requests = %d, want 1", requests)
+	}
+	want := []struct {
+		seller, buyer, amount, currency string
+		at                              time.Time
+	}{
+		{"s2", "b2", "2.5", "SOL", time.Date(2022, 2, 1, 10, 0, 0, 0, time.UTC)},
+		{"s1", "b1", "100", "USDC", time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)},
+	}
+	if len(sales) != len(want) {
+		t.Fatalf("sales = %d, want %d", len(sales), len(want))
+	}
+	for i, w := range want {
+		s := sales[i]
+		if s.Source != "solanart.io" || s.ContractAddress != "m1&x=1" || s.Seller != w.seller || s.Buyer != w.buyer || s.Currency != w.currency {
+			t.Fatalf("sale %d = %+v", i, s)
+		}
+		if s.Amount.Text('f', -1) != w.amount {
+			t.Fatalf("sale %d amount = %s, want %s", i, s.Amount.Text('f', -1), w.amount)
+		}
+		if s.TransactionAt == nil || !s.TransactionAt.Equal(w.at) {
+			t.Fatalf("sale %d at = %v", i, s.TransactionAt)
+		}
+	}
+}
+
+func TestSolanartGetSaleHistoriesEmpty(t *testing.T) {
+	for _, body := range []string{"[]", "null"} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, body)
+		}))
+		p := NewSolanartProvider(srv.URL)
+		sales, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1"})
+		srv.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		if sales == nil || len(sales) != 0 {
+			t.Fatalf("%s: sales = %v, want empty", body, sales)
+		}
+	}
+}
+
+func TestSolanartGetSaleHistoriesErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr string
+	}{
+		{name: "not found", status: http.StatusNotFound, body: "no such token", wantErr: "bad status 404 no such token"},
+		{name: "server error", status: http.StatusBadGateway, body: "", wantErr: "bad status 502"},
+		{name: "truncated", status: http.StatusOK, body: `[{"mint":"m1"`},
+		{name: "object instead of list", status: http.StatusOK, body: `{"error":"x"}`},
+		{name: "price not a number", status: http.StatusOK, body: `[{"mint":"m1","price":"1"}]`},
+		{name: "invalid date", status: http.StatusOK, body: `[{"mint":"m1","date":"yesterday"}]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(tt.status)
+				fmt.Fprint(w, tt.body)
+			}))
+			defer srv.Close()
+			p := NewSolanartProvider(srv.URL)
+			sales, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1"})
+			if err == nil {
+				t.Fatalf("sales = %+v, want error", sales)
+			}
+			if tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package saletrack
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	SolseaWssURL = "wss://api.all.art/socket.io/?EIO=3&transport=websocket"
+
+	solseaOutboxSize      = 256
+	solseaPendingTTL      = 10 * time.Minute
+	solseaPingInterval    = 5 * time.Second
+	solseaReadTimeout     = 30 * time.Second
+	solseaBackoffMin      = time.Second
+	solseaBackoffMax      = time.Minute
+	solseaFindMsgPrefix   = `421["find","listed-archive",`
+	solseaFindReplyPrefix = "431"
+)
+
+var errSolseaQueueFull = errors.New("solsea request queue is full")
+
+type SolseaSaleResp struct {
+	Mint      string     `json:"mint"`
+	Price     uint64     `json:"price"`
+	SellerKey string     `json:"sellerKey"`
+	BuyerKey  string     `json:"buyerKey"`
+	Status    string     `json:"status"`
+	ListedAt  *time.Time `json:"listedAt"`
+}
+
+// SolseaProvider requests the sales over the solsea websocket, the sales are
+// delivered to the stream started with StartSaleStream. Requests are kept
+// pending until their reply arrives so they can be sent again after a reconnect
+type SolseaProvider struct {
+	wssURL     string
+	outbox     chan string
+	mtx        sync.Mutex
+	pending    map[string]*solseaPending
+	health     SaleStreamHealth
+	backoffMin time.Duration
+	backoffMax time.Duration
+}
+
+type solseaPending struct {
+	mint    string
+	msg     string
+	addedAt time.Time
+}
+
+func NewSolseaProvider(wssURL string) *SolseaProvider {
+	return &SolseaProvider{
+		wssURL:     wssURL,
+		outbox:     make(chan string, solseaOutboxSize),
+		pending:    map[string]*solseaPending{},
+		backoffMin: solseaBackoffMin,
+		backoffMax: solseaBackoffMax,
+	}
+}
+
+func (p *SolseaProvider) Source() string {
+	return "solsea.io"
+}
+
+func (p *SolseaProvider) RateLimit() ratelimit.Rule {
+	return ratelimit.Rule{Limit: 120, Period: time.Minute}
+}
+
+// GetSaleHistories queues a find request, a mint already waiting for its
+// reply is not queued again. It fails instead of blocking when too many
+// requests are waiting
+func (p *SolseaProvider) GetSaleHistories(q *SaleQuery) ([]*Sale, error) {
+	msg := fmt.Sprintf(`%s{"Mint":"%s","status":"SOLD"}]`, solseaFindMsgPrefix, q.ContractAddress)
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.expirePending(time.Now())
+	if _, ok := p.pending[q.ContractAddress]; ok {
+		return nil, nil
+	}
+	if len(p.pending) >= solseaOutboxSize {
+		return nil, errSolseaQueueFull
+	}
+	select {
+	case p.outbox <- msg:
+		{
+			p.pending[q.ContractAddress] = &solseaPending{
+				mint:    q.ContractAddress,
+				msg:     msg,
+				addedAt: time.Now(),
+			}
+		}
+	default:
+		{
+			return nil, errSolseaQueueFull
+		}
+	}
+	return nil, nil
+}
+
+func (p *SolseaProvider) Health() *SaleStreamHealth {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	h := p.health
+	h.Source = p.Source()
+	h.Pending = len(p.pending)
+	return &h
+}
+
+// expirePending drops the requests never answered, solsea does not reply to a
+// find without results
+func (p *SolseaProvider) expirePending(now time.Time) {
+	for mint, m := range p.pending {
+		if now.Sub(m.addedAt) >= solseaPendingTTL {
+			delete(p.pending, mint)
+		}
+	}
+}
+
+func (p *SolseaProvider) resolvePending(sales []*Sale) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	for _, sale := range sales {
+		delete(p.pending, sale.ContractAddress)
+	}
+}
+
+// pendingMsgs drains the outbox and returns every pending request, they are
+// sent once on the new connection
+func (p *SolseaProvider) pendingMsgs() []string {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+drain:
+	for {
+		select {
+		case <-p.outbox:
+		default:
+			break drain
+		}
+	}
+	p.expirePending(time.Now())
+	msgs := []string{}
+	for _, m := range p.pending {
+		msgs = append(msgs, m.msg)
+	}
+	return msgs
+}
+
+func (p *SolseaProvider) setConnected(connected bool, err error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.health.Connected = connected
+	if connected {
+		p.health.ConnectedAt = time.Now()
+		p.health.LastError = ""
+	}
+	if err != nil {
+		p.health.LastError = err.Error()
+		p.health.LastErrorAt = time.Now()
+	}
+}
+
+func (p *SolseaProvider) setReceived() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.health.LastMessageAt = time.Now()
+}
+
+func (p *SolseaProvider) setReconnecting() {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.health.Reconnects++
+}
+
+// parseSales reads the sales out of a find reply, other messages are ignored
+func (p *SolseaProvider) parseSales(msg string) ([]*Sale, error) {
+	if !strings.HasPrefix(msg, solseaFindReplyPrefix) {
+		return nil, nil
+	}
+	msg = strings.TrimPrefix(msg, solseaFindReplyPrefix)
+	resps := []*struct {
+		Data []*SolseaSaleResp `json:"data"`
+	}{}
+	err := json.Unmarshal([]byte(msg), &resps)
+	if err != nil {
+		return nil, err
+	}
+	sales := []*Sale{}
+	for _, resp := range resps {
+		if resp == nil {
+			continue
+		}
+		for _, d := range resp.Data {
+			sales = append(sales, &Sale{
+				Source:          p.Source(),
+				ContractAddress: d.Mint,
+				Seller:          d.SellerKey,
+				Buyer:           d.BuyerKey,
+				TransactionAt:   d.ListedAt,
+				Amount:          convertDecimals(new(big.Int).SetUint64(d.Price), 9),
+				Currency:        "SOL",
+			})
+		}
+	}
+	return sales, nil
+}
+
+// StartSaleStream keeps the websocket connected, it reconnects with an
+// exponential backoff and only returns once ctx is done
+func (p *SolseaProvider) StartSaleStream(ctx context.Context, salesReceivedFunc func(sales []*Sale)) {
+	p.stream(ctx.Done(), salesReceivedFunc)
+}
+
+func (p *SolseaProvider) stream(interrupt <-chan struct{}, salesReceivedFunc func(sales []*Sale)) {
+	backoff := p.backoffMin
+	for {
+		connected, err := p.runSession(interrupt, salesReceivedFunc)
+		if err == nil {
+			return
+		}
+		p.setConnected(false, err)
+		log.Println("solsea:", err)
+		if connected {
+			backoff = p.backoffMin
+		}
+		select {
+		case <-interrupt:
+			return
+		case <-time.After(backoff):
+		}
+		p.setReconnecting()
+		backoff = backoff * 2
+		if backoff > p.backoffMax {
+			backoff = p.backoffMax
+		}
+	}
+}
+
+// runSession serves one connection until it fails, a nil error means the
+// stream was interrupted
+func (p *SolseaProvider) runSession(interrupt <-chan struct{}, salesReceivedFunc func(sales []*Sale)) (bool, error) {
+	wc, _, err := websocket.DefaultDialer.Dial(p.wssURL, nil)
+	if err != nil {
+		return false, fmt.Errorf("dial: %v", err)
+	}
+	defer wc.Close()
+	p.setConnected(true, nil)
+	for _, msg := range p.pendingMsgs() {
+		err := wc.WriteMessage(websocket.TextMessage, []byte(msg))
+		if err != nil {
+			return true, fmt.Errorf("write: %v", err)
+		}
+	}
+	done := make(chan error, 1)
+	go func() {
+		for {
+			wc.SetReadDeadline(time.Now().Add(solseaReadTimeout))
+			_, message, err := wc.ReadMessage()
+			if err != nil {
+				done <- fmt.Errorf("read: %v", err)
+				return
+			}
+			p.setReceived()
+			sales, err := p.parseSales(string(message))
+			if err != nil {
+				log.Println("solsea parse:", err)
+				continue
+			}
+			if len(sales) > 0 {
+				p.resolvePending(sales)
+				if salesReceivedFunc != nil {
+					salesReceivedFunc(sales)
+				}
+			}
+		}
+	}()
+	ticker := time.NewTicker(solseaPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case err := <-done:
+			{
+				return true, err
+			}
+		case <-ticker.C:
+			{
+				err := wc.WriteMessage(websocket.TextMessage, []byte("2"))
+				if err != nil {
+					return true, fmt.Errorf("write: %v", err)
+				}
+			}
+		case msg := <-p.outbox:
+			{
+				err := wc.WriteMessage(websocket.TextMessage, []byte(msg))
+				if err != nil {
+					return true, fmt.Errorf("write: %v", err)
+				}
+			}
+		case <-interrupt:
+			{
+				err := wc.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+				if err != nil {
+					return true, nil
+				}
+				select {
+				case <-done:
+				case <-time.After(time.Second):
+				}
+				return true, nil
+			}
+		}
+	}
+}
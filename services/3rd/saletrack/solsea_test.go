@@ -0,0 +1,325 @@
+package saletrack
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// solseaServer is a local solsea websocket, serve is called with the number of
+// the connection and returns to close it
+type solseaServer struct {
+	*httptest.Server
+	mtx   sync.Mutex
+	conns int
+	msgs  []string
+}
+
+func newSolseaServer(t *testing.T, serve func(s *solseaServer, n int, c *websocket.Conn)) *solseaServer {
+	t.Helper()
+	s := &solseaServer{}
+	up := websocket.Upgrader{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := up.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		s.mtx.Lock()
+		s.conns++
+		n := s.conns
+		s.mtx.Unlock()
+		serve(s, n, c)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *solseaServer) wssURL() string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+// read returns the next find request, the pings are skipped
+func (s *solseaServer) read(c *websocket.Conn) (string, error) {
+	for {
+		_, msg, err := c.ReadMessage()
+		if err != nil {
+			return "", err
+		}
+		if string(msg) == "2" {
+			continue
+		}
+		s.mtx.Lock()
+		s.msgs = append(s.msgs, string(msg))
+		s.mtx.Unlock()
+		return string(msg), nil
+	}
+}
+
+func (s *solseaServer) received() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string{}, s.msgs...)
+}
+
+func solseaFindReply(mint string, price uint64) string {
+	return fmt.Sprintf(`%s[{"data":[{"mint":"%s","price":%d,"sellerKey":"s","buyerKey":"b","status":"SOLD","listedAt":"2022-02-01T00:00:00Z"}]}]`, solseaFindReplyPrefix, mint, price)
+}
+
+func solseaFindMsg(mint string) string {
+	return fmt.Sprintf(`%s{"Mint":"%s","status":"SOLD"}]`, solseaFindMsgPrefix, mint)
+}
+
+// startSolseaStream runs the stream until the returned stop is called
+func startSolseaStream(t *testing.T, p *SolseaProvider, salesReceivedFunc func(sales []*Sale)) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.StartSaleStream(ctx, salesReceivedFunc)
+		close(done)
+	}()
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			cancel()
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("stream did not stop")
+			}
+		})
+	}
+	t.Cleanup(stop)
+	return stop
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSolseaGetSaleHistoriesQueuesPendingOnce(t *testing.T) {
+	p := NewSolseaProvider("")
+	for _, mint := range []string{"m1", "m1", "m2", "m1"} {
+		sales, err := p.GetSaleHistories(&SaleQuery{ContractAddress: mint})
+		if err != nil {
+			t.Fatalf("GetSaleHistories(%s): %v", mint, err)
+		}
+		if sales != nil {
+			t.Fatalf("GetSaleHistories(%s) = %v, want nil", mint, sales)
+		}
+	}
+	if len(p.outbox) != 2 {
+		t.Fatalf("outbox = %d, want 2", len(p.outbox))
+	}
+	if h := p.Health(); h.Pending != 2 {
+		t.Fatalf("pending = %d, want 2", h.Pending)
+	}
+	// an expired request is queued again
+	p.pending["m1"].addedAt = time.Now().Add(-solseaPendingTTL)
+	_, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.outbox) != 3 {
+		t.Fatalf("outbox = %d, want 3", len(p.outbox))
+	}
+}
+
+func TestSolseaGetSaleHistoriesQueueFull(t *testing.T) {
+	p := NewSolseaProvider("")
+	for i := 0; i < solseaOutboxSize; i++ {
+		_, err := p.GetSaleHistories(&SaleQuery{ContractAddress: fmt.Sprintf("m%d", i)})
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+	_, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "other"})
+	if err != errSolseaQueueFull {
+		t.Fatalf("err = %v, want %v", err, errSolseaQueueFull)
+	}
+	_, err = p.GetSaleHistories(&SaleQuery{ContractAddress: "m0"})
+	if err != nil {
+		t.Fatalf("pending mint: %v", err)
+	}
+}
+
+func TestSolseaParseSales(t *testing.T) {
+	p := NewSolseaProvider("")
+	sales, err := p.parseSales(solseaFindReply("m1", 1500000000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sales) != 1 {
+		t.Fatalf("sales = %d, want 1", len(sales))
+	}
+	sale := sales[0]
+	if sale.ContractAddress != "m1" || sale.Seller != "s" || sale.Buyer != "b" || sale.Currency != "SOL" {
+		t.Fatalf("sale = %+v", sale)
+	}
+	if sale.Amount.Text('f', 9) != "1.500000000" {
+		t.Fatalf("amount = %s, want 1.5", sale.Amount.Text('f', 9))
+	}
+	if sale.TransactionAt == nil || !sale.TransactionAt.Equal(time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("transaction at = %v", sale.TransactionAt)
+	}
+	for _, msg := range []string{"3", "40", `42["message"]`} {
+		sales, err := p.parseSales(msg)
+		if err != nil || sales != nil {
+			t.Fatalf("parseSales(%q) = %v, %v, want nil", msg, sales, err)
+		}
+	}
+	_, err = p.parseSales(solseaFindReplyPrefix + "{")
+	if err == nil {
+		t.Fatal("malformed reply parsed")
+	}
+}
+
+func TestSolseaStreamDeliversSales(t *testing.T) {
+	srv := newSolseaServer(t, func(s *solseaServer, n int, c *websocket.Conn) {
+		for {
+			msg, err := s.read(c)
+			if err != nil {
+				return
+			}
+			if msg == solseaFindMsg("m1") {
+				c.WriteMessage(websocket.TextMessage, []byte("3"))
+				c.WriteMessage(websocket.TextMessage, []byte(solseaFindReplyPrefix+"{"))
+				c.WriteMessage(websocket.TextMessage, []byte(solseaFindReply("m1", 2000000000)))
+			}
+		}
+	})
+	p := NewSolseaProvider(srv.wssURL())
+	received := make(chan []*Sale, 1)
+	stop := startSolseaStream(t, p, func(sales []*Sale) {
+		received <- sales
+	})
+	waitFor(t, "connection", func() bool { return p.Health().Connected })
+	_, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sales := <-received:
+		{
+			if len(sales) != 1 || sales[0].ContractAddress != "m1" || sales[0].Amount.Text('f', 0) != "2" {
+				t.Fatalf("sales = %+v", sales)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		{
+			t.Fatal("no sales received")
+		}
+	}
+	h := p.Health()
+	if h.Source != "solsea.io" || !h.Connected || h.Pending != 0 || h.LastMessageAt.IsZero() || h.Reconnects != 0 {
+		t.Fatalf("health = %+v", h)
+	}
+	stop()
+}
+
+func TestSolseaStreamReconnectsAndResendsPending(t *testing.T) {
+	srv := newSolseaServer(t, func(s *solseaServer, n int, c *websocket.Conn) {
+		msg, err := s.read(c)
+		if err != nil {
+			return
+		}
+		// the first connection drops before answering
+		if n == 1 {
+			return
+		}
+		if msg == solseaFindMsg("m1") {
+			c.WriteMessage(websocket.TextMessage, []byte(solseaFindReply("m1", 1000000000)))
+		}
+		for {
+			if _, err := s.read(c); err != nil {
+				return
+			}
+		}
+	})
+	p := NewSolseaProvider(srv.wssURL())
+	p.backoffMin = 10 * time.Millisecond
+	p.backoffMax = 20 * time.Millisecond
+	received := make(chan []*Sale, 1)
+	startSolseaStream(t, p, func(sales []*Sale) {
+		received <- sales
+	})
+	waitFor(t, "connection", func() bool { return p.Health().Connected })
+	_, err := p.GetSaleHistories(&SaleQuery{ContractAddress: "m1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case sales := <-received:
+		{
+			if len(sales) != 1 || sales[0].ContractAddress != "m1" {
+				t.Fatalf("sales = %+v", sales)
+			}
+		}
+	case <-time.After(5 * time.Second):
+		{
+			t.Fatal("no sales received after reconnect")
+		}
+	}
+	msgs := srv.received()
+	if len(msgs) != 2 || msgs[0] != solseaFindMsg("m1") || msgs[1] != solseaFindMsg("m1") {
+		t.Fatalf("server received %q, want the request on both connections", msgs)
+	}
+	h := p.Health()
+	if !h.Connected || h.Reconnects != 1 || h.Pending != 0 || h.LastError != "" || h.LastErrorAt.IsZero() {
+		t.Fatalf("health = %+v", h)
+	}
+}
+
+func TestSolseaStreamBacksOff(t *testing.T) {
+	srv := newSolseaServer(t, func(s *solseaServer, n int, c *websocket.Conn) {})
+	url := srv.wssURL()
+	srv.Close()
+	p := NewSolseaProvider(url)
+	p.backoffMin = 20 * time.Millisecond
+	p.backoffMax = 80 * time.Millisecond
+	stop := startSolseaStream(t, p, nil)
+	time.Sleep(time.Second)
+	stop()
+	// 20, 40, 80 and then 80ms waits, about 13 attempts in a second. Without the
+	// cap the waits double to 5 attempts, without the backoff they are thousands
+	h := p.Health()
+	if h.Reconnects < 8 || h.Reconnects > 20 {
+		t.Fatalf("reconnects = %d, want about 13", h.Reconnects)
+	}
+	if h.Connected || !strings.HasPrefix(h.LastError, "dial:") || h.LastErrorAt.IsZero() {
+		t.Fatalf("health = %+v", h)
+	}
+}
+
+func TestSolseaStreamResetsBackoffAfterConnecting(t *testing.T) {
+	srv := newSolseaServer(t, func(s *solseaServer, n int, c *websocket.Conn) {})
+	p := NewSolseaProvider(srv.wssURL())
+	p.backoffMin = 20 * time.Millisecond
+	p.backoffMax = time.Minute
+	stop := startSolseaStream(t, p, nil)
+	time.Sleep(500 * time.Millisecond)
+	stop()
+	// every session connects before it drops, the wait stays at 20ms
+	h := p.Health()
+	if h.Reconnects < 8 {
+		t.Fatalf("reconnects = %d, want the minimum backoff after each connection", h.Reconnects)
+	}
+	if h.LastErrorAt.IsZero() || h.ConnectedAt.IsZero() {
+		t.Fatalf("health = %+v", h)
+	}
+}
@@ -0,0 +1,559 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	adminCurrencyMaxDecimals = 36
+)
+
+func validateNetwork(network models.Chain) error {
+	switch network {
+	case models.ChainSOL, models.ChainETH, models.ChainMATIC:
+		{
+			return nil
+		}
+	}
+	return errs.NewError(errs.ErrNetworkInvalid)
+}
+
+func (s *NftLend) AdminGetCollections(ctx context.Context, enabled *bool, page int, limit int) ([]*models.Collection, uint, error) {
+	filter := &daos.CollectionFilter{
+		Enabled: enabled,
+	}
+	collections, count, err := s.cld.Find4PageSpec(
+		s.conn.DB(ctx),
+		filter.Spec().Order("id desc"),
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return collections, count, nil
+}
+
+// validateCollectionReq trims the request and checks the fields shared by create and update
+func validateCollectionReq(req *serializers.AdminCollectionReq) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.SeoURL = strings.TrimSpace(req.SeoURL)
+	req.OriginContractAddress = strings.TrimSpace(req.OriginContractAddress)
+	err := validateNetwork(req.Network)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if req.Name == "" {
+		return errs.NewInvalidParamsError("name is required")
+	}
+	if req.SeoURL == "" {
+		req.SeoURL = helpers.MakeSeoURL(req.Name)
+	}
+	if req.SeoURL != helpers.MakeSeoURL(req.SeoURL) {
+		return errs.NewInvalidParamsError("seo_url is invalid")
+	}
+	if (req.OriginNetwork == "") != (req.OriginContractAddress == "") {
+		return errs.NewInvalidParamsError("origin_network and origin_contract_address must be set together")
+	}
+	if req.OriginNetwork != "" {
+		req.OriginContractAddress, err = normalizeAddress(req.OriginNetwork, req.OriginContractAddress)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+func (s *NftLend) checkCollectionSeoURL(tx *gorm.DB, seoURL string, id uint) error {
+	filter := &daos.CollectionFilter{
+		SeoURL: seoURL,
+	}
+	m, err := s.cld.FirstSpec(
+		tx,
+		filter.Spec(),
+		false,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if m != nil && m.ID != id {
+		return errs.NewError(errs.ErrCollectionExists)
+	}
+	return nil
+}
+
+func (s *NftLend) AdminCreateCollection(ctx context.Context, req *serializers.AdminCollectionReq) (*models.Collection, error) {
+	err := validateCollectionReq(req)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	var collection *models.Collection
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			err := s.checkCollectionSeoURL(tx, req.SeoURL, 0)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			collection = &models.Collection{
+				Network:               req.Network,
+				SeoURL:                req.SeoURL,
+				Name:                  req.Name,
+				Description:           req.Description,
+				Creator:               req.Creator,
+				OriginNetwork:         req.OriginNetwork,
+				OriginContractAddress: req.OriginContractAddress,
+				Enabled:               req.Enabled,
+			}
+			err = s.cld.Create(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			err = s.indexSearchCollection(tx, collection)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return collection, nil
+}
+
+// AdminUpdateCollection overwrites the editable fields, enabled is changed by AdminSetCollectionEnabled
+func (s *NftLend) AdminUpdateCollection(ctx context.Context, id uint, req *serializers.AdminCollectionReq) (*models.Collection, error) {
+	err := validateCollectionReq(req)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	var collection *models.Collection
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			collection, err = s.cld.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if collection == nil {
+				return errs.NewError(errs.ErrCollectionNotFound)
+			}
+			err = s.checkCollectionSeoURL(tx, req.SeoURL, collection.ID)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			collection.Network = req.Network
+			collection.SeoURL = req.SeoURL
+			collection.Name = req.Name
+			collection.Description = req.Description
+			collection.Creator = req.Creator
+			collection.OriginNetwork = req.OriginNetwork
+			collection.OriginContractAddress = req.OriginContractAddress
+			err = s.cld.Save(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			err = s.indexSearchCollection(tx, collection)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return collection, nil
+}
+
+func (s *NftLend) AdminSetCollectionEnabled(ctx context.Context, id uint, enabled bool) (*models.Collection, error) {
+	var collection *models.Collection
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			collection, err = s.cld.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if collection == nil {
+				return errs.NewError(errs.ErrCollectionNotFound)
+			}
+			collection.Enabled = enabled
+			err = s.cld.Save(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return collection, nil
+}
+
+func (s *NftLend) AdminGetCurrencies(ctx context.Context, network models.Chain) ([]*models.Currency, error) {
+	filters := map[string][]interface{}{}
+	if network != "" {
+		filters["network = ?"] = []interface{}{network}
+	}
+	currencies, err := s.cd.Find(
+		s.conn.DB(ctx),
+		filters,
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		0,
+		99999999,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return currencies, nil
+}
+
+func validateCurrencyReq(req *serializers.AdminCurrencyReq) error {
+	req.Symbol = strings.TrimSpace(req.Symbol)
+	req.Name = strings.TrimSpace(req.Name)
+	err := validateNetwork(req.Network)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	req.ContractAddress, err = normalizeAddress(req.Network, req.ContractAddress)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if req.AdminFeeAddress != "" {
+		req.AdminFeeAddress, err = normalizeAddress(req.Network, req.AdminFeeAddress)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	if req.Symbol == "" {
+		return errs.NewInvalidParamsError("symbol is required")
+	}
+	if req.Decimals > adminCurrencyMaxDecimals {
+		return errs.NewInvalidParamsError("decimals must not be greater than %d", adminCurrencyMaxDecimals)
+	}
+	return nil
+}
+
+func (s *NftLend) checkCurrencyContractAddress(tx *gorm.DB, network models.Chain, contractAddress string, id uint) error {
+	m, err := s.cd.First(
+		tx,
+		map[string][]interface{}{
+			"network = ?":          []interface{}{network},
+			"contract_address = ?": []interface{}{contractAddress},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if m != nil && m.ID != id {
+		return errs.NewError(errs.ErrCurrencyExists)
+	}
+	return nil
+}
+
+func currencyEnabledValue(enabled bool) float64 {
+	if enabled {
+		return 1
+	}
+	return 0
+}
+
+func (s *NftLend) AdminCreateCurrency(ctx context.Context, req *serializers.AdminCurrencyReq) (*models.Currency, error) {
+	err := validateCurrencyReq(req)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	var currency *models.Currency
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			err := s.checkCurrencyContractAddress(tx, req.Network, req.ContractAddress, 0)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			currency = &models.Currency{
+				Network:         req.Network,
+				ContractAddress: req.ContractAddress,
+				Decimals:        req.Decimals,
+				Symbol:          req.Symbol,
+				Name:            req.Name,
+				IconURL:         req.IconURL,
+				AdminFeeAddress: req.AdminFeeAddress,
+				Enabled:         currencyEnabledValue(req.Enabled),
+			}
+			err = s.cd.Create(
+				tx,
+				currency,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return currency, nil
+}
+
+// AdminUpdateCurrency overwrites the editable fields, enabled is changed by AdminSetCurrencyEnabled
+func (s *NftLend) AdminUpdateCurrency(ctx context.Context, id uint, req *serializers.AdminCurrencyReq) (*models.Currency, error) {
+	err := validateCurrencyReq(req)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	var currency *models.Currency
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			currency, err = s.cd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if currency == nil {
+				return errs.NewError(errs.ErrCurrencyNotFound)
+			}
+			err = s.checkCurrencyContractAddress(tx, req.Network, req.ContractAddress, currency.ID)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			currency.Network = req.Network
+			currency.ContractAddress = req.ContractAddress
+			currency.Decimals = req.Decimals
+			currency.Symbol = req.Symbol
+			currency.Name = req.Name
+			currency.IconURL = req.IconURL
+			currency.AdminFeeAddress = req.AdminFeeAddress
+			err = s.cd.Save(
+				tx,
+				currency,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return currency, nil
+}
+
+func (s *NftLend) AdminSetCurrencyEnabled(ctx context.Context, id uint, enabled bool) (*models.Currency, error) {
+	var currency *models.Currency
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			currency, err = s.cd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if currency == nil {
+				return errs.NewError(errs.ErrCurrencyNotFound)
+			}
+			currency.Enabled = currencyEnabledValue(enabled)
+			err = s.cd.Save(
+				tx,
+				currency,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return currency, nil
+}
+
+func (s *NftLend) getAdminAsset(tx *gorm.DB, id uint, forUpdate bool) (*models.Asset, error) {
+	asset, err := s.ad.FirstByID(
+		tx,
+		id,
+		map[string][]interface{}{
+			"Collection": []interface{}{},
+		},
+		forUpdate,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if asset == nil {
+		return nil, errs.NewError(errs.ErrAssetNotFound)
+	}
+	return asset, nil
+}
+
+// AdminUpdateAssetInfo forces the on chain metadata of the asset to be fetched again
+func (s *NftLend) AdminUpdateAssetInfo(ctx context.Context, id uint) (*models.Asset, error) {
+	asset, err := s.getAdminAsset(s.conn.DB(ctx), id, false)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	err = s.UpdateAssetInfo(ctx, asset.ContractAddress)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	asset, err = s.getAdminAsset(s.conn.DB(ctx), id, false)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return asset, nil
+}
+
+// AdminUpdateAssetTestContract maps the asset to the contracts used on the test networks,
+// empty addresses clear the mapping
+func (s *NftLend) AdminUpdateAssetTestContract(ctx context.Context, id uint, req *serializers.AdminAssetTestContractReq) (*models.Asset, error) {
+	var asset *models.Asset
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			asset, err = s.getAdminAsset(tx, id, true)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			testContractAddress := strings.TrimSpace(req.TestContractAddress)
+			if testContractAddress != "" {
+				testContractAddress, err = normalizeAddress(asset.Network, testContractAddress)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			}
+			testOriginContractAddress := strings.TrimSpace(req.TestOriginContractAddress)
+			if testOriginContractAddress != "" {
+				if asset.OriginNetwork == "" {
+					return errs.NewInvalidParamsError("asset has no origin network")
+				}
+				testOriginContractAddress, err = normalizeAddress(asset.OriginNetwork, testOriginContractAddress)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			} else if req.TestOriginTokenID > 0 {
+				return errs.NewInvalidParamsError("test_origin_token_id requires test_origin_contract_address")
+			}
+			asset.TestContractAddress = testContractAddress
+			asset.TestOriginContractAddress = testOriginContractAddress
+			asset.TestOriginTokenID = req.TestOriginTokenID
+			err = s.ad.Save(
+				tx,
+				asset,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return asset, nil
+}
+
+func (s *NftLend) AdminGetInstructions(ctx context.Context, status string, instruction string, transactionHash string, page int, limit int) ([]*models.Instruction, uint, error) {
+	filters := map[string][]interface{}{}
+	if status != "" {
+		filters["status = ?"] = []interface{}{status}
+	}
+	if instruction != "" {
+		filters["instruction = ?"] = []interface{}{instruction}
+	}
+	if transactionHash != "" {
+		filters["transaction_hash = ?"] = []interface{}{transactionHash}
+	}
+	instructions, count, err := s.id.Find4Page(
+		s.conn.DB(ctx),
+		filters,
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return instructions, count, nil
+}
+
+func (s *NftLend) AdminGetInstruction(ctx context.Context, id uint) (*models.Instruction, error) {
+	instruction, err := s.id.FirstByID(
+		s.conn.DB(ctx),
+		id,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if instruction == nil {
+		return nil, errs.NewError(errs.ErrInstructionNotFound)
+	}
+	return instruction, nil
+}
+
+// AdminReprocessInstruction runs an instruction that failed to process again,
+// processed instructions are never replayed
+func (s *NftLend) AdminReprocessInstruction(ctx context.Context, id uint) (*models.Instruction, error) {
+	ins, err := s.AdminGetInstruction(ctx, id)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if ins.Status == "done" {
+		return nil, errs.NewError(errs.ErrInstructionProcessed)
+	}
+	err = s.ProcessSolanaInstruction(ctx, ins.ID)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return s.AdminGetInstruction(ctx, id)
+}
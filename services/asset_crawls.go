@@ -0,0 +1,532 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+	"github.com/czConstant/constant-nftylend-api/types/numeric"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+const (
+	assetCrawlBatchSize   = 100
+	assetCrawlInterval    = 24 * time.Hour
+	assetCrawlMinInterval = time.Hour
+	assetCrawlRetryBase   = 5 * time.Minute
+	assetCrawlRetryMax    = 24 * time.Hour
+	assetCrawlMaxPages    = 10
+)
+
+// assetCrawlCheckpoint is the sync position of a paginated history after a
+// crawl, see models.AssetCrawl
+type assetCrawlCheckpoint struct {
+	TransactionID     string
+	HeadTransactionID string
+	Cursor            string
+}
+
+// assetCrawlNextInterval shortens the refresh interval of popular assets
+func assetCrawlNextInterval(popularity uint) time.Duration {
+	d := assetCrawlInterval / time.Duration(1+popularity)
+	if d < assetCrawlMinInterval {
+		d = assetCrawlMinInterval
+	}
+	return d
+}
+
+// assetCrawlRetryDelay doubles the delay on every consecutive failure
+func assetCrawlRetryDelay(attempts uint) time.Duration {
+	d := assetCrawlRetryBase
+	for i := uint(1); i < attempts && d < assetCrawlRetryMax; i++ {
+		d = d * 2
+	}
+	if d > assetCrawlRetryMax {
+		d = assetCrawlRetryMax
+	}
+	return d
+}
+
+func assetCrawlTokenAddress(asset *models.Asset) string {
+	if asset.TestContractAddress != "" {
+		return asset.TestContractAddress
+	}
+	return asset.ContractAddress
+}
+
+// assetCrawlNetworks returns the chains the sales of an asset are crawled on,
+// bridged assets are also crawled on their origin chain
+func assetCrawlNetworks(asset *models.Asset) []models.Chain {
+	networks := []models.Chain{asset.Network}
+	if asset.OriginNetwork != "" &&
+		asset.OriginNetwork != asset.Network &&
+		asset.OriginContractAddress != "" &&
+		asset.OriginTokenID != "" {
+		networks = append(networks, asset.OriginNetwork)
+	}
+	return networks
+}
+
+// assetCrawlQuery identifies the asset on network, the origin contract and
+// token are used off the asset network
+func assetCrawlQuery(asset *models.Asset, network models.Chain) *saletrack.SaleQuery {
+	if network == asset.Network {
+		return &saletrack.SaleQuery{
+			ContractAddress: assetCrawlTokenAddress(asset),
+		}
+	}
+	if asset.TestOriginContractAddress != "" {
+		return &saletrack.SaleQuery{
+			ContractAddress: asset.TestOriginContractAddress,
+			TokenID:         fmt.Sprintf("%d", asset.TestOriginTokenID),
+		}
+	}
+	return &saletrack.SaleQuery{
+		ContractAddress: asset.OriginContractAddress,
+		TokenID:         asset.OriginTokenID,
+	}
+}
+
+// enqueueAssetCrawls adds the asset to the crawl queue of every marketplace of
+// its network, bump counts a new listing of the asset in its popularity
+func (s *NftLend) enqueueAssetCrawls(tx *gorm.DB, asset *models.Asset, bump bool) error {
+	for _, network := range assetCrawlNetworks(asset) {
+		err := s.enqueueAssetCrawlsOn(tx, asset, network, bump)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+func (s *NftLend) enqueueAssetCrawlsOn(tx *gorm.DB, asset *models.Asset, network models.Chain, bump bool) error {
+	for _, p := range s.shr.Providers(string(network)) {
+		source := p.Source()
+		m, err := s.acd.First(
+			tx,
+			map[string][]interface{}{
+				"asset_id = ?": []interface{}{asset.ID},
+				"source = ?":   []interface{}{source},
+			},
+			map[string][]interface{}{},
+			[]string{},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		if m == nil {
+			m = &models.AssetCrawl{
+				AssetID:     asset.ID,
+				Source:      source,
+				Network:     network,
+				NextCrawlAt: helpers.TimeNow(),
+			}
+			if bump {
+				m.Popularity = 1
+			}
+			err = s.acd.Create(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			continue
+		}
+		if !bump {
+			continue
+		}
+		m.Popularity++
+		if m.CrawledAt != nil && m.Attempts == 0 {
+			nextCrawlAt := m.CrawledAt.Add(assetCrawlNextInterval(m.Popularity))
+			if m.NextCrawlAt == nil || nextCrawlAt.Before(*m.NextCrawlAt) {
+				m.NextCrawlAt = &nextCrawlAt
+			}
+		}
+		err = s.acd.Save(
+			tx,
+			m,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+// JobSeedAssetCrawls adds the assets missing from the crawl queue, new assets
+// are queued when they are listed
+func (s *NftLend) JobSeedAssetCrawls(ctx context.Context) error {
+	var lastID uint
+	for {
+		assets, err := s.ad.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"id > ?": []interface{}{lastID},
+			},
+			map[string][]interface{}{},
+			[]string{"id asc"},
+			0,
+			500,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		if len(assets) == 0 {
+			break
+		}
+		assetIDs := []uint{}
+		for _, asset := range assets {
+			assetIDs = append(assetIDs, asset.ID)
+		}
+		crawls, err := s.acd.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"asset_id in (?)": []interface{}{assetIDs},
+			},
+			map[string][]interface{}{},
+			[]string{},
+			0,
+			99999999,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		queued := map[string]bool{}
+		for _, crawl := range crawls {
+			queued[fmt.Sprintf("%d_%s", crawl.AssetID, crawl.Source)] = true
+		}
+		err = s.conn.WithTransaction(
+			ctx,
+			func(tx *gorm.DB) error {
+				for _, asset := range assets {
+					for _, network := range assetCrawlNetworks(asset) {
+						for _, p := range s.shr.Providers(string(network)) {
+							source := p.Source()
+							if queued[fmt.Sprintf("%d_%s", asset.ID, source)] {
+								continue
+							}
+							queued[fmt.Sprintf("%d_%s", asset.ID, source)] = true
+							err := s.acd.Create(
+								tx,
+								&models.AssetCrawl{
+									AssetID:     asset.ID,
+									Source:      source,
+									Network:     network,
+									NextCrawlAt: helpers.TimeNow(),
+								},
+							)
+							if err != nil {
+								return errs.NewError(err)
+							}
+						}
+					}
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		lastID = assets[len(assets)-1].ID
+	}
+	return nil
+}
+
+// JobCrawlAssetTransactions crawls a batch of due queue entries, the most popular
+// then the stalest first
+func (s *NftLend) JobCrawlAssetTransactions(ctx context.Context) error {
+	crawls, err := s.acd.Find(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"next_crawl_at <= ?": []interface{}{time.Now()},
+		},
+		map[string][]interface{}{
+			"Asset": []interface{}{},
+		},
+		[]string{"popularity desc", "next_crawl_at asc"},
+		0,
+		assetCrawlBatchSize,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	limited := map[string]bool{}
+	for _, crawl := range crawls {
+		if limited[crawl.Source] {
+			continue
+		}
+		p := s.shr.Provider(crawl.Source)
+		if p != nil {
+			r, err := s.crl.Take(ctx, crawl.Source, p.RateLimit())
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if !r.Allowed {
+				limited[crawl.Source] = true
+				continue
+			}
+		}
+		cp, crawlErr := s.crawlAsset(ctx, p, crawl)
+		err = s.finishAssetCrawl(ctx, crawl.ID, cp, crawlErr)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+func (s *NftLend) crawlAsset(ctx context.Context, p saletrack.SaleHistoryProvider, crawl *models.AssetCrawl) (*assetCrawlCheckpoint, error) {
+	if p == nil {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	if crawl.Asset == nil {
+		return nil, errs.NewError(errs.ErrAssetNotFound)
+	}
+	network := crawl.Network
+	if network == "" {
+		network = crawl.Asset.Network
+	}
+	q := assetCrawlQuery(crawl.Asset, network)
+	if pp, ok := p.(saletrack.SalePageProvider); ok {
+		cp, err := s.syncAssetSalePages(ctx, pp, crawl, network, q)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		return cp, nil
+	}
+	sales, err := p.GetSaleHistories(q)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	err = s.createAssetSales(ctx, crawl.Asset, network, sales)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return nil, nil
+}
+
+// syncAssetSalePages walks a paginated history from the newest page down to
+// the last synced sale. At most assetCrawlMaxPages are read per crawl, a
+// longer history keeps its cursor and is resumed by the next crawls
+func (s *NftLend) syncAssetSalePages(ctx context.Context, p saletrack.SalePageProvider, crawl *models.AssetCrawl, network models.Chain, q *saletrack.SaleQuery) (*assetCrawlCheckpoint, error) {
+	cp := &assetCrawlCheckpoint{
+		TransactionID:     crawl.SyncTransactionID,
+		HeadTransactionID: crawl.SyncHeadTransactionID,
+		Cursor:            crawl.SyncCursor,
+	}
+	for i := 0; i < assetCrawlMaxPages; i++ {
+		page, err := p.GetSaleHistoryPage(q, cp.Cursor)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		var (
+			sales   = []*saletrack.Sale{}
+			reached bool
+		)
+		for _, sale := range page.Sales {
+			if cp.TransactionID != "" && sale.TransactionID == cp.TransactionID {
+				reached = true
+				break
+			}
+			if cp.HeadTransactionID == "" {
+				cp.HeadTransactionID = sale.TransactionID
+			}
+			sales = append(sales, sale)
+		}
+		err = s.createAssetSales(ctx, crawl.Asset, network, sales)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		if reached || page.NextCursor == "" {
+			if cp.HeadTransactionID != "" {
+				cp.TransactionID = cp.HeadTransactionID
+			}
+			cp.HeadTransactionID = ""
+			cp.Cursor = ""
+			return cp, nil
+		}
+		cp.Cursor = page.NextCursor
+	}
+	return cp, nil
+}
+
+// createAssetSales stores the normalized sales of a provider as asset
+// transactions, network is the chain the sales happened on. Sales already
+// stored are refreshed in place, the sales paid in a currency without row are
+// skipped
+func (s *NftLend) createAssetSales(ctx context.Context, asset *models.Asset, network models.Chain, sales []*saletrack.Sale) error {
+	currencies := map[string]*models.Currency{}
+	for _, sale := range sales {
+		c, ok := currencies[sale.Currency]
+		if !ok {
+			var err error
+			c, err = s.cd.First(
+				s.conn.DB(ctx),
+				map[string][]interface{}{
+					"network = ?": []interface{}{network},
+					"symbol = ?":  []interface{}{sale.Currency},
+				},
+				map[string][]interface{}{},
+				[]string{},
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if c == nil {
+				c, err = s.cd.First(
+					s.conn.DB(ctx),
+					map[string][]interface{}{
+						"symbol = ?": []interface{}{sale.Currency},
+					},
+					map[string][]interface{}{},
+					[]string{},
+				)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			}
+			currencies[sale.Currency] = c
+		}
+		if c == nil {
+			logger.Info(
+				"asset_crawl",
+				"sale currency unknown",
+				zap.Uint("asset_id", asset.ID),
+				zap.String("source", sale.Source),
+				zap.String("transaction_id", sale.TransactionID),
+				zap.String("currency", sale.Currency),
+			)
+			continue
+		}
+		err := s.upsertAssetTransaction(
+			s.conn.DB(ctx),
+			&models.AssetTransaction{
+				Source:        sale.Source,
+				Network:       network,
+				AssetID:       asset.ID,
+				Type:          models.AssetTransactionTypeExchange,
+				Seller:        sale.Seller,
+				Buyer:         sale.Buyer,
+				TransactionID: sale.TransactionID,
+				TransactionAt: sale.TransactionAt,
+				Amount:        numeric.BigFloat{*sale.Amount},
+				CurrencyID:    c.ID,
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+// GetSaleStreamHealths reports the connection of every stream provider
+func (s *NftLend) GetSaleStreamHealths(ctx context.Context) []*saletrack.SaleStreamHealth {
+	hs := []*saletrack.SaleStreamHealth{}
+	for _, p := range s.shr.All() {
+		sp, ok := p.(saletrack.SaleStreamProvider)
+		if ok {
+			hs = append(hs, sp.Health())
+		}
+	}
+	return hs
+}
+
+// saleStreamReceived stores the sales delivered by a stream provider, sales of
+// unknown mints are skipped
+func (s *NftLend) saleStreamReceived(sales []*saletrack.Sale) {
+	ctx := context.Background()
+	for _, sale := range sales {
+		asset, err := s.ad.First(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"contract_address = ?": []interface{}{sale.ContractAddress},
+			},
+			map[string][]interface{}{},
+			[]string{"id desc"},
+		)
+		if err != nil {
+			return
+		}
+		if asset == nil {
+			asset, err = s.ad.First(
+				s.conn.DB(ctx),
+				map[string][]interface{}{
+					"test_contract_address = ?": []interface{}{sale.ContractAddress},
+				},
+				map[string][]interface{}{},
+				[]string{"id desc"},
+			)
+			if err != nil {
+				return
+			}
+			if asset == nil {
+				continue
+			}
+		}
+		err = s.createAssetSales(ctx, asset, asset.Network, []*saletrack.Sale{sale})
+		if err != nil {
+			return
+		}
+	}
+}
+
+// finishAssetCrawl schedules the next crawl, failures are retried with backoff.
+// The checkpoint of a paginated history is stored and an unfinished backfill
+// is due again right away
+func (s *NftLend) finishAssetCrawl(ctx context.Context, crawlID uint, cp *assetCrawlCheckpoint, crawlErr error) error {
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			m, err := s.acd.FirstByID(
+				tx,
+				crawlID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m == nil {
+				return errs.NewError(errs.ErrBadRequest)
+			}
+			if crawlErr != nil {
+				m.Attempts++
+				m.LastError = crawlErr.Error()
+				m.NextCrawlAt = helpers.TimeNowAdd(assetCrawlRetryDelay(m.Attempts))
+			} else {
+				m.Attempts = 0
+				m.LastError = ""
+				m.CrawledAt = helpers.TimeNow()
+				m.NextCrawlAt = helpers.TimeNowAdd(assetCrawlNextInterval(m.Popularity))
+				if cp != nil {
+					m.SyncTransactionID = cp.TransactionID
+					m.SyncHeadTransactionID = cp.HeadTransactionID
+					m.SyncCursor = cp.Cursor
+					if cp.Cursor != "" {
+						m.NextCrawlAt = helpers.TimeNow()
+					}
+				}
+			}
+			err = s.acd.Save(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
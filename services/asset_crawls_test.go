@@ -0,0 +1,264 @@
+package services_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+	"github.com/czConstant/constant-nftylend-api/services/fakes"
+)
+
+func (lt *lendTest) crawlAsset(n int) *models.Asset {
+	lt.t.Helper()
+	asset := &models.Asset{
+		Network:         models.ChainSOL,
+		CollectionID:    lt.collection.ID,
+		SeoURL:          fmt.Sprintf("crawl-asset-%d", n),
+		ContractAddress: fmt.Sprintf("CrawlMint%d", n),
+		Name:            fmt.Sprintf("Degen Ape #%d", n),
+	}
+	lt.create((&daos.Asset{}).Create, asset)
+	return asset
+}
+
+// queueCrawl queues the asset on source, due since the given time ago
+func (lt *lendTest) queueCrawl(asset *models.Asset, source string, popularity uint, due time.Duration) *models.AssetCrawl {
+	lt.t.Helper()
+	nextCrawlAt := time.Now().Add(-due)
+	crawl := &models.AssetCrawl{
+		AssetID:     asset.ID,
+		Source:      source,
+		Network:     asset.Network,
+		Popularity:  popularity,
+		NextCrawlAt: &nextCrawlAt,
+	}
+	lt.create((&daos.AssetCrawl{}).Create, crawl)
+	return crawl
+}
+
+func (lt *lendTest) assetCrawl(id uint) *models.AssetCrawl {
+	lt.t.Helper()
+	crawl, err := (&daos.AssetCrawl{}).FirstByID(lt.db, id, map[string][]interface{}{}, false)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	return crawl
+}
+
+// crawlDue runs the crawl job with every given crawl due again
+func (lt *lendTest) crawlDue(crawls ...*models.AssetCrawl) {
+	lt.t.Helper()
+	for _, crawl := range crawls {
+		m := lt.assetCrawl(crawl.ID)
+		nextCrawlAt := time.Now().Add(-time.Second)
+		m.NextCrawlAt = &nextCrawlAt
+		lt.create((&daos.AssetCrawl{}).Save, m)
+	}
+	err := lt.s.JobCrawlAssetTransactions(context.Background())
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+}
+
+func (lt *lendTest) assetSales(asset *models.Asset) []string {
+	lt.t.Helper()
+	ms, err := (&daos.AssetTransaction{}).Find(lt.db, map[string][]interface{}{"asset_id = ?": []interface{}{asset.ID}}, map[string][]interface{}{}, []string{"transaction_id asc"}, 0, -1)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	ids := []string{}
+	for _, m := range ms {
+		ids = append(ids, m.TransactionID)
+	}
+	return ids
+}
+
+func queriedAddresses(p *fakes.SaleHistoryProvider) []string {
+	addresses := []string{}
+	for _, q := range p.Queries {
+		addresses = append(addresses, q.ContractAddress)
+	}
+	return addresses
+}
+
+// sales builds the history of an asset newest first, transaction ids count
+// down from n so they sort like the sales
+func sales(source string, asset *models.Asset, n int) []*saletrack.Sale {
+	ss := []*saletrack.Sale{}
+	for i := n; i > 0; i-- {
+		transactionAt := genesis.Add(time.Duration(i) * time.Hour)
+		ss = append(ss, &saletrack.Sale{
+			Source:          source,
+			ContractAddress: asset.ContractAddress,
+			TransactionID:   fmt.Sprintf("sale%02d", i),
+			Seller:          borrower,
+			Buyer:           lenderOne,
+			TransactionAt:   &transactionAt,
+			Amount:          big.NewFloat(float64(i)),
+			Currency:        "SOL",
+		})
+	}
+	return ss
+}
+
+// within checks a scheduled time against the delay from the job run, the
+// database keeps whole seconds
+func within(at *time.Time, from time.Time, to time.Time, d time.Duration) bool {
+	return at != nil &&
+		!at.Before(from.Add(d).Add(-time.Second)) &&
+		!at.After(to.Add(d).Add(time.Second))
+}
+
+func TestCrawlAssetTransactionsOrder(t *testing.T) {
+	lt := newLendTest(t)
+	p := fakes.NewSaleHistoryProvider("magiceden")
+	lt.shr.Register(string(models.ChainSOL), p)
+	assets := []*models.Asset{}
+	for i := 0; i < 5; i++ {
+		assets = append(assets, lt.crawlAsset(i))
+	}
+	lt.queueCrawl(assets[0], p.Source(), 0, time.Hour)
+	lt.queueCrawl(assets[1], p.Source(), 2, time.Minute)
+	lt.queueCrawl(assets[2], p.Source(), 0, 2*time.Hour)
+	lt.queueCrawl(assets[3], p.Source(), 2, time.Hour)
+	// not due yet
+	lt.queueCrawl(assets[4], p.Source(), 5, -time.Hour)
+	err := lt.s.JobCrawlAssetTransactions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the most popular then the stalest first
+	want := []string{
+		assets[3].ContractAddress,
+		assets[1].ContractAddress,
+		assets[2].ContractAddress,
+		assets[0].ContractAddress,
+	}
+	if got := queriedAddresses(p); !reflect.DeepEqual(got, want) {
+		t.Fatalf("crawled %v, want %v", got, want)
+	}
+}
+
+func TestCrawlAssetTransactionsRetry(t *testing.T) {
+	lt := newLendTest(t)
+	p := fakes.NewSaleHistoryProvider("magiceden")
+	lt.shr.Register(string(models.ChainSOL), p)
+	p.Errors[lt.asset.ContractAddress] = errors.New("marketplace unavailable")
+	p.Sales[lt.asset.ContractAddress] = sales(p.Source(), lt.asset, 2)
+	crawl := lt.queueCrawl(lt.asset, p.Source(), 0, time.Minute)
+	// every consecutive failure doubles the delay
+	for attempts, delay := range []time.Duration{5 * time.Minute, 10 * time.Minute, 20 * time.Minute} {
+		from := time.Now()
+		lt.crawlDue(crawl)
+		to := time.Now()
+		m := lt.assetCrawl(crawl.ID)
+		if m.Attempts != uint(attempts+1) || m.LastError == "" || m.CrawledAt != nil {
+			t.Fatalf("failure %d = %d attempts error %q crawled at %v", attempts+1, m.Attempts, m.LastError, m.CrawledAt)
+		}
+		if !within(m.NextCrawlAt, from, to, delay) {
+			t.Fatalf("failure %d next crawl at %v, want %v later", attempts+1, m.NextCrawlAt, delay)
+		}
+	}
+	// a success resets the attempts and schedules the regular refresh
+	delete(p.Errors, lt.asset.ContractAddress)
+	from := time.Now()
+	lt.crawlDue(crawl)
+	to := time.Now()
+	m := lt.assetCrawl(crawl.ID)
+	if m.Attempts != 0 || m.LastError != "" || m.CrawledAt == nil {
+		t.Fatalf("success = %d attempts error %q crawled at %v", m.Attempts, m.LastError, m.CrawledAt)
+	}
+	if !within(m.NextCrawlAt, from, to, 24*time.Hour) {
+		t.Fatalf("next crawl at %v, want a day later", m.NextCrawlAt)
+	}
+	if got, want := lt.assetSales(lt.asset), []string{"sale01", "sale02"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("sales = %v, want %v", got, want)
+	}
+}
+
+func TestCrawlAssetTransactionsRateLimit(t *testing.T) {
+	lt := newLendTest(t)
+	limited := fakes.NewSaleHistoryProvider("solanart")
+	limited.Rule = ratelimit.Rule{Limit: 1, Period: time.Hour}
+	open := fakes.NewSaleHistoryProvider("magiceden")
+	lt.shr.Register(string(models.ChainSOL), limited)
+	lt.shr.Register(string(models.ChainSOL), open)
+	other := lt.crawlAsset(1)
+	lt.queueCrawl(lt.asset, limited.Source(), 1, time.Hour)
+	lt.queueCrawl(lt.asset, open.Source(), 1, time.Hour)
+	skipped := lt.queueCrawl(other, limited.Source(), 0, time.Hour)
+	lt.queueCrawl(other, open.Source(), 0, time.Hour)
+	err := lt.s.JobCrawlAssetTransactions(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the limited source is skipped for the rest of the batch, the other
+	// sources go on
+	if got, want := queriedAddresses(limited), []string{lt.asset.ContractAddress}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("limited source crawled %v, want %v", got, want)
+	}
+	if got, want := queriedAddresses(open), []string{lt.asset.ContractAddress, other.ContractAddress}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("open source crawled %v, want %v", got, want)
+	}
+	// the skipped crawl stays due and is not counted as a failure
+	m := lt.assetCrawl(skipped.ID)
+	if m.Attempts != 0 || m.CrawledAt != nil || m.NextCrawlAt.After(time.Now()) {
+		t.Fatalf("skipped crawl = %d attempts crawled at %v next at %v, want untouched", m.Attempts, m.CrawledAt, m.NextCrawlAt)
+	}
+}
+
+func TestSyncAssetSalePagesResume(t *testing.T) {
+	lt := newLendTest(t)
+	p := fakes.NewSalePageProvider("magiceden", 1)
+	lt.shr.Register(string(models.ChainSOL), p)
+	history := sales(p.Source(), lt.asset, 12)
+	p.Sales[lt.asset.ContractAddress] = history
+	crawl := lt.queueCrawl(lt.asset, p.Source(), 0, time.Minute)
+	// a crawl reads 10 pages and keeps the cursor of the backfill
+	lt.crawlDue(crawl)
+	m := lt.assetCrawl(crawl.ID)
+	if m.SyncCursor != "10" || m.SyncHeadTransactionID != "sale12" || m.SyncTransactionID != "" {
+		t.Fatalf("checkpoint = cursor %q head %q synced %q, want the backfill at 10", m.SyncCursor, m.SyncHeadTransactionID, m.SyncTransactionID)
+	}
+	if m.NextCrawlAt.After(time.Now().Add(time.Second)) {
+		t.Fatalf("unfinished backfill next crawl at %v, want due", m.NextCrawlAt)
+	}
+	if n := len(lt.assetSales(lt.asset)); n != 10 {
+		t.Fatalf("sales after the first crawl = %d, want 10", n)
+	}
+	// the next crawl resumes from the cursor and completes the history
+	p.Queries = nil
+	lt.crawlDue(crawl)
+	m = lt.assetCrawl(crawl.ID)
+	if m.SyncCursor != "" || m.SyncHeadTransactionID != "" || m.SyncTransactionID != "sale12" {
+		t.Fatalf("checkpoint = cursor %q head %q synced %q, want synced to sale12", m.SyncCursor, m.SyncHeadTransactionID, m.SyncTransactionID)
+	}
+	if n := len(p.Queries); n != 3 {
+		t.Fatalf("pages read resuming = %d, want 3", n)
+	}
+	if n := len(lt.assetSales(lt.asset)); n != 12 {
+		t.Fatalf("sales after the backfill = %d, want 12", n)
+	}
+	// new sales are read down to the last synced one
+	p.Sales[lt.asset.ContractAddress] = sales(p.Source(), lt.asset, 14)
+	p.Queries = nil
+	lt.crawlDue(crawl)
+	m = lt.assetCrawl(crawl.ID)
+	if m.SyncCursor != "" || m.SyncHeadTransactionID != "" || m.SyncTransactionID != "sale14" {
+		t.Fatalf("checkpoint = cursor %q head %q synced %q, want synced to sale14", m.SyncCursor, m.SyncHeadTransactionID, m.SyncTransactionID)
+	}
+	if n := len(p.Queries); n != 3 {
+		t.Fatalf("pages read for new sales = %d, want 3", n)
+	}
+	if n := len(lt.assetSales(lt.asset)); n != 14 {
+		t.Fatalf("sales after the refresh = %d, want 14", n)
+	}
+}
@@ -0,0 +1,354 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	assetMetadataBatchSize       = 100
+	assetMetadataRefreshInterval = 7 * 24 * time.Hour
+	assetMetadataRetryBase       = 5 * time.Minute
+	assetMetadataRetryMax        = 24 * time.Hour
+
+	placeholderCollectionSeoURL = "unidentified"
+)
+
+// assetMetadataRetryDelay doubles the delay on every consecutive failure
+func assetMetadataRetryDelay(attempts uint) time.Duration {
+	d := assetMetadataRetryBase
+	for i := uint(1); i < attempts && d < assetMetadataRetryMax; i++ {
+		d = d * 2
+	}
+	if d > assetMetadataRetryMax {
+		d = assetMetadataRetryMax
+	}
+	return d
+}
+
+// setAssetMetadata copies the metadata json to the asset and schedules its
+// next refresh
+func setAssetMetadata(asset *models.Asset, meta *solana.MetadataResp, metaInfo *solana.MetadataInfoResp) error {
+	var sellerFeeBasisPoints int64
+	switch metaInfo.SellerFeeBasisPoints.(type) {
+	case string:
+		{
+			sellerFeeBasisPoints, _ = strconv.ParseInt(metaInfo.SellerFeeBasisPoints.(string), 10, 64)
+		}
+	case float64:
+		{
+			sellerFeeBasisPoints = int64(metaInfo.SellerFeeBasisPoints.(float64))
+		}
+	}
+	sellerFeeRate, _ := models.ConvertWeiToBigFloat(big.NewInt(sellerFeeBasisPoints), 4).Float64()
+	attributes, _ := json.Marshal(metaInfo.Attributes)
+	metaJson, err := json.Marshal(metaInfo)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	asset.Symbol = metaInfo.Symbol
+	asset.Name = metaInfo.Name
+	asset.TokenURL = metaInfo.Image
+	asset.ExternalUrl = metaInfo.ExternalUrl
+	asset.SellerFeeRate = sellerFeeRate
+	asset.Attributes = string(attributes)
+	asset.MetaJson = string(metaJson)
+	asset.MetaJsonUrl = meta.Data.Uri
+	asset.MetaPlaceholder = false
+	asset.MetaAttempts = 0
+	asset.MetaError = ""
+	asset.MetaRefreshedAt = helpers.TimeNow()
+	asset.MetaNextRefreshAt = helpers.TimeNowAdd(assetMetadataRefreshInterval)
+	return nil
+}
+
+// getAssetCollection resolves the collection of a new asset, unverified
+// collections are created from the metadata name. Without metadata json or
+// collection name the asset is matched by its creators or parked in the
+// placeholder collection until its metadata is refreshed
+func (s *NftLend) getAssetCollection(tx *gorm.DB, p *assetPrefetch) (*models.Collection, string, error) {
+	collection, tokenId, err := s.getCollectionVerified(tx, p)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	if collection != nil {
+		return collection, tokenId, nil
+	}
+	var collectionName string
+	if p.MetaInfo != nil {
+		collectionName = p.MetaInfo.Collection.Name
+		if collectionName == "" {
+			names := strings.Split(p.MetaInfo.Name, "#")
+			if len(names) >= 2 {
+				collectionName = strings.TrimSpace(names[0])
+			}
+		}
+	}
+	if collectionName == "" {
+		for _, creator := range p.Meta.Data.Creators {
+			enabled := true
+			filter := &daos.CollectionFilter{
+				CreatorLike: creator.Address,
+				Enabled:     &enabled,
+			}
+			collection, err = s.cld.FirstSpec(
+				tx,
+				filter.Spec().
+					Order("id desc"),
+				false,
+			)
+			if err != nil {
+				return nil, "", errs.NewError(err)
+			}
+			if collection != nil {
+				return collection, "", nil
+			}
+		}
+		collection, err = s.getPlaceholderCollection(tx)
+		if err != nil {
+			return nil, "", errs.NewError(err)
+		}
+		return collection, "", nil
+	}
+	collection, err = s.cld.First(
+		tx,
+		map[string][]interface{}{
+			"name = ?": []interface{}{collectionName},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	if collection == nil {
+		collection = &models.Collection{
+			Network:     models.ChainSOL,
+			SeoURL:      helpers.MakeSeoURL(collectionName),
+			Name:        collectionName,
+			Description: p.MetaInfo.Description,
+			Enabled:     true,
+		}
+		err = s.cld.Create(
+			tx,
+			collection,
+		)
+		if err != nil {
+			return nil, "", errs.NewError(err)
+		}
+		err = s.indexSearchCollection(tx, collection)
+		if err != nil {
+			return nil, "", errs.NewError(err)
+		}
+	}
+	return collection, tokenId, nil
+}
+
+func isPlaceholderCollection(collection *models.Collection) bool {
+	return collection.SeoURL == placeholderCollectionSeoURL
+}
+
+// getPlaceholderCollection holds the assets whose collection is unknown until
+// their metadata is fetched, it is disabled so it is never listed
+func (s *NftLend) getPlaceholderCollection(tx *gorm.DB) (*models.Collection, error) {
+	collection, err := s.cld.First(
+		tx,
+		map[string][]interface{}{
+			"seo_url = ?": []interface{}{placeholderCollectionSeoURL},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if collection == nil {
+		collection = &models.Collection{
+			Network: models.ChainSOL,
+			SeoURL:  placeholderCollectionSeoURL,
+			Name:    "Unidentified",
+			Enabled: false,
+		}
+		err = s.cld.Create(
+			tx,
+			collection,
+		)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+	}
+	return collection, nil
+}
+
+// JobRefreshAssetMetadata refreshes a batch of due assets, the placeholders
+// first then the stalest. Failures are recorded on the asset and retried with
+// backoff without stopping the batch
+func (s *NftLend) JobRefreshAssetMetadata(ctx context.Context) error {
+	assets, err := s.ad.FindSpec(
+		s.conn.DB(ctx),
+		daos.NewSpec().
+			Where(
+				daos.Eq("assets.network", models.ChainSOL),
+				daos.Or(
+					daos.Raw("assets.meta_next_refresh_at is null"),
+					daos.Lte("assets.meta_next_refresh_at", time.Now()),
+				),
+			).
+			Order("assets.meta_placeholder desc", "assets.meta_next_refresh_at asc"),
+		0,
+		assetMetadataBatchSize,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	for _, asset := range assets {
+		_, err = s.refreshAssetMetadata(ctx, asset.ID, false)
+		if err != nil {
+			err = s.deferAssetMetadataRefresh(ctx, asset.ID, err)
+			if err != nil {
+				return errs.NewError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// recordAssetMetadataError keeps the error on the asset and backs off its next
+// refresh
+func (s *NftLend) recordAssetMetadataError(tx *gorm.DB, asset *models.Asset, refreshErr error) error {
+	asset.MetaAttempts++
+	asset.MetaError = refreshErr.Error()
+	asset.MetaNextRefreshAt = helpers.TimeNowAdd(assetMetadataRetryDelay(asset.MetaAttempts))
+	err := s.ad.Save(
+		tx,
+		asset,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// deferAssetMetadataRefresh records a refresh which failed after the fetch so
+// the asset does not hold the head of the queue
+func (s *NftLend) deferAssetMetadataRefresh(ctx context.Context, assetID uint, refreshErr error) error {
+	return s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			asset, err := s.ad.FirstByID(
+				tx,
+				assetID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if asset == nil {
+				return nil
+			}
+			err = s.recordAssetMetadataError(tx, asset, refreshErr)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+}
+
+// refreshAssetMetadata fetches the metadata of the asset then stores it, a
+// placeholder asset is also moved to its collection. The fetch happens out of
+// the transaction, force bypasses the metadata cache. A failed fetch is only
+// recorded on the asset and reported by fetched
+func (s *NftLend) refreshAssetMetadata(ctx context.Context, assetID uint, force bool) (bool, error) {
+	asset, err := s.ad.FirstByID(
+		s.conn.DB(ctx),
+		assetID,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return false, errs.NewError(err)
+	}
+	if asset == nil {
+		return false, errs.NewError(errs.ErrAssetNotFound)
+	}
+	p, fetchErr := s.prefetchAsset(asset.ContractAddress, force)
+	if fetchErr == nil {
+		fetchErr = p.MetaErr
+	}
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			asset, err := s.ad.FirstByID(
+				tx,
+				assetID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if fetchErr != nil {
+				err = s.recordAssetMetadataError(tx, asset, fetchErr)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				return nil
+			}
+			collectionID, attributes, placeholder := asset.CollectionID, asset.Attributes, asset.MetaPlaceholder
+			var collection *models.Collection
+			if placeholder {
+				var tokenId string
+				collection, tokenId, err = s.getAssetCollection(tx, p)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				asset.CollectionID = collection.ID
+				asset.OriginNetwork = collection.OriginNetwork
+				asset.OriginContractAddress = collection.OriginContractAddress
+				asset.OriginTokenID = tokenId
+			}
+			err = setAssetMetadata(asset, p.Meta, p.MetaInfo)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if placeholder {
+				asset.MetaPlaceholder = isPlaceholderCollection(collection)
+			}
+			err = s.ad.Save(
+				tx,
+				asset,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			err = s.indexSearchAsset(tx, asset)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if asset.CollectionID != collectionID || asset.Attributes != attributes {
+				err = s.markCollectionRarityStale(tx, asset.CollectionID)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return false, errs.NewError(err)
+	}
+	return fetchErr == nil, nil
+}
@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	assetTransactionDedupeBatchSize = 500
+)
+
+// assetTransactionDedupKey is the natural key of a sale on its source, the
+// marketplace transaction id or a hash of the sale for the marketplaces which
+// do not report one. Amounts are compared at 9 decimals so that a crawled
+// float and the stored decimal of the same sale hash alike
+func assetTransactionDedupKey(m *models.AssetTransaction) string {
+	if m.TransactionID != "" {
+		return fmt.Sprintf("tx:%s", m.TransactionID)
+	}
+	var transactionAt int64
+	if m.TransactionAt != nil {
+		transactionAt = m.TransactionAt.Unix()
+	}
+	h := sha256.Sum256(
+		[]byte(
+			fmt.Sprintf(
+				"%d|%s|%s|%d|%s|%d",
+				m.AssetID,
+				m.Seller,
+				m.Buyer,
+				transactionAt,
+				m.Amount.Text('f', 9),
+				m.CurrencyID,
+			),
+		),
+	)
+	return fmt.Sprintf("sha:%s", hex.EncodeToString(h[:]))
+}
+
+// upsertAssetTransaction stores a sale once per dedup key, crawling the same
+// sale again only refreshes the stored row
+func (s *NftLend) upsertAssetTransaction(tx *gorm.DB, m *models.AssetTransaction) error {
+	dedupKey := assetTransactionDedupKey(m)
+	m.DedupKey = &dedupKey
+	err := s.atd.Upsert(tx, m)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// JobDedupeAssetTransactions is the one-off cleanup of the sales stored
+// before dedup keys existed, every row gets its key and the rows repeating
+// an already keyed sale are merged into it and deleted
+func (s *NftLend) JobDedupeAssetTransactions(ctx context.Context) (uint, error) {
+	var (
+		lastID uint
+		merged uint
+	)
+	for {
+		ms, err := s.atd.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"dedup_key is null": nil,
+				"id > ?":            []interface{}{lastID},
+			},
+			map[string][]interface{}{},
+			[]string{"id asc"},
+			0,
+			assetTransactionDedupeBatchSize,
+		)
+		if err != nil {
+			return merged, errs.NewError(err)
+		}
+		for _, m := range ms {
+			lastID = m.ID
+			isMerged, err := s.dedupeAssetTransaction(ctx, m.ID)
+			if err != nil {
+				return merged, errs.NewError(err)
+			}
+			if isMerged {
+				merged++
+			}
+		}
+		if len(ms) < assetTransactionDedupeBatchSize {
+			break
+		}
+	}
+	return merged, nil
+}
+
+func (s *NftLend) dedupeAssetTransaction(ctx context.Context, id uint) (bool, error) {
+	var isMerged bool
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			m, err := s.atd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m == nil || m.DedupKey != nil {
+				return nil
+			}
+			dedupKey := assetTransactionDedupKey(m)
+			e, err := s.atd.First(
+				tx,
+				map[string][]interface{}{
+					"asset_id = ?":  []interface{}{m.AssetID},
+					"source = ?":    []interface{}{m.Source},
+					"dedup_key = ?": []interface{}{dedupKey},
+				},
+				map[string][]interface{}{},
+				[]string{},
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if e == nil {
+				m.DedupKey = &dedupKey
+				err = s.atd.Save(tx, m)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				return nil
+			}
+			if e.Seller == "" {
+				e.Seller = m.Seller
+			}
+			if e.Buyer == "" {
+				e.Buyer = m.Buyer
+			}
+			if e.TransactionAt == nil {
+				e.TransactionAt = m.TransactionAt
+			}
+			err = s.atd.Save(tx, e)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			err = s.atd.Delete(tx, m)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			isMerged = true
+			return nil
+		},
+	)
+	if err != nil {
+		return false, errs.NewError(err)
+	}
+	return isMerged, nil
+}
@@ -0,0 +1,193 @@
+package services_test
+
+import (
+	"context"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/services"
+	"github.com/czConstant/constant-nftylend-api/types/numeric"
+)
+
+// floatAmount is an amount as crawled from a marketplace
+func floatAmount(f float64) numeric.BigFloat {
+	return numeric.BigFloat{*big.NewFloat(f)}
+}
+
+// decimalAmount is an amount as read back from the decimal column
+func decimalAmount(t *testing.T, s string) numeric.BigFloat {
+	t.Helper()
+	var n numeric.BigFloat
+	err := n.Scan([]byte(s))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+// assetSale is a sale of the test asset on magiceden without transaction id
+func assetSale(seller string, transactionAt *time.Time, amount numeric.BigFloat) *models.AssetTransaction {
+	return &models.AssetTransaction{
+		Source:        "magiceden",
+		Network:       models.ChainSOL,
+		AssetID:       1,
+		Type:          models.AssetTransactionTypeExchange,
+		Seller:        seller,
+		Buyer:         lenderOne,
+		TransactionAt: transactionAt,
+		Amount:        amount,
+		CurrencyID:    1,
+	}
+}
+
+func TestAssetTransactionDedupKey(t *testing.T) {
+	at := genesis.Add(90 * time.Minute)
+	atLocal := at.In(time.FixedZone("UTC+7", 7*3600))
+	later := at.Add(time.Second)
+	for _, c := range []struct {
+		name string
+		a    *models.AssetTransaction
+		b    func(m *models.AssetTransaction)
+		same bool
+	}{
+		{"float and decimal amount", assetSale(borrower, &at, floatAmount(1.1)), func(m *models.AssetTransaction) { m.Amount = decimalAmount(t, "1.100000000000000000") }, true},
+		{"float and decimal small amount", assetSale(borrower, &at, floatAmount(0.123456789)), func(m *models.AssetTransaction) { m.Amount = decimalAmount(t, "0.123456789000000000") }, true},
+		{"float noise below 9 decimals", assetSale(borrower, &at, floatAmount(0.30000000000000004)), func(m *models.AssetTransaction) { m.Amount = decimalAmount(t, "0.3") }, true},
+		{"amount past 9 decimals", assetSale(borrower, &at, floatAmount(2)), func(m *models.AssetTransaction) { m.Amount = decimalAmount(t, "2.0000000004") }, true},
+		{"transaction time zone", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.TransactionAt = &atLocal }, true},
+		{"other amount", assetSale(borrower, &at, floatAmount(1.1)), func(m *models.AssetTransaction) { m.Amount = decimalAmount(t, "1.100000001") }, false},
+		{"other seller", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.Seller = lenderTwo }, false},
+		{"other time", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.TransactionAt = &later }, false},
+		{"no time", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.TransactionAt = nil }, false},
+		{"other currency", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.CurrencyID = 2 }, false},
+		{"other asset", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.AssetID = 2 }, false},
+		{"transaction id", assetSale(borrower, &at, floatAmount(1)), func(m *models.AssetTransaction) { m.TransactionID = "sale01" }, false},
+	} {
+		b := *c.a
+		c.b(&b)
+		ka, kb := services.AssetTransactionDedupKey(c.a), services.AssetTransactionDedupKey(&b)
+		if (ka == kb) != c.same {
+			t.Errorf("%s: keys %s and %s, want same %v", c.name, ka, kb, c.same)
+		}
+	}
+	// the transaction id is the key whatever the contents
+	m := assetSale(borrower, &at, floatAmount(1))
+	m.TransactionID = "sale01"
+	o := assetSale(lenderTwo, nil, floatAmount(2))
+	o.TransactionID = "sale01"
+	if k := services.AssetTransactionDedupKey(m); k != "tx:sale01" || services.AssetTransactionDedupKey(o) != k {
+		t.Errorf("keys of sale01 = %s and %s, want tx:sale01", k, services.AssetTransactionDedupKey(o))
+	}
+}
+
+type dedupedSale struct {
+	Source        string
+	TransactionID string
+	Seller        string
+	Buyer         string
+	TransactionAt int64
+	Keyed         bool
+}
+
+func TestDedupeAssetTransactions(t *testing.T) {
+	at := genesis.Add(90 * time.Minute)
+	withID := func(m *models.AssetTransaction, transactionID string) *models.AssetTransaction {
+		m.TransactionID = transactionID
+		return m
+	}
+	bare := func(m *models.AssetTransaction) *models.AssetTransaction {
+		m.Seller = ""
+		m.Buyer = ""
+		m.TransactionAt = nil
+		return m
+	}
+	onSource := func(m *models.AssetTransaction, source string) *models.AssetTransaction {
+		m.Source = source
+		return m
+	}
+	for _, c := range []struct {
+		name   string
+		keyed  []*models.AssetTransaction
+		legacy []*models.AssetTransaction
+		merged uint
+		want   []dedupedSale
+	}{
+		{
+			name:   "legacy fills the keyed row",
+			keyed:  []*models.AssetTransaction{bare(withID(assetSale(borrower, &at, floatAmount(1)), "sale01"))},
+			legacy: []*models.AssetTransaction{withID(assetSale(borrower, &at, floatAmount(1)), "sale01")},
+			merged: 1,
+			want:   []dedupedSale{{"magiceden", "sale01", borrower, lenderOne, at.Unix(), true}},
+		},
+		{
+			name:   "keyed row keeps its parties",
+			keyed:  []*models.AssetTransaction{withID(assetSale(lenderTwo, &at, floatAmount(1)), "sale01")},
+			legacy: []*models.AssetTransaction{withID(assetSale(borrower, nil, floatAmount(1)), "sale01")},
+			merged: 1,
+			want:   []dedupedSale{{"magiceden", "sale01", lenderTwo, lenderOne, at.Unix(), true}},
+		},
+		{
+			name:   "crawled float merges the stored decimal",
+			keyed:  []*models.AssetTransaction{assetSale(borrower, &at, floatAmount(1.1))},
+			legacy: []*models.AssetTransaction{assetSale(borrower, &at, floatAmount(1.1))},
+			merged: 1,
+			want:   []dedupedSale{{"magiceden", "", borrower, lenderOne, at.Unix(), true}},
+		},
+		{
+			name:   "legacy duplicates merge together",
+			legacy: []*models.AssetTransaction{withID(assetSale(borrower, &at, floatAmount(1)), "sale01"), withID(bare(assetSale(borrower, &at, floatAmount(1))), "sale01")},
+			merged: 1,
+			want:   []dedupedSale{{"magiceden", "sale01", borrower, lenderOne, at.Unix(), true}},
+		},
+		{
+			name:   "other sales are only keyed",
+			keyed:  []*models.AssetTransaction{withID(assetSale(borrower, &at, floatAmount(1)), "sale01")},
+			legacy: []*models.AssetTransaction{withID(assetSale(borrower, &at, floatAmount(1)), "sale02"), onSource(withID(assetSale(borrower, &at, floatAmount(1)), "sale01"), "solanart")},
+			want: []dedupedSale{
+				{"magiceden", "sale01", borrower, lenderOne, at.Unix(), true},
+				{"magiceden", "sale02", borrower, lenderOne, at.Unix(), true},
+				{"solanart", "sale01", borrower, lenderOne, at.Unix(), true},
+			},
+		},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			lt := newLendTest(t)
+			for _, m := range c.keyed {
+				m.AssetID = lt.asset.ID
+				key := services.AssetTransactionDedupKey(m)
+				m.DedupKey = &key
+				lt.create((&daos.AssetTransaction{}).Create, m)
+			}
+			for _, m := range c.legacy {
+				m.AssetID = lt.asset.ID
+				lt.create((&daos.AssetTransaction{}).Create, m)
+			}
+			merged, err := lt.s.JobDedupeAssetTransactions(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if merged != c.merged {
+				t.Fatalf("merged = %d, want %d", merged, c.merged)
+			}
+			ms, err := (&daos.AssetTransaction{}).Find(lt.db, map[string][]interface{}{}, map[string][]interface{}{}, []string{"source asc", "transaction_id asc"}, 0, -1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []dedupedSale{}
+			for _, m := range ms {
+				s := dedupedSale{m.Source, m.TransactionID, m.Seller, m.Buyer, 0, m.DedupKey != nil}
+				if m.TransactionAt != nil {
+					s.TransactionAt = m.TransactionAt.Unix()
+				}
+				got = append(got, s)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Fatalf("sales = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+// fields left out of the audit diff, they change on every save
+var auditDiffIgnoredFields = map[string]bool{
+	"UpdatedAt": true,
+}
+
+func (s *NftLend) getAuditTarget(ctx context.Context, targetType models.AuditTargetType, id uint) (interface{}, error) {
+	db := s.conn.DB(ctx)
+	preloads := map[string][]interface{}{}
+	switch targetType {
+	case models.AuditTargetCollection:
+		{
+			m, err := s.cld.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			return m, nil
+		}
+	case models.AuditTargetCurrency:
+		{
+			m, err := s.cd.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			return m, nil
+		}
+	case models.AuditTargetAsset:
+		{
+			m, err := s.ad.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			return m, nil
+		}
+	case models.AuditTargetInstruction:
+		{
+			m, err := s.id.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			return m, nil
+		}
+	case models.AuditTargetCollectionSubmitted:
+		{
+			m, err := s.clsd.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			return m, nil
+		}
+	case models.AuditTargetApiKey:
+		{
+			m, err := s.akd.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			m.KeyHash = ""
+			return m, nil
+		}
+	case models.AuditTargetPartner:
+		{
+			m, err := s.pd.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			m.KeyHash = ""
+			return m, nil
+		}
+	case models.AuditTargetUserRole:
+		{
+			m, err := s.urd.FirstByID(db, id, preloads, false)
+			if err != nil || m == nil {
+				return nil, errs.NewError(err)
+			}
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// GetAuditSnapshot serializes the entity a privileged request acts on, it is
+// taken before and after the request
+func (s *NftLend) GetAuditSnapshot(ctx context.Context, targetType models.AuditTargetType, id uint) (string, error) {
+	m, err := s.getAuditTarget(ctx, targetType, id)
+	if err != nil {
+		return "", errs.NewError(err)
+	}
+	if m == nil || reflect.ValueOf(m).IsNil() {
+		return "", nil
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return "", errs.NewError(err)
+	}
+	return string(data), nil
+}
+
+func auditFields(snapshot string) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+	if snapshot == "" {
+		return fields, nil
+	}
+	err := json.Unmarshal([]byte(snapshot), &fields)
+	if err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// auditDiff keeps the changed top level fields as {"field": {"before": .., "after": ..}}
+func auditDiff(before map[string]interface{}, after map[string]interface{}) (string, error) {
+	diff := map[string]map[string]interface{}{}
+	for k, v := range before {
+		if auditDiffIgnoredFields[k] {
+			continue
+		}
+		if !reflect.DeepEqual(v, after[k]) {
+			diff[k] = map[string]interface{}{"before": v, "after": after[k]}
+		}
+	}
+	for k, v := range after {
+		if auditDiffIgnoredFields[k] {
+			continue
+		}
+		if _, ok := before[k]; !ok && v != nil {
+			diff[k] = map[string]interface{}{"before": nil, "after": v}
+		}
+	}
+	if len(diff) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(diff)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// CreateAuditLog appends the audit row, the diff is computed from the Before
+// and After snapshots
+func (s *NftLend) CreateAuditLog(ctx context.Context, m *models.AuditLog) error {
+	before, err := auditFields(m.Before)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	after, err := auditFields(m.After)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	m.Diff, err = auditDiff(before, after)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	err = s.ald.Create(
+		s.conn.DB(ctx),
+		m,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+func (s *NftLend) AdminGetAuditLogs(ctx context.Context, actor string, targetType models.AuditTargetType, targetID uint, route string, fromTime *time.Time, toTime *time.Time, page int, limit int) ([]*models.AuditLog, uint, error) {
+	filters := map[string][]interface{}{}
+	if actor != "" {
+		filters["actor = ?"] = []interface{}{actor}
+	}
+	if targetType != models.AuditTargetNone {
+		filters["target_type = ?"] = []interface{}{targetType}
+	}
+	if targetID > 0 {
+		filters["target_id = ?"] = []interface{}{targetID}
+	}
+	if route != "" {
+		filters["route = ?"] = []interface{}{route}
+	}
+	if fromTime != nil {
+		filters["created_at >= ?"] = []interface{}{fromTime}
+	}
+	if toTime != nil {
+		filters["created_at < ?"] = []interface{}{toTime}
+	}
+	ms, count, err := s.ald.Find4Page(
+		s.conn.DB(ctx),
+		filters,
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, count, nil
+}
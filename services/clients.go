@@ -0,0 +1,109 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/czConstant/blockchain-api/bcclient"
+	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/nftmeta"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+)
+
+// NftVerification is the wrapped-nft origin of a solana mint
+type NftVerification struct {
+	IsWrapped    bool
+	Chain        string
+	AssetAddress string
+	TokenID      string
+}
+
+type BlockchainClient interface {
+	NftLendUpdateBlock(block uint64) error
+	GetMetadata(mintAddress string) (*solana.MetadataResp, error)
+	GetMetadataInfo(uri string) (*solana.MetadataInfoResp, error)
+	RefreshMetadataInfo(uri string) (*solana.MetadataInfoResp, error)
+	GetMetadataMedia(uri string) ([]byte, string, error)
+	GetNftVerifier(mintAddress string) (*NftVerification, error)
+}
+
+// SaleHistoryRegistry serves the marketplace providers of every network
+type SaleHistoryRegistry interface {
+	Providers(network string) []saletrack.SaleHistoryProvider
+	Provider(source string) saletrack.SaleHistoryProvider
+	All() []saletrack.SaleHistoryProvider
+}
+
+var _ SaleHistoryRegistry = (*saletrack.Registry)(nil)
+
+// blockchainClient reads the on chain metadata from the solana client and the
+// metadata json through the gateways of mdc
+type blockchainClient struct {
+	bcs *bcclient.Client
+	mdc *nftmeta.Client
+}
+
+func NewBlockchainClient(bcs *bcclient.Client, mdc *nftmeta.Client) BlockchainClient {
+	return &blockchainClient{
+		bcs: bcs,
+		mdc: mdc,
+	}
+}
+
+func (c *blockchainClient) NftLendUpdateBlock(block uint64) error {
+	return c.bcs.Solana.NftLendUpdateBlock(block)
+}
+
+func (c *blockchainClient) GetMetadata(mintAddress string) (*solana.MetadataResp, error) {
+	return c.bcs.Solana.GetMetadata(mintAddress)
+}
+
+func (c *blockchainClient) GetMetadataInfo(uri string) (*solana.MetadataInfoResp, error) {
+	info, err := c.mdc.GetMetadataInfo(uri)
+	if err != nil {
+		return nil, err
+	}
+	return newMetadataInfoResp(info), nil
+}
+
+func (c *blockchainClient) RefreshMetadataInfo(uri string) (*solana.MetadataInfoResp, error) {
+	info, err := c.mdc.RefreshMetadataInfo(uri)
+	if err != nil {
+		return nil, err
+	}
+	return newMetadataInfoResp(info), nil
+}
+
+func (c *blockchainClient) GetMetadataMedia(uri string) ([]byte, string, error) {
+	return c.mdc.GetMedia(uri)
+}
+
+func newMetadataInfoResp(info *nftmeta.MetadataInfo) *solana.MetadataInfoResp {
+	m := &solana.MetadataInfoResp{
+		Name:                 info.Name,
+		Symbol:               info.Symbol,
+		Description:          info.Description,
+		Image:                info.Image,
+		ExternalUrl:          info.ExternalUrl,
+		SellerFeeBasisPoints: info.SellerFeeBasisPoints,
+		Attributes:           info.Attributes,
+	}
+	m.Collection.Name = info.Collection.Name
+	m.Collection.Family = info.Collection.Family
+	return m
+}
+
+func (c *blockchainClient) GetNftVerifier(mintAddress string) (*NftVerification, error) {
+	vrs, err := c.bcs.SolanaNftVerifier.GetNftVerifier(mintAddress)
+	if err != nil {
+		return nil, err
+	}
+	m := &NftVerification{
+		IsWrapped:    vrs.IsWrapped,
+		AssetAddress: vrs.AssetAddress,
+		TokenID:      vrs.TokenID,
+	}
+	if vrs.IsWrapped {
+		m.Chain = fmt.Sprint(c.bcs.SolanaNftVerifier.ParseChain(vrs.ChainID))
+	}
+	return m, nil
+}
@@ -0,0 +1,347 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	collectionStatsMaxLimit    = 720
+	collectionAggregatesWindow = 7 * 24 * time.Hour
+)
+
+// collectionStatPeriod returns the last complete period of the interval
+// before now, periods are aligned on utc hours and days
+func collectionStatPeriod(interval models.CollectionStatInterval, now time.Time) (time.Time, time.Time, error) {
+	now = now.UTC()
+	switch interval {
+	case models.CollectionStatIntervalHour:
+		{
+			end := now.Truncate(time.Hour)
+			return end.Add(-time.Hour), end, nil
+		}
+	case models.CollectionStatIntervalDay:
+		{
+			end := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+			return end.AddDate(0, 0, -1), end, nil
+		}
+	}
+	return time.Time{}, time.Time{}, errs.NewError(errs.ErrBadRequest)
+}
+
+// JobSnapshotCollectionStats writes the snapshot of the last complete period
+// of the interval for every collection with loans, running it again for the
+// same period overwrites the snapshots. The amounts are the ones of the price
+// currency of the collection, see collectionPriceCurrencyStat
+func (s *NftLend) JobSnapshotCollectionStats(ctx context.Context, interval models.CollectionStatInterval) error {
+	start, end, err := collectionStatPeriod(interval, time.Now())
+	if err != nil {
+		return errs.NewError(err)
+	}
+	rs, err := s.ld.GetRPTCollectionStats(
+		s.conn.DB(ctx),
+		nil,
+		start,
+		end,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	crs, err := s.ld.GetRPTCollectionCurrencyStats(
+		s.conn.DB(ctx),
+		nil,
+		start,
+		end,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	crsMap := map[uint][]*models.NftyRPTCollectionCurrencyStat{}
+	for _, cr := range crs {
+		crsMap[cr.CollectionID] = append(crsMap[cr.CollectionID], cr)
+	}
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			for _, r := range rs {
+				m, err := s.csd.First(
+					tx,
+					map[string][]interface{}{
+						"collection_id = ?": []interface{}{r.CollectionID},
+						"stat_interval = ?": []interface{}{interval},
+						"period_start = ?":  []interface{}{start},
+					},
+					map[string][]interface{}{},
+					[]string{},
+				)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				if m == nil {
+					m = &models.CollectionStat{
+						CollectionID: r.CollectionID,
+						StatInterval: interval,
+						PeriodStart:  start,
+					}
+				}
+				cr := collectionPriceCurrencyStat(crsMap[r.CollectionID])
+				m.PeriodEnd = end
+				m.NewListings = r.NewListings
+				m.ActiveListings = r.ActiveListings
+				m.PriceCurrencyID = cr.CurrencyID
+				m.FloorPrice = cr.FloorPrice
+				m.FundedCount = r.FundedCount
+				m.FundedVolume = cr.FundedVolume
+				m.AvgPrincipal = cr.AvgPrincipal
+				m.AvgInterestRate = r.AvgInterestRate
+				m.FinishedCount = r.FinishedCount
+				m.DefaultedCount = r.DefaultedCount
+				m.DefaultRate = 0
+				if r.FinishedCount > 0 {
+					m.DefaultRate = float64(r.DefaultedCount) / float64(r.FinishedCount)
+				}
+				m.TotalFundedCount = r.TotalFundedCount
+				m.TotalVolume = cr.TotalVolume
+				err = s.csd.Save(
+					tx,
+					m,
+				)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// GetCollectionStats returns the latest snapshots of the collection oldest
+// first
+func (s *NftLend) GetCollectionStats(ctx context.Context, seoURL string, interval models.CollectionStatInterval, limit int) ([]*models.CollectionStat, error) {
+	if interval != models.CollectionStatIntervalHour && interval != models.CollectionStatIntervalDay {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	if limit <= 0 || limit > collectionStatsMaxLimit {
+		limit = collectionStatsMaxLimit
+	}
+	filter := &daos.CollectionFilter{
+		SeoURL: seoURL,
+	}
+	collection, err := s.cld.FirstSpec(
+		s.conn.DB(ctx),
+		filter.Spec().Order("id desc"),
+		false,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if collection == nil {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	ms, err := s.csd.Find(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"collection_id = ?": []interface{}{collection.ID},
+			"stat_interval = ?": []interface{}{interval},
+		},
+		map[string][]interface{}{
+			"PriceCurrency": []interface{}{},
+		},
+		[]string{"period_start desc"},
+		0,
+		limit,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	for i, j := 0, len(ms)-1; i < j; i, j = i+1, j-1 {
+		ms[i], ms[j] = ms[j], ms[i]
+	}
+	return ms, nil
+}
+
+// GetCollectionLatestStat returns the last daily snapshot of the collection,
+// nil until the first daily job ran
+func (s *NftLend) GetCollectionLatestStat(ctx context.Context, collectionID uint) (*models.CollectionStat, error) {
+	m, err := s.csd.First(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"collection_id = ?": []interface{}{collectionID},
+			"stat_interval = ?": []interface{}{models.CollectionStatIntervalDay},
+		},
+		map[string][]interface{}{
+			"PriceCurrency": []interface{}{},
+		},
+		[]string{"period_start desc"},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
+
+// collectionPriceCurrencyStat returns the price figures of the currency of
+// the most listings, then of the most funded loans, of a collection. It is
+// empty for a collection without loans
+func collectionPriceCurrencyStat(crs []*models.NftyRPTCollectionCurrencyStat) *models.NftyRPTCollectionCurrencyStat {
+	var cr *models.NftyRPTCollectionCurrencyStat
+	for _, v := range crs {
+		if cr == nil ||
+			v.ActiveListings > cr.ActiveListings ||
+			(v.ActiveListings == cr.ActiveListings && v.FundedCount > cr.FundedCount) ||
+			(v.ActiveListings == cr.ActiveListings && v.FundedCount == cr.FundedCount && v.CurrencyID < cr.CurrencyID) {
+			cr = v
+		}
+	}
+	if cr == nil {
+		cr = &models.NftyRPTCollectionCurrencyStat{}
+	}
+	return cr
+}
+
+// setCollectionAggregates copies the ranking figures of the collection from
+// its loans aggregate, r is nil for a collection without loans. The price
+// figures are the ones of collectionPriceCurrencyStat
+func setCollectionAggregates(m *models.Collection, r *models.NftyRPTCollectionStat, crs []*models.NftyRPTCollectionCurrencyStat) {
+	if r == nil {
+		r = &models.NftyRPTCollectionStat{}
+	}
+	cr := collectionPriceCurrencyStat(crs)
+	m.ListingCount = r.ActiveListings
+	m.PriceCurrencyID = cr.CurrencyID
+	m.FloorPrice = cr.FloorPrice
+	m.WeeklyVolume = cr.FundedVolume
+	m.AvgInterestRate = r.AvgInterestRate
+	m.DefaultRate = 0
+	if r.FinishedCount > 0 {
+		m.DefaultRate = float64(r.DefaultedCount) / float64(r.FinishedCount)
+	}
+	m.AggregatedAt = helpers.TimeNow()
+}
+
+// saveCollectionAggregates stores the ranking figures of the collection. Only
+// the collection row is locked and no loan is, the order the rarity job takes
+// too
+func (s *NftLend) saveCollectionAggregates(ctx context.Context, collectionID uint, r *models.NftyRPTCollectionStat, crs []*models.NftyRPTCollectionCurrencyStat) error {
+	return s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			collection, err := s.cld.FirstByID(
+				tx,
+				collectionID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if collection == nil {
+				return nil
+			}
+			setCollectionAggregates(collection, r, crs)
+			err = s.cld.Save(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+}
+
+// refreshAssetCollectionAggregates recomputes the ranking figures of the
+// collection of the asset after a loan was listed, funded or finished. It runs
+// once the instruction is committed so that the aggregate over the loans of
+// the collection is not read under the loan locks
+func (s *NftLend) refreshAssetCollectionAggregates(ctx context.Context, assetID uint) error {
+	asset, err := s.ad.FirstByID(
+		s.conn.DB(ctx),
+		assetID,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if asset == nil {
+		return errs.NewError(errs.ErrAssetNotFound)
+	}
+	now := time.Now()
+	rs, err := s.ld.GetRPTCollectionStats(
+		s.conn.DB(ctx),
+		[]uint{asset.CollectionID},
+		now.Add(-collectionAggregatesWindow),
+		now,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	crs, err := s.ld.GetRPTCollectionCurrencyStats(
+		s.conn.DB(ctx),
+		[]uint{asset.CollectionID},
+		now.Add(-collectionAggregatesWindow),
+		now,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	var r *models.NftyRPTCollectionStat
+	for _, m := range rs {
+		if m.CollectionID == asset.CollectionID {
+			r = m
+		}
+	}
+	err = s.saveCollectionAggregates(ctx, asset.CollectionID, r, crs)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// JobRefreshCollectionAggregates recomputes the ranking figures of every
+// collection with loans, it slides the 7 days window of the collections with
+// no recent loan event and backfills the new columns
+func (s *NftLend) JobRefreshCollectionAggregates(ctx context.Context) error {
+	now := time.Now()
+	rs, err := s.ld.GetRPTCollectionStats(
+		s.conn.DB(ctx),
+		nil,
+		now.Add(-collectionAggregatesWindow),
+		now,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	crs, err := s.ld.GetRPTCollectionCurrencyStats(
+		s.conn.DB(ctx),
+		nil,
+		now.Add(-collectionAggregatesWindow),
+		now,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	crsMap := map[uint][]*models.NftyRPTCollectionCurrencyStat{}
+	for _, cr := range crs {
+		crsMap[cr.CollectionID] = append(crsMap[cr.CollectionID], cr)
+	}
+	for _, r := range rs {
+		err = s.saveCollectionAggregates(ctx, r.CollectionID, r, crsMap[r.CollectionID])
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+const usdcAddress = "EPjFWdd5AufqSSqeM2qN1xzybapC8G4wEGGkZwyTDt1v"
+
+// listLoan lists the asset for amount of the currency at loanAddress
+func (lt *lendTest) listLoan(asset *models.Asset, loanAddress string, currency string, amount uint64) {
+	lt.t.Helper()
+	data := initLoanData(asset.ContractAddress, loanAddress)
+	data["loan_currency"] = currency
+	data["loan_principal_amount"] = amount
+	lt.mustHook("InitLoan", data)
+}
+
+// the floor of a collection listed in two currencies is the one of the
+// currency of the most listings, the amounts are never compared across
+// currencies
+func TestJobSnapshotCollectionStatsPriceCurrency(t *testing.T) {
+	lt := newLendTest(t)
+	usdc := &models.Currency{
+		Network:         models.ChainSOL,
+		ContractAddress: usdcAddress,
+		Decimals:        6,
+		Symbol:          "USDC",
+		Name:            "USD Coin",
+		Enabled:         1,
+	}
+	lt.create((&daos.Currency{}).Create, usdc)
+	lt.listLoan(lt.asset, loanAccount, solAddress, 5000000000)
+	lt.listLoan(lt.crawlAsset(1), "LoanInfo2", usdcAddress, 100000000)
+	lt.listLoan(lt.crawlAsset(2), "LoanInfo3", usdcAddress, 80000000)
+	for _, interval := range []models.CollectionStatInterval{models.CollectionStatIntervalHour, models.CollectionStatIntervalDay} {
+		err := lt.s.JobSnapshotCollectionStats(context.Background(), interval)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := lt.s.GetCollectionStats(context.Background(), lt.collection.SeoURL, interval, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(stats) != 1 {
+			t.Fatalf("%s snapshots = %d, want 1", interval, len(stats))
+		}
+		stat := stats[0]
+		if stat.ActiveListings != 3 {
+			t.Errorf("%s active listings = %d, want every listing", interval, stat.ActiveListings)
+		}
+		if stat.PriceCurrency == nil || stat.PriceCurrency.ID != usdc.ID {
+			t.Errorf("%s price currency = %+v, want usdc", interval, stat.PriceCurrency)
+		}
+		if floor := stat.FloorPrice.Text('f', 2); floor != "80.00" {
+			t.Errorf("%s floor = %s, want the usdc floor 80.00", interval, floor)
+		}
+	}
+}
@@ -2,29 +2,473 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/czConstant/constant-nftylend-api/daos"
 	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
 	"github.com/czConstant/constant-nftylend-api/models"
 	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/jinzhu/gorm"
 )
 
-func (s *NftLend) CreateCollectionSubmitted(ctx context.Context, req *serializers.CollectionSubmittedReq) error {
-	err := s.clsd.Create(
-		daos.GetDBMainCtx(ctx),
-		&models.CollectionSubmitted{
-			Network:         req.Network,
-			Name:            req.Name,
-			Description:     req.Description,
-			Creator:         req.Creator,
-			ContractAddress: req.ContractAddress,
-			ContactInfo:     req.ContactInfo,
-			Verified:        req.Verified,
-			WhoVerified:     req.WhoVerified,
+// validateCollectionSubmittedReq trims the request, enforces the required fields
+// and the address formats of the submitted network
+func validateCollectionSubmittedReq(req *serializers.CollectionSubmittedReq) error {
+	req.Name = strings.TrimSpace(req.Name)
+	req.Description = strings.TrimSpace(req.Description)
+	req.Creator = strings.TrimSpace(req.Creator)
+	req.ContactInfo = strings.TrimSpace(req.ContactInfo)
+	err := validateNetwork(req.Network)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if req.Name == "" {
+		return errs.NewInvalidParamsError("name is required")
+	}
+	if req.ContactInfo == "" {
+		return errs.NewInvalidParamsError("contact_info is required")
+	}
+	if strings.TrimSpace(req.ContractAddress) == "" {
+		return errs.NewInvalidParamsError("contract_address is required")
+	}
+	req.ContractAddress, err = normalizeAddress(req.Network, req.ContractAddress)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if req.Creator != "" {
+		req.Creator, err = normalizeAddress(req.Network, req.Creator)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+// checkCollectionSubmittedDuplicated rejects submissions of listed collections
+// and of contracts still waiting for review
+func (s *NftLend) checkCollectionSubmittedDuplicated(tx *gorm.DB, req *serializers.CollectionSubmittedReq) error {
+	filter := &daos.CollectionFilter{}
+	switch req.Network {
+	case models.ChainSOL:
+		{
+			filter.Name = req.Name
+		}
+	default:
+		{
+			filter.OriginNetwork = req.Network
+			filter.OriginContractAddress = req.ContractAddress
+		}
+	}
+	collection, err := s.cld.FirstSpec(
+		tx,
+		filter.Spec(),
+		false,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if collection != nil {
+		return errs.NewError(errs.ErrCollectionExists)
+	}
+	submitted, err := s.clsd.First(
+		tx,
+		map[string][]interface{}{
+			"network = ?":          []interface{}{req.Network},
+			"contract_address = ?": []interface{}{req.ContractAddress},
+			"status in (?)": []interface{}{
+				[]models.CollectionSubmittedStatus{
+					models.CollectionSubmittedStatusPending,
+					models.CollectionSubmittedStatusInReview,
+				},
+			},
 		},
+		map[string][]interface{}{},
+		[]string{},
 	)
 	if err != nil {
 		return errs.NewError(err)
 	}
+	if submitted != nil {
+		return errs.NewError(errs.ErrSubmissionExists)
+	}
 	return nil
 }
+
+// CreateCollectionSubmitted stores a pending submission, the submitter keeps
+// the lookup token to follow the review
+func (s *NftLend) CreateCollectionSubmitted(ctx context.Context, submitterIP string, req *serializers.CollectionSubmittedReq) (*models.CollectionSubmitted, error) {
+	err := validateCollectionSubmittedReq(req)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	lookupToken, err := randomHex(16)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	var m *models.CollectionSubmitted
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			err = s.checkCollectionSubmittedDuplicated(tx, req)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			m = &models.CollectionSubmitted{
+				Network:         req.Network,
+				Name:            req.Name,
+				Description:     req.Description,
+				Creator:         req.Creator,
+				ContractAddress: req.ContractAddress,
+				ContactInfo:     req.ContactInfo,
+				Status:          models.CollectionSubmittedStatusPending,
+				LookupToken:     lookupToken,
+				SubmitterIP:     submitterIP,
+			}
+			err = s.clsd.Create(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
+
+func (s *NftLend) GetCollectionSubmittedByToken(ctx context.Context, lookupToken string) (*models.CollectionSubmitted, error) {
+	lookupToken = strings.TrimSpace(lookupToken)
+	if lookupToken == "" {
+		return nil, errs.NewError(errs.ErrSubmissionNotFound)
+	}
+	m, err := s.clsd.First(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"lookup_token = ?": []interface{}{lookupToken},
+		},
+		map[string][]interface{}{
+			"Collection": []interface{}{},
+		},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if m == nil {
+		return nil, errs.NewError(errs.ErrSubmissionNotFound)
+	}
+	return m, nil
+}
+
+func (s *NftLend) AdminGetCollectionSubmitteds(ctx context.Context, status models.CollectionSubmittedStatus, page int, limit int) ([]*models.CollectionSubmitted, uint, error) {
+	filters := map[string][]interface{}{}
+	if status != "" {
+		filters["status = ?"] = []interface{}{status}
+	}
+	ms, count, err := s.clsd.Find4Page(
+		s.conn.DB(ctx),
+		filters,
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, count, nil
+}
+
+func (s *NftLend) AdminGetCollectionSubmitted(ctx context.Context, id uint) (*models.CollectionSubmitted, error) {
+	m, err := s.clsd.FirstByID(
+		s.conn.DB(ctx),
+		id,
+		map[string][]interface{}{
+			"Collection": []interface{}{},
+			"Comments":   []interface{}{},
+		},
+		false,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if m == nil {
+		return nil, errs.NewError(errs.ErrSubmissionNotFound)
+	}
+	return m, nil
+}
+
+func (s *NftLend) AdminCommentCollectionSubmitted(ctx context.Context, id uint, author string, comment string) (*models.CollectionSubmitted, error) {
+	comment = strings.TrimSpace(comment)
+	if comment == "" {
+		return nil, errs.NewInvalidParamsError("comment is required")
+	}
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			m, err := s.clsd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				false,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m == nil {
+				return errs.NewError(errs.ErrSubmissionNotFound)
+			}
+			err = s.clscd.Create(
+				tx,
+				&models.CollectionSubmittedComment{
+					CollectionSubmittedID: m.ID,
+					Author:                author,
+					Comment:               comment,
+				},
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return s.AdminGetCollectionSubmitted(ctx, id)
+}
+
+// updateCollectionSubmittedStatus locks the submission and moves it to status,
+// only pending and in review submissions can change
+func (s *NftLend) updateCollectionSubmittedStatus(ctx context.Context, id uint, status models.CollectionSubmittedStatus, reviewer string, note string, callback func(tx *gorm.DB, m *models.CollectionSubmitted) error) (*models.CollectionSubmitted, error) {
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			m, err := s.clsd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m == nil {
+				return errs.NewError(errs.ErrSubmissionNotFound)
+			}
+			switch m.Status {
+			case models.CollectionSubmittedStatusPending, models.CollectionSubmittedStatusInReview, "":
+				{
+				}
+			default:
+				{
+					return errs.NewError(errs.ErrSubmissionStatusInvalid)
+				}
+			}
+			if m.Status == status {
+				return errs.NewError(errs.ErrSubmissionStatusInvalid)
+			}
+			if callback != nil {
+				err = callback(tx, m)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			}
+			m.Status = status
+			m.ReviewedBy = reviewer
+			m.ReviewedAt = helpers.TimeNow()
+			if note != "" {
+				m.ReviewNote = note
+			}
+			err = s.clsd.Save(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return s.AdminGetCollectionSubmitted(ctx, id)
+}
+
+func (s *NftLend) AdminReviewCollectionSubmitted(ctx context.Context, id uint, reviewer string) (*models.CollectionSubmitted, error) {
+	return s.updateCollectionSubmittedStatus(ctx, id, models.CollectionSubmittedStatusInReview, reviewer, "", nil)
+}
+
+func (s *NftLend) AdminRejectCollectionSubmitted(ctx context.Context, id uint, reviewer string, note string) (*models.CollectionSubmitted, error) {
+	note = strings.TrimSpace(note)
+	if note == "" {
+		return nil, errs.NewInvalidParamsError("note is required")
+	}
+	return s.updateCollectionSubmittedStatus(ctx, id, models.CollectionSubmittedStatusRejected, reviewer, note, nil)
+}
+
+// AdminApproveCollectionSubmitted enables the collection matching the submission
+// or creates it, evm submissions are bridged collections on solana keyed by origin
+func (s *NftLend) AdminApproveCollectionSubmitted(ctx context.Context, id uint, reviewer string, note string) (*models.CollectionSubmitted, error) {
+	return s.updateCollectionSubmittedStatus(
+		ctx,
+		id,
+		models.CollectionSubmittedStatusApproved,
+		reviewer,
+		strings.TrimSpace(note),
+		func(tx *gorm.DB, m *models.CollectionSubmitted) error {
+			collection, err := s.approveCollectionSubmitted(tx, m)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			m.CollectionID = collection.ID
+			return nil
+		},
+	)
+}
+
+func (s *NftLend) approveCollectionSubmitted(tx *gorm.DB, m *models.CollectionSubmitted) (*models.Collection, error) {
+	name := strings.TrimSpace(m.Name)
+	if name == "" {
+		return nil, errs.NewInvalidParamsError("name is required")
+	}
+	collection := &models.Collection{
+		Network:     models.ChainSOL,
+		Name:        name,
+		Description: m.Description,
+		Creator:     m.Creator,
+	}
+	filter := &daos.CollectionFilter{}
+	switch m.Network {
+	case models.ChainSOL:
+		{
+			filter.Name = name
+			filter.CreatorLike = strings.TrimSpace(m.Creator)
+		}
+	case models.ChainETH, models.ChainMATIC:
+		{
+			contractAddress, err := normalizeAddress(m.Network, m.ContractAddress)
+			if err != nil {
+				return nil, errs.NewError(err)
+			}
+			collection.OriginNetwork = m.Network
+			collection.OriginContractAddress = contractAddress
+			filter.OriginNetwork = collection.OriginNetwork
+			filter.OriginContractAddress = collection.OriginContractAddress
+		}
+	default:
+		{
+			return nil, errs.NewError(errs.ErrNetworkInvalid)
+		}
+	}
+	existed, err := s.cld.FirstSpec(
+		tx,
+		filter.Spec().Order("id desc"),
+		true,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if existed != nil {
+		existed.Enabled = true
+		existed.Verified = true
+		err = s.cld.Save(
+			tx,
+			existed,
+		)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		return existed, nil
+	}
+	collection.SeoURL = helpers.MakeSeoURL(name)
+	seoFilter := &daos.CollectionFilter{
+		SeoURL: collection.SeoURL,
+	}
+	seoCollection, err := s.cld.FirstSpec(
+		tx,
+		seoFilter.Spec(),
+		false,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if seoCollection != nil {
+		collection.SeoURL = fmt.Sprintf("%s-%d", collection.SeoURL, m.ID)
+	}
+	collection.Enabled = true
+	collection.Verified = true
+	err = s.cld.Create(
+		tx,
+		collection,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	err = s.indexSearchCollection(tx, collection)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return collection, nil
+}
+
+// JobVerifyApprovedCollections is the one-off backfill of the verified flag
+// of the collections approved before the flag existed, it returns the number
+// of collections marked
+func (s *NftLend) JobVerifyApprovedCollections(ctx context.Context) (uint, error) {
+	submitteds, err := s.clsd.Find(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"status = ?":        []interface{}{models.CollectionSubmittedStatusApproved},
+			"collection_id > ?": []interface{}{0},
+		},
+		map[string][]interface{}{},
+		[]string{"id asc"},
+		0,
+		99999999,
+	)
+	if err != nil {
+		return 0, errs.NewError(err)
+	}
+	var verified uint
+	for _, submitted := range submitteds {
+		err = s.conn.WithTransaction(
+			ctx,
+			func(tx *gorm.DB) error {
+				collection, err := s.cld.FirstByID(
+					tx,
+					submitted.CollectionID,
+					map[string][]interface{}{},
+					true,
+				)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				if collection == nil || collection.Verified {
+					return nil
+				}
+				collection.Verified = true
+				err = s.cld.Save(
+					tx,
+					collection,
+				)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				verified++
+				return nil
+			},
+		)
+		if err != nil {
+			return verified, errs.NewError(err)
+		}
+	}
+	return verified, nil
+}
@@ -0,0 +1,62 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+// submittedCollection adds a collection with a submission of status
+func (lt *lendTest) submittedCollection(n int, status models.CollectionSubmittedStatus, verified bool) *models.Collection {
+	lt.t.Helper()
+	collection := &models.Collection{
+		Network:  models.ChainSOL,
+		SeoURL:   fmt.Sprintf("submitted-%d", n),
+		Name:     fmt.Sprintf("Submitted %d", n),
+		Enabled:  true,
+		Verified: verified,
+	}
+	lt.create((&daos.Collection{}).Create, collection)
+	lt.create((&daos.CollectionSubmitted{}).Create, &models.CollectionSubmitted{
+		Network:      models.ChainSOL,
+		Name:         collection.Name,
+		Status:       status,
+		LookupToken:  fmt.Sprintf("token-%d", n),
+		CollectionID: collection.ID,
+	})
+	return collection
+}
+
+func TestJobVerifyApprovedCollections(t *testing.T) {
+	lt := newLendTest(t)
+	approved := lt.submittedCollection(1, models.CollectionSubmittedStatusApproved, false)
+	lt.submittedCollection(2, models.CollectionSubmittedStatusApproved, true)
+	pending := lt.submittedCollection(3, models.CollectionSubmittedStatusPending, false)
+	for i, want := range []uint{1, 0} {
+		verified, err := lt.s.JobVerifyApprovedCollections(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if verified != want {
+			t.Fatalf("run %d verified = %d, want %d", i, verified, want)
+		}
+	}
+	for _, c := range []struct {
+		collection *models.Collection
+		verified   bool
+	}{
+		{approved, true},
+		{pending, false},
+	} {
+		m, err := (&daos.Collection{}).FirstByID(lt.db, c.collection.ID, map[string][]interface{}{}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if m.Verified != c.verified {
+			t.Errorf("%s verified = %v, want %v", m.Name, m.Verified, c.verified)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package services
+
+// AssetTransactionDedupKey exposes the dedup key to the tests of the package
+var AssetTransactionDedupKey = assetTransactionDedupKey
+
+// ScoreCollectionRarity returns the trait counts, scores and ranks the rarity
+// job computes for the assets given by id with their traits
+func ScoreCollectionRarity(assetTraitsMap map[uint]map[string]string) (map[string]map[string]uint, map[uint]float64, map[uint]uint) {
+	r := scoreCollectionRarity(assetTraitsMap)
+	return r.counts, r.scores, r.ranks
+}
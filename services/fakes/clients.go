@@ -0,0 +1,205 @@
+package fakes
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
+	"github.com/czConstant/constant-nftylend-api/services"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+)
+
+// BlockchainClient serves metadata and verifier results registered by mint
+// address or uri, unknown keys return an error like the real client would
+type BlockchainClient struct {
+	mtx          sync.Mutex
+	Blocks       []uint64
+	Metadatas    map[string]*solana.MetadataResp
+	MetadataInfo map[string]*solana.MetadataInfoResp
+	Verifiers    map[string]*services.NftVerification
+	Media        map[string][]byte
+}
+
+func NewBlockchainClient() *BlockchainClient {
+	return &BlockchainClient{
+		Metadatas:    map[string]*solana.MetadataResp{},
+		MetadataInfo: map[string]*solana.MetadataInfoResp{},
+		Verifiers:    map[string]*services.NftVerification{},
+		Media:        map[string][]byte{},
+	}
+}
+
+func (c *BlockchainClient) NftLendUpdateBlock(block uint64) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.Blocks = append(c.Blocks, block)
+	return nil
+}
+
+func (c *BlockchainClient) GetMetadata(mintAddress string) (*solana.MetadataResp, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	m, ok := c.Metadatas[mintAddress]
+	if !ok {
+		return nil, fmt.Errorf("fakes: no metadata for %s", mintAddress)
+	}
+	return m, nil
+}
+
+func (c *BlockchainClient) GetMetadataInfo(uri string) (*solana.MetadataInfoResp, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	m, ok := c.MetadataInfo[uri]
+	if !ok {
+		return nil, fmt.Errorf("fakes: no metadata info for %s", uri)
+	}
+	return m, nil
+}
+
+func (c *BlockchainClient) RefreshMetadataInfo(uri string) (*solana.MetadataInfoResp, error) {
+	return c.GetMetadataInfo(uri)
+}
+
+// GetMetadataMedia sniffs the content type of the registered media
+func (c *BlockchainClient) GetMetadataMedia(uri string) ([]byte, string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	m, ok := c.Media[uri]
+	if !ok {
+		return nil, "", fmt.Errorf("fakes: no media for %s", uri)
+	}
+	return m, http.DetectContentType(m), nil
+}
+
+// GetNftVerifier treats unknown mints as native solana nfts
+func (c *BlockchainClient) GetNftVerifier(mintAddress string) (*services.NftVerification, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	m, ok := c.Verifiers[mintAddress]
+	if !ok {
+		return &services.NftVerification{}, nil
+	}
+	return m, nil
+}
+
+// SaleHistoryProvider returns canned sales or errors registered by contract
+// address and records the queries, DeliverSales acts as the stream of a
+// websocket provider
+type SaleHistoryProvider struct {
+	mtx         sync.Mutex
+	source      string
+	Rule        ratelimit.Rule
+	Sales       map[string][]*saletrack.Sale
+	Errors      map[string]error
+	Queries     []*saletrack.SaleQuery
+	salesRecvFn func(sales []*saletrack.Sale)
+}
+
+func NewSaleHistoryProvider(source string) *SaleHistoryProvider {
+	return &SaleHistoryProvider{
+		source: source,
+		Rule:   ratelimit.Rule{Limit: 1000, Period: time.Minute},
+		Sales:  map[string][]*saletrack.Sale{},
+		Errors: map[string]error{},
+	}
+}
+
+func (p *SaleHistoryProvider) Source() string {
+	return p.source
+}
+
+func (p *SaleHistoryProvider) RateLimit() ratelimit.Rule {
+	return p.Rule
+}
+
+func (p *SaleHistoryProvider) GetSaleHistories(q *saletrack.SaleQuery) ([]*saletrack.Sale, error) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.Queries = append(p.Queries, q)
+	if err, ok := p.Errors[q.ContractAddress]; ok {
+		return nil, err
+	}
+	return p.Sales[q.ContractAddress], nil
+}
+
+// StartSaleStream subscribes the service until ctx is done
+func (p *SaleHistoryProvider) StartSaleStream(ctx context.Context, salesReceivedFunc func(sales []*saletrack.Sale)) {
+	p.mtx.Lock()
+	p.salesRecvFn = salesReceivedFunc
+	p.mtx.Unlock()
+	<-ctx.Done()
+	p.mtx.Lock()
+	p.salesRecvFn = nil
+	p.mtx.Unlock()
+}
+
+func (p *SaleHistoryProvider) Health() *saletrack.SaleStreamHealth {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return &saletrack.SaleStreamHealth{
+		Source:    p.source,
+		Connected: p.salesRecvFn != nil,
+	}
+}
+
+// DeliverSales hands sales to the subscribed service
+func (p *SaleHistoryProvider) DeliverSales(sales []*saletrack.Sale) {
+	p.mtx.Lock()
+	fn := p.salesRecvFn
+	p.mtx.Unlock()
+	if fn != nil {
+		fn(sales)
+	}
+}
+
+// SalePageProvider serves the sales of its SaleHistoryProvider newest first in
+// pages of PageSize
+type SalePageProvider struct {
+	*SaleHistoryProvider
+	PageSize int
+}
+
+func NewSalePageProvider(source string, pageSize int) *SalePageProvider {
+	return &SalePageProvider{
+		SaleHistoryProvider: NewSaleHistoryProvider(source),
+		PageSize:            pageSize,
+	}
+}
+
+func (p *SalePageProvider) GetSaleHistoryPage(q *saletrack.SaleQuery, cursor string) (*saletrack.SalePage, error) {
+	var skip int
+	if cursor != "" {
+		var err error
+		skip, err = strconv.Atoi(cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+	sales, err := p.GetSaleHistories(q)
+	if err != nil {
+		return nil, err
+	}
+	page := &saletrack.SalePage{
+		Sales: []*saletrack.Sale{},
+	}
+	if skip < len(sales) {
+		end := skip + p.PageSize
+		if end > len(sales) {
+			end = len(sales)
+		}
+		page.Sales = sales[skip:end]
+		page.NextCursor = strconv.Itoa(end)
+	}
+	return page, nil
+}
+
+var (
+	_ services.BlockchainClient    = (*BlockchainClient)(nil)
+	_ saletrack.SaleStreamProvider = (*SaleHistoryProvider)(nil)
+	_ saletrack.SalePageProvider   = (*SalePageProvider)(nil)
+)
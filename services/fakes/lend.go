@@ -0,0 +1,41 @@
+package fakes
+
+import (
+	"github.com/czConstant/constant-nftylend-api/blobstore"
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/services"
+	"github.com/jinzhu/gorm"
+)
+
+// NewNftLend wires a service with the real daos over db and the given fake
+// clients, media is kept in memory
+func NewNftLend(db *gorm.DB, bcs services.BlockchainClient, shr services.SaleHistoryRegistry) *services.NftLend {
+	return services.NewNftLend(
+		daos.NewConn(db),
+		bcs,
+		shr,
+		blobstore.NewMemoryStore(),
+		&daos.Currency{},
+		&daos.Collection{},
+		&daos.CollectionSubmitted{},
+		&daos.Asset{},
+		&daos.AssetTransaction{},
+		&daos.Loan{},
+		&daos.LoanOffer{},
+		&daos.LoanTransaction{},
+		&daos.Instruction{},
+		&daos.SearchIndex{},
+		&daos.User{},
+		&daos.UserSession{},
+		&daos.CollectionSubmittedComment{},
+		&daos.ApiKey{},
+		&daos.UserRole{},
+		&daos.AuditLog{},
+		&daos.Partner{},
+		&daos.PartnerUsage{},
+		&daos.AssetCrawl{},
+		&daos.CollectionTrait{},
+		&daos.CollectionStat{},
+		&daos.UserNonce{},
+	)
+}
@@ -4,30 +4,36 @@ import (
 	"context"
 	"encoding/json"
 	"math/big"
-	"strconv"
-	"strings"
 	"time"
 
-	"github.com/czConstant/constant-nftylend-api/daos"
 	"github.com/czConstant/constant-nftylend-api/errs"
 	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/logger"
 	"github.com/czConstant/constant-nftylend-api/models"
 	"github.com/czConstant/constant-nftylend-api/types/numeric"
 	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
 )
 
 func (s *NftLend) LendNftLendUpdateBlock(ctx context.Context, block uint64) error {
-	err := s.bcs.Solana.NftLendUpdateBlock(block)
+	err := s.bcs.NftLendUpdateBlock(block)
 	if err != nil {
 		return errs.NewError(err)
 	}
 	return nil
 }
 
+// ProcessSolanaInstruction applies an instruction in two phases, the off chain
+// reads are prefetched first so the transaction holding the row locks only
+// waits on the database
 func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) error {
-	var loadAssetTransactionForId uint
-	err := daos.WithTransaction(
-		daos.GetDBMainCtx(ctx),
+	prefetch, err := s.prefetchSolanaInstruction(ctx, insId)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	var aggregateAssetID uint
+	err = s.conn.WithTransaction(
+		ctx,
 		func(tx *gorm.DB) error {
 			ins, err := s.id.FirstByID(
 				tx,
@@ -86,79 +92,13 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 						return errs.NewError(err)
 					}
 					if asset == nil {
-						// parse info and new collection
-						meta, err := s.bcs.Solana.GetMetadata(req.NftCollateralContract)
-						if err != nil {
-							return errs.NewError(err)
-						}
-						metaInfo, err := s.bcs.Solana.GetMetadataInfo(meta.Data.Uri)
-						if err != nil {
-							return errs.NewError(err)
-						}
-						collection, tokenId, err := s.getCollectionVerified(
-							tx,
-							req.NftCollateralContract,
-							meta,
-							metaInfo,
-						)
-						if err != nil {
-							return errs.NewError(err)
-						}
-						if collection == nil {
-							// return errs.NewError(errs.ErrBadRequest)
-
-							collectionName := metaInfo.Collection.Name
-							if collectionName == "" {
-								names := strings.Split(metaInfo.Name, "#")
-								if len(names) >= 2 {
-									collectionName = strings.TrimSpace(names[0])
-								}
-							}
-							if collectionName == "" {
-								return errs.NewError(errs.ErrBadRequest)
-							}
-							collection, err = s.cld.First(
-								tx,
-								map[string][]interface{}{
-									"name = ?": []interface{}{collectionName},
-								},
-								map[string][]interface{}{},
-								[]string{},
-							)
-							if err != nil {
-								return errs.NewError(err)
-							}
-							if collection == nil {
-								collection = &models.Collection{
-									Network:     models.ChainSOL,
-									SeoURL:      helpers.MakeSeoURL(collectionName),
-									Name:        collectionName,
-									Description: metaInfo.Description,
-									Enabled:     true,
-								}
-								err = s.cld.Create(
-									tx,
-									collection,
-								)
-								if err != nil {
-									return errs.NewError(err)
-								}
-							}
-						}
-						var sellerFeeBasisPoints int64
-						switch metaInfo.SellerFeeBasisPoints.(type) {
-						case string:
-							{
-								sellerFeeBasisPoints, _ = strconv.ParseInt(metaInfo.SellerFeeBasisPoints.(string), 10, 64)
-							}
-						case float64:
-							{
-								sellerFeeBasisPoints = int64(metaInfo.SellerFeeBasisPoints.(float64))
-							}
+						// new collection from the prefetched info, the asset is
+						// created with placeholder metadata when its json could not
+						// be fetched
+						if prefetch == nil || prefetch.MintAddress != req.NftCollateralContract {
+							return errs.NewError(errs.ErrBadRequest)
 						}
-						sellerFeeRate, _ := models.ConvertWeiToBigFloat(big.NewInt(sellerFeeBasisPoints), 4).Float64()
-						attributes, _ := json.Marshal(metaInfo.Attributes)
-						metaJson, err := json.Marshal(metaInfo)
+						collection, tokenId, err := s.getAssetCollection(tx, prefetch)
 						if err != nil {
 							return errs.NewError(err)
 						}
@@ -167,18 +107,24 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 							SeoURL:                req.NftCollateralContract,
 							ContractAddress:       req.NftCollateralContract,
 							CollectionID:          collection.ID,
-							Symbol:                metaInfo.Symbol,
-							Name:                  metaInfo.Name,
-							TokenURL:              metaInfo.Image,
-							ExternalUrl:           metaInfo.ExternalUrl,
-							SellerFeeRate:         sellerFeeRate,
-							Attributes:            string(attributes),
-							MetaJson:              string(metaJson),
-							MetaJsonUrl:           meta.Data.Uri,
+							MetaJsonUrl:           prefetch.Meta.Data.Uri,
 							OriginNetwork:         collection.OriginNetwork,
 							OriginContractAddress: collection.OriginContractAddress,
 							OriginTokenID:         tokenId,
 						}
+						if prefetch.MetaErr != nil {
+							asset.Name = req.NftCollateralContract
+							asset.MetaPlaceholder = true
+							asset.MetaAttempts = 1
+							asset.MetaError = prefetch.MetaErr.Error()
+							asset.MetaNextRefreshAt = helpers.TimeNowAdd(assetMetadataRetryDelay(asset.MetaAttempts))
+						} else {
+							err = setAssetMetadata(asset, prefetch.Meta, prefetch.MetaInfo)
+							if err != nil {
+								return errs.NewError(err)
+							}
+							asset.MetaPlaceholder = isPlaceholderCollection(collection)
+						}
 						err = s.ad.Create(
 							tx,
 							asset,
@@ -186,6 +132,14 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 						if err != nil {
 							return errs.NewError(err)
 						}
+						err = s.indexSearchAsset(tx, asset)
+						if err != nil {
+							return errs.NewError(err)
+						}
+						err = s.markCollectionRarityStale(tx, asset.CollectionID)
+						if err != nil {
+							return errs.NewError(err)
+						}
 					}
 					principalAmount := models.ConvertWeiToBigFloat(big.NewInt(int64(req.LoanPrincipalAmount)), currency.Decimals)
 					interestRate, _ := models.ConvertWeiToBigFloat(big.NewInt(int64(req.InterestRate)), 4).Float64()
@@ -203,6 +157,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 						AssetID:          asset.ID,
 						Status:           models.LoanStatusNew,
 						InitTxHash:       ins.TransactionHash,
+						AssetRarityScore: asset.RarityScore,
 					}
 					err = s.ld.Create(
 						tx,
@@ -211,6 +166,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					aggregateAssetID = loan.AssetID
 					err = s.ltd.Create(
 						tx,
 						&models.LoanTransaction{
@@ -229,7 +185,14 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
-					loadAssetTransactionForId = asset.ID
+					err = s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
+					err = s.enqueueAssetCrawls(tx, asset, true)
+					if err != nil {
+						return errs.NewError(err)
+					}
 				}
 			case "MakeOffer":
 				{
@@ -383,6 +346,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					aggregateAssetID = loan.AssetID
 					for _, otherOffer := range loan.Offers {
 						if otherOffer.ID != offer.ID {
 							if otherOffer.Status == models.LoanOfferStatusNew {
@@ -416,6 +380,10 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					err = s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
 				}
 			case "CancelLoan":
 				{
@@ -455,6 +423,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					aggregateAssetID = loan.AssetID
 					for _, otherOffer := range loan.Offers {
 						if otherOffer.Status == models.LoanOfferStatusNew {
 							otherOffer.Status = models.LoanOfferStatusRejected
@@ -485,6 +454,10 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					err = s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
 				}
 			case "CancelOffer":
 				{
@@ -574,6 +547,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					aggregateAssetID = loan.AssetID
 					offer, err := s.lod.First(
 						tx,
 						map[string][]interface{}{
@@ -617,6 +591,10 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					err = s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
 				}
 			case "LiquidateLoan":
 				{
@@ -655,6 +633,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					aggregateAssetID = loan.AssetID
 					offer, err := s.lod.First(
 						tx,
 						map[string][]interface{}{
@@ -696,6 +675,10 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					err = s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
 				}
 			case "CloseOffer":
 				{
@@ -802,6 +785,7 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					aggregateAssetID = loan.AssetID
 					for _, otherOffer := range loan.Offers {
 						if otherOffer.ID != offer.ID {
 							if otherOffer.Status == models.LoanOfferStatusNew {
@@ -835,6 +819,10 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 					if err != nil {
 						return errs.NewError(err)
 					}
+					err = s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
 				}
 			default:
 				{
@@ -855,8 +843,18 @@ func (s *NftLend) ProcessSolanaInstruction(ctx context.Context, insId uint) erro
 	if err != nil {
 		return errs.NewError(err)
 	}
-	if loadAssetTransactionForId > 0 {
-		s.updateAssetTransactions(ctx, loadAssetTransactionForId)
+	if aggregateAssetID > 0 {
+		// the instruction is done, a failed refresh is caught up by the
+		// aggregates job
+		err = s.refreshAssetCollectionAggregates(ctx, aggregateAssetID)
+		if err != nil {
+			logger.Error(
+				"collection_aggregates",
+				"refresh aggregates failed",
+				zap.Uint("asset_id", aggregateAssetID),
+				zap.Error(err),
+			)
+		}
 	}
 	return nil
 }
@@ -868,8 +866,8 @@ func (s *NftLend) InternalHookSolanaInstruction(ctx context.Context, blockNumber
 	}
 	var isProcess bool
 	var ins *models.Instruction
-	err = daos.WithTransaction(
-		daos.GetDBMainCtx(ctx),
+	err = s.conn.WithTransaction(
+		ctx,
 		func(tx *gorm.DB) error {
 			ins, err = s.id.First(
 				tx,
@@ -924,70 +922,28 @@ func (s *NftLend) InternalHookSolanaInstruction(ctx context.Context, blockNumber
 	return nil
 }
 
+// UpdateAssetInfo fetches the metadata of the asset again bypassing the cache
 func (s *NftLend) UpdateAssetInfo(ctx context.Context, address string) error {
-	err := daos.WithTransaction(
-		daos.GetDBMainCtx(ctx),
-		func(tx *gorm.DB) error {
-			asset, err := s.ad.First(
-				tx,
-				map[string][]interface{}{
-					"contract_address =?": []interface{}{address},
-				},
-				map[string][]interface{}{},
-				[]string{},
-			)
-			if err != nil {
-				return errs.NewError(err)
-			}
-			if asset == nil {
-				return errs.NewError(errs.ErrBadRequest)
-			}
-			// parse info and new collection
-			meta, err := s.bcs.Solana.GetMetadata(asset.ContractAddress)
-			if err != nil {
-				return errs.NewError(err)
-			}
-			metaInfo, err := s.bcs.Solana.GetMetadataInfo(meta.Data.Uri)
-			if err != nil {
-				return errs.NewError(err)
-			}
-			var sellerFeeBasisPoints int64
-			switch metaInfo.SellerFeeBasisPoints.(type) {
-			case string:
-				{
-					sellerFeeBasisPoints, _ = strconv.ParseInt(metaInfo.SellerFeeBasisPoints.(string), 10, 64)
-				}
-			case float64:
-				{
-					sellerFeeBasisPoints = int64(metaInfo.SellerFeeBasisPoints.(float64))
-				}
-			}
-			metaJson, err := json.Marshal(metaInfo)
-			if err != nil {
-				return errs.NewError(err)
-			}
-			sellerFeeRate, _ := models.ConvertWeiToBigFloat(big.NewInt(sellerFeeBasisPoints), 4).Float64()
-			attributes, _ := json.Marshal(metaInfo.Attributes)
-			asset.Symbol = metaInfo.Symbol
-			asset.Name = metaInfo.Name
-			asset.TokenURL = metaInfo.Image
-			asset.ExternalUrl = metaInfo.ExternalUrl
-			asset.SellerFeeRate = sellerFeeRate
-			asset.Attributes = string(attributes)
-			asset.MetaJson = string(metaJson)
-			asset.MetaJsonUrl = meta.Data.Uri
-			err = s.ad.Save(
-				tx,
-				asset,
-			)
-			if err != nil {
-				return errs.NewError(err)
-			}
-			return nil
+	asset, err := s.ad.First(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"contract_address =?": []interface{}{address},
 		},
+		map[string][]interface{}{},
+		[]string{},
 	)
 	if err != nil {
 		return errs.NewError(err)
 	}
+	if asset == nil {
+		return errs.NewError(errs.ErrBadRequest)
+	}
+	fetched, err := s.refreshAssetMetadata(ctx, asset.ID, true)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if !fetched {
+		return errs.NewError(errs.ErrMetadataUnavailable)
+	}
 	return nil
 }
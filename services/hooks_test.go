@@ -0,0 +1,695 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/databases/memdb"
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/services"
+	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
+	"github.com/czConstant/constant-nftylend-api/services/fakes"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	solAddress  = "So11111111111111111111111111111111111111112"
+	assetMint   = "AssetMint1111111111111111111111111111111111"
+	borrower    = "Borrower11111111111111111111111111111111111"
+	lenderOne   = "LenderOne111111111111111111111111111111111"
+	lenderTwo   = "LenderTwo111111111111111111111111111111111"
+	loanAccount = "LoanInfo1111111111111111111111111111111111"
+	offerOne    = "OfferOne11111111111111111111111111111111111"
+	offerTwo    = "OfferTwo11111111111111111111111111111111111"
+)
+
+var genesis = time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)
+
+func TestMain(m *testing.M) {
+	logger.NewLogger("nft-api-services-test", "", false)
+	code := m.Run()
+	logger.Sync()
+	os.Exit(code)
+}
+
+// lendTest is a lending service over an embedded database seeded with the
+// sol currency and one asset of a collection, hooks run one block apart
+type lendTest struct {
+	t          *testing.T
+	db         *gorm.DB
+	bcs        *fakes.BlockchainClient
+	shr        *saletrack.Registry
+	s          *services.NftLend
+	collection *models.Collection
+	asset      *models.Asset
+	block      uint64
+}
+
+func newLendTest(t *testing.T) *lendTest {
+	t.Helper()
+	db, err := memdb.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+	})
+	lt := &lendTest{
+		t:   t,
+		db:  db.DB,
+		bcs: fakes.NewBlockchainClient(),
+		shr: saletrack.NewRegistry(),
+	}
+	lt.s = fakes.NewNftLend(lt.db, lt.bcs, lt.shr)
+	lt.create((&daos.Currency{}).Create, &models.Currency{
+		Network:         models.ChainSOL,
+		ContractAddress: solAddress,
+		Decimals:        9,
+		Symbol:          "SOL",
+		Name:            "Solana",
+		Enabled:         1,
+	})
+	lt.collection = &models.Collection{
+		Network: models.ChainSOL,
+		SeoURL:  "degen-ape-academy",
+		Name:    "Degen Ape Academy",
+		Enabled: true,
+	}
+	lt.create((&daos.Collection{}).Create, lt.collection)
+	lt.asset = &models.Asset{
+		Network:         models.ChainSOL,
+		CollectionID:    lt.collection.ID,
+		SeoURL:          assetMint,
+		ContractAddress: assetMint,
+		Name:            "Degen Ape #1024",
+	}
+	lt.create((&daos.Asset{}).Create, lt.asset)
+	return lt
+}
+
+func (lt *lendTest) create(create func(tx *gorm.DB, m interface{}) error, m interface{}) {
+	lt.t.Helper()
+	err := create(lt.db, m)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+}
+
+func (lt *lendTest) blockTime(block uint64) time.Time {
+	return genesis.Add(time.Duration(block) * time.Hour)
+}
+
+// hook sends the instruction in the next block, the transaction hash is the
+// instruction and the block number
+func (lt *lendTest) hook(instruction string, data map[string]interface{}) (string, error) {
+	lt.block++
+	txHash := fmt.Sprintf("%s%d", instruction, lt.block)
+	err := lt.s.InternalHookSolanaInstruction(
+		context.Background(),
+		lt.block,
+		uint64(lt.blockTime(lt.block).Unix()),
+		txHash,
+		0,
+		0,
+		"nftylend",
+		instruction,
+		data,
+	)
+	return txHash, err
+}
+
+func (lt *lendTest) mustHook(instruction string, data map[string]interface{}) string {
+	lt.t.Helper()
+	txHash, err := lt.hook(instruction, data)
+	if err != nil {
+		lt.t.Fatalf("%s: %v", instruction, err)
+	}
+	return txHash
+}
+
+// rejectHook expects a bad request that leaves the instruction new
+func (lt *lendTest) rejectHook(instruction string, data map[string]interface{}) {
+	lt.t.Helper()
+	txHash, err := lt.hook(instruction, data)
+	if e, ok := err.(*errs.Error); !ok || e.Code != errs.ErrBadRequest.Code {
+		lt.t.Fatalf("%s: err = %v, want bad request", instruction, err)
+	}
+	ins := lt.instruction(txHash)
+	if ins == nil || ins.Status != "new" {
+		lt.t.Fatalf("%s: instruction = %+v, want new", instruction, ins)
+	}
+}
+
+func (lt *lendTest) instruction(txHash string) *models.Instruction {
+	lt.t.Helper()
+	ins, err := (&daos.Instruction{}).First(lt.db, map[string][]interface{}{"transaction_hash = ?": []interface{}{txHash}}, map[string][]interface{}{}, []string{})
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	return ins
+}
+
+func (lt *lendTest) loan(address string) *models.Loan {
+	lt.t.Helper()
+	loan, err := (&daos.Loan{}).First(lt.db, map[string][]interface{}{"data_loan_address = ?": []interface{}{address}}, map[string][]interface{}{}, []string{})
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	if loan == nil {
+		lt.t.Fatalf("no loan %s", address)
+	}
+	return loan
+}
+
+func (lt *lendTest) offer(address string) *models.LoanOffer {
+	lt.t.Helper()
+	offer, err := (&daos.LoanOffer{}).First(lt.db, map[string][]interface{}{"data_offer_address = ?": []interface{}{address}}, map[string][]interface{}{}, []string{})
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	if offer == nil {
+		lt.t.Fatalf("no offer %s", address)
+	}
+	return offer
+}
+
+func (lt *lendTest) count(find func() (int, error)) int {
+	lt.t.Helper()
+	n, err := find()
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	return n
+}
+
+func (lt *lendTest) loans() int {
+	return lt.count(func() (int, error) {
+		ms, err := (&daos.Loan{}).Find(lt.db, map[string][]interface{}{}, map[string][]interface{}{}, []string{}, 0, -1)
+		return len(ms), err
+	})
+}
+
+func (lt *lendTest) offers() int {
+	return lt.count(func() (int, error) {
+		ms, err := (&daos.LoanOffer{}).Find(lt.db, map[string][]interface{}{}, map[string][]interface{}{}, []string{}, 0, -1)
+		return len(ms), err
+	})
+}
+
+// loanTransactions returns the history of the loan in order
+func (lt *lendTest) loanTransactions(loanID uint) []*models.LoanTransaction {
+	lt.t.Helper()
+	txs, err := (&daos.LoanTransaction{}).Find(lt.db, map[string][]interface{}{"loan_id = ?": []interface{}{loanID}}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	return txs
+}
+
+// lastLoanTransaction checks the type and hash of the newest loan transaction
+func (lt *lendTest) lastLoanTransaction(loanID uint, typ models.LoanTransactionType, txHash string) *models.LoanTransaction {
+	lt.t.Helper()
+	txs := lt.loanTransactions(loanID)
+	if len(txs) == 0 {
+		lt.t.Fatalf("loan %d has no transactions", loanID)
+	}
+	m := txs[len(txs)-1]
+	if m.Type != typ || m.TxHash != txHash || m.Network != models.ChainSOL {
+		lt.t.Fatalf("loan transaction = %+v, want %s in %s", m, typ, txHash)
+	}
+	return m
+}
+
+func (lt *lendTest) listingCount() uint {
+	lt.t.Helper()
+	collection, err := (&daos.Collection{}).FirstByID(lt.db, lt.collection.ID, map[string][]interface{}{}, false)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	return collection.ListingCount
+}
+
+func (lt *lendTest) at(block uint64) *time.Time {
+	t := lt.blockTime(block)
+	return &t
+}
+
+func initLoanData(mint string, loanAddress string) map[string]interface{} {
+	return map[string]interface{}{
+		"loan_principal_amount":    5000000000,
+		"loan_duration":            2592000,
+		"interest_rate":            1200,
+		"nft_collateral_contract":  mint,
+		"loan_currency":            solAddress,
+		"borrower_account":         borrower,
+		"temp_nft_account":         "TempNft" + mint,
+		"token_to_receive_account": "Receive" + borrower,
+		"loan_info_account":        loanAddress,
+	}
+}
+
+func makeOfferData(offerAddress string, lender string, amount uint64, duration uint64, interestRate uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"loan_id":               loanAccount,
+		"loan_principal_amount": amount,
+		"loan_duration":         duration,
+		"interest_rate":         interestRate,
+		"loan_currency":         solAddress,
+		"lender_account":        lender,
+		"temp_token_account":    "TempToken" + lender,
+		"offer_info_account":    offerAddress,
+	}
+}
+
+func acceptOfferData(offerAddress string) map[string]interface{} {
+	return map[string]interface{}{
+		"loan_id":  loanAccount,
+		"offer_id": offerAddress,
+	}
+}
+
+func offerData(offerAddress string) map[string]interface{} {
+	return map[string]interface{}{
+		"offer_id": offerAddress,
+	}
+}
+
+func payLoanData(offerAddress string, amount uint64) map[string]interface{} {
+	return map[string]interface{}{
+		"loan_id":    loanAccount,
+		"offer_id":   offerAddress,
+		"pay_amount": amount,
+	}
+}
+
+// funded lists the loan, makes two offers and accepts the second
+func (lt *lendTest) funded() {
+	lt.t.Helper()
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	lt.mustHook("MakeOffer", makeOfferData(offerTwo, lenderTwo, 4500000000, 1296000, 1800))
+	lt.mustHook("AcceptOffer", acceptOfferData(offerTwo))
+}
+
+func TestInitLoan(t *testing.T) {
+	lt := newLendTest(t)
+	txHash := lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	loan := lt.loan(loanAccount)
+	if loan.Status != models.LoanStatusNew || loan.Network != models.ChainSOL || loan.Owner != borrower || loan.AssetID != lt.asset.ID || loan.CurrencyID != 1 || loan.InitTxHash != txHash || loan.DataAssetAddress != "TempNft"+assetMint {
+		t.Fatalf("loan = %+v", loan)
+	}
+	if loan.PrincipalAmount.Text('f', -1) != "5" || loan.InterestRate != 0.12 || loan.Duration != 2592000 {
+		t.Fatalf("loan terms = %s %v %d", loan.PrincipalAmount.Text('f', -1), loan.InterestRate, loan.Duration)
+	}
+	if !loan.StartedAt.Equal(*lt.at(1)) || !loan.ExpiredAt.Equal(lt.at(1).Add(30*24*time.Hour)) {
+		t.Fatalf("loan period = %v - %v", loan.StartedAt, loan.ExpiredAt)
+	}
+	m := lt.lastLoanTransaction(loan.ID, models.LoanTransactionTypeListed, txHash)
+	if m.Borrower != borrower || m.Lender != "" || m.PrincipalAmount.Text('f', -1) != "5" {
+		t.Fatalf("loan transaction = %+v", m)
+	}
+	if ins := lt.instruction(txHash); ins.Status != "done" {
+		t.Fatalf("instruction status = %s, want done", ins.Status)
+	}
+	if n := lt.listingCount(); n != 1 {
+		t.Fatalf("listing count = %d, want 1", n)
+	}
+	// the same loan account is listed once
+	lt.rejectHook("InitLoan", initLoanData(assetMint, loanAccount))
+	if n := lt.loans(); n != 1 {
+		t.Fatalf("loans = %d, want 1", n)
+	}
+	// a replayed instruction is not applied again
+	err := lt.s.InternalHookSolanaInstruction(context.Background(), 1, uint64(lt.blockTime(1).Unix()), txHash, 0, 0, "nftylend", "InitLoan", initLoanData(assetMint, "Other"+loanAccount))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := lt.loans(); n != 1 {
+		t.Fatalf("loans after the replay = %d, want 1", n)
+	}
+	// only enabled lend currencies are accepted
+	data := initLoanData(assetMint, "Usdc"+loanAccount)
+	data["loan_currency"] = "UnknownMint"
+	lt.rejectHook("InitLoan", data)
+}
+
+func TestInitLoanNewAsset(t *testing.T) {
+	lt := newLendTest(t)
+	mint := "NewMint11111111111111111111111111111111111"
+	meta := &solana.MetadataResp{}
+	meta.Data.Uri = "https://arweave.net/new-mint.json"
+	info := &solana.MetadataInfoResp{
+		Name:   "Degen Ape #7",
+		Symbol: "DAPE",
+		Image:  "https://arweave.net/new-mint.png",
+	}
+	info.Collection.Name = lt.collection.Name
+	lt.bcs.Metadatas[mint] = meta
+	lt.bcs.MetadataInfo[meta.Data.Uri] = info
+	lt.mustHook("InitLoan", initLoanData(mint, loanAccount))
+	asset, err := (&daos.Asset{}).First(lt.db, map[string][]interface{}{"contract_address = ?": []interface{}{mint}}, map[string][]interface{}{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if asset == nil || asset.CollectionID != lt.collection.ID || asset.Name != "Degen Ape #7" || asset.Symbol != "DAPE" || asset.TokenURL != info.Image || asset.MetaJsonUrl != meta.Data.Uri || asset.MetaPlaceholder {
+		t.Fatalf("asset = %+v", asset)
+	}
+	if loan := lt.loan(loanAccount); loan.AssetID != asset.ID {
+		t.Fatalf("loan asset = %d, want %d", loan.AssetID, asset.ID)
+	}
+}
+
+func TestInitLoanNewAssetWithoutMetadata(t *testing.T) {
+	lt := newLendTest(t)
+	mint := "NoJsonMint111111111111111111111111111111111"
+	meta := &solana.MetadataResp{}
+	meta.Data.Uri = "https://arweave.net/missing.json"
+	lt.bcs.Metadatas[mint] = meta
+	lt.mustHook("InitLoan", initLoanData(mint, loanAccount))
+	asset, err := (&daos.Asset{}).First(lt.db, map[string][]interface{}{"contract_address = ?": []interface{}{mint}}, map[string][]interface{}{}, []string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the loan is listed on a placeholder the refresh job fills in later
+	if asset == nil || asset.Name != mint || !asset.MetaPlaceholder || asset.MetaAttempts != 1 || asset.MetaError == "" || asset.MetaNextRefreshAt == nil {
+		t.Fatalf("asset = %+v", asset)
+	}
+	if loan := lt.loan(loanAccount); loan.AssetID != asset.ID || loan.Status != models.LoanStatusNew {
+		t.Fatalf("loan = %+v", loan)
+	}
+	// without the on chain metadata the prefetch fails and the indexer retries
+	txHash, err := lt.hook("InitLoan", initLoanData("UnknownMint", "Other"+loanAccount))
+	if err == nil {
+		t.Fatal("loan of an unknown mint listed")
+	}
+	if ins := lt.instruction(txHash); ins.Status != "new" || lt.loans() != 1 {
+		t.Fatalf("instruction status = %s, loans = %d", ins.Status, lt.loans())
+	}
+}
+
+func TestMakeOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	txHash := lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 1296000, 1500))
+	loan := lt.loan(loanAccount)
+	offer := lt.offer(offerOne)
+	if offer.Status != models.LoanOfferStatusNew || offer.LoanID != loan.ID || offer.Lender != lenderOne || offer.MakeTxHash != txHash || offer.DataCurrencyAddress != "TempToken"+lenderOne {
+		t.Fatalf("offer = %+v", offer)
+	}
+	if offer.PrincipalAmount.Text('f', -1) != "4" || offer.InterestRate != 0.15 || offer.Duration != 1296000 {
+		t.Fatalf("offer terms = %s %v %d", offer.PrincipalAmount.Text('f', -1), offer.InterestRate, offer.Duration)
+	}
+	// an offer does not change the loan or add to its history
+	if loan.Status != models.LoanStatusNew || loan.Lender != "" || len(lt.loanTransactions(loan.ID)) != 1 {
+		t.Fatalf("loan = %+v", loan)
+	}
+	lt.rejectHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 1296000, 1500))
+	data := makeOfferData(offerTwo, lenderTwo, 4000000000, 1296000, 1500)
+	data["loan_id"] = "Unknown" + loanAccount
+	lt.rejectHook("MakeOffer", data)
+	if n := lt.offers(); n != 1 {
+		t.Fatalf("offers = %d, want 1", n)
+	}
+}
+
+func TestMakeOfferOnFundedLoan(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	// the chain accepted the offer, it is recorded as rejected
+	lt.mustHook("MakeOffer", makeOfferData("Late"+offerOne, lenderOne, 4000000000, 1296000, 1500))
+	if offer := lt.offer("Late" + offerOne); offer.Status != models.LoanOfferStatusRejected {
+		t.Fatalf("offer status = %s, want rejected", offer.Status)
+	}
+}
+
+func TestAcceptOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	lt.mustHook("MakeOffer", makeOfferData(offerTwo, lenderTwo, 4500000000, 1296000, 1800))
+	txHash := lt.mustHook("AcceptOffer", acceptOfferData(offerTwo))
+	offer := lt.offer(offerTwo)
+	if offer.Status != models.LoanOfferStatusApproved || offer.AcceptTxHash != txHash {
+		t.Fatalf("offer = %+v", offer)
+	}
+	if !offer.StartedAt.Equal(*lt.at(4)) || !offer.ExpiredAt.Equal(lt.at(4).Add(15*24*time.Hour)) {
+		t.Fatalf("offer period = %v - %v", offer.StartedAt, offer.ExpiredAt)
+	}
+	loan := lt.loan(loanAccount)
+	if loan.Status != models.LoanStatusCreated || loan.Lender != lenderTwo || loan.OfferDuration != 1296000 || loan.OfferInterestRate != 0.18 || loan.OfferPrincipalAmount.Text('f', -1) != "4.5" {
+		t.Fatalf("loan = %+v", loan)
+	}
+	if !loan.OfferStartedAt.Equal(*offer.StartedAt) || !loan.OfferExpiredAt.Equal(*offer.ExpiredAt) {
+		t.Fatalf("loan offer period = %v - %v", loan.OfferStartedAt, loan.OfferExpiredAt)
+	}
+	// the listing terms stay as the borrower set them
+	if loan.PrincipalAmount.Text('f', -1) != "5" || loan.Duration != 2592000 {
+		t.Fatalf("loan terms = %s %d", loan.PrincipalAmount.Text('f', -1), loan.Duration)
+	}
+	m := lt.lastLoanTransaction(loan.ID, models.LoanTransactionTypeOffered, txHash)
+	if m.Lender != lenderTwo || m.Borrower != borrower || m.PrincipalAmount.Text('f', -1) != "4.5" || !m.StartedAt.Equal(*offer.StartedAt) {
+		t.Fatalf("loan transaction = %+v", m)
+	}
+	if n := lt.listingCount(); n != 0 {
+		t.Fatalf("listing count = %d, want 0", n)
+	}
+	// the loan is funded once
+	lt.rejectHook("AcceptOffer", acceptOfferData(offerOne))
+	if offer := lt.offer(offerOne); offer.Status == models.LoanOfferStatusApproved {
+		t.Fatalf("offer one = %+v", offer)
+	}
+}
+
+func TestAcceptOfferRequiresNewOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	lt.mustHook("CancelOffer", offerData(offerOne))
+	lt.rejectHook("AcceptOffer", acceptOfferData(offerOne))
+	lt.rejectHook("AcceptOffer", acceptOfferData("Unknown"+offerOne))
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusNew || loan.Lender != "" {
+		t.Fatalf("loan = %+v", loan)
+	}
+}
+
+func TestCancelLoan(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	lt.mustHook("MakeOffer", makeOfferData(offerTwo, lenderTwo, 4500000000, 1296000, 1800))
+	lt.mustHook("CancelOffer", offerData(offerTwo))
+	txHash := lt.mustHook("CancelLoan", map[string]interface{}{"loan_id": loanAccount})
+	loan := lt.loan(loanAccount)
+	if loan.Status != models.LoanStatusCancelled || loan.CancelTxHash != txHash || loan.FinishedAt == nil || !loan.FinishedAt.Equal(*lt.at(5)) {
+		t.Fatalf("loan = %+v", loan)
+	}
+	// the open offer is rejected, the cancelled one is left alone
+	if offer := lt.offer(offerOne); offer.Status != models.LoanOfferStatusRejected {
+		t.Fatalf("offer one status = %s, want rejected", offer.Status)
+	}
+	if offer := lt.offer(offerTwo); offer.Status != models.LoanOfferStatusCancelled {
+		t.Fatalf("offer two status = %s, want cancelled", offer.Status)
+	}
+	m := lt.lastLoanTransaction(loan.ID, models.LoanTransactionTypeCancelled, txHash)
+	if m.Borrower != borrower || m.Lender != "" {
+		t.Fatalf("loan transaction = %+v", m)
+	}
+	if n := lt.listingCount(); n != 0 {
+		t.Fatalf("listing count = %d, want 0", n)
+	}
+	lt.rejectHook("CancelLoan", map[string]interface{}{"loan_id": loanAccount})
+	lt.rejectHook("CancelLoan", map[string]interface{}{"loan_id": "Unknown" + loanAccount})
+}
+
+func TestCancelFundedLoan(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	lt.rejectHook("CancelLoan", map[string]interface{}{"loan_id": loanAccount})
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusCreated {
+		t.Fatalf("loan status = %s, want created", loan.Status)
+	}
+}
+
+func TestCancelOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	txHash := lt.mustHook("CancelOffer", offerData(offerOne))
+	offer := lt.offer(offerOne)
+	if offer.Status != models.LoanOfferStatusCancelled || offer.CancelTxHash != txHash || offer.FinishedAt == nil || !offer.FinishedAt.Equal(*lt.at(3)) {
+		t.Fatalf("offer = %+v", offer)
+	}
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusNew || len(lt.loanTransactions(loan.ID)) != 1 {
+		t.Fatalf("loan = %+v", loan)
+	}
+	lt.rejectHook("CancelOffer", offerData(offerOne))
+	lt.rejectHook("CancelOffer", offerData("Unknown"+offerOne))
+}
+
+func TestCancelRejectedOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	lt.mustHook("CancelLoan", map[string]interface{}{"loan_id": loanAccount})
+	// the lender withdraws the tokens of a rejected offer
+	lt.mustHook("CancelOffer", offerData(offerOne))
+	if offer := lt.offer(offerOne); offer.Status != models.LoanOfferStatusCancelled {
+		t.Fatalf("offer status = %s, want cancelled", offer.Status)
+	}
+}
+
+func TestCancelApprovedOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	lt.rejectHook("CancelOffer", offerData(offerTwo))
+	if offer := lt.offer(offerTwo); offer.Status != models.LoanOfferStatusApproved {
+		t.Fatalf("offer status = %s, want approved", offer.Status)
+	}
+}
+
+func TestPayLoan(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	txHash := lt.mustHook("PayLoan", payLoanData(offerTwo, 4610958904))
+	loan := lt.loan(loanAccount)
+	if loan.Status != models.LoanStatusDone || loan.PayTxHash != txHash || loan.FeeRate != 0.01 || loan.RepaidAmount.Text('f', -1) != "4.610958904" || !loan.FinishedAt.Equal(*lt.at(5)) {
+		t.Fatalf("loan = %+v", loan)
+	}
+	offer := lt.offer(offerTwo)
+	if offer.Status != models.LoanOfferStatusRepaid || offer.RepaidAmount.Text('f', -1) != "4.610958904" || !offer.RepaidAt.Equal(*lt.at(5)) {
+		t.Fatalf("offer = %+v", offer)
+	}
+	m := lt.lastLoanTransaction(loan.ID, models.LoanTransactionTypeRepaid, txHash)
+	if m.Lender != lenderTwo || m.PrincipalAmount.Text('f', -1) != "4.5" {
+		t.Fatalf("loan transaction = %+v", m)
+	}
+	types := []models.LoanTransactionType{}
+	for _, m := range lt.loanTransactions(loan.ID) {
+		types = append(types, m.Type)
+	}
+	if fmt.Sprint(types) != "[listed offered repaid]" {
+		t.Fatalf("loan history = %v", types)
+	}
+	lt.rejectHook("PayLoan", payLoanData(offerTwo, 4610958904))
+}
+
+func TestPayLoanRequiresFunding(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	lt.rejectHook("PayLoan", payLoanData(offerOne, 4000000000))
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusNew {
+		t.Fatalf("loan status = %s, want new", loan.Status)
+	}
+}
+
+func TestPayLoanUnknownOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	// the loan is saved before the offer is read, the failure rolls it back
+	lt.rejectHook("PayLoan", payLoanData("Unknown"+offerTwo, 4610958904))
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusCreated || loan.PayTxHash != "" {
+		t.Fatalf("loan = %+v", loan)
+	}
+}
+
+func TestLiquidateLoan(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	txHash := lt.mustHook("LiquidateLoan", acceptOfferData(offerTwo))
+	loan := lt.loan(loanAccount)
+	if loan.Status != models.LoanStatusLiquidated || loan.LiquidateTxHash != txHash || !loan.FinishedAt.Equal(*lt.at(5)) {
+		t.Fatalf("loan = %+v", loan)
+	}
+	if offer := lt.offer(offerTwo); offer.Status != models.LoanOfferStatusLiquidated {
+		t.Fatalf("offer status = %s, want liquidated", offer.Status)
+	}
+	m := lt.lastLoanTransaction(loan.ID, models.LoanTransactionTypeLiquidated, txHash)
+	if m.Lender != lenderTwo || m.Borrower != borrower {
+		t.Fatalf("loan transaction = %+v", m)
+	}
+	lt.rejectHook("LiquidateLoan", acceptOfferData(offerTwo))
+	lt.rejectHook("PayLoan", payLoanData(offerTwo, 4610958904))
+}
+
+func TestLiquidateLoanRequiresFunding(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.rejectHook("LiquidateLoan", acceptOfferData(offerOne))
+	lt.rejectHook("LiquidateLoan", map[string]interface{}{"loan_id": "Unknown" + loanAccount})
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusNew {
+		t.Fatalf("loan status = %s, want new", loan.Status)
+	}
+}
+
+func TestCloseOffer(t *testing.T) {
+	lt := newLendTest(t)
+	lt.funded()
+	// only a repaid offer is closed
+	lt.rejectHook("CloseOffer", offerData(offerTwo))
+	lt.mustHook("PayLoan", payLoanData(offerTwo, 4610958904))
+	txHash := lt.mustHook("CloseOffer", offerData(offerTwo))
+	offer := lt.offer(offerTwo)
+	if offer.Status != models.LoanOfferStatusDone || offer.CloseTxHash != txHash || !offer.FinishedAt.Equal(*lt.at(7)) {
+		t.Fatalf("offer = %+v", offer)
+	}
+	if loan := lt.loan(loanAccount); loan.Status != models.LoanStatusDone {
+		t.Fatalf("loan status = %s, want done", loan.Status)
+	}
+	lt.rejectHook("CloseOffer", offerData(offerTwo))
+	lt.rejectHook("CloseOffer", offerData("Unknown"+offerTwo))
+}
+
+func TestOrder(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.mustHook("MakeOffer", makeOfferData(offerOne, lenderOne, 4000000000, 2592000, 1500))
+	txHash := lt.mustHook("Order", map[string]interface{}{
+		"loan_id":            loanAccount,
+		"lender_account":     lenderTwo,
+		"temp_token_account": "TempToken" + lenderTwo,
+		"offer_info_account": offerTwo,
+	})
+	loan := lt.loan(loanAccount)
+	// the order takes the listing terms as they are
+	offer := lt.offer(offerTwo)
+	if offer.Status != models.LoanOfferStatusApproved || offer.LoanID != loan.ID || offer.Lender != lenderTwo || offer.MakeTxHash != txHash || offer.AcceptTxHash != txHash {
+		t.Fatalf("offer = %+v", offer)
+	}
+	if offer.PrincipalAmount.Text('f', -1) != "5" || offer.InterestRate != 0.12 || offer.Duration != 2592000 || !offer.StartedAt.Equal(*lt.at(3)) || !offer.ExpiredAt.Equal(lt.at(3).Add(30*24*time.Hour)) {
+		t.Fatalf("offer terms = %+v", offer)
+	}
+	if loan.Status != models.LoanStatusCreated || loan.Lender != lenderTwo || loan.InitTxHash != txHash || loan.OfferPrincipalAmount.Text('f', -1) != "5" || !loan.OfferStartedAt.Equal(*offer.StartedAt) {
+		t.Fatalf("loan = %+v", loan)
+	}
+	if other := lt.offer(offerOne); other.Status != models.LoanOfferStatusRejected {
+		t.Fatalf("offer one status = %s, want rejected", other.Status)
+	}
+	m := lt.lastLoanTransaction(loan.ID, models.LoanTransactionTypeOffered, txHash)
+	if m.Lender != lenderTwo || m.PrincipalAmount.Text('f', -1) != "5" {
+		t.Fatalf("loan transaction = %+v", m)
+	}
+	if n := lt.listingCount(); n != 0 {
+		t.Fatalf("listing count = %d, want 0", n)
+	}
+	lt.rejectHook("Order", map[string]interface{}{
+		"loan_id":            loanAccount,
+		"lender_account":     lenderOne,
+		"offer_info_account": "Late" + offerTwo,
+	})
+	if n := lt.offers(); n != 2 {
+		t.Fatalf("offers = %d, want 2", n)
+	}
+}
+
+func TestUnknownInstruction(t *testing.T) {
+	lt := newLendTest(t)
+	lt.rejectHook("Unknown", map[string]interface{}{})
+}
@@ -2,68 +2,129 @@ package services
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"math/big"
 	"strings"
-	"time"
+	"sync"
 
-	"github.com/czConstant/blockchain-api/bcclient"
-	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/blobstore"
 	"github.com/czConstant/constant-nftylend-api/daos"
 	"github.com/czConstant/constant-nftylend-api/errs"
-	"github.com/czConstant/constant-nftylend-api/helpers"
 	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/ratelimit"
 	"github.com/czConstant/constant-nftylend-api/services/3rd/saletrack"
-	"github.com/czConstant/constant-nftylend-api/types/numeric"
 	"github.com/jinzhu/gorm"
 )
 
 type NftLend struct {
-	bcs  *bcclient.Client
-	stc  *saletrack.Client
-	cd   *daos.Currency
-	cld  *daos.Collection
-	clsd *daos.CollectionSubmitted
-	ad   *daos.Asset
-	atd  *daos.AssetTransaction
-	ld   *daos.Loan
-	lod  *daos.LoanOffer
-	ltd  *daos.LoanTransaction
-	id   *daos.Instruction
+	conn  daos.Conn
+	bcs   BlockchainClient
+	shr   SaleHistoryRegistry
+	bls   blobstore.Store
+	cd    CurrencyRepository
+	cld   CollectionRepository
+	clsd  CollectionSubmittedRepository
+	ad    AssetRepository
+	atd   AssetTransactionRepository
+	ld    LoanRepository
+	lod   LoanOfferRepository
+	ltd   LoanTransactionRepository
+	id    InstructionRepository
+	sid   SearchIndexRepository
+	ud    UserRepository
+	usd   UserSessionRepository
+	clscd CollectionSubmittedCommentRepository
+	akd   ApiKeyRepository
+	urd   UserRoleRepository
+	ald   AuditLogRepository
+	pd    PartnerRepository
+	pud   PartnerUsageRepository
+	acd   AssetCrawlRepository
+	crl   ratelimit.Store
+	apc   *assetPrefetchCache
+	mls   *mediaLocks
+	ctd   CollectionTraitRepository
+	csd   CollectionStatRepository
+	und   UserNonceRepository
 }
 
 func NewNftLend(
-	bcs *bcclient.Client,
-	stc *saletrack.Client,
-	cd *daos.Currency,
-	cld *daos.Collection,
-	clsd *daos.CollectionSubmitted,
-	ad *daos.Asset,
-	atd *daos.AssetTransaction,
-	ld *daos.Loan,
-	lod *daos.LoanOffer,
-	ltd *daos.LoanTransaction,
-	id *daos.Instruction,
-
+	conn daos.Conn,
+	bcs BlockchainClient,
+	shr SaleHistoryRegistry,
+	bls blobstore.Store,
+	cd CurrencyRepository,
+	cld CollectionRepository,
+	clsd CollectionSubmittedRepository,
+	ad AssetRepository,
+	atd AssetTransactionRepository,
+	ld LoanRepository,
+	lod LoanOfferRepository,
+	ltd LoanTransactionRepository,
+	id InstructionRepository,
+	sid SearchIndexRepository,
+	ud UserRepository,
+	usd UserSessionRepository,
+	clscd CollectionSubmittedCommentRepository,
+	akd ApiKeyRepository,
+	urd UserRoleRepository,
+	ald AuditLogRepository,
+	pd PartnerRepository,
+	pud PartnerUsageRepository,
+	acd AssetCrawlRepository,
+	ctd CollectionTraitRepository,
+	csd CollectionStatRepository,
+	und UserNonceRepository,
 ) *NftLend {
 	s := &NftLend{
-		bcs:  bcs,
-		stc:  stc,
-		cd:   cd,
-		cld:  cld,
-		clsd: clsd,
-		ad:   ad,
-		atd:  atd,
-		ld:   ld,
-		lod:  lod,
-		ltd:  ltd,
-		id:   id,
+		conn:  conn,
+		bcs:   bcs,
+		shr:   shr,
+		bls:   bls,
+		cd:    cd,
+		cld:   cld,
+		clsd:  clsd,
+		ad:    ad,
+		atd:   atd,
+		ld:    ld,
+		lod:   lod,
+		ltd:   ltd,
+		id:    id,
+		sid:   sid,
+		ud:    ud,
+		usd:   usd,
+		clscd: clscd,
+		akd:   akd,
+		urd:   urd,
+		ald:   ald,
+		pd:    pd,
+		pud:   pud,
+		acd:   acd,
+		crl:   ratelimit.NewMemoryStore(),
+		apc:   newAssetPrefetchCache(),
+		mls:   newMediaLocks(),
+		ctd:   ctd,
+		csd:   csd,
+		und:   und,
 	}
-	go stc.StartWssSolsea(s.solseaMsgReceived)
 	return s
 }
 
+// RunSaleStreams runs the stream of every stream provider and returns once ctx
+// is done and the streams are stopped
+func (s *NftLend) RunSaleStreams(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, p := range s.shr.All() {
+		sp, ok := p.(saletrack.SaleStreamProvider)
+		if ok {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				sp.StartSaleStream(ctx, s.saleStreamReceived)
+			}()
+		}
+	}
+	wg.Wait()
+}
+
 func (s *NftLend) getLendCurrency(tx *gorm.DB, address string) (*models.Currency, error) {
 	c, err := s.cd.First(
 		tx,
@@ -101,25 +162,23 @@ func (s *NftLend) getLendCurrencyBySymbol(tx *gorm.DB, symbol string) (*models.C
 }
 
 func (s *NftLend) GetAssetDetail(ctx context.Context, seoURL string) (*models.Asset, error) {
-	m, err := s.ad.First(
-		daos.GetDBMainCtx(ctx),
-		map[string][]interface{}{
-			"seo_url = ?": []interface{}{seoURL},
-		},
-		map[string][]interface{}{
-			"Collection": []interface{}{},
-			"NewLoan": []interface{}{
-				"status = ?",
-				models.LoanStatusNew,
-			},
-			"NewLoan.Currency": []interface{}{},
-			"NewLoan.Offers": []interface{}{
+	filter := &daos.AssetFilter{
+		SeoURL: seoURL,
+	}
+	m, err := s.ad.FirstSpec(
+		s.conn.DB(ctx),
+		filter.Spec().
+			Preload("Collection").
+			Preload("NewLoan", "status = ?", models.LoanStatusNew).
+			Preload("NewLoan.Currency").
+			Preload(
+				"NewLoan.Offers",
 				func(db *gorm.DB) *gorm.DB {
 					return db.Order("loan_offers.id DESC")
 				},
-			},
-		},
-		[]string{"id desc"},
+			).
+			Order("id desc"),
+		false,
 	)
 	if err != nil {
 		return nil, errs.NewError(err)
@@ -127,12 +186,24 @@ func (s *NftLend) GetAssetDetail(ctx context.Context, seoURL string) (*models.As
 	return m, nil
 }
 
-func (s *NftLend) GetCollections(ctx context.Context, page int, limit int) ([]*models.Collection, uint, error) {
-	categories, count, err := s.cld.Find4Page(
-		daos.GetDBMainCtx(ctx),
-		map[string][]interface{}{},
-		map[string][]interface{}{
-			"ListingAsset": []interface{}{
+// GetCollections lists the enabled collections, the sorts and filters read the
+// aggregate columns maintained on the collections
+func (s *NftLend) GetCollections(ctx context.Context, network models.Chain, verified *bool, hasActiveListings bool, priceCurrencyID uint, sort []string, page int, limit int) ([]*models.Collection, uint, error) {
+	if len(sort) == 0 {
+		sort = []string{"id desc"}
+	}
+	filter := &daos.CollectionFilter{
+		AnyNetwork:        network,
+		Verified:          verified,
+		HasActiveListings: hasActiveListings,
+		PriceCurrencyID:   priceCurrencyID,
+	}
+	categories, count, err := s.cld.Find4PageSpec(
+		s.conn.DB(ctx),
+		filter.Spec().
+			Preload("PriceCurrency").
+			Preload(
+				"ListingAsset",
 				`id in (
 					select asset_id
 					from loans
@@ -152,9 +223,8 @@ func (s *NftLend) GetCollections(ctx context.Context, page int, limit int) ([]*m
 					) desc
 					`)
 				},
-			},
-		},
-		[]string{"id desc"},
+			).
+			Order(sort...),
 		page,
 		limit,
 	)
@@ -165,13 +235,13 @@ func (s *NftLend) GetCollections(ctx context.Context, page int, limit int) ([]*m
 }
 
 func (s *NftLend) GetCollectionDetail(ctx context.Context, seoURL string) (*models.Collection, error) {
-	m, err := s.cld.First(
-		daos.GetDBMainCtx(ctx),
-		map[string][]interface{}{
-			"seo_url = ?": []interface{}{seoURL},
-		},
-		map[string][]interface{}{},
-		[]string{"id desc"},
+	filter := &daos.CollectionFilter{
+		SeoURL: seoURL,
+	}
+	m, err := s.cld.FirstSpec(
+		s.conn.DB(ctx),
+		filter.Spec().Order("id desc"),
+		false,
 	)
 	if err != nil {
 		return nil, errs.NewError(err)
@@ -181,7 +251,7 @@ func (s *NftLend) GetCollectionDetail(ctx context.Context, seoURL string) (*mode
 
 func (s *NftLend) GetCurrencies(ctx context.Context) ([]*models.Currency, error) {
 	currencies, err := s.cd.Find(
-		daos.GetDBMainCtx(ctx),
+		s.conn.DB(ctx),
 		map[string][]interface{}{
 			"enabled = ?": []interface{}{true},
 		},
@@ -196,22 +266,17 @@ func (s *NftLend) GetCurrencies(ctx context.Context) ([]*models.Currency, error)
 	return currencies, nil
 }
 
-func (s *NftLend) GetRPTListingCollection(ctx context.Context) ([]*models.NftyRPTListingCollection, error) {
-	ms, err := s.ad.GetRPTListingCollection(
-		daos.GetDBMainCtx(ctx),
-	)
+func (s *NftLend) GetCollectionVerified(ctx context.Context, mintAddress string) (*models.Collection, error) {
+	p, err := s.prefetchAsset(mintAddress, false)
 	if err != nil {
 		return nil, errs.NewError(err)
 	}
-	return ms, nil
-}
-
-func (s *NftLend) GetCollectionVerified(ctx context.Context, mintAddress string) (*models.Collection, error) {
+	if !p.Verification.IsWrapped && p.MetaErr != nil {
+		return nil, errs.NewError(p.MetaErr)
+	}
 	m, _, err := s.getCollectionVerified(
-		daos.GetDBMainCtx(ctx),
-		mintAddress,
-		nil,
-		nil,
+		s.conn.DB(ctx),
+		p,
 	)
 	if err != nil {
 		return nil, errs.NewError(err)
@@ -219,22 +284,23 @@ func (s *NftLend) GetCollectionVerified(ctx context.Context, mintAddress string)
 	return m, nil
 }
 
-func (s *NftLend) getCollectionVerified(tx *gorm.DB, mintAddress string, meta *solana.MetadataResp, metaInfo *solana.MetadataInfoResp) (*models.Collection, string, error) {
-	vrs, err := s.bcs.SolanaNftVerifier.GetNftVerifier(mintAddress)
-	if err != nil {
-		return nil, "", errs.NewError(err)
-	}
+// getCollectionVerified matches the prefetched asset with an enabled
+// collection, by origin contract for the wrapped nfts else by name and
+// creator. An asset without metadata json only matches as a wrapped nft
+func (s *NftLend) getCollectionVerified(tx *gorm.DB, p *assetPrefetch) (*models.Collection, string, error) {
+	vrs := p.Verification
 	if vrs.IsWrapped {
-		chain := s.bcs.SolanaNftVerifier.ParseChain(vrs.ChainID)
-		m, err := s.cld.First(
+		enabled := true
+		filter := &daos.CollectionFilter{
+			OriginContractAddress: vrs.AssetAddress,
+			Enabled:               &enabled,
+		}
+		m, err := s.cld.FirstSpec(
 			tx,
-			map[string][]interface{}{
-				"origin_network = ?":          []interface{}{chain},
-				"origin_contract_address = ?": []interface{}{vrs.AssetAddress},
-				"enabled = ?":                 []interface{}{true},
-			},
-			map[string][]interface{}{},
-			[]string{"id desc"},
+			filter.Spec().
+				Where(daos.Eq("collections.origin_network", vrs.Chain)).
+				Order("id desc"),
+			false,
 		)
 		if err != nil {
 			return nil, "", errs.NewError(err)
@@ -242,19 +308,8 @@ func (s *NftLend) getCollectionVerified(tx *gorm.DB, mintAddress string, meta *s
 		if m != nil {
 			return m, vrs.TokenID, nil
 		}
-	} else {
-		if meta == nil {
-			meta, err = s.bcs.Solana.GetMetadata(mintAddress)
-			if err != nil {
-				return nil, "", errs.NewError(err)
-			}
-		}
-		if metaInfo == nil {
-			metaInfo, err = s.bcs.Solana.GetMetadataInfo(meta.Data.Uri)
-			if err != nil {
-				return nil, "", errs.NewError(err)
-			}
-		}
+	} else if p.MetaInfo != nil {
+		meta, metaInfo := p.Meta, p.MetaInfo
 		collectionName := metaInfo.Collection.Name
 		if collectionName == "" {
 			collectionName = metaInfo.Collection.Family
@@ -266,18 +321,19 @@ func (s *NftLend) getCollectionVerified(tx *gorm.DB, mintAddress string, meta *s
 			}
 		}
 		if collectionName == "" {
-			return nil, "", errs.NewError(err)
+			return nil, "", nil
 		}
 		for _, creator := range meta.Data.Creators {
-			m, err := s.cld.First(
+			enabled := true
+			filter := &daos.CollectionFilter{
+				Name:        collectionName,
+				CreatorLike: creator.Address,
+				Enabled:     &enabled,
+			}
+			m, err := s.cld.FirstSpec(
 				tx,
-				map[string][]interface{}{
-					"name = ?":       []interface{}{collectionName},
-					"creator like ?": []interface{}{fmt.Sprintf("%%%s%%", creator.Address)},
-					"enabled = ?":    []interface{}{true},
-				},
-				map[string][]interface{}{},
-				[]string{},
+				filter.Spec(),
+				false,
 			)
 			if err != nil {
 				return nil, "", errs.NewError(err)
@@ -291,22 +347,16 @@ func (s *NftLend) getCollectionVerified(tx *gorm.DB, mintAddress string, meta *s
 }
 
 func (s *NftLend) GetAseetTransactions(ctx context.Context, assetId uint, page int, limit int) ([]*models.AssetTransaction, uint, error) {
-	err := s.updateAssetTransactions(ctx, assetId)
-	if err != nil {
-		return nil, 0, errs.NewError(err)
+	filter := &daos.AssetTransactionFilter{
+		AssetID: assetId,
 	}
-	filters := map[string][]interface{}{}
-	if assetId > 0 {
-		filters["asset_id = ?"] = []interface{}{assetId}
-	}
-	txns, count, err := s.atd.Find4Page(
-		daos.GetDBMainCtx(ctx),
-		filters,
-		map[string][]interface{}{
-			"Asset":            []interface{}{},
-			"Asset.Collection": []interface{}{},
-		},
-		[]string{"transaction_at desc"},
+	txns, count, err := s.atd.Find4PageSpec(
+		s.conn.DB(ctx),
+		filter.Spec().
+			Preload("Asset").
+			Preload("Asset.Collection").
+			Preload("Currency").
+			Order("transaction_at desc"),
 		page,
 		limit,
 	)
@@ -316,246 +366,24 @@ func (s *NftLend) GetAseetTransactions(ctx context.Context, assetId uint, page i
 	return txns, count, nil
 }
 
-func (s *NftLend) updateAssetTransactions(ctx context.Context, assetId uint) error {
-	asset, err := s.ad.FirstByID(
-		daos.GetDBMainCtx(ctx),
-		assetId,
-		map[string][]interface{}{},
-		false,
+func (s *NftLend) GetAseetTransactions4Cursor(ctx context.Context, assetId uint, cursor string, limit int, withCount bool) ([]*models.AssetTransaction, *daos.CursorPage, error) {
+	filter := &daos.AssetTransactionFilter{
+		AssetID: assetId,
+	}
+	txns, page, err := s.atd.Find4Cursor(
+		s.conn.DB(ctx),
+		filter.Spec().
+			Preload("Asset").
+			Preload("Asset.Collection").
+			Preload("Currency"),
+		"transaction_at",
+		true,
+		cursor,
+		limit,
+		withCount,
 	)
 	if err != nil {
-		return errs.NewError(err)
-	}
-	if asset == nil {
-		return errs.NewError(errs.ErrBadRequest)
-	}
-	if asset.MagicEdenCrawAt == nil ||
-		asset.MagicEdenCrawAt.Before(time.Now().Add(-24*time.Hour)) {
-		c, err := s.getLendCurrencyBySymbol(daos.GetDBMainCtx(ctx), "SOL")
-		if err != nil {
-			return errs.NewError(err)
-		}
-		tokenAddress := asset.ContractAddress
-		if asset.TestContractAddress != "" {
-			tokenAddress = asset.TestContractAddress
-		}
-		rs, _ := s.stc.GetMagicEdenSaleHistories(tokenAddress)
-		for _, r := range rs {
-			if r.TxType == "exchange" {
-				txnAt := time.Unix(r.BlockTime, 0)
-				_ = s.atd.Create(
-					daos.GetDBMainCtx(ctx),
-					&models.AssetTransaction{
-						Source:        "magiceden.io",
-						Network:       models.ChainSOL,
-						AssetID:       asset.ID,
-						Type:          models.AssetTransactionTypeExchange,
-						Seller:        r.SellerAddress,
-						Buyer:         r.BuyerAddress,
-						TransactionID: r.TransactionID,
-						TransactionAt: &txnAt,
-						Amount:        numeric.BigFloat{*models.ConvertWeiToBigFloat(big.NewInt(int64(r.ParsedTransaction.TotalAmount)), 9)},
-						CurrencyID:    c.ID,
-					},
-				)
-			}
-		}
-		err = daos.WithTransaction(
-			daos.GetDBMainCtx(ctx),
-			func(tx *gorm.DB) error {
-				asset, err := s.ad.FirstByID(
-					tx,
-					asset.ID,
-					map[string][]interface{}{},
-					true,
-				)
-				if err != nil {
-					return errs.NewError(err)
-				}
-				if asset == nil {
-					return errs.NewError(errs.ErrBadRequest)
-				}
-				asset.MagicEdenCrawAt = helpers.TimeNow()
-				err = s.ad.Save(
-					tx,
-					asset,
-				)
-				if err != nil {
-					return errs.NewError(err)
-				}
-				return nil
-			},
-		)
-		if err != nil {
-			return errs.NewError(err)
-		}
-	}
-	if asset.SolanartCrawAt == nil ||
-		asset.SolanartCrawAt.Before(time.Now().Add(-24*time.Hour)) {
-		tokenAddress := asset.ContractAddress
-		if asset.TestContractAddress != "" {
-			tokenAddress = asset.TestContractAddress
-		}
-		rs, _ := s.stc.GetSolnartSaleHistories(tokenAddress)
-		for _, r := range rs {
-			c, err := s.getLendCurrencyBySymbol(daos.GetDBMainCtx(ctx), r.Currency)
-			if err != nil {
-				return errs.NewError(err)
-			}
-			_ = s.atd.Create(
-				daos.GetDBMainCtx(ctx),
-				&models.AssetTransaction{
-					Source:        "solanart.io",
-					Network:       models.ChainSOL,
-					AssetID:       asset.ID,
-					Type:          models.AssetTransactionTypeExchange,
-					Seller:        r.SellerAddress,
-					Buyer:         r.BuyerAdd,
-					TransactionAt: r.Date,
-					Amount:        numeric.BigFloat{*big.NewFloat(r.Price)},
-					CurrencyID:    c.ID,
-				},
-			)
-		}
-		err = daos.WithTransaction(
-			daos.GetDBMainCtx(ctx),
-			func(tx *gorm.DB) error {
-				asset, err := s.ad.FirstByID(
-					tx,
-					asset.ID,
-					map[string][]interface{}{},
-					true,
-				)
-				if err != nil {
-					return errs.NewError(err)
-				}
-				if asset == nil {
-					return errs.NewError(errs.ErrBadRequest)
-				}
-				asset.SolanartCrawAt = helpers.TimeNow()
-				err = s.ad.Save(
-					tx,
-					asset,
-				)
-				if err != nil {
-					return errs.NewError(err)
-				}
-				return nil
-			},
-		)
-		if err != nil {
-			return errs.NewError(err)
-		}
-	}
-	if asset.SolSeaCrawAt == nil ||
-		asset.SolSeaCrawAt.Before(time.Now().Add(-24*time.Hour)) {
-		tokenAddress := asset.ContractAddress
-		if asset.TestContractAddress != "" {
-			tokenAddress = asset.TestContractAddress
-		}
-		s.stc.PubSolseaMsg(
-			fmt.Sprintf(`421["find","listed-archive",{"Mint":"%s","status":"SOLD"}]`, tokenAddress),
-		)
-		err = daos.WithTransaction(
-			daos.GetDBMainCtx(ctx),
-			func(tx *gorm.DB) error {
-				asset, err := s.ad.FirstByID(
-					tx,
-					asset.ID,
-					map[string][]interface{}{},
-					true,
-				)
-				if err != nil {
-					return errs.NewError(err)
-				}
-				if asset == nil {
-					return errs.NewError(errs.ErrBadRequest)
-				}
-				asset.SolSeaCrawAt = helpers.TimeNow()
-				err = s.ad.Save(
-					tx,
-					asset,
-				)
-				if err != nil {
-					return errs.NewError(err)
-				}
-				return nil
-			},
-		)
-		if err != nil {
-			return errs.NewError(err)
-		}
-	}
-	return nil
-}
-
-func (s *NftLend) solseaMsgReceived(msg string) {
-	if strings.HasPrefix(msg, "431") {
-		msg = strings.TrimLeft(msg, "431")
-		resps := []*struct {
-			Data []*struct {
-				Mint      string     `json:"mint"`
-				Price     uint64     `json:"price"`
-				SellerKey string     `json:"sellerKey"`
-				BuyerKey  string     `json:"buyerKey"`
-				Status    string     `json:"status"`
-				ListedAt  *time.Time `json:"listedAt"`
-			} `json:"data"`
-		}{}
-		err := json.Unmarshal([]byte(msg), &resps)
-		if err != nil {
-			return
-		}
-		c, err := s.getLendCurrencyBySymbol(daos.GetDBMainCtx(context.Background()), "SOL")
-		if err != nil {
-			return
-		}
-		for _, resp := range resps {
-			if resp != nil {
-				for _, d := range resp.Data {
-					asset, err := s.ad.First(
-						daos.GetDBMainCtx(context.Background()),
-						map[string][]interface{}{
-							"contract_address = ?": []interface{}{d.Mint},
-						},
-						map[string][]interface{}{},
-						[]string{"id desc"},
-					)
-					if err != nil {
-						return
-					}
-					if asset == nil {
-						asset, err = s.ad.First(
-							daos.GetDBMainCtx(context.Background()),
-							map[string][]interface{}{
-								"test_contract_address = ?": []interface{}{d.Mint},
-							},
-							map[string][]interface{}{},
-							[]string{"id desc"},
-						)
-						if err != nil {
-							return
-						}
-						if asset == nil {
-							return
-						}
-					}
-					_ = s.atd.Create(
-						daos.GetDBMainCtx(context.Background()),
-						&models.AssetTransaction{
-							Source:        "solsea.io",
-							Network:       models.ChainSOL,
-							AssetID:       asset.ID,
-							Type:          models.AssetTransactionTypeExchange,
-							Seller:        d.SellerKey,
-							Buyer:         d.BuyerKey,
-							TransactionAt: d.ListedAt,
-							Amount:        numeric.BigFloat{*models.ConvertWeiToBigFloat(big.NewInt(int64(d.Price)), 9)},
-							CurrencyID:    c.ID,
-						},
-					)
-				}
-			}
-		}
+		return nil, nil, errs.NewError(err)
 	}
+	return txns, page, nil
 }
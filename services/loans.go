@@ -8,6 +8,48 @@ import (
 	"github.com/czConstant/constant-nftylend-api/models"
 )
 
+func (s *NftLend) listingLoansSpec(
+	collectionId uint,
+	minPrice float64,
+	maxPrice float64,
+	minDuration uint,
+	maxDuration uint,
+	minInterestRate float64,
+	maxInterestRate float64,
+	excludeIds []uint,
+) *daos.Spec {
+	filter := &daos.LoanFilter{
+		Statuses: []models.LoanStatus{
+			models.LoanStatusNew,
+		},
+		CollectionID:       collectionId,
+		MinPrincipalAmount: minPrice,
+		MaxPrincipalAmount: maxPrice,
+		MinDuration:        minDuration,
+		MaxDuration:        maxDuration,
+		MinInterestRate:    minInterestRate,
+		MaxInterestRate:    maxInterestRate,
+		ExcludeIDs:         excludeIds,
+	}
+	return withLoanPreloads(filter.Spec())
+}
+
+func withLoanPreloads(spec *daos.Spec) *daos.Spec {
+	return spec.
+		Preload("Asset").
+		Preload("Asset.Collection").
+		Preload("Currency").
+		Preload("ApprovedOffer", "status = ?", models.LoanOfferStatusApproved)
+}
+
+func withLoanRelatedPreloads(spec *daos.Spec) *daos.Spec {
+	return spec.
+		Preload("Loan").
+		Preload("Loan.Asset").
+		Preload("Loan.Asset.Collection").
+		Preload("Loan.Currency")
+}
+
 func (s *NftLend) GetListingLoans(
 	ctx context.Context,
 	collectionId uint,
@@ -22,62 +64,21 @@ func (s *NftLend) GetListingLoans(
 	page int,
 	limit int,
 ) ([]*models.Loan, uint, error) {
-	filters := map[string][]interface{}{
-		"status in (?)": []interface{}{
-			[]models.LoanStatus{
-				models.LoanStatusNew,
-			}},
-	}
-	if collectionId > 0 {
-		filters[`
-		exists(
-			select 1
-			from assets
-			where asset_id = assets.id
-			  and assets.collection_id = ?
-		)
-		`] = []interface{}{collectionId}
-	}
-	if minPrice > 0 {
-		filters["principal_amount >= ?"] = []interface{}{minPrice}
-	}
-	if maxPrice > 0 {
-		filters["principal_amount <= ?"] = []interface{}{maxPrice}
-	}
-	if minDuration > 0 {
-		filters["duration >= ?"] = []interface{}{minDuration}
-	}
-	if maxDuration > 0 {
-		filters["duration <= ?"] = []interface{}{maxDuration}
-	}
-	if len(excludeIds) > 0 {
-		filters["id not in (?)"] = []interface{}{excludeIds}
-	}
-	if minInterestRate > 0 {
-		filters["interest_rate >= ?"] = []interface{}{minInterestRate}
-	}
-	if maxInterestRate > 0 {
-		filters["interest_rate <= ?"] = []interface{}{maxInterestRate}
-	}
-	if len(excludeIds) > 0 {
-		filters["id not in (?)"] = []interface{}{excludeIds}
-	}
 	if len(sort) == 0 {
 		sort = []string{"id desc"}
 	}
-	loans, count, err := s.ld.Find4Page(
-		daos.GetDBMainCtx(ctx),
-		filters,
-		map[string][]interface{}{
-			"Asset":            []interface{}{},
-			"Asset.Collection": []interface{}{},
-			"Currency":         []interface{}{},
-			"ApprovedOffer": []interface{}{
-				"status = ?",
-				models.LoanOfferStatusApproved,
-			},
-		},
-		sort,
+	loans, count, err := s.ld.Find4PageSpec(
+		s.conn.DB(ctx),
+		s.listingLoansSpec(
+			collectionId,
+			minPrice,
+			maxPrice,
+			minDuration,
+			maxDuration,
+			minInterestRate,
+			maxInterestRate,
+			excludeIds,
+		).Order(sort...),
 		page,
 		limit,
 	)
@@ -87,33 +88,62 @@ func (s *NftLend) GetListingLoans(
 	return loans, count, nil
 }
 
+func (s *NftLend) GetListingLoans4Cursor(
+	ctx context.Context,
+	collectionId uint,
+	minPrice float64,
+	maxPrice float64,
+	minDuration uint,
+	maxDuration uint,
+	minInterestRate float64,
+	maxInterestRate float64,
+	excludeIds []uint,
+	sortColumn string,
+	sortDesc bool,
+	cursor string,
+	limit int,
+	withCount bool,
+) ([]*models.Loan, *daos.CursorPage, error) {
+	loans, page, err := s.ld.Find4Cursor(
+		s.conn.DB(ctx),
+		s.listingLoansSpec(
+			collectionId,
+			minPrice,
+			maxPrice,
+			minDuration,
+			maxDuration,
+			minInterestRate,
+			maxInterestRate,
+			excludeIds,
+		),
+		sortColumn,
+		sortDesc,
+		cursor,
+		limit,
+		withCount,
+	)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return loans, page, nil
+}
+
+func (s *NftLend) loansSpec(owner string, lender string, assetId uint, statues []string) *daos.Spec {
+	filter := &daos.LoanFilter{
+		Owner:   owner,
+		Lender:  lender,
+		AssetID: assetId,
+	}
+	for _, status := range statues {
+		filter.Statuses = append(filter.Statuses, models.LoanStatus(status))
+	}
+	return withLoanPreloads(filter.Spec())
+}
+
 func (s *NftLend) GetLoans(ctx context.Context, owner string, lender string, assetId uint, statues []string, page int, limit int) ([]*models.Loan, uint, error) {
-	filters := map[string][]interface{}{}
-	if owner != "" {
-		filters["owner = ?"] = []interface{}{owner}
-	}
-	if lender != "" {
-		filters["lender = ?"] = []interface{}{lender}
-	}
-	if assetId > 0 {
-		filters["asset_id = ?"] = []interface{}{assetId}
-	}
-	if len(statues) > 0 {
-		filters["status in (?)"] = []interface{}{statues}
-	}
-	loans, count, err := s.ld.Find4Page(
-		daos.GetDBMainCtx(ctx),
-		filters,
-		map[string][]interface{}{
-			"Asset":            []interface{}{},
-			"Asset.Collection": []interface{}{},
-			"Currency":         []interface{}{},
-			"ApprovedOffer": []interface{}{
-				"status = ?",
-				models.LoanOfferStatusApproved,
-			},
-		},
-		[]string{"id desc"},
+	loans, count, err := s.ld.Find4PageSpec(
+		s.conn.DB(ctx),
+		s.loansSpec(owner, lender, assetId, statues).Order("id desc"),
 		page,
 		limit,
 	)
@@ -123,34 +153,37 @@ func (s *NftLend) GetLoans(ctx context.Context, owner string, lender string, ass
 	return loans, count, nil
 }
 
+func (s *NftLend) GetLoans4Cursor(ctx context.Context, owner string, lender string, assetId uint, statues []string, cursor string, limit int, withCount bool) ([]*models.Loan, *daos.CursorPage, error) {
+	loans, page, err := s.ld.Find4Cursor(
+		s.conn.DB(ctx),
+		s.loansSpec(owner, lender, assetId, statues),
+		"id",
+		true,
+		cursor,
+		limit,
+		withCount,
+	)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return loans, page, nil
+}
+
+func (s *NftLend) loanOffersSpec(borrower string, lender string, statues []string) *daos.Spec {
+	filter := &daos.LoanOfferFilter{
+		Borrower: borrower,
+		Lender:   lender,
+	}
+	for _, status := range statues {
+		filter.Statuses = append(filter.Statuses, models.LoanOfferStatus(status))
+	}
+	return withLoanRelatedPreloads(filter.Spec())
+}
+
 func (s *NftLend) GetLoanOffers(ctx context.Context, borrower string, lender string, statues []string, page int, limit int) ([]*models.LoanOffer, uint, error) {
-	filters := map[string][]interface{}{}
-	if borrower != "" {
-		filters[`
-		exists(
-			select 1
-			from loans
-			where loan_id = loans.id
-			  and loans.owner = ?
-		)
-		`] = []interface{}{borrower}
-	}
-	if lender != "" {
-		filters["lender = ?"] = []interface{}{lender}
-	}
-	if len(statues) > 0 {
-		filters["status in (?)"] = []interface{}{statues}
-	}
-	offers, count, err := s.lod.Find4Page(
-		daos.GetDBMainCtx(ctx),
-		filters,
-		map[string][]interface{}{
-			"Loan":                  []interface{}{},
-			"Loan.Asset":            []interface{}{},
-			"Loan.Asset.Collection": []interface{}{},
-			"Loan.Currency":         []interface{}{},
-		},
-		[]string{"id desc"},
+	offers, count, err := s.lod.Find4PageSpec(
+		s.conn.DB(ctx),
+		s.loanOffersSpec(borrower, lender, statues).Order("id desc"),
 		page,
 		limit,
 	)
@@ -160,75 +193,54 @@ func (s *NftLend) GetLoanOffers(ctx context.Context, borrower string, lender str
 	return offers, count, nil
 }
 
-func (s *NftLend) GetLastListingLoanByCollection(ctx context.Context, collectionId uint) (*models.Loan, error) {
-	filters := map[string][]interface{}{
-		"status in (?)": []interface{}{
-			[]models.LoanStatus{
-				models.LoanStatusNew,
-			}},
-	}
-	filters[`
-		exists(
-			select 1
-			from assets
-			where asset_id = assets.id
-			  and assets.collection_id = ?
-		)
-		`] = []interface{}{collectionId}
-	loan, err := s.ld.First(
-		daos.GetDBMainCtx(ctx),
-		filters,
-		map[string][]interface{}{
-			"Asset": []interface{}{},
-			"ApprovedOffer": []interface{}{
-				"status = ?",
-				models.LoanOfferStatusApproved,
-			},
-		},
-		[]string{"id desc"},
+func (s *NftLend) GetLoanOffers4Cursor(ctx context.Context, borrower string, lender string, statues []string, cursor string, limit int, withCount bool) ([]*models.LoanOffer, *daos.CursorPage, error) {
+	offers, page, err := s.lod.Find4Cursor(
+		s.conn.DB(ctx),
+		s.loanOffersSpec(borrower, lender, statues),
+		"id",
+		true,
+		cursor,
+		limit,
+		withCount,
 	)
 	if err != nil {
-		return nil, errs.NewError(err)
+		return nil, nil, errs.NewError(err)
 	}
-	return loan, nil
+	return offers, page, nil
 }
 
-func (s *NftLend) GetRPTCollectionLoan(ctx context.Context, collectionID uint) (*models.NftyRPTCollectionLoan, error) {
-	m, err := s.ld.GetRPTCollectionLoan(
-		daos.GetDBMainCtx(ctx),
-		collectionID,
+func (s *NftLend) GetLastListingLoanByCollection(ctx context.Context, collectionId uint) (*models.Loan, error) {
+	filter := &daos.LoanFilter{
+		Statuses: []models.LoanStatus{
+			models.LoanStatusNew,
+		},
+		CollectionID: collectionId,
+	}
+	loan, err := s.ld.FirstSpec(
+		s.conn.DB(ctx),
+		filter.Spec().
+			Preload("Asset").
+			Preload("ApprovedOffer", "status = ?", models.LoanOfferStatusApproved).
+			Order("id desc"),
+		false,
 	)
 	if err != nil {
 		return nil, errs.NewError(err)
 	}
-	if m == nil {
-		return nil, errs.NewError(errs.ErrBadRequest)
+	return loan, nil
+}
+
+func (s *NftLend) loanTransactionsSpec(assetId uint) *daos.Spec {
+	filter := &daos.LoanTransactionFilter{
+		AssetID: assetId,
 	}
-	return m, nil
+	return withLoanRelatedPreloads(filter.Spec())
 }
 
 func (s *NftLend) GetLoanTransactions(ctx context.Context, assetId uint, page int, limit int) ([]*models.LoanTransaction, uint, error) {
-	filters := map[string][]interface{}{}
-	if assetId > 0 {
-		filters[`
-		exists(
-			select 1
-			from loans
-			where loan_id = loans.id
-			  and loans.asset_id = ?
-		)
-		`] = []interface{}{assetId}
-	}
-	txns, count, err := s.ltd.Find4Page(
-		daos.GetDBMainCtx(ctx),
-		filters,
-		map[string][]interface{}{
-			"Loan":                  []interface{}{},
-			"Loan.Asset":            []interface{}{},
-			"Loan.Asset.Collection": []interface{}{},
-			"Loan.Currency":         []interface{}{},
-		},
-		[]string{"id desc"},
+	txns, count, err := s.ltd.Find4PageSpec(
+		s.conn.DB(ctx),
+		s.loanTransactionsSpec(assetId).Order("id desc"),
 		page,
 		limit,
 	)
@@ -237,3 +249,19 @@ func (s *NftLend) GetLoanTransactions(ctx context.Context, assetId uint, page in
 	}
 	return txns, count, nil
 }
+
+func (s *NftLend) GetLoanTransactions4Cursor(ctx context.Context, assetId uint, cursor string, limit int, withCount bool) ([]*models.LoanTransaction, *daos.CursorPage, error) {
+	txns, page, err := s.ltd.Find4Cursor(
+		s.conn.DB(ctx),
+		s.loanTransactionsSpec(assetId),
+		"id",
+		true,
+		cursor,
+		limit,
+		withCount,
+	)
+	if err != nil {
+		return nil, nil, errs.NewError(err)
+	}
+	return txns, page, nil
+}
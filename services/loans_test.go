@@ -0,0 +1,441 @@
+package services_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/types/numeric"
+)
+
+const (
+	ownerOne = "OwnerOne"
+	ownerTwo = "OwnerTwo"
+	lenderA  = "LenderA"
+	lenderB  = "LenderB"
+)
+
+// listingTest adds a second collection and asset to the lend test, then
+//
+//	loan 1  asset 1  collection 1  new        1 SOL   7 days 10%  owner one
+//	loan 2  asset 2  collection 1  new        5 SOL  30 days 20%  owner two
+//	loan 3  asset 3  collection 2  new       10 SOL  14 days 30%  owner one
+//	loan 4  asset 1  collection 1  created    2 SOL   7 days 10%  owner one  lender a
+//	loan 5  asset 2  collection 1  cancelled  3 SOL   7 days 10%  owner two
+//
+// with an approved offer of lender a on loan 4, a new offer of lender b on
+// loan 2 and a rejected offer of lender a on loan 1
+type listingTest struct {
+	*lendTest
+	assets []*models.Asset
+	other  *models.Collection
+}
+
+func bigFloat(x float64) numeric.BigFloat {
+	return numeric.BigFloat{*big.NewFloat(x)}
+}
+
+func newListingTest(t *testing.T) *listingTest {
+	lt := &listingTest{lendTest: newLendTest(t)}
+	lt.other = &models.Collection{
+		Network: models.ChainSOL,
+		SeoURL:  "solana-monkey-business",
+		Name:    "Solana Monkey Business",
+		Enabled: true,
+	}
+	lt.create((&daos.Collection{}).Create, lt.other)
+	lt.assets = []*models.Asset{lt.asset}
+	for i, collectionID := range []uint{lt.collection.ID, lt.other.ID} {
+		asset := &models.Asset{
+			Network:         models.ChainSOL,
+			CollectionID:    collectionID,
+			SeoURL:          fmt.Sprintf("asset-%d", i+2),
+			ContractAddress: fmt.Sprintf("Mint%d", i+2),
+		}
+		lt.create((&daos.Asset{}).Create, asset)
+		lt.assets = append(lt.assets, asset)
+	}
+	day := uint(24 * 60 * 60)
+	for _, v := range []struct {
+		asset     int
+		status    models.LoanStatus
+		principal float64
+		duration  uint
+		rate      float64
+		owner     string
+		lender    string
+	}{
+		{0, models.LoanStatusNew, 1, 7 * day, 0.1, ownerOne, ""},
+		{1, models.LoanStatusNew, 5, 30 * day, 0.2, ownerTwo, ""},
+		{2, models.LoanStatusNew, 10, 14 * day, 0.3, ownerOne, ""},
+		{0, models.LoanStatusCreated, 2, 7 * day, 0.1, ownerOne, lenderA},
+		{1, models.LoanStatusCancelled, 3, 7 * day, 0.1, ownerTwo, ""},
+	} {
+		lt.create((&daos.Loan{}).Create, &models.Loan{
+			Network:         models.ChainSOL,
+			Owner:           v.owner,
+			Lender:          v.lender,
+			AssetID:         lt.assets[v.asset].ID,
+			CurrencyID:      1,
+			PrincipalAmount: bigFloat(v.principal),
+			Duration:        v.duration,
+			InterestRate:    v.rate,
+			Status:          v.status,
+		})
+	}
+	for _, v := range []struct {
+		loan   uint
+		lender string
+		status models.LoanOfferStatus
+	}{
+		{4, lenderA, models.LoanOfferStatusApproved},
+		{2, lenderB, models.LoanOfferStatusNew},
+		{1, lenderA, models.LoanOfferStatusRejected},
+	} {
+		lt.create((&daos.LoanOffer{}).Create, &models.LoanOffer{
+			Network: models.ChainSOL,
+			LoanID:  v.loan,
+			Lender:  v.lender,
+			Status:  v.status,
+		})
+	}
+	for _, v := range []struct {
+		loan uint
+		typ  models.LoanTransactionType
+	}{
+		{1, models.LoanTransactionTypeListed},
+		{4, models.LoanTransactionTypeListed},
+		{4, models.LoanTransactionTypeOffered},
+		{3, models.LoanTransactionTypeListed},
+	} {
+		lt.create((&daos.LoanTransaction{}).Create, &models.LoanTransaction{
+			Network: models.ChainSOL,
+			LoanID:  v.loan,
+			Type:    v.typ,
+		})
+	}
+	return lt
+}
+
+func loanIDs(loans []*models.Loan) string {
+	ids := []uint{}
+	for _, m := range loans {
+		ids = append(ids, m.ID)
+	}
+	return fmt.Sprint(ids)
+}
+
+func TestGetListingLoans(t *testing.T) {
+	lt := newListingTest(t)
+	day := uint(24 * 60 * 60)
+	tests := []struct {
+		name            string
+		collectionID    uint
+		minPrice        float64
+		maxPrice        float64
+		minDuration     uint
+		maxDuration     uint
+		minInterestRate float64
+		maxInterestRate float64
+		excludeIDs      []uint
+		sort            []string
+		want            string
+	}{
+		{name: "all listings", want: "[3 2 1]"},
+		{name: "collection", collectionID: lt.collection.ID, want: "[2 1]"},
+		{name: "other collection", collectionID: lt.other.ID, want: "[3]"},
+		{name: "min price", minPrice: 2, want: "[3 2]"},
+		{name: "max price", maxPrice: 5, want: "[2 1]"},
+		{name: "price range", minPrice: 2, maxPrice: 5, want: "[2]"},
+		{name: "min duration", minDuration: 14 * day, want: "[3 2]"},
+		{name: "max duration", maxDuration: 14 * day, want: "[3 1]"},
+		{name: "min interest rate", minInterestRate: 0.2, want: "[3 2]"},
+		{name: "max interest rate", maxInterestRate: 0.2, want: "[2 1]"},
+		{name: "exclude ids", excludeIDs: []uint{2, 4}, want: "[3 1]"},
+		{name: "sort", sort: []string{"principal_amount asc"}, want: "[1 2 3]"},
+		{name: "filters combine", collectionID: lt.collection.ID, maxInterestRate: 0.1, want: "[1]"},
+		{name: "no match", collectionID: lt.other.ID, maxPrice: 5, want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loans, count, err := lt.s.GetListingLoans(
+				context.Background(),
+				tt.collectionID,
+				tt.minPrice,
+				tt.maxPrice,
+				tt.minDuration,
+				tt.maxDuration,
+				tt.minInterestRate,
+				tt.maxInterestRate,
+				tt.excludeIDs,
+				tt.sort,
+				1,
+				10,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := loanIDs(loans); got != tt.want {
+				t.Fatalf("loans = %s, want %s", got, tt.want)
+			}
+			if int(count) != len(loans) {
+				t.Fatalf("count = %d, want %d", count, len(loans))
+			}
+		})
+	}
+}
+
+func TestGetListingLoansPreloadsAndPages(t *testing.T) {
+	lt := newListingTest(t)
+	loans, count, err := lt.s.GetListingLoans(context.Background(), 0, 0, 0, 0, 0, 0, 0, nil, nil, 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loanIDs(loans) != "[3 2]" || count != 3 {
+		t.Fatalf("first page = %s of %d", loanIDs(loans), count)
+	}
+	for _, loan := range loans {
+		if loan.Asset == nil || loan.Asset.Collection == nil || loan.Currency == nil || loan.Currency.Symbol != "SOL" {
+			t.Fatalf("loan %d preloads = %+v", loan.ID, loan)
+		}
+	}
+	if loans[0].Asset.Collection.ID != lt.other.ID || loans[1].Asset.Collection.ID != lt.collection.ID {
+		t.Fatalf("collections = %d %d", loans[0].Asset.Collection.ID, loans[1].Asset.Collection.ID)
+	}
+	loans, _, err = lt.s.GetListingLoans(context.Background(), 0, 0, 0, 0, 0, 0, 0, nil, nil, 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loanIDs(loans) != "[1]" {
+		t.Fatalf("second page = %s, want [1]", loanIDs(loans))
+	}
+}
+
+func TestGetListingLoans4Cursor(t *testing.T) {
+	lt := newListingTest(t)
+	got := []*models.Loan{}
+	cursor := ""
+	for i := 0; i < 5; i++ {
+		loans, page, err := lt.s.GetListingLoans4Cursor(context.Background(), lt.collection.ID, 0, 0, 0, 0, 0, 0, nil, "principal_amount", true, cursor, 1, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Count == nil || *page.Count != 2 {
+			t.Fatalf("count = %v, want 2", page.Count)
+		}
+		got = append(got, loans...)
+		cursor = page.NextCursor
+		if !page.HasMore {
+			break
+		}
+	}
+	if loanIDs(got) != "[2 1]" || cursor != "" {
+		t.Fatalf("loans = %s, cursor %q", loanIDs(got), cursor)
+	}
+}
+
+// the loans are created a second apart in different zones, the cursor keeps
+// the created_at of the last row in utc
+func TestGetListingLoans4CursorByCreatedAt(t *testing.T) {
+	lt := newListingTest(t)
+	createdAts := map[uint]time.Time{
+		1: time.Date(2022, 2, 1, 10, 0, 2, 0, time.FixedZone("ICT", 7*60*60)),
+		2: time.Date(2022, 2, 1, 3, 0, 1, 0, time.UTC),
+	}
+	for id, createdAt := range createdAts {
+		err := lt.db.Model(&models.Loan{}).Where("id = ?", id).UpdateColumn("created_at", createdAt).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		desc bool
+		want string
+		key  string
+	}{
+		{false, "[2 1]", "2022-02-01T03:00:01Z"},
+		{true, "[1 2]", "2022-02-01T03:00:02Z"},
+	} {
+		got := []*models.Loan{}
+		cursor := ""
+		for i := 0; i < 5; i++ {
+			loans, page, err := lt.s.GetListingLoans4Cursor(context.Background(), lt.collection.ID, 0, 0, 0, 0, 0, 0, nil, "created_at", tt.desc, cursor, 1, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if i == 0 {
+				b, err := base64.RawURLEncoding.DecodeString(page.NextCursor)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var token struct {
+					Key string `json:"k"`
+				}
+				err = json.Unmarshal(b, &token)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if token.Key != tt.key {
+					t.Fatalf("desc %v: cursor key = %q, want %q", tt.desc, token.Key, tt.key)
+				}
+			}
+			got = append(got, loans...)
+			cursor = page.NextCursor
+			if !page.HasMore {
+				break
+			}
+		}
+		if loanIDs(got) != tt.want {
+			t.Fatalf("desc %v: loans = %s, want %s", tt.desc, loanIDs(got), tt.want)
+		}
+	}
+}
+
+func TestGetLoans(t *testing.T) {
+	lt := newListingTest(t)
+	tests := []struct {
+		name     string
+		owner    string
+		lender   string
+		assetID  uint
+		statuses []string
+		want     string
+	}{
+		{name: "all", want: "[5 4 3 2 1]"},
+		{name: "owner", owner: ownerOne, want: "[4 3 1]"},
+		{name: "lender", lender: lenderA, want: "[4]"},
+		{name: "asset", assetID: lt.assets[1].ID, want: "[5 2]"},
+		{name: "status", owner: ownerOne, statuses: []string{"new"}, want: "[3 1]"},
+		{name: "statuses", statuses: []string{"new", "cancelled"}, want: "[5 3 2 1]"},
+		{name: "other owner", owner: ownerTwo, lender: lenderA, want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loans, count, err := lt.s.GetLoans(context.Background(), tt.owner, tt.lender, tt.assetID, tt.statuses, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := loanIDs(loans); got != tt.want {
+				t.Fatalf("loans = %s, want %s", got, tt.want)
+			}
+			if int(count) != len(loans) {
+				t.Fatalf("count = %d, want %d", count, len(loans))
+			}
+			cursorLoans, _, err := lt.s.GetLoans4Cursor(context.Background(), tt.owner, tt.lender, tt.assetID, tt.statuses, "", 10, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := loanIDs(cursorLoans); got != tt.want {
+				t.Fatalf("cursor loans = %s, want %s", got, tt.want)
+			}
+		})
+	}
+	// only the approved offer is preloaded
+	loans, _, err := lt.s.GetLoans(context.Background(), ownerOne, "", 0, nil, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, loan := range loans {
+		if (loan.ID == 4) != (loan.ApprovedOffer != nil) {
+			t.Fatalf("loan %d approved offer = %+v", loan.ID, loan.ApprovedOffer)
+		}
+	}
+	if loans[0].ApprovedOffer.Lender != lenderA {
+		t.Fatalf("approved offer = %+v", loans[0].ApprovedOffer)
+	}
+}
+
+func TestGetLoanOffers(t *testing.T) {
+	lt := newListingTest(t)
+	tests := []struct {
+		name     string
+		borrower string
+		lender   string
+		statuses []string
+		want     string
+	}{
+		{name: "all", want: "[3 2 1]"},
+		{name: "borrower", borrower: ownerOne, want: "[3 1]"},
+		{name: "other borrower", borrower: ownerTwo, want: "[2]"},
+		{name: "lender", lender: lenderA, want: "[3 1]"},
+		{name: "status", statuses: []string{"approved"}, want: "[1]"},
+		{name: "borrower and status", borrower: ownerOne, statuses: []string{"new"}, want: "[]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offers, count, err := lt.s.GetLoanOffers(context.Background(), tt.borrower, tt.lender, tt.statuses, 1, 10)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []uint{}
+			for _, offer := range offers {
+				if offer.Loan == nil || offer.Loan.ID != offer.LoanID || offer.Loan.Asset == nil || offer.Loan.Currency == nil {
+					t.Fatalf("offer %d loan = %+v", offer.ID, offer.Loan)
+				}
+				ids = append(ids, offer.ID)
+			}
+			if got := fmt.Sprint(ids); got != tt.want {
+				t.Fatalf("offers = %s, want %s", got, tt.want)
+			}
+			if int(count) != len(offers) {
+				t.Fatalf("count = %d, want %d", count, len(offers))
+			}
+		})
+	}
+	offers, page, err := lt.s.GetLoanOffers4Cursor(context.Background(), "", lenderA, nil, "", 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(offers) != 1 || offers[0].ID != 3 || !page.HasMore || page.Count != nil {
+		t.Fatalf("offers = %v, page = %+v", offers, page)
+	}
+}
+
+func TestGetLastListingLoanByCollection(t *testing.T) {
+	lt := newListingTest(t)
+	for collectionID, want := range map[uint]uint{lt.collection.ID: 2, lt.other.ID: 3} {
+		loan, err := lt.s.GetLastListingLoanByCollection(context.Background(), collectionID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if loan == nil || loan.ID != want || loan.Asset == nil {
+			t.Fatalf("collection %d loan = %+v, want %d", collectionID, loan, want)
+		}
+	}
+	loan, err := lt.s.GetLastListingLoanByCollection(context.Background(), 99)
+	if err != nil || loan != nil {
+		t.Fatalf("unknown collection loan = %+v, %v", loan, err)
+	}
+}
+
+func TestGetLoanTransactions(t *testing.T) {
+	lt := newListingTest(t)
+	txs, count, err := lt.s.GetLoanTransactions(context.Background(), lt.asset.ID, 1, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	types := []string{}
+	for _, m := range txs {
+		if m.Loan == nil || m.Loan.AssetID != lt.asset.ID || m.Loan.Asset == nil {
+			t.Fatalf("transaction %d loan = %+v", m.ID, m.Loan)
+		}
+		types = append(types, fmt.Sprintf("%d %s", m.ID, m.Type))
+	}
+	if fmt.Sprint(types) != "[3 offered 2 listed 1 listed]" || count != 3 {
+		t.Fatalf("transactions = %v of %d", types, count)
+	}
+	var page *daos.CursorPage
+	txs, page, err = lt.s.GetLoanTransactions4Cursor(context.Background(), lt.assets[2].ID, "", 10, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(txs) != 1 || txs[0].ID != 4 || page.HasMore || *page.Count != 1 {
+		t.Fatalf("transactions = %v, page = %+v", txs, page)
+	}
+}
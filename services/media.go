@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/blobstore"
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/thumbnail"
+)
+
+// mediaFailureTTL is how long a failed fetch is answered from memory before
+// the gateways are tried again
+const mediaFailureTTL = 5 * time.Minute
+
+// mediaLocks serializes the work on a media key so that concurrent requests
+// for a missing blob fetch or resize it only once, and remembers the fetches
+// which failed so that an unavailable image does not hold the key lock
+// through the gateway retries on every request
+type mediaLocks struct {
+	mtx      sync.Mutex
+	locks    map[string]*mediaLock
+	failures map[string]time.Time
+}
+
+type mediaLock struct {
+	mtx  sync.Mutex
+	refs int
+}
+
+func newMediaLocks() *mediaLocks {
+	return &mediaLocks{
+		locks:    map[string]*mediaLock{},
+		failures: map[string]time.Time{},
+	}
+}
+
+func (l *mediaLocks) failed(key string) bool {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	until, ok := l.failures[key]
+	if !ok {
+		return false
+	}
+	if time.Now().After(until) {
+		delete(l.failures, key)
+		return false
+	}
+	return true
+}
+
+func (l *mediaLocks) fail(key string) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	now := time.Now()
+	for k, until := range l.failures {
+		if now.After(until) {
+			delete(l.failures, k)
+		}
+	}
+	l.failures[key] = now.Add(mediaFailureTTL)
+}
+
+func (l *mediaLocks) lock(key string) func() {
+	l.mtx.Lock()
+	k, ok := l.locks[key]
+	if !ok {
+		k = &mediaLock{}
+		l.locks[key] = k
+	}
+	k.refs++
+	l.mtx.Unlock()
+	k.mtx.Lock()
+	return func() {
+		k.mtx.Unlock()
+		l.mtx.Lock()
+		k.refs--
+		if k.refs == 0 {
+			delete(l.locks, key)
+		}
+		l.mtx.Unlock()
+	}
+}
+
+// assetMediaKey is the blob key of a size of the asset image, the hash of the
+// image url changes the key when a metadata refresh changes the image
+func assetMediaKey(asset *models.Asset, size models.MediaSize) string {
+	h := sha256.Sum256([]byte(asset.TokenURL))
+	return fmt.Sprintf("assets/%d/%s/%s", asset.ID, hex.EncodeToString(h[:8]), size)
+}
+
+// GetAssetMedia serves the image of the asset in one of the preset sizes. The
+// source image is fetched once and the thumbnails are made on the first
+// request, the media which can not be resized are served as they are
+func (s *NftLend) GetAssetMedia(ctx context.Context, assetID uint, size models.MediaSize) (*blobstore.Blob, error) {
+	maxSize, ok := models.MediaSizes[size]
+	if !ok && size != models.MediaSizeOriginal {
+		return nil, errs.NewError(errs.ErrBadRequest)
+	}
+	asset, err := s.ad.FirstByID(
+		s.conn.DB(ctx),
+		assetID,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if asset == nil {
+		return nil, errs.NewError(errs.ErrAssetNotFound)
+	}
+	if asset.TokenURL == "" {
+		return nil, errs.NewError(errs.ErrMediaUnavailable)
+	}
+	if size == models.MediaSizeOriginal {
+		blob, err := s.getAssetMediaSource(ctx, asset)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		return blob, nil
+	}
+	key := assetMediaKey(asset, size)
+	blob, err := s.bls.Get(ctx, key)
+	if err == nil {
+		return blob, nil
+	}
+	if err != blobstore.ErrNotFound {
+		return nil, errs.NewError(err)
+	}
+	unlock := s.mls.lock(key)
+	defer unlock()
+	blob, err = s.bls.Get(ctx, key)
+	if err == nil {
+		return blob, nil
+	}
+	if err != blobstore.ErrNotFound {
+		return nil, errs.NewError(err)
+	}
+	source, err := s.getAssetMediaSource(ctx, asset)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	data, contentType, err := thumbnail.Make(source.Data, maxSize)
+	if err == thumbnail.ErrUnsupported {
+		return source, nil
+	}
+	if err == thumbnail.ErrTooLarge {
+		return nil, errs.NewError(errs.ErrMediaUnavailable)
+	}
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	blob = &blobstore.Blob{
+		Data:        data,
+		ContentType: contentType,
+		ModTime:     source.ModTime,
+	}
+	err = s.bls.Put(ctx, key, blob)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return blob, nil
+}
+
+// getAssetMediaSource returns the stored image of the asset or fetches it
+// through the metadata gateways
+func (s *NftLend) getAssetMediaSource(ctx context.Context, asset *models.Asset) (*blobstore.Blob, error) {
+	key := assetMediaKey(asset, models.MediaSizeOriginal)
+	blob, err := s.bls.Get(ctx, key)
+	if err == nil {
+		return blob, nil
+	}
+	if err != blobstore.ErrNotFound {
+		return nil, errs.NewError(err)
+	}
+	if s.mls.failed(key) {
+		return nil, errs.NewError(errs.ErrMediaUnavailable)
+	}
+	unlock := s.mls.lock(key)
+	defer unlock()
+	blob, err = s.bls.Get(ctx, key)
+	if err == nil {
+		return blob, nil
+	}
+	if err != blobstore.ErrNotFound {
+		return nil, errs.NewError(err)
+	}
+	if s.mls.failed(key) {
+		return nil, errs.NewError(errs.ErrMediaUnavailable)
+	}
+	data, contentType, err := s.bcs.GetMetadataMedia(asset.TokenURL)
+	if err != nil {
+		s.mls.fail(key)
+		return nil, errs.NewError(errs.ErrMediaUnavailable)
+	}
+	blob = &blobstore.Blob{
+		Data:        data,
+		ContentType: contentType,
+		ModTime:     time.Now(),
+	}
+	err = s.bls.Put(ctx, key, blob)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return blob, nil
+}
@@ -0,0 +1,231 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	partnerKeyPrefix    = "nftyp_"
+	partnerUsageDateFmt = "2006-01-02"
+)
+
+func newPartnerKey() (string, error) {
+	key, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s", partnerKeyPrefix, key), nil
+}
+
+func validatePartnerUsageDate(date string) error {
+	if date == "" {
+		return nil
+	}
+	_, err := time.Parse(partnerUsageDateFmt, date)
+	if err != nil {
+		return errs.NewInvalidParamsError("date must be formatted as yyyy-mm-dd")
+	}
+	return nil
+}
+
+// GetPartner resolves a plain partner key, revoked keys are rejected
+func (s *NftLend) GetPartner(ctx context.Context, key string) (*models.Partner, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errs.NewError(errs.ErrTokenInvalid)
+	}
+	m, err := s.pd.First(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"key_hash = ?": []interface{}{hashToken(key)},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if m == nil || m.RevokedAt != nil {
+		return nil, errs.NewError(errs.ErrTokenInvalid)
+	}
+	return m, nil
+}
+
+// MeterPartnerRequest counts a request on the usage of the current UTC day, once
+// the daily quota is used the request is counted as rejected and not allowed
+func (s *NftLend) MeterPartnerRequest(ctx context.Context, partner *models.Partner) (bool, error) {
+	allowed, err := s.pud.Meter(
+		s.conn.DB(ctx),
+		partner.ID,
+		time.Now().UTC().Format(partnerUsageDateFmt),
+		partner.DailyQuota,
+	)
+	if err != nil {
+		return false, errs.NewError(err)
+	}
+	return allowed, nil
+}
+
+// GetPartnerUsages returns the daily usage of a partner, fromDate and toDate are
+// inclusive yyyy-mm-dd days
+func (s *NftLend) GetPartnerUsages(ctx context.Context, partnerID uint, fromDate string, toDate string, page int, limit int) ([]*models.PartnerUsage, uint, error) {
+	err := validatePartnerUsageDate(fromDate)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	err = validatePartnerUsageDate(toDate)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	filters := map[string][]interface{}{
+		"partner_id = ?": []interface{}{partnerID},
+	}
+	if fromDate != "" {
+		filters["date >= ?"] = []interface{}{fromDate}
+	}
+	if toDate != "" {
+		filters["date <= ?"] = []interface{}{toDate}
+	}
+	ms, count, err := s.pud.Find4Page(
+		s.conn.DB(ctx),
+		filters,
+		map[string][]interface{}{},
+		[]string{"date desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, count, nil
+}
+
+func (s *NftLend) AdminGetPartners(ctx context.Context, page int, limit int) ([]*models.Partner, uint, error) {
+	ms, count, err := s.pd.Find4Page(
+		s.conn.DB(ctx),
+		map[string][]interface{}{},
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, count, nil
+}
+
+func (s *NftLend) getAdminPartner(tx *gorm.DB, id uint) (*models.Partner, error) {
+	m, err := s.pd.FirstByID(
+		tx,
+		id,
+		map[string][]interface{}{},
+		true,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if m == nil {
+		return nil, errs.NewError(errs.ErrPartnerNotFound)
+	}
+	return m, nil
+}
+
+// AdminCreatePartner issues a partner key, the plain key is only returned here
+func (s *NftLend) AdminCreatePartner(ctx context.Context, req *serializers.AdminPartnerReq) (*models.Partner, string, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, "", errs.NewInvalidParamsError("name is required")
+	}
+	key, err := newPartnerKey()
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	m := &models.Partner{
+		Name:         name,
+		ContactEmail: strings.TrimSpace(req.ContactEmail),
+		KeyPrefix:    key[:len(partnerKeyPrefix)+apiKeyPrefixLen],
+		KeyHash:      hashToken(key),
+		DailyQuota:   req.DailyQuota,
+	}
+	err = s.pd.Create(
+		s.conn.DB(ctx),
+		m,
+	)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	return m, key, nil
+}
+
+func (s *NftLend) AdminUpdatePartner(ctx context.Context, id uint, req *serializers.AdminPartnerReq) (*models.Partner, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errs.NewInvalidParamsError("name is required")
+	}
+	var m *models.Partner
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			m, err = s.getAdminPartner(tx, id)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			m.Name = name
+			m.ContactEmail = strings.TrimSpace(req.ContactEmail)
+			m.DailyQuota = req.DailyQuota
+			err = s.pd.Save(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
+
+func (s *NftLend) AdminRevokePartner(ctx context.Context, id uint) (*models.Partner, error) {
+	var m *models.Partner
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			m, err = s.getAdminPartner(tx, id)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m.RevokedAt != nil {
+				return nil
+			}
+			m.RevokedAt = helpers.TimeNow()
+			err = s.pd.Save(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
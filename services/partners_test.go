@@ -0,0 +1,104 @@
+package services_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/serializers"
+)
+
+func (lt *lendTest) partner(quota uint) *models.Partner {
+	lt.t.Helper()
+	partner, _, err := lt.s.AdminCreatePartner(context.Background(), &serializers.AdminPartnerReq{
+		Name:       "partner",
+		DailyQuota: quota,
+	})
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	return partner
+}
+
+func (lt *lendTest) partnerUsage(partner *models.Partner) *models.PartnerUsage {
+	lt.t.Helper()
+	usages, _, err := lt.s.GetPartnerUsages(context.Background(), partner.ID, "", "", 1, 10)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	if len(usages) != 1 {
+		lt.t.Fatalf("usages = %d, want one for today", len(usages))
+	}
+	return usages[0]
+}
+
+func TestMeterPartnerRequest(t *testing.T) {
+	lt := newLendTest(t)
+	partner := lt.partner(2)
+	for i, want := range []bool{true, true, false, false} {
+		allowed, err := lt.s.MeterPartnerRequest(context.Background(), partner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if allowed != want {
+			t.Fatalf("request %d allowed = %v, want %v", i, allowed, want)
+		}
+	}
+	usage := lt.partnerUsage(partner)
+	if usage.Requests != 2 || usage.Rejected != 2 {
+		t.Fatalf("usage = %d requests %d rejected, want 2 and 2", usage.Requests, usage.Rejected)
+	}
+}
+
+func TestMeterPartnerRequestWithoutQuota(t *testing.T) {
+	lt := newLendTest(t)
+	partner := lt.partner(0)
+	for i := 0; i < 3; i++ {
+		allowed, err := lt.s.MeterPartnerRequest(context.Background(), partner)
+		if err != nil || !allowed {
+			t.Fatalf("request %d = %v %v, want allowed", i, allowed, err)
+		}
+	}
+	usage := lt.partnerUsage(partner)
+	if usage.Requests != 3 || usage.Rejected != 0 {
+		t.Fatalf("usage = %d requests %d rejected, want 3 and 0", usage.Requests, usage.Rejected)
+	}
+}
+
+// the first requests of the day race on creating the usage row
+func TestMeterPartnerRequestConcurrently(t *testing.T) {
+	lt := newLendTest(t)
+	partner := lt.partner(5)
+	var (
+		wg      sync.WaitGroup
+		mtx     sync.Mutex
+		allowed int
+		errs    []error
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ok, err := lt.s.MeterPartnerRequest(context.Background(), partner)
+			mtx.Lock()
+			defer mtx.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			} else if ok {
+				allowed++
+			}
+		}()
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		t.Fatal(errs)
+	}
+	if allowed != 5 {
+		t.Fatalf("allowed = %d, want the quota of 5", allowed)
+	}
+	usage := lt.partnerUsage(partner)
+	if usage.Requests != 5 || usage.Rejected != 15 {
+		t.Fatalf("usage = %d requests %d rejected, want 5 and 15", usage.Requests, usage.Rejected)
+	}
+}
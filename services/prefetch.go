@@ -0,0 +1,157 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/czConstant/blockchain-api/bcclient/solana"
+	"github.com/czConstant/constant-nftylend-api/errs"
+)
+
+const (
+	assetPrefetchCacheTTL  = 10 * time.Minute
+	assetPrefetchCacheSize = 10000
+)
+
+// assetPrefetch is what the ingestion of an asset reads off chain. It is
+// resolved before the db transaction so the apply phase only waits on the
+// database, MetaErr is set when the metadata json could not be fetched and the
+// asset gets placeholder metadata
+type assetPrefetch struct {
+	MintAddress  string
+	Meta         *solana.MetadataResp
+	MetaInfo     *solana.MetadataInfoResp
+	MetaErr      error
+	Verification *NftVerification
+}
+
+type assetPrefetchCacheEntry struct {
+	prefetch  *assetPrefetch
+	expiresAt time.Time
+}
+
+// assetPrefetchCache keeps the complete prefetches of the mints seen recently,
+// an instruction retried after a failed apply does not hit the chain again
+type assetPrefetchCache struct {
+	mtx     sync.Mutex
+	entries map[string]*assetPrefetchCacheEntry
+}
+
+func newAssetPrefetchCache() *assetPrefetchCache {
+	return &assetPrefetchCache{
+		entries: map[string]*assetPrefetchCacheEntry{},
+	}
+}
+
+func (c *assetPrefetchCache) get(mintAddress string) *assetPrefetch {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	e, ok := c.entries[mintAddress]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(e.expiresAt) {
+		delete(c.entries, mintAddress)
+		return nil
+	}
+	return e.prefetch
+}
+
+func (c *assetPrefetchCache) set(mintAddress string, p *assetPrefetch) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	now := time.Now()
+	if len(c.entries) >= assetPrefetchCacheSize {
+		for k, e := range c.entries {
+			if now.After(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= assetPrefetchCacheSize {
+			c.entries = map[string]*assetPrefetchCacheEntry{}
+		}
+	}
+	c.entries[mintAddress] = &assetPrefetchCacheEntry{
+		prefetch:  p,
+		expiresAt: now.Add(assetPrefetchCacheTTL),
+	}
+}
+
+// prefetchAsset reads the on chain metadata, the metadata json and the wrapped
+// verification of a mint, refresh bypasses the caches
+func (s *NftLend) prefetchAsset(mintAddress string, refresh bool) (*assetPrefetch, error) {
+	if !refresh {
+		if p := s.apc.get(mintAddress); p != nil {
+			return p, nil
+		}
+	}
+	vrs, err := s.bcs.GetNftVerifier(mintAddress)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	meta, err := s.bcs.GetMetadata(mintAddress)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	p := &assetPrefetch{
+		MintAddress:  mintAddress,
+		Meta:         meta,
+		Verification: vrs,
+	}
+	if refresh {
+		p.MetaInfo, p.MetaErr = s.bcs.RefreshMetadataInfo(meta.Data.Uri)
+	} else {
+		p.MetaInfo, p.MetaErr = s.bcs.GetMetadataInfo(meta.Data.Uri)
+	}
+	if p.MetaErr != nil {
+		p.MetaInfo = nil
+	} else {
+		s.apc.set(mintAddress, p)
+	}
+	return p, nil
+}
+
+// prefetchSolanaInstruction resolves the off chain reads an instruction needs
+// before it is applied, only the InitLoan of an unknown asset needs any
+func (s *NftLend) prefetchSolanaInstruction(ctx context.Context, insId uint) (*assetPrefetch, error) {
+	ins, err := s.id.FirstByID(
+		s.conn.DB(ctx),
+		insId,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if ins == nil || ins.Instruction != "InitLoan" {
+		return nil, nil
+	}
+	var req struct {
+		NftCollateralContract string `json:"nft_collateral_contract"`
+	}
+	err = json.Unmarshal([]byte(ins.Data), &req)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	asset, err := s.ad.First(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"contract_address =?": []interface{}{req.NftCollateralContract},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if asset != nil {
+		return nil, nil
+	}
+	p, err := s.prefetchAsset(req.NftCollateralContract, false)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return p, nil
+}
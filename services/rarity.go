@@ -0,0 +1,431 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/logger"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+	"go.uber.org/zap"
+)
+
+const (
+	rarityCollectionBatchSize = 10
+	rarityAssetBatchSize      = 1000
+
+	rarityTraitNone = "None"
+)
+
+type assetAttribute struct {
+	TraitType string      `json:"trait_type"`
+	Value     interface{} `json:"value"`
+}
+
+// assetTraits parses the attributes of an asset into its trait values by
+// type, the attributes without type or value are ignored
+func assetTraits(asset *models.Asset) map[string]string {
+	attrs := []*assetAttribute{}
+	json.Unmarshal([]byte(asset.Attributes), &attrs)
+	traits := map[string]string{}
+	for _, attr := range attrs {
+		if attr == nil || attr.Value == nil {
+			continue
+		}
+		traitType := strings.TrimSpace(attr.TraitType)
+		value := strings.TrimSpace(fmt.Sprint(attr.Value))
+		if traitType == "" || value == "" {
+			continue
+		}
+		traits[traitType] = value
+	}
+	return traits
+}
+
+// markCollectionRarityStale queues the collection for the rarity job, it is
+// called whenever an asset joins the collection or its attributes change
+func (s *NftLend) markCollectionRarityStale(tx *gorm.DB, collectionID uint) error {
+	collection, err := s.cld.FirstByID(
+		tx,
+		collectionID,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	if collection == nil || collection.RarityStale {
+		return nil
+	}
+	collection.RarityStale = true
+	err = s.cld.Save(
+		tx,
+		collection,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// JobComputeCollectionRarity recomputes the rarity of a batch of the
+// collections which received assets since their last computation. A failing
+// collection is logged and moved behind the others so that it can not hold
+// the batch back
+func (s *NftLend) JobComputeCollectionRarity(ctx context.Context) error {
+	collections, err := s.cld.Find(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"rarity_stale = ?": []interface{}{true},
+		},
+		map[string][]interface{}{},
+		[]string{"rarity_computed_at asc", "id asc"},
+		0,
+		rarityCollectionBatchSize,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	for _, collection := range collections {
+		err = s.computeCollectionRarity(ctx, collection.ID)
+		if err != nil {
+			logger.Error(
+				"collection_rarity",
+				"compute rarity failed",
+				zap.Uint("collection_id", collection.ID),
+				zap.Error(err),
+			)
+			err = s.deferCollectionRarity(ctx, collection.ID)
+			if err != nil {
+				return errs.NewError(err)
+			}
+		}
+	}
+	return nil
+}
+
+// deferCollectionRarity marks the collection stale again but stamps its
+// computation time so that the next batches pick the other collections first
+func (s *NftLend) deferCollectionRarity(ctx context.Context, collectionID uint) error {
+	return s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			collection, err := s.cld.FirstByID(
+				tx,
+				collectionID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if collection == nil {
+				return nil
+			}
+			collection.RarityStale = true
+			collection.RarityComputedAt = helpers.TimeNow()
+			err = s.cld.Save(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+}
+
+// collectionRarity is the trait counts of a collection and the scores and
+// ranks of its assets with attributes
+type collectionRarity struct {
+	counts map[string]map[string]uint
+	total  int
+	scores map[uint]float64
+	ranks  map[uint]uint
+}
+
+// scoreCollectionRarity scores the assets, given by id with their traits, by
+// statistical rarity: the sum over every trait type of the inverse frequency
+// of the asset value, a missing trait counting as the None value. Assets are
+// ranked by score, equal scores share their rank and the assets without
+// traits are left unranked
+func scoreCollectionRarity(assetTraitsMap map[uint]map[string]string) *collectionRarity {
+	traitTypes := map[string]bool{}
+	for _, traits := range assetTraitsMap {
+		for traitType := range traits {
+			traitTypes[traitType] = true
+		}
+	}
+	r := &collectionRarity{
+		counts: map[string]map[string]uint{},
+		scores: map[uint]float64{},
+		ranks:  map[uint]uint{},
+	}
+	for traitType := range traitTypes {
+		r.counts[traitType] = map[string]uint{}
+	}
+	for _, traits := range assetTraitsMap {
+		if len(traits) == 0 {
+			continue
+		}
+		r.total++
+		for traitType := range traitTypes {
+			value, ok := traits[traitType]
+			if !ok {
+				value = rarityTraitNone
+			}
+			r.counts[traitType][value]++
+		}
+	}
+	ranked := []uint{}
+	for assetID, traits := range assetTraitsMap {
+		if len(traits) == 0 {
+			continue
+		}
+		var score float64
+		for traitType := range traitTypes {
+			value, ok := traits[traitType]
+			if !ok {
+				value = rarityTraitNone
+			}
+			score += float64(r.total) / float64(r.counts[traitType][value])
+		}
+		r.scores[assetID] = math.Round(score*1e6) / 1e6
+		ranked = append(ranked, assetID)
+	}
+	sort.Slice(
+		ranked,
+		func(i, j int) bool {
+			if r.scores[ranked[i]] != r.scores[ranked[j]] {
+				return r.scores[ranked[i]] > r.scores[ranked[j]]
+			}
+			return ranked[i] < ranked[j]
+		},
+	)
+	for i, assetID := range ranked {
+		if i > 0 && r.scores[assetID] == r.scores[ranked[i-1]] {
+			r.ranks[assetID] = r.ranks[ranked[i-1]]
+		} else {
+			r.ranks[assetID] = uint(i + 1)
+		}
+	}
+	return r
+}
+
+// computeCollectionRarity counts the trait values of the collection and
+// stores the rarity of its assets, see scoreCollectionRarity. The collection
+// is only locked to claim and stamp the computation, the assets are read and
+// written in batches so that a large collection does not hold a transaction
+// open. Assets joining meanwhile mark the collection stale again
+func (s *NftLend) computeCollectionRarity(ctx context.Context, collectionID uint) error {
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			collection, err := s.cld.FirstByID(
+				tx,
+				collectionID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if collection == nil {
+				return errs.NewError(errs.ErrBadRequest)
+			}
+			collection.RarityStale = false
+			err = s.cld.Save(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	var (
+		assetIDs       = []uint{}
+		assetTraitsMap = map[uint]map[string]string{}
+		storedScores   = map[uint]float64{}
+		storedRanks    = map[uint]uint{}
+		lastID         uint
+	)
+	for {
+		assets, err := s.ad.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"collection_id = ?": []interface{}{collectionID},
+				"id > ?":            []interface{}{lastID},
+			},
+			map[string][]interface{}{},
+			[]string{"id asc"},
+			0,
+			rarityAssetBatchSize,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		for _, asset := range assets {
+			lastID = asset.ID
+			assetIDs = append(assetIDs, asset.ID)
+			assetTraitsMap[asset.ID] = assetTraits(asset)
+			storedScores[asset.ID] = asset.RarityScore
+			storedRanks[asset.ID] = asset.RarityRank
+		}
+		if len(assets) < rarityAssetBatchSize {
+			break
+		}
+	}
+	r := scoreCollectionRarity(assetTraitsMap)
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			return s.saveCollectionTraits(tx, collectionID, r.counts, r.total)
+		},
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	changed := []uint{}
+	for _, assetID := range assetIDs {
+		if storedScores[assetID] != r.scores[assetID] || storedRanks[assetID] != r.ranks[assetID] {
+			changed = append(changed, assetID)
+		}
+	}
+	for i := 0; i < len(changed); i += rarityAssetBatchSize {
+		batch := changed[i:]
+		if len(batch) > rarityAssetBatchSize {
+			batch = batch[:rarityAssetBatchSize]
+		}
+		err = s.conn.WithTransaction(
+			ctx,
+			func(tx *gorm.DB) error {
+				for _, assetID := range batch {
+					err := s.ad.UpdateRarity(
+						tx,
+						assetID,
+						r.scores[assetID],
+						r.ranks[assetID],
+					)
+					if err != nil {
+						return errs.NewError(err)
+					}
+					if storedScores[assetID] == r.scores[assetID] {
+						continue
+					}
+					err = s.ld.UpdateAssetRarityScore(
+						tx,
+						assetID,
+						r.scores[assetID],
+					)
+					if err != nil {
+						return errs.NewError(err)
+					}
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			collection, err := s.cld.FirstByID(
+				tx,
+				collectionID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if collection == nil {
+				return nil
+			}
+			collection.RarityComputedAt = helpers.TimeNow()
+			err = s.cld.Save(
+				tx,
+				collection,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// saveCollectionTraits replaces the trait counts of the collection, the
+// values no longer held by any asset are deleted for good so that they can be
+// created again when an asset brings them back
+func (s *NftLend) saveCollectionTraits(tx *gorm.DB, collectionID uint, counts map[string]map[string]uint, total int) error {
+	traits, err := s.ctd.Find(
+		tx,
+		map[string][]interface{}{
+			"collection_id = ?": []interface{}{collectionID},
+		},
+		map[string][]interface{}{},
+		[]string{},
+		0,
+		99999999,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	existing := map[string]*models.CollectionTrait{}
+	for _, trait := range traits {
+		existing[trait.TraitType+"\x00"+trait.Value] = trait
+	}
+	for traitType, values := range counts {
+		for value, count := range values {
+			key := traitType + "\x00" + value
+			trait, ok := existing[key]
+			if !ok {
+				trait = &models.CollectionTrait{
+					CollectionID: collectionID,
+					TraitType:    traitType,
+					Value:        value,
+				}
+			}
+			delete(existing, key)
+			frequency := float64(count) / float64(total)
+			if ok && trait.Count == count && trait.Frequency == frequency {
+				continue
+			}
+			trait.Count = count
+			trait.Frequency = frequency
+			if ok {
+				err = s.ctd.Save(tx, trait)
+			} else {
+				err = s.ctd.Create(tx, trait)
+			}
+			if err != nil {
+				return errs.NewError(err)
+			}
+		}
+	}
+	for _, trait := range existing {
+		err = s.ctd.DeleteUnscoped(tx, trait)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/czConstant/constant-nftylend-api/services"
+)
+
+func TestScoreCollectionRarity(t *testing.T) {
+	for _, c := range []struct {
+		name   string
+		traits map[uint]map[string]string
+		counts map[string]map[string]uint
+		scores map[uint]float64
+		ranks  map[uint]uint
+	}{
+		{
+			name: "missing traits count as None and equal scores share their rank",
+			traits: map[uint]map[string]string{
+				1: {"Background": "Blue", "Hat": "Cap"},
+				2: {"Background": "Blue", "Hat": "Cap"},
+				3: {"Background": "Red"},
+				4: {},
+				5: {"Background": "Red", "Hat": "Crown"},
+			},
+			counts: map[string]map[string]uint{
+				"Background": {"Blue": 2, "Red": 2},
+				"Hat":        {"Cap": 2, "Crown": 1, "None": 1},
+			},
+			scores: map[uint]float64{1: 4, 2: 4, 3: 6, 5: 6},
+			ranks:  map[uint]uint{1: 3, 2: 3, 3: 1, 5: 1},
+		},
+		{
+			name: "an explicit None is counted with the missing traits",
+			traits: map[uint]map[string]string{
+				1: {"Hat": "None"},
+				2: {"Background": "Blue"},
+				3: {"Background": "Blue", "Hat": "Cap"},
+			},
+			counts: map[string]map[string]uint{
+				"Background": {"Blue": 2, "None": 1},
+				"Hat":        {"Cap": 1, "None": 2},
+			},
+			scores: map[uint]float64{1: 4.5, 2: 3, 3: 4.5},
+			ranks:  map[uint]uint{1: 1, 2: 3, 3: 1},
+		},
+		{
+			name: "ranks skip the positions of the tied assets",
+			traits: map[uint]map[string]string{
+				1: {"Eyes": "Laser"},
+				2: {"Eyes": "Sleepy"},
+				3: {"Eyes": "Sleepy"},
+				4: {"Eyes": "Wink"},
+				5: {"Eyes": "Wink"},
+				6: {"Eyes": "Wink"},
+			},
+			counts: map[string]map[string]uint{
+				"Eyes": {"Laser": 1, "Sleepy": 2, "Wink": 3},
+			},
+			scores: map[uint]float64{1: 6, 2: 3, 3: 3, 4: 2, 5: 2, 6: 2},
+			ranks:  map[uint]uint{1: 1, 2: 2, 3: 2, 4: 4, 5: 4, 6: 4},
+		},
+		{
+			name: "assets without traits are unranked",
+			traits: map[uint]map[string]string{
+				1: {},
+				2: {},
+			},
+			counts: map[string]map[string]uint{},
+			scores: map[uint]float64{},
+			ranks:  map[uint]uint{},
+		},
+	} {
+		counts, scores, ranks := services.ScoreCollectionRarity(c.traits)
+		if !reflect.DeepEqual(counts, c.counts) {
+			t.Errorf("%s: counts = %v, want %v", c.name, counts, c.counts)
+		}
+		if !reflect.DeepEqual(scores, c.scores) {
+			t.Errorf("%s: scores = %v, want %v", c.name, scores, c.scores)
+		}
+		if !reflect.DeepEqual(ranks, c.ranks) {
+			t.Errorf("%s: ranks = %v, want %v", c.name, ranks, c.ranks)
+		}
+	}
+}
+
+func (lt *lendTest) setAttributes(asset *models.Asset, traits map[string]string) {
+	lt.t.Helper()
+	attrs := "["
+	for traitType, value := range traits {
+		if attrs != "[" {
+			attrs += ","
+		}
+		attrs += fmt.Sprintf(`{"trait_type":%q,"value":%q}`, traitType, value)
+	}
+	asset.Attributes = attrs + "]"
+	lt.create((&daos.Asset{}).Save, asset)
+}
+
+func (lt *lendTest) markRarityStale() {
+	lt.t.Helper()
+	collection, err := (&daos.Collection{}).FirstByID(lt.db, lt.collection.ID, map[string][]interface{}{}, false)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	collection.RarityStale = true
+	lt.create((&daos.Collection{}).Save, collection)
+}
+
+func TestJobComputeCollectionRarity(t *testing.T) {
+	lt := newLendTest(t)
+	lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	lt.setAttributes(lt.asset, map[string]string{"Background": "Red", "Hat": "Crown"})
+	common := lt.crawlAsset(1)
+	lt.setAttributes(common, map[string]string{"Background": "Blue", "Hat": "Cap"})
+	bare := lt.crawlAsset(2)
+	lt.setAttributes(bare, map[string]string{"Background": "Blue"})
+	lt.crawlAsset(3)
+	lt.markRarityStale()
+	err := lt.s.JobComputeCollectionRarity(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	collection, err := (&daos.Collection{}).FirstByID(lt.db, lt.collection.ID, map[string][]interface{}{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if collection.RarityStale || collection.RarityComputedAt == nil {
+		t.Fatalf("collection stale %v computed at %v, want computed", collection.RarityStale, collection.RarityComputedAt)
+	}
+	assets, err := (&daos.Asset{}).Find(lt.db, map[string][]interface{}{}, map[string][]interface{}{}, []string{"id asc"}, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	type rarity struct {
+		Score float64
+		Rank  uint
+	}
+	got := []rarity{}
+	for _, asset := range assets {
+		got = append(got, rarity{asset.RarityScore, asset.RarityRank})
+	}
+	// the asset without attributes is unranked, the one without hat ties with
+	// the common one
+	want := []rarity{{6, 1}, {4.5, 2}, {4.5, 2}, {0, 0}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("asset rarities = %v, want %v", got, want)
+	}
+	traits, err := (&daos.CollectionTrait{}).Find(lt.db, map[string][]interface{}{}, map[string][]interface{}{}, []string{"trait_type asc", "value asc"}, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gotTraits := []string{}
+	for _, trait := range traits {
+		gotTraits = append(gotTraits, fmt.Sprintf("%s:%s:%d", trait.TraitType, trait.Value, trait.Count))
+	}
+	wantTraits := []string{"Background:Blue:2", "Background:Red:1", "Hat:Cap:1", "Hat:Crown:1", "Hat:None:1"}
+	if !reflect.DeepEqual(gotTraits, wantTraits) {
+		t.Fatalf("traits = %v, want %v", gotTraits, wantTraits)
+	}
+	if loan := lt.loan(loanAccount); loan.AssetRarityScore != 6 {
+		t.Fatalf("loan rarity score = %v, want the asset score 6", loan.AssetRarityScore)
+	}
+}
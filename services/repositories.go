@@ -0,0 +1,284 @@
+package services
+
+import (
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+// repositories the service depends on, implemented by the daos package and by
+// the in-memory fakes in services/fakes
+
+type CurrencyRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.Currency, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.Currency, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.Currency, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.Currency, uint, error)
+}
+
+type CollectionRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.Collection, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.Collection, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.Collection, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.Collection, uint, error)
+	FirstSpec(tx *gorm.DB, spec *daos.Spec, forUpdate bool) (*models.Collection, error)
+	FindSpec(tx *gorm.DB, spec *daos.Spec, offset int, limit int) ([]*models.Collection, error)
+	Find4PageSpec(tx *gorm.DB, spec *daos.Spec, page int, limit int) ([]*models.Collection, uint, error)
+}
+
+type CollectionSubmittedRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.CollectionSubmitted, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.CollectionSubmitted, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.CollectionSubmitted, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.CollectionSubmitted, uint, error)
+}
+
+type AssetRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.Asset, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.Asset, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.Asset, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.Asset, uint, error)
+	FirstSpec(tx *gorm.DB, spec *daos.Spec, forUpdate bool) (*models.Asset, error)
+	FindSpec(tx *gorm.DB, spec *daos.Spec, offset int, limit int) ([]*models.Asset, error)
+	Find4PageSpec(tx *gorm.DB, spec *daos.Spec, page int, limit int) ([]*models.Asset, uint, error)
+	UpdateRarity(tx *gorm.DB, id uint, score float64, rank uint) error
+}
+
+type AssetTransactionRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.AssetTransaction, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.AssetTransaction, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.AssetTransaction, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.AssetTransaction, uint, error)
+	FirstSpec(tx *gorm.DB, spec *daos.Spec, forUpdate bool) (*models.AssetTransaction, error)
+	FindSpec(tx *gorm.DB, spec *daos.Spec, offset int, limit int) ([]*models.AssetTransaction, error)
+	Find4PageSpec(tx *gorm.DB, spec *daos.Spec, page int, limit int) ([]*models.AssetTransaction, uint, error)
+	Find4Cursor(tx *gorm.DB, spec *daos.Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.AssetTransaction, *daos.CursorPage, error)
+	Upsert(tx *gorm.DB, m *models.AssetTransaction) error
+}
+
+type LoanRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.Loan, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.Loan, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.Loan, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.Loan, uint, error)
+	FirstSpec(tx *gorm.DB, spec *daos.Spec, forUpdate bool) (*models.Loan, error)
+	FindSpec(tx *gorm.DB, spec *daos.Spec, offset int, limit int) ([]*models.Loan, error)
+	Find4PageSpec(tx *gorm.DB, spec *daos.Spec, page int, limit int) ([]*models.Loan, uint, error)
+	Find4Cursor(tx *gorm.DB, spec *daos.Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.Loan, *daos.CursorPage, error)
+	GetRPTCollectionStats(tx *gorm.DB, collectionIDs []uint, start time.Time, end time.Time) ([]*models.NftyRPTCollectionStat, error)
+	GetRPTCollectionCurrencyStats(tx *gorm.DB, collectionIDs []uint, start time.Time, end time.Time) ([]*models.NftyRPTCollectionCurrencyStat, error)
+	UpdateAssetRarityScore(tx *gorm.DB, assetID uint, score float64) error
+}
+
+type LoanOfferRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.LoanOffer, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.LoanOffer, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.LoanOffer, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.LoanOffer, uint, error)
+	FirstSpec(tx *gorm.DB, spec *daos.Spec, forUpdate bool) (*models.LoanOffer, error)
+	FindSpec(tx *gorm.DB, spec *daos.Spec, offset int, limit int) ([]*models.LoanOffer, error)
+	Find4PageSpec(tx *gorm.DB, spec *daos.Spec, page int, limit int) ([]*models.LoanOffer, uint, error)
+	Find4Cursor(tx *gorm.DB, spec *daos.Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.LoanOffer, *daos.CursorPage, error)
+}
+
+type LoanTransactionRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.LoanTransaction, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.LoanTransaction, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.LoanTransaction, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.LoanTransaction, uint, error)
+	FirstSpec(tx *gorm.DB, spec *daos.Spec, forUpdate bool) (*models.LoanTransaction, error)
+	FindSpec(tx *gorm.DB, spec *daos.Spec, offset int, limit int) ([]*models.LoanTransaction, error)
+	Find4PageSpec(tx *gorm.DB, spec *daos.Spec, page int, limit int) ([]*models.LoanTransaction, uint, error)
+	Find4Cursor(tx *gorm.DB, spec *daos.Spec, sortColumn string, desc bool, cursor string, limit int, withCount bool) ([]*models.LoanTransaction, *daos.CursorPage, error)
+}
+
+type InstructionRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.Instruction, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.Instruction, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.Instruction, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.Instruction, uint, error)
+}
+
+type SearchIndexRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.SearchIndex, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.SearchIndex, error)
+	DeleteByRef(tx *gorm.DB, typ models.SearchIndexType, refID uint, fields []models.SearchIndexField) error
+	DeleteByRefRange(tx *gorm.DB, typ models.SearchIndexType, fromRefID uint, toRefID uint) error
+	Search(tx *gorm.DB, tokens []string, types []models.SearchIndexType, limit int) ([]*models.SearchResult, error)
+}
+
+type UserRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.User, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.User, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.User, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.User, uint, error)
+}
+
+type UserSessionRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.UserSession, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.UserSession, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.UserSession, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.UserSession, uint, error)
+}
+
+type CollectionSubmittedCommentRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.CollectionSubmittedComment, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.CollectionSubmittedComment, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.CollectionSubmittedComment, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.CollectionSubmittedComment, uint, error)
+}
+
+type ApiKeyRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.ApiKey, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.ApiKey, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.ApiKey, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.ApiKey, uint, error)
+}
+
+type UserRoleRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.UserRole, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.UserRole, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.UserRole, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.UserRole, uint, error)
+}
+
+// AuditLogRepository leaves out Save and Delete, audit logs are append only
+type AuditLogRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.AuditLog, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.AuditLog, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.AuditLog, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.AuditLog, uint, error)
+}
+
+type PartnerRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.Partner, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.Partner, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.Partner, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.Partner, uint, error)
+}
+
+type PartnerUsageRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.PartnerUsage, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.PartnerUsage, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.PartnerUsage, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.PartnerUsage, uint, error)
+	Meter(tx *gorm.DB, partnerID uint, date string, quota uint) (bool, error)
+}
+
+type AssetCrawlRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.AssetCrawl, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.AssetCrawl, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.AssetCrawl, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.AssetCrawl, uint, error)
+}
+
+type CollectionTraitRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.CollectionTrait, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.CollectionTrait, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.CollectionTrait, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.CollectionTrait, uint, error)
+	DeleteUnscoped(tx *gorm.DB, m *models.CollectionTrait) error
+}
+
+type CollectionStatRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.CollectionStat, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.CollectionStat, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.CollectionStat, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.CollectionStat, uint, error)
+}
+
+type UserNonceRepository interface {
+	Create(tx *gorm.DB, m interface{}) error
+	Save(tx *gorm.DB, m interface{}) error
+	Delete(tx *gorm.DB, m interface{}) error
+	FirstByID(tx *gorm.DB, id uint, preloads map[string][]interface{}, forUpdate bool) (*models.UserNonce, error)
+	First(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string) (*models.UserNonce, error)
+	Find(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, offset int, limit int) ([]*models.UserNonce, error)
+	Find4Page(tx *gorm.DB, filters map[string][]interface{}, preloads map[string][]interface{}, orders []string, page int, limit int) ([]*models.UserNonce, uint, error)
+}
+
+var (
+	_ CurrencyRepository                   = (*daos.Currency)(nil)
+	_ CollectionRepository                 = (*daos.Collection)(nil)
+	_ CollectionSubmittedRepository        = (*daos.CollectionSubmitted)(nil)
+	_ AssetRepository                      = (*daos.Asset)(nil)
+	_ AssetTransactionRepository           = (*daos.AssetTransaction)(nil)
+	_ LoanRepository                       = (*daos.Loan)(nil)
+	_ LoanOfferRepository                  = (*daos.LoanOffer)(nil)
+	_ LoanTransactionRepository            = (*daos.LoanTransaction)(nil)
+	_ InstructionRepository                = (*daos.Instruction)(nil)
+	_ SearchIndexRepository                = (*daos.SearchIndex)(nil)
+	_ CollectionSubmittedCommentRepository = (*daos.CollectionSubmittedComment)(nil)
+	_ ApiKeyRepository                     = (*daos.ApiKey)(nil)
+	_ UserRoleRepository                   = (*daos.UserRole)(nil)
+	_ AuditLogRepository                   = (*daos.AuditLog)(nil)
+	_ PartnerRepository                    = (*daos.Partner)(nil)
+	_ PartnerUsageRepository               = (*daos.PartnerUsage)(nil)
+	_ AssetCrawlRepository                 = (*daos.AssetCrawl)(nil)
+	_ CollectionTraitRepository            = (*daos.CollectionTrait)(nil)
+	_ CollectionStatRepository             = (*daos.CollectionStat)(nil)
+	_ UserNonceRepository                  = (*daos.UserNonce)(nil)
+)
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	apiKeyPrefixLen = 8
+)
+
+func validateRole(role models.Role) error {
+	switch role {
+	case models.RoleAdmin, models.RoleReviewer, models.RoleJob, models.RoleIndexer:
+		{
+			return nil
+		}
+	}
+	return errs.NewError(errs.ErrRoleInvalid)
+}
+
+func newApiKey() (string, error) {
+	key, err := randomHex(24)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("nfty_%s", key), nil
+}
+
+// GetApiKey resolves a plain api key, keys are looked up on every call so
+// rotated and revoked keys apply without a restart
+func (s *NftLend) GetApiKey(ctx context.Context, key string) (*models.ApiKey, error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, errs.NewError(errs.ErrTokenInvalid)
+	}
+	m, err := s.akd.First(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"key_hash = ?": []interface{}{hashToken(key)},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if m == nil ||
+		m.RevokedAt != nil ||
+		(m.ExpiredAt != nil && m.ExpiredAt.Before(time.Now())) {
+		return nil, errs.NewError(errs.ErrTokenInvalid)
+	}
+	return m, nil
+}
+
+func (s *NftLend) GetUserRoles(ctx context.Context, network models.Chain, address string) ([]models.Role, error) {
+	ms, err := s.urd.Find(
+		s.conn.DB(ctx),
+		map[string][]interface{}{
+			"network = ?": []interface{}{network},
+			"address = ?": []interface{}{address},
+		},
+		map[string][]interface{}{},
+		[]string{"id asc"},
+		0,
+		99999999,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	roles := []models.Role{}
+	for _, m := range ms {
+		roles = append(roles, m.Role)
+	}
+	return roles, nil
+}
+
+func (s *NftLend) AdminGetApiKeys(ctx context.Context, page int, limit int) ([]*models.ApiKey, uint, error) {
+	ms, count, err := s.akd.Find4Page(
+		s.conn.DB(ctx),
+		map[string][]interface{}{},
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, count, nil
+}
+
+func (s *NftLend) createApiKey(tx *gorm.DB, name string, role models.Role, expiredAt *time.Time) (*models.ApiKey, string, error) {
+	key, err := newApiKey()
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	m := &models.ApiKey{
+		Name:      name,
+		KeyPrefix: key[:len("nfty_")+apiKeyPrefixLen],
+		KeyHash:   hashToken(key),
+		Role:      role,
+		ExpiredAt: expiredAt,
+	}
+	err = s.akd.Create(
+		tx,
+		m,
+	)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	return m, key, nil
+}
+
+// AdminCreateApiKey issues a key for role, the plain key is only returned here
+func (s *NftLend) AdminCreateApiKey(ctx context.Context, name string, role models.Role, expiredAt *time.Time) (*models.ApiKey, string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, "", errs.NewInvalidParamsError("name is required")
+	}
+	err := validateRole(role)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	if expiredAt != nil && expiredAt.Before(time.Now()) {
+		return nil, "", errs.NewInvalidParamsError("expired_at must be in the future")
+	}
+	m, key, err := s.createApiKey(s.conn.DB(ctx), name, role, expiredAt)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	return m, key, nil
+}
+
+// AdminRotateApiKey issues a new key with the same name and role, the old key
+// keeps working for grace so callers can switch over
+func (s *NftLend) AdminRotateApiKey(ctx context.Context, id uint, grace time.Duration) (*models.ApiKey, string, error) {
+	if grace < 0 {
+		return nil, "", errs.NewInvalidParamsError("grace_seconds must not be negative")
+	}
+	var m *models.ApiKey
+	var key string
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			old, err := s.akd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if old == nil || old.RevokedAt != nil {
+				return errs.NewError(errs.ErrApiKeyNotFound)
+			}
+			m, key, err = s.createApiKey(tx, old.Name, old.Role, old.ExpiredAt)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if grace > 0 {
+				old.ExpiredAt = helpers.TimeNowAdd(grace)
+			} else {
+				old.RevokedAt = helpers.TimeNow()
+			}
+			err = s.akd.Save(
+				tx,
+				old,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	return m, key, nil
+}
+
+func (s *NftLend) AdminRevokeApiKey(ctx context.Context, id uint) (*models.ApiKey, error) {
+	var m *models.ApiKey
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			m, err = s.akd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m == nil {
+				return errs.NewError(errs.ErrApiKeyNotFound)
+			}
+			if m.RevokedAt != nil {
+				return nil
+			}
+			m.RevokedAt = helpers.TimeNow()
+			err = s.akd.Save(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
+
+func (s *NftLend) AdminGetUserRoles(ctx context.Context, address string, role models.Role, page int, limit int) ([]*models.UserRole, uint, error) {
+	filters := map[string][]interface{}{}
+	if address != "" {
+		filters["address = ?"] = []interface{}{address}
+	}
+	if role != "" {
+		filters["role = ?"] = []interface{}{role}
+	}
+	ms, count, err := s.urd.Find4Page(
+		s.conn.DB(ctx),
+		filters,
+		map[string][]interface{}{},
+		[]string{"id desc"},
+		page,
+		limit,
+	)
+	if err != nil {
+		return nil, 0, errs.NewError(err)
+	}
+	return ms, count, nil
+}
+
+func (s *NftLend) AdminCreateUserRole(ctx context.Context, network models.Chain, address string, role models.Role) (*models.UserRole, error) {
+	address, err := normalizeAddress(network, address)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	err = validateRole(role)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	var m *models.UserRole
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			m, err = s.urd.First(
+				tx,
+				map[string][]interface{}{
+					"network = ?": []interface{}{network},
+					"address = ?": []interface{}{address},
+					"role = ?":    []interface{}{role},
+				},
+				map[string][]interface{}{},
+				[]string{},
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m != nil {
+				return nil
+			}
+			m = &models.UserRole{
+				Network: network,
+				Address: address,
+				Role:    role,
+			}
+			err = s.urd.Create(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
+
+func (s *NftLend) AdminDeleteUserRole(ctx context.Context, id uint) (*models.UserRole, error) {
+	var m *models.UserRole
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			m, err = s.urd.FirstByID(
+				tx,
+				id,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m == nil {
+				return errs.NewError(errs.ErrUserRoleNotFound)
+			}
+			err = s.urd.Delete(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
@@ -0,0 +1,413 @@
+package services
+
+import (
+	"context"
+	"strings"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	searchKeywordMinLength = 2
+)
+
+func normalizeSearchKeyword(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// searchTokens splits a normalized keyword into the words it is indexed and
+// searched by, dropping the ones too short to match on
+func searchTokens(keyword string) []string {
+	tokens := []string{}
+	for _, token := range strings.FieldsFunc(keyword, func(r rune) bool {
+		return r == ' ' || r == '#' || r == '-' || r == '_' || r == '.'
+	}) {
+		if len(token) >= searchKeywordMinLength {
+			tokens = append(tokens, token)
+		}
+	}
+	return tokens
+}
+
+func (s *NftLend) createSearchIndexes(tx *gorm.DB, typ models.SearchIndexType, refID uint, field models.SearchIndexField, title string, value string, weight float64, tokenize bool) error {
+	keyword := normalizeSearchKeyword(value)
+	if len(keyword) < searchKeywordMinLength {
+		return nil
+	}
+	keywords := []string{keyword}
+	if tokenize {
+		for _, token := range searchTokens(keyword) {
+			if token != keyword {
+				keywords = append(keywords, token)
+			}
+		}
+	}
+	for i, k := range keywords {
+		w := weight
+		if i > 0 {
+			w = weight / 2
+		}
+		err := s.sid.Create(
+			tx,
+			&models.SearchIndex{
+				Type:    typ,
+				RefID:   refID,
+				Field:   field,
+				Keyword: k,
+				Title:   title,
+				Weight:  w,
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+func (s *NftLend) indexSearchCollection(tx *gorm.DB, collection *models.Collection) error {
+	err := s.sid.DeleteByRef(tx, models.SearchIndexTypeCollection, collection.ID, nil)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	err = s.createSearchIndexes(tx, models.SearchIndexTypeCollection, collection.ID, models.SearchIndexFieldName, collection.Name, collection.Name, 3, true)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	err = s.createSearchIndexes(tx, models.SearchIndexTypeCollection, collection.ID, models.SearchIndexFieldContractAddress, collection.Name, collection.OriginContractAddress, 2, false)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+func (s *NftLend) indexSearchAsset(tx *gorm.DB, asset *models.Asset) error {
+	err := s.sid.DeleteByRef(tx, models.SearchIndexTypeAsset, asset.ID, nil)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	err = s.createSearchIndexes(tx, models.SearchIndexTypeAsset, asset.ID, models.SearchIndexFieldName, asset.Name, asset.Name, 2, true)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	err = s.createSearchIndexes(tx, models.SearchIndexTypeAsset, asset.ID, models.SearchIndexFieldSymbol, asset.Name, asset.Symbol, 1.5, false)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	err = s.createSearchIndexes(tx, models.SearchIndexTypeAsset, asset.ID, models.SearchIndexFieldContractAddress, asset.Name, asset.ContractAddress, 2, false)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// indexSearchLoan rebuilds the tx hash keywords of the loan from its
+// transactions, so a reprocessed instruction does not duplicate them
+func (s *NftLend) indexSearchLoan(tx *gorm.DB, loan *models.Loan) error {
+	err := s.sid.DeleteByRef(tx, models.SearchIndexTypeLoan, loan.ID, []models.SearchIndexField{models.SearchIndexFieldTxHash})
+	if err != nil {
+		return errs.NewError(err)
+	}
+	txns, err := s.ltd.Find(
+		tx,
+		map[string][]interface{}{
+			"loan_id = ?": []interface{}{loan.ID},
+		},
+		map[string][]interface{}{},
+		[]string{"id asc"},
+		0,
+		99999999,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	txHashes := map[string]bool{}
+	for _, txn := range txns {
+		if txHashes[txn.TxHash] {
+			continue
+		}
+		txHashes[txn.TxHash] = true
+		err = s.createSearchIndexes(tx, models.SearchIndexTypeLoan, loan.ID, models.SearchIndexFieldTxHash, loan.DataLoanAddress, txn.TxHash, 1, false)
+		if err != nil {
+			return errs.NewError(err)
+		}
+	}
+	return nil
+}
+
+func (s *NftLend) parseSearchTypes(types []string) ([]models.SearchIndexType, error) {
+	if len(types) == 0 {
+		return []models.SearchIndexType{
+			models.SearchIndexTypeCollection,
+			models.SearchIndexTypeAsset,
+			models.SearchIndexTypeLoan,
+		}, nil
+	}
+	rets := []models.SearchIndexType{}
+	for _, t := range types {
+		switch models.SearchIndexType(t) {
+		case models.SearchIndexTypeCollection,
+			models.SearchIndexTypeAsset,
+			models.SearchIndexTypeLoan:
+			{
+				rets = append(rets, models.SearchIndexType(t))
+			}
+		default:
+			{
+				return nil, errs.NewError(errs.ErrBadRequest)
+			}
+		}
+	}
+	return rets, nil
+}
+
+func (s *NftLend) Search(ctx context.Context, keyword string, types []string, limit int) ([]*models.SearchResult, error) {
+	keyword = normalizeSearchKeyword(keyword)
+	if len(keyword) < searchKeywordMinLength {
+		return []*models.SearchResult{}, nil
+	}
+	searchTypes, err := s.parseSearchTypes(types)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	rs, err := s.sid.Search(
+		s.conn.DB(ctx),
+		searchTokens(keyword),
+		searchTypes,
+		limit,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	idsByType := map[models.SearchIndexType][]uint{}
+	for _, r := range rs {
+		idsByType[r.Type] = append(idsByType[r.Type], r.RefID)
+	}
+	mapCollections := map[uint]*models.Collection{}
+	if ids := idsByType[models.SearchIndexTypeCollection]; len(ids) > 0 {
+		filter := &daos.CollectionFilter{
+			IDs: ids,
+		}
+		collections, err := s.cld.FindSpec(
+			s.conn.DB(ctx),
+			filter.Spec(),
+			0,
+			len(ids),
+		)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		for _, m := range collections {
+			mapCollections[m.ID] = m
+		}
+	}
+	mapAssets := map[uint]*models.Asset{}
+	if ids := idsByType[models.SearchIndexTypeAsset]; len(ids) > 0 {
+		filter := &daos.AssetFilter{
+			IDs: ids,
+		}
+		assets, err := s.ad.FindSpec(
+			s.conn.DB(ctx),
+			filter.Spec().
+				Preload("Collection").
+				Preload("NewLoan", "status = ?", models.LoanStatusNew).
+				Preload("NewLoan.Currency"),
+			0,
+			len(ids),
+		)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		for _, m := range assets {
+			mapAssets[m.ID] = m
+		}
+	}
+	mapLoans := map[uint]*models.Loan{}
+	if ids := idsByType[models.SearchIndexTypeLoan]; len(ids) > 0 {
+		filter := &daos.LoanFilter{
+			IDs: ids,
+		}
+		loans, err := s.ld.FindSpec(
+			s.conn.DB(ctx),
+			withLoanPreloads(filter.Spec()),
+			0,
+			len(ids),
+		)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+		for _, m := range loans {
+			mapLoans[m.ID] = m
+		}
+	}
+	rets := []*models.SearchResult{}
+	for _, r := range rs {
+		switch r.Type {
+		case models.SearchIndexTypeCollection:
+			{
+				r.Collection = mapCollections[r.RefID]
+				if r.Collection == nil {
+					continue
+				}
+			}
+		case models.SearchIndexTypeAsset:
+			{
+				r.Asset = mapAssets[r.RefID]
+				if r.Asset == nil {
+					continue
+				}
+			}
+		case models.SearchIndexTypeLoan:
+			{
+				r.Loan = mapLoans[r.RefID]
+				if r.Loan == nil {
+					continue
+				}
+			}
+		}
+		rets = append(rets, r)
+	}
+	return rets, nil
+}
+
+func (s *NftLend) SearchAutocomplete(ctx context.Context, keyword string, limit int) ([]*models.SearchResult, error) {
+	keyword = normalizeSearchKeyword(keyword)
+	if len(keyword) < searchKeywordMinLength {
+		return []*models.SearchResult{}, nil
+	}
+	rs, err := s.sid.Search(
+		s.conn.DB(ctx),
+		searchTokens(keyword),
+		[]models.SearchIndexType{
+			models.SearchIndexTypeCollection,
+			models.SearchIndexTypeAsset,
+		},
+		limit,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return rs, nil
+}
+
+func (s *NftLend) JobSearchReindex(ctx context.Context) error {
+	var lastID uint
+	for {
+		collections, err := s.cld.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"id > ?": []interface{}{lastID},
+			},
+			map[string][]interface{}{},
+			[]string{"id asc"},
+			0,
+			500,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		if len(collections) == 0 {
+			break
+		}
+		err = s.conn.WithTransaction(
+			ctx,
+			func(tx *gorm.DB) error {
+				for _, collection := range collections {
+					err := s.indexSearchCollection(tx, collection)
+					if err != nil {
+						return errs.NewError(err)
+					}
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		lastID = collections[len(collections)-1].ID
+	}
+	lastID = 0
+	for {
+		assets, err := s.ad.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"id > ?": []interface{}{lastID},
+			},
+			map[string][]interface{}{},
+			[]string{"id asc"},
+			0,
+			500,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		if len(assets) == 0 {
+			break
+		}
+		err = s.conn.WithTransaction(
+			ctx,
+			func(tx *gorm.DB) error {
+				for _, asset := range assets {
+					err := s.indexSearchAsset(tx, asset)
+					if err != nil {
+						return errs.NewError(err)
+					}
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		lastID = assets[len(assets)-1].ID
+	}
+	// the loan rows are rebuilt by id range so that the rows of deleted loans
+	// go too, each range is swapped in one transaction
+	lastID = 0
+	for {
+		loans, err := s.ld.Find(
+			s.conn.DB(ctx),
+			map[string][]interface{}{
+				"id > ?": []interface{}{lastID},
+			},
+			map[string][]interface{}{},
+			[]string{"id asc"},
+			0,
+			500,
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		var toID uint
+		if len(loans) > 0 {
+			toID = loans[len(loans)-1].ID
+		}
+		err = s.conn.WithTransaction(
+			ctx,
+			func(tx *gorm.DB) error {
+				err := s.sid.DeleteByRefRange(tx, models.SearchIndexTypeLoan, lastID, toID)
+				if err != nil {
+					return errs.NewError(err)
+				}
+				for _, loan := range loans {
+					err := s.indexSearchLoan(tx, loan)
+					if err != nil {
+						return errs.NewError(err)
+					}
+				}
+				return nil
+			},
+		)
+		if err != nil {
+			return errs.NewError(err)
+		}
+		if len(loans) == 0 {
+			break
+		}
+		lastID = toID
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package services_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/czConstant/constant-nftylend-api/daos"
+	"github.com/czConstant/constant-nftylend-api/models"
+)
+
+// searchTest adds a disabled collection with an asset named like the enabled
+// one, then reindexes
+type searchTest struct {
+	*lendTest
+	disabled      *models.Collection
+	disabledAsset *models.Asset
+}
+
+func newSearchTest(t *testing.T) *searchTest {
+	lt := &searchTest{lendTest: newLendTest(t)}
+	lt.disabled = &models.Collection{
+		Network: models.ChainSOL,
+		SeoURL:  "degen-ape-copies",
+		Name:    "Degen Ape Copies",
+		Enabled: false,
+	}
+	lt.create((&daos.Collection{}).Create, lt.disabled)
+	lt.disabledAsset = &models.Asset{
+		Network:         models.ChainSOL,
+		CollectionID:    lt.disabled.ID,
+		SeoURL:          "degen-ape-copy-1024",
+		ContractAddress: "CopyMint",
+		Name:            "Degen Ape Copy #1024",
+	}
+	lt.create((&daos.Asset{}).Create, lt.disabledAsset)
+	lt.reindex()
+	return lt
+}
+
+func (lt *searchTest) reindex() {
+	lt.t.Helper()
+	err := lt.s.JobSearchReindex(context.Background())
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+}
+
+type searchHit struct {
+	Type  models.SearchIndexType
+	RefID uint
+}
+
+func (lt *searchTest) search(keyword string, types ...string) []searchHit {
+	lt.t.Helper()
+	rs, err := lt.s.Search(context.Background(), keyword, types, 10)
+	if err != nil {
+		lt.t.Fatal(err)
+	}
+	hits := []searchHit{}
+	for _, r := range rs {
+		hits = append(hits, searchHit{r.Type, r.RefID})
+	}
+	return hits
+}
+
+func TestSearchMatchesEveryToken(t *testing.T) {
+	lt := newSearchTest(t)
+	collection := searchHit{models.SearchIndexTypeCollection, lt.collection.ID}
+	asset := searchHit{models.SearchIndexTypeAsset, lt.asset.ID}
+	for _, c := range []struct {
+		keyword string
+		want    []searchHit
+	}{
+		{"degen", []searchHit{collection, asset}},
+		{"ape degen", []searchHit{collection, asset}},
+		{"deg ac", []searchHit{collection}},
+		{"ape #1024", []searchHit{asset}},
+		{"degen monkey", []searchHit{}},
+		{"academy", []searchHit{collection}},
+	} {
+		hits := lt.search(c.keyword)
+		if !reflect.DeepEqual(hits, c.want) {
+			t.Errorf("search %q = %v, want %v", c.keyword, hits, c.want)
+		}
+	}
+}
+
+func TestSearchSkipsDisabledCollections(t *testing.T) {
+	lt := newSearchTest(t)
+	for _, keyword := range []string{"copies", "copy", "copymint"} {
+		hits := lt.search(keyword)
+		if len(hits) != 0 {
+			t.Errorf("search %q = %v, want nothing from the disabled collection", keyword, hits)
+		}
+	}
+	lt.disabled.Enabled = true
+	lt.create((&daos.Collection{}).Save, lt.disabled)
+	hits := lt.search("copies")
+	want := []searchHit{{models.SearchIndexTypeCollection, lt.disabled.ID}}
+	if !reflect.DeepEqual(hits, want) {
+		t.Fatalf("search copies after enabling = %v, want %v", hits, want)
+	}
+}
+
+func TestSearchReindexDropsDeletedLoans(t *testing.T) {
+	lt := newSearchTest(t)
+	txHash := lt.mustHook("InitLoan", initLoanData(assetMint, loanAccount))
+	loan := lt.loan(loanAccount)
+	want := []searchHit{{models.SearchIndexTypeLoan, loan.ID}}
+	if hits := lt.search(txHash, "loan"); !reflect.DeepEqual(hits, want) {
+		t.Fatalf("search %s = %v, want %v", txHash, hits, want)
+	}
+	lt.create((&daos.Loan{}).Delete, loan)
+	lt.reindex()
+	rows, err := (&daos.SearchIndex{}).Find(lt.db, map[string][]interface{}{"type = ?": []interface{}{models.SearchIndexTypeLoan}}, map[string][]interface{}{}, []string{}, 0, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 0 {
+		t.Fatalf("loan search rows after the reindex = %+v, want none", rows)
+	}
+}
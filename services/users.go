@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/czConstant/constant-nftylend-api/errs"
+	"github.com/czConstant/constant-nftylend-api/helpers"
+	"github.com/czConstant/constant-nftylend-api/models"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	userNonceTTL   = 10 * time.Minute
+	userSessionTTL = 30 * 24 * time.Hour
+	userMeLimit    = 20
+)
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func hashToken(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// normalizeAddress validates the wallet or mint address format of the network,
+// evm addresses are compared lower case
+func normalizeAddress(network models.Chain, address string) (string, error) {
+	address = strings.TrimSpace(address)
+	switch network {
+	case models.ChainSOL:
+		{
+			pub, err := helpers.Base58Decode(address)
+			if err != nil || len(pub) != 32 {
+				return "", errs.NewError(errs.ErrAddressInvalid)
+			}
+			return address, nil
+		}
+	case models.ChainETH, models.ChainMATIC:
+		{
+			if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+				return "", errs.NewError(errs.ErrAddressInvalid)
+			}
+			_, err := hex.DecodeString(address[2:])
+			if err != nil {
+				return "", errs.NewError(errs.ErrAddressInvalid)
+			}
+			return strings.ToLower(address), nil
+		}
+	}
+	return "", errs.NewError(errs.ErrNetworkInvalid)
+}
+
+func UserSignInMessage(m *models.UserNonce) string {
+	return fmt.Sprintf("Sign in to NFTy Lend\n\nWallet: %s\nNonce: %s", m.Address, m.Nonce)
+}
+
+func verifyUserSignature(m *models.UserNonce, signature string) (bool, error) {
+	message := UserSignInMessage(m)
+	switch m.Network {
+	case models.ChainSOL:
+		{
+			return helpers.VerifySolanaSignature(m.Address, message, signature)
+		}
+	case models.ChainETH, models.ChainMATIC:
+		{
+			return helpers.VerifyEthereumSignature(m.Address, message, signature)
+		}
+	}
+	return false, errs.NewError(errs.ErrNetworkInvalid)
+}
+
+func (s *NftLend) getUser(tx *gorm.DB, network models.Chain, address string, forUpdate bool) (*models.User, error) {
+	user, err := s.ud.First(
+		tx,
+		map[string][]interface{}{
+			"network = ?": []interface{}{network},
+			"address = ?": []interface{}{address},
+		},
+		map[string][]interface{}{},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if user != nil && forUpdate {
+		user, err = s.ud.FirstByID(
+			tx,
+			user.ID,
+			map[string][]interface{}{},
+			true,
+		)
+		if err != nil {
+			return nil, errs.NewError(err)
+		}
+	}
+	return user, nil
+}
+
+// GetUserNonce issues a new sign in challenge for the wallet, the user is only
+// created once a challenge is signed
+func (s *NftLend) GetUserNonce(ctx context.Context, network models.Chain, address string) (*models.UserNonce, error) {
+	address, err := normalizeAddress(network, address)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	m := &models.UserNonce{
+		Network:   network,
+		Address:   address,
+		Nonce:     nonce,
+		ExpiredAt: helpers.TimeNowAdd(userNonceTTL),
+	}
+	err = s.und.Create(
+		s.conn.DB(ctx),
+		m,
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return m, nil
+}
+
+// UserSignIn verifies the signed challenge and returns a new session token, the
+// nonce is consumed either way
+func (s *NftLend) UserSignIn(ctx context.Context, network models.Chain, address string, nonce string, signature string) (*models.User, string, error) {
+	address, err := normalizeAddress(network, address)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	var user *models.User
+	var token string
+	var verified bool
+	err = s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			m, err := s.und.First(
+				tx,
+				map[string][]interface{}{
+					"nonce = ?": []interface{}{nonce},
+				},
+				map[string][]interface{}{},
+				[]string{},
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if m != nil {
+				m, err = s.und.FirstByID(
+					tx,
+					m.ID,
+					map[string][]interface{}{},
+					true,
+				)
+				if err != nil {
+					return errs.NewError(err)
+				}
+			}
+			if m == nil ||
+				m.UsedAt != nil ||
+				m.Network != network ||
+				m.Address != address {
+				return errs.NewError(errs.ErrSignatureInvalid)
+			}
+			if m.ExpiredAt == nil || m.ExpiredAt.Before(time.Now()) {
+				return errs.NewError(errs.ErrNonceExpired)
+			}
+			verified, err = verifyUserSignature(m, signature)
+			if err != nil {
+				verified = false
+			}
+			m.UsedAt = helpers.TimeNow()
+			err = s.und.Save(
+				tx,
+				m,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if !verified {
+				return nil
+			}
+			user, err = s.getUser(tx, network, address, true)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if user == nil {
+				user = &models.User{
+					Network: network,
+					Address: address,
+				}
+			}
+			user.SignedInAt = helpers.TimeNow()
+			err = s.ud.Save(
+				tx,
+				user,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			token, err = randomHex(32)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			err = s.usd.Create(
+				tx,
+				&models.UserSession{
+					UserID:    user.ID,
+					TokenHash: hashToken(token),
+					ExpiredAt: helpers.TimeNowAdd(userSessionTTL),
+				},
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, "", errs.NewError(err)
+	}
+	if !verified {
+		return nil, "", errs.NewError(errs.ErrSignatureInvalid)
+	}
+	return user, token, nil
+}
+
+func (s *NftLend) getUserSession(tx *gorm.DB, token string) (*models.UserSession, error) {
+	session, err := s.usd.First(
+		tx,
+		map[string][]interface{}{
+			"token_hash = ?": []interface{}{hashToken(token)},
+		},
+		map[string][]interface{}{
+			"User": []interface{}{},
+		},
+		[]string{},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	if session == nil ||
+		session.User == nil ||
+		session.RevokedAt != nil ||
+		session.ExpiredAt == nil ||
+		session.ExpiredAt.Before(time.Now()) {
+		return nil, errs.NewError(errs.ErrSessionInvalid)
+	}
+	return session, nil
+}
+
+func (s *NftLend) GetUserBySessionToken(ctx context.Context, token string) (*models.User, error) {
+	session, err := s.getUserSession(s.conn.DB(ctx), token)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return session.User, nil
+}
+
+func (s *NftLend) UserSignOut(ctx context.Context, token string) error {
+	session, err := s.getUserSession(s.conn.DB(ctx), token)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	session.RevokedAt = helpers.TimeNow()
+	err = s.usd.Save(
+		s.conn.DB(ctx),
+		session,
+	)
+	if err != nil {
+		return errs.NewError(err)
+	}
+	return nil
+}
+
+// GetUserMe returns the user with the latest loans it listed and offers it made
+func (s *NftLend) GetUserMe(ctx context.Context, userID uint) (*models.User, []*models.Loan, []*models.LoanOffer, error) {
+	user, err := s.ud.FirstByID(
+		s.conn.DB(ctx),
+		userID,
+		map[string][]interface{}{},
+		false,
+	)
+	if err != nil {
+		return nil, nil, nil, errs.NewError(err)
+	}
+	if user == nil {
+		return nil, nil, nil, errs.NewError(errs.ErrUserNotFound)
+	}
+	loans, _, err := s.GetLoans(ctx, user.Address, "", 0, nil, 1, userMeLimit)
+	if err != nil {
+		return nil, nil, nil, errs.NewError(err)
+	}
+	offers, _, err := s.GetLoanOffers(ctx, "", user.Address, nil, 1, userMeLimit)
+	if err != nil {
+		return nil, nil, nil, errs.NewError(err)
+	}
+	return user, loans, offers, nil
+}
+
+func (s *NftLend) UpdateUserSettings(ctx context.Context, userID uint, email *string, newsNotiEnabled *bool, loanNotiEnabled *bool) (*models.User, error) {
+	var user *models.User
+	err := s.conn.WithTransaction(
+		ctx,
+		func(tx *gorm.DB) error {
+			var err error
+			user, err = s.ud.FirstByID(
+				tx,
+				userID,
+				map[string][]interface{}{},
+				true,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			if user == nil {
+				return errs.NewError(errs.ErrUserNotFound)
+			}
+			if email != nil {
+				user.Email = strings.TrimSpace(*email)
+			}
+			if newsNotiEnabled != nil {
+				user.NewsNotiEnabled = *newsNotiEnabled
+			}
+			if loanNotiEnabled != nil {
+				user.LoanNotiEnabled = *loanNotiEnabled
+			}
+			err = s.ud.Save(
+				tx,
+				user,
+			)
+			if err != nil {
+				return errs.NewError(err)
+			}
+			return nil
+		},
+	)
+	if err != nil {
+		return nil, errs.NewError(err)
+	}
+	return user, nil
+}
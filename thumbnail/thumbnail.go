@@ -0,0 +1,174 @@
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	jpegQuality = 85
+	// maxPixels bounds the memory of a decode, about 100MB for rgba
+	maxPixels = 25000000
+)
+
+// ErrUnsupported is returned for the media the standard library can not
+// decode, webp, svg and videos are served as they are
+var ErrUnsupported = errors.New("thumbnail: unsupported format")
+
+// ErrTooLarge is returned for the images above the pixel budget, the header
+// is read before the decode so that a small file can not claim huge bounds
+var ErrTooLarge = errors.New("thumbnail: image too large")
+
+// Make scales the image down to fit a maxSize square, smaller images keep
+// their size. Opaque images are encoded as jpeg, the others as png
+func Make(data []byte, maxSize int) ([]byte, string, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, "", ErrUnsupported
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, "", ErrUnsupported
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return nil, "", ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err == image.ErrFormat {
+		return nil, "", ErrUnsupported
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	dst := resize(src, maxSize)
+	var buf bytes.Buffer
+	if isOpaque(dst) {
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality})
+		if err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+	err = png.Encode(&buf, dst)
+	if err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+func isOpaque(img image.Image) bool {
+	if o, ok := img.(interface{ Opaque() bool }); ok {
+		return o.Opaque()
+	}
+	return false
+}
+
+// resize averages the source pixels covered by every destination pixel, a
+// box filter is enough for the downscaling of thumbnails. The source is read
+// one row at a time so that only the destination is allocated
+func resize(src image.Image, maxSize int) *image.NRGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := sw, sh
+	if sw > maxSize || sh > maxSize {
+		if sw >= sh {
+			dw = maxSize
+			dh = sh * maxSize / sw
+		} else {
+			dh = maxSize
+			dw = sw * maxSize / sh
+		}
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+	xs := make([]int, dw+1)
+	for x := 0; x <= dw; x++ {
+		xs[x] = x * sw / dw
+	}
+	row := make([]uint8, sw*4)
+	acc := make([]uint64, dw*4)
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0 := y * sh / dh
+		y1 := (y + 1) * sh / dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for i := range acc {
+			acc[i] = 0
+		}
+		for sy := y0; sy < y1; sy++ {
+			readRow(src, b.Min.Y+sy, row)
+			for x := 0; x < dw; x++ {
+				x0, x1 := xs[x], xs[x+1]
+				if x1 <= x0 {
+					x1 = x0 + 1
+				}
+				a := acc[x*4 : x*4+4]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					pa := uint64(p[3])
+					a[0] += uint64(p[0]) * pa
+					a[1] += uint64(p[1]) * pa
+					a[2] += uint64(p[2]) * pa
+					a[3] += pa
+				}
+			}
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := xs[x], xs[x+1]
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+			n := uint64((x1 - x0) * (y1 - y0))
+			a := acc[x*4 : x*4+4]
+			c := color.NRGBA{A: uint8(a[3] / n)}
+			if a[3] > 0 {
+				c.R = uint8(a[0] / a[3])
+				c.G = uint8(a[1] / a[3])
+				c.B = uint8(a[2] / a[3])
+			}
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+	return dst
+}
+
+// readRow writes the row y of the source into row as non premultiplied
+// rgba, the decoders' own types skip the color conversion of At
+func readRow(src image.Image, y int, row []uint8) {
+	b := src.Bounds()
+	switch img := src.(type) {
+	case *image.NRGBA:
+		{
+			i := img.PixOffset(b.Min.X, y)
+			copy(row, img.Pix[i:i+b.Dx()*4])
+		}
+	case *image.YCbCr:
+		{
+			for x := 0; x < b.Dx(); x++ {
+				c := img.YCbCrAt(b.Min.X+x, y)
+				row[x*4], row[x*4+1], row[x*4+2] = color.YCbCrToRGB(c.Y, c.Cb, c.Cr)
+				row[x*4+3] = 0xff
+			}
+		}
+	default:
+		{
+			for x := 0; x < b.Dx(); x++ {
+				c := color.NRGBAModel.Convert(src.At(b.Min.X+x, y)).(color.NRGBA)
+				row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = c.R, c.G, c.B, c.A
+			}
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"strconv"
 	"strings"
 
 	"github.com/shopspring/decimal"
@@ -48,11 +49,10 @@ func (n *Decimal) Scan(src interface{}) error {
 		n = nil
 		return nil
 	}
-	b, ok := src.([]byte)
-	if !ok {
-		return errors.New("invalid data type")
+	s, err := scanText(src)
+	if err != nil {
+		return err
 	}
-	s := string(b)
 	if s == "" {
 		n = nil
 		return nil
@@ -110,11 +110,10 @@ func (n *BigFloat) Scan(src interface{}) error {
 		n = nil
 		return nil
 	}
-	b, ok := src.([]byte)
-	if !ok {
-		return errors.New("invalid data type")
+	s, err := scanText(src)
+	if err != nil {
+		return err
 	}
-	s := string(b)
 	if s == "" {
 		n = nil
 		return nil
@@ -173,11 +172,10 @@ func (n *BigInt) Scan(src interface{}) error {
 		n = nil
 		return nil
 	}
-	b, ok := src.([]byte)
-	if !ok {
-		return errors.New("invalid data type")
+	s, err := scanText(src)
+	if err != nil {
+		return err
 	}
-	s := string(b)
 	if s == "" {
 		n = nil
 		return nil
@@ -195,3 +193,27 @@ func (n *BigInt) Scan(src interface{}) error {
 func (n BigInt) Value() (driver.Value, error) {
 	return n.String(), nil
 }
+
+// scanText reads a numeric column as text, the driver hands decimals over as
+// bytes but parses the integer and float columns of the text protocol
+func scanText(src interface{}) (string, error) {
+	switch v := src.(type) {
+	case []byte:
+		{
+			return string(v), nil
+		}
+	case string:
+		{
+			return v, nil
+		}
+	case int64:
+		{
+			return strconv.FormatInt(v, 10), nil
+		}
+	case float64:
+		{
+			return strconv.FormatFloat(v, 'f', -1, 64), nil
+		}
+	}
+	return "", errors.New("invalid data type")
+}